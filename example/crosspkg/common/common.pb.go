@@ -0,0 +1,158 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.26.0
+// 	protoc        v3.15.6
+// source: crosspkg/common/common.proto
+
+package common
+
+import (
+	_ "github.com/bakins/protoc-gen-twirp-go/twirpgo"
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// A Color is used by services in other packages.
+type Color struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Name string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+}
+
+func (x *Color) Reset() {
+	*x = Color{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_crosspkg_common_common_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Color) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Color) ProtoMessage() {}
+
+func (x *Color) ProtoReflect() protoreflect.Message {
+	mi := &file_crosspkg_common_common_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Color.ProtoReflect.Descriptor instead.
+func (*Color) Descriptor() ([]byte, []int) {
+	return file_crosspkg_common_common_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Color) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+var File_crosspkg_common_common_proto protoreflect.FileDescriptor
+
+var file_crosspkg_common_common_proto_rawDesc = []byte{
+	0x0a, 0x1c, 0x63, 0x72, 0x6f, 0x73, 0x73, 0x70, 0x6b, 0x67, 0x2f, 0x63, 0x6f, 0x6d, 0x6d, 0x6f,
+	0x6e, 0x2f, 0x63, 0x6f, 0x6d, 0x6d, 0x6f, 0x6e, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x1b,
+	0x74, 0x77, 0x69, 0x74, 0x63, 0x68, 0x2e, 0x74, 0x77, 0x69, 0x72, 0x70, 0x2e, 0x65, 0x78, 0x61,
+	0x6d, 0x70, 0x6c, 0x65, 0x2e, 0x63, 0x6f, 0x6d, 0x6d, 0x6f, 0x6e, 0x1a, 0x15, 0x74, 0x77, 0x69,
+	0x72, 0x70, 0x67, 0x6f, 0x2f, 0x6f, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x2e, 0x70, 0x72, 0x6f,
+	0x74, 0x6f, 0x22, 0x1b, 0x0a, 0x05, 0x43, 0x6f, 0x6c, 0x6f, 0x72, 0x12, 0x12, 0x0a, 0x04, 0x6e,
+	0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x32,
+	0x65, 0x0a, 0x06, 0x43, 0x6f, 0x6c, 0x6f, 0x72, 0x73, 0x12, 0x4d, 0x0a, 0x03, 0x4d, 0x69, 0x78,
+	0x12, 0x22, 0x2e, 0x74, 0x77, 0x69, 0x74, 0x63, 0x68, 0x2e, 0x74, 0x77, 0x69, 0x72, 0x70, 0x2e,
+	0x65, 0x78, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x2e, 0x63, 0x6f, 0x6d, 0x6d, 0x6f, 0x6e, 0x2e, 0x43,
+	0x6f, 0x6c, 0x6f, 0x72, 0x1a, 0x22, 0x2e, 0x74, 0x77, 0x69, 0x74, 0x63, 0x68, 0x2e, 0x74, 0x77,
+	0x69, 0x72, 0x70, 0x2e, 0x65, 0x78, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x2e, 0x63, 0x6f, 0x6d, 0x6d,
+	0x6f, 0x6e, 0x2e, 0x43, 0x6f, 0x6c, 0x6f, 0x72, 0x1a, 0x0c, 0xf2, 0xe0, 0x18, 0x02, 0x76, 0x31,
+	0xf2, 0xe0, 0x18, 0x02, 0x76, 0x32, 0x42, 0x3f, 0x5a, 0x3d, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62,
+	0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x62, 0x61, 0x6b, 0x69, 0x6e, 0x73, 0x2f, 0x70, 0x72, 0x6f, 0x74,
+	0x6f, 0x63, 0x2d, 0x67, 0x65, 0x6e, 0x2d, 0x74, 0x77, 0x69, 0x72, 0x70, 0x2d, 0x67, 0x6f, 0x2f,
+	0x65, 0x78, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x2f, 0x63, 0x72, 0x6f, 0x73, 0x73, 0x70, 0x6b, 0x67,
+	0x2f, 0x63, 0x6f, 0x6d, 0x6d, 0x6f, 0x6e, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_crosspkg_common_common_proto_rawDescOnce sync.Once
+	file_crosspkg_common_common_proto_rawDescData = file_crosspkg_common_common_proto_rawDesc
+)
+
+func file_crosspkg_common_common_proto_rawDescGZIP() []byte {
+	file_crosspkg_common_common_proto_rawDescOnce.Do(func() {
+		file_crosspkg_common_common_proto_rawDescData = protoimpl.X.CompressGZIP(file_crosspkg_common_common_proto_rawDescData)
+	})
+	return file_crosspkg_common_common_proto_rawDescData
+}
+
+var file_crosspkg_common_common_proto_msgTypes = make([]protoimpl.MessageInfo, 1)
+var file_crosspkg_common_common_proto_goTypes = []interface{}{
+	(*Color)(nil), // 0: twitch.twirp.example.common.Color
+}
+var file_crosspkg_common_common_proto_depIdxs = []int32{
+	0, // 0: twitch.twirp.example.common.Colors.Mix:input_type -> twitch.twirp.example.common.Color
+	0, // 1: twitch.twirp.example.common.Colors.Mix:output_type -> twitch.twirp.example.common.Color
+	1, // [1:2] is the sub-list for method output_type
+	0, // [0:1] is the sub-list for method input_type
+	0, // [0:0] is the sub-list for extension type_name
+	0, // [0:0] is the sub-list for extension extendee
+	0, // [0:0] is the sub-list for field type_name
+}
+
+func init() { file_crosspkg_common_common_proto_init() }
+func file_crosspkg_common_common_proto_init() {
+	if File_crosspkg_common_common_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_crosspkg_common_common_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Color); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_crosspkg_common_common_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   1,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_crosspkg_common_common_proto_goTypes,
+		DependencyIndexes: file_crosspkg_common_common_proto_depIdxs,
+		MessageInfos:      file_crosspkg_common_common_proto_msgTypes,
+	}.Build()
+	File_crosspkg_common_common_proto = out.File
+	file_crosspkg_common_common_proto_rawDesc = nil
+	file_crosspkg_common_common_proto_goTypes = nil
+	file_crosspkg_common_common_proto_depIdxs = nil
+}
@@ -0,0 +1,93 @@
+package common
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type versionedColors struct{}
+
+func (versionedColors) Mix(ctx context.Context, color *Color) (*Color, error) {
+	version, _ := TwirpVersion(ctx)
+	if version == "v2" {
+		return &Color{Name: "pale " + color.Name}, nil
+	}
+	return &Color{Name: "light " + color.Name}, nil
+}
+
+func TestVersions(t *testing.T) {
+	s := NewColorsTwirpServer(versionedColors{})
+	require.Equal(t, []string{"/twirp/v1/twitch.twirp.example.common.Colors/", "/twirp/v2/twitch.twirp.example.common.Colors/"}, s.PathPrefixes())
+	require.Equal(t, "/twirp/v1/twitch.twirp.example.common.Colors/", s.PathPrefix())
+
+	svr := httptest.NewServer(NewTwirpCombinedHandler(s))
+	defer svr.Close()
+
+	tests := map[string]string{
+		"":   "light red",
+		"v1": "light red",
+		"v2": "pale red",
+	}
+
+	for version, expected := range tests {
+		c, err := NewColorsTwirpClient(svr.URL, http.DefaultTransport, WithTwirpClientVersion(version))
+		require.NoError(t, err)
+
+		color, err := c.Mix(context.Background(), &Color{Name: "red"})
+		require.NoError(t, err)
+		require.Equal(t, expected, color.Name)
+	}
+
+	_, err := NewColorsTwirpClient(svr.URL, http.DefaultTransport, WithTwirpClientVersion("v3"))
+	require.Error(t, err)
+}
+
+func TestRegisterHandler(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	})
+
+	var patterns []string
+	router := TwirpRouterFunc(func(pattern string, handler http.Handler) {
+		patterns = append(patterns, pattern)
+		mux.Handle(pattern, handler)
+	})
+
+	s := RegisterColorsTwirpHandler(router, versionedColors{})
+	require.Equal(t, s.PathPrefixes(), patterns)
+
+	svr := httptest.NewServer(mux)
+	defer svr.Close()
+
+	for version, expected := range map[string]string{"v1": "light red", "v2": "pale red"} {
+		c, err := NewColorsTwirpClient(svr.URL, http.DefaultTransport, WithTwirpClientVersion(version))
+		require.NoError(t, err)
+
+		color, err := c.Mix(context.Background(), &Color{Name: "red"})
+		require.NoError(t, err)
+		require.Equal(t, expected, color.Name)
+	}
+
+	// the server still rejects requests to unknown methods and other HTTP methods itself
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodPost, svr.URL+"/twirp/v1/twitch.twirp.example.common.Colors/Blend", nil),
+		httptest.NewRequest(http.MethodGet, svr.URL+"/twirp/v1/twitch.twirp.example.common.Colors/Mix", nil),
+	} {
+		req.RequestURI = ""
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+		require.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	}
+
+	resp, err := http.Get(svr.URL + "/healthz")
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+}
@@ -0,0 +1,29 @@
+// Code generated by protoc-gen-twirp-go DO NOT EDIT.
+package common
+
+import (
+	proto "google.golang.org/protobuf/proto"
+)
+
+// ColorBuilder builds a Color one field at a time, such as for requests built by admin
+// tools that call methods with Call. It is a convenience over setting the fields of the struct
+// directly, which works as well.
+type ColorBuilder struct {
+	msg *Color
+}
+
+// NewColorBuilder returns a builder for an empty Color.
+func NewColorBuilder() *ColorBuilder {
+	return &ColorBuilder{msg: &Color{}}
+}
+
+// WithName sets the name field to v.
+func (b *ColorBuilder) WithName(v string) *ColorBuilder {
+	b.msg.Name = v
+	return b
+}
+
+// Build returns a copy of the message built so far, so the builder can be used again.
+func (b *ColorBuilder) Build() *Color {
+	return proto.Clone(b.msg).(*Color)
+}
@@ -0,0 +1,338 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.26.0
+// 	protoc        v3.15.6
+// source: crosspkg/shop/shop.proto
+
+package shop
+
+import (
+	common "github.com/bakins/protoc-gen-twirp-go/example/crosspkg/common"
+	twirpgo "github.com/bakins/protoc-gen-twirp-go/twirpgo"
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// PaintRequest asks for an item to be painted.
+type PaintRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Item  string        `protobuf:"bytes,1,opt,name=item,proto3" json:"item,omitempty"`
+	Color *common.Color `protobuf:"bytes,2,opt,name=color,proto3" json:"color,omitempty"`
+}
+
+func (x *PaintRequest) Reset() {
+	*x = PaintRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_crosspkg_shop_shop_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *PaintRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PaintRequest) ProtoMessage() {}
+
+func (x *PaintRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_crosspkg_shop_shop_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PaintRequest.ProtoReflect.Descriptor instead.
+func (*PaintRequest) Descriptor() ([]byte, []int) {
+	return file_crosspkg_shop_shop_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *PaintRequest) GetItem() string {
+	if x != nil {
+		return x.Item
+	}
+	return ""
+}
+
+func (x *PaintRequest) GetColor() *common.Color {
+	if x != nil {
+		return x.Color
+	}
+	return nil
+}
+
+// PaintAllRequest asks for several items to be painted.
+type PaintAllRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Items []*PaintRequest `protobuf:"bytes,1,rep,name=items,proto3" json:"items,omitempty"`
+}
+
+func (x *PaintAllRequest) Reset() {
+	*x = PaintAllRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_crosspkg_shop_shop_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *PaintAllRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PaintAllRequest) ProtoMessage() {}
+
+func (x *PaintAllRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_crosspkg_shop_shop_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PaintAllRequest.ProtoReflect.Descriptor instead.
+func (*PaintAllRequest) Descriptor() ([]byte, []int) {
+	return file_crosspkg_shop_shop_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *PaintAllRequest) GetItems() []*PaintRequest {
+	if x != nil {
+		return x.Items
+	}
+	return nil
+}
+
+// PaintAllResponse has the colors of the items that were painted, and the
+// errors of the others.
+type PaintAllResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Colors []*common.Color      `protobuf:"bytes,1,rep,name=colors,proto3" json:"colors,omitempty"`
+	Errors []*twirpgo.ItemError `protobuf:"bytes,2,rep,name=errors,proto3" json:"errors,omitempty"`
+}
+
+func (x *PaintAllResponse) Reset() {
+	*x = PaintAllResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_crosspkg_shop_shop_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *PaintAllResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PaintAllResponse) ProtoMessage() {}
+
+func (x *PaintAllResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_crosspkg_shop_shop_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PaintAllResponse.ProtoReflect.Descriptor instead.
+func (*PaintAllResponse) Descriptor() ([]byte, []int) {
+	return file_crosspkg_shop_shop_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *PaintAllResponse) GetColors() []*common.Color {
+	if x != nil {
+		return x.Colors
+	}
+	return nil
+}
+
+func (x *PaintAllResponse) GetErrors() []*twirpgo.ItemError {
+	if x != nil {
+		return x.Errors
+	}
+	return nil
+}
+
+var File_crosspkg_shop_shop_proto protoreflect.FileDescriptor
+
+var file_crosspkg_shop_shop_proto_rawDesc = []byte{
+	0x0a, 0x18, 0x63, 0x72, 0x6f, 0x73, 0x73, 0x70, 0x6b, 0x67, 0x2f, 0x73, 0x68, 0x6f, 0x70, 0x2f,
+	0x73, 0x68, 0x6f, 0x70, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x19, 0x74, 0x77, 0x69, 0x74,
+	0x63, 0x68, 0x2e, 0x74, 0x77, 0x69, 0x72, 0x70, 0x2e, 0x65, 0x78, 0x61, 0x6d, 0x70, 0x6c, 0x65,
+	0x2e, 0x73, 0x68, 0x6f, 0x70, 0x1a, 0x1c, 0x63, 0x72, 0x6f, 0x73, 0x73, 0x70, 0x6b, 0x67, 0x2f,
+	0x63, 0x6f, 0x6d, 0x6d, 0x6f, 0x6e, 0x2f, 0x63, 0x6f, 0x6d, 0x6d, 0x6f, 0x6e, 0x2e, 0x70, 0x72,
+	0x6f, 0x74, 0x6f, 0x1a, 0x15, 0x74, 0x77, 0x69, 0x72, 0x70, 0x67, 0x6f, 0x2f, 0x6f, 0x70, 0x74,
+	0x69, 0x6f, 0x6e, 0x73, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0x5c, 0x0a, 0x0c, 0x50, 0x61,
+	0x69, 0x6e, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x69, 0x74,
+	0x65, 0x6d, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x69, 0x74, 0x65, 0x6d, 0x12, 0x38,
+	0x0a, 0x05, 0x63, 0x6f, 0x6c, 0x6f, 0x72, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x22, 0x2e,
+	0x74, 0x77, 0x69, 0x74, 0x63, 0x68, 0x2e, 0x74, 0x77, 0x69, 0x72, 0x70, 0x2e, 0x65, 0x78, 0x61,
+	0x6d, 0x70, 0x6c, 0x65, 0x2e, 0x63, 0x6f, 0x6d, 0x6d, 0x6f, 0x6e, 0x2e, 0x43, 0x6f, 0x6c, 0x6f,
+	0x72, 0x52, 0x05, 0x63, 0x6f, 0x6c, 0x6f, 0x72, 0x22, 0x50, 0x0a, 0x0f, 0x50, 0x61, 0x69, 0x6e,
+	0x74, 0x41, 0x6c, 0x6c, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x3d, 0x0a, 0x05, 0x69,
+	0x74, 0x65, 0x6d, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x27, 0x2e, 0x74, 0x77, 0x69,
+	0x74, 0x63, 0x68, 0x2e, 0x74, 0x77, 0x69, 0x72, 0x70, 0x2e, 0x65, 0x78, 0x61, 0x6d, 0x70, 0x6c,
+	0x65, 0x2e, 0x73, 0x68, 0x6f, 0x70, 0x2e, 0x50, 0x61, 0x69, 0x6e, 0x74, 0x52, 0x65, 0x71, 0x75,
+	0x65, 0x73, 0x74, 0x52, 0x05, 0x69, 0x74, 0x65, 0x6d, 0x73, 0x22, 0x7a, 0x0a, 0x10, 0x50, 0x61,
+	0x69, 0x6e, 0x74, 0x41, 0x6c, 0x6c, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x3a,
+	0x0a, 0x06, 0x63, 0x6f, 0x6c, 0x6f, 0x72, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x22,
+	0x2e, 0x74, 0x77, 0x69, 0x74, 0x63, 0x68, 0x2e, 0x74, 0x77, 0x69, 0x72, 0x70, 0x2e, 0x65, 0x78,
+	0x61, 0x6d, 0x70, 0x6c, 0x65, 0x2e, 0x63, 0x6f, 0x6d, 0x6d, 0x6f, 0x6e, 0x2e, 0x43, 0x6f, 0x6c,
+	0x6f, 0x72, 0x52, 0x06, 0x63, 0x6f, 0x6c, 0x6f, 0x72, 0x73, 0x12, 0x2a, 0x0a, 0x06, 0x65, 0x72,
+	0x72, 0x6f, 0x72, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x74, 0x77, 0x69,
+	0x72, 0x70, 0x67, 0x6f, 0x2e, 0x49, 0x74, 0x65, 0x6d, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x52, 0x06,
+	0x65, 0x72, 0x72, 0x6f, 0x72, 0x73, 0x32, 0x98, 0x02, 0x0a, 0x04, 0x53, 0x68, 0x6f, 0x70, 0x12,
+	0x54, 0x0a, 0x05, 0x50, 0x61, 0x69, 0x6e, 0x74, 0x12, 0x27, 0x2e, 0x74, 0x77, 0x69, 0x74, 0x63,
+	0x68, 0x2e, 0x74, 0x77, 0x69, 0x72, 0x70, 0x2e, 0x65, 0x78, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x2e,
+	0x73, 0x68, 0x6f, 0x70, 0x2e, 0x50, 0x61, 0x69, 0x6e, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x1a, 0x22, 0x2e, 0x74, 0x77, 0x69, 0x74, 0x63, 0x68, 0x2e, 0x74, 0x77, 0x69, 0x72, 0x70,
+	0x2e, 0x65, 0x78, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x2e, 0x63, 0x6f, 0x6d, 0x6d, 0x6f, 0x6e, 0x2e,
+	0x43, 0x6f, 0x6c, 0x6f, 0x72, 0x12, 0x55, 0x0a, 0x05, 0x4d, 0x61, 0x74, 0x63, 0x68, 0x12, 0x22,
+	0x2e, 0x74, 0x77, 0x69, 0x74, 0x63, 0x68, 0x2e, 0x74, 0x77, 0x69, 0x72, 0x70, 0x2e, 0x65, 0x78,
+	0x61, 0x6d, 0x70, 0x6c, 0x65, 0x2e, 0x63, 0x6f, 0x6d, 0x6d, 0x6f, 0x6e, 0x2e, 0x43, 0x6f, 0x6c,
+	0x6f, 0x72, 0x1a, 0x22, 0x2e, 0x74, 0x77, 0x69, 0x74, 0x63, 0x68, 0x2e, 0x74, 0x77, 0x69, 0x72,
+	0x70, 0x2e, 0x65, 0x78, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x2e, 0x63, 0x6f, 0x6d, 0x6d, 0x6f, 0x6e,
+	0x2e, 0x43, 0x6f, 0x6c, 0x6f, 0x72, 0x22, 0x04, 0xf8, 0xe0, 0x18, 0x01, 0x12, 0x63, 0x0a, 0x08,
+	0x50, 0x61, 0x69, 0x6e, 0x74, 0x41, 0x6c, 0x6c, 0x12, 0x2a, 0x2e, 0x74, 0x77, 0x69, 0x74, 0x63,
+	0x68, 0x2e, 0x74, 0x77, 0x69, 0x72, 0x70, 0x2e, 0x65, 0x78, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x2e,
+	0x73, 0x68, 0x6f, 0x70, 0x2e, 0x50, 0x61, 0x69, 0x6e, 0x74, 0x41, 0x6c, 0x6c, 0x52, 0x65, 0x71,
+	0x75, 0x65, 0x73, 0x74, 0x1a, 0x2b, 0x2e, 0x74, 0x77, 0x69, 0x74, 0x63, 0x68, 0x2e, 0x74, 0x77,
+	0x69, 0x72, 0x70, 0x2e, 0x65, 0x78, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x2e, 0x73, 0x68, 0x6f, 0x70,
+	0x2e, 0x50, 0x61, 0x69, 0x6e, 0x74, 0x41, 0x6c, 0x6c, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73,
+	0x65, 0x42, 0x3d, 0x5a, 0x3b, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f,
+	0x62, 0x61, 0x6b, 0x69, 0x6e, 0x73, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x2d, 0x67, 0x65,
+	0x6e, 0x2d, 0x74, 0x77, 0x69, 0x72, 0x70, 0x2d, 0x67, 0x6f, 0x2f, 0x65, 0x78, 0x61, 0x6d, 0x70,
+	0x6c, 0x65, 0x2f, 0x63, 0x72, 0x6f, 0x73, 0x73, 0x70, 0x6b, 0x67, 0x2f, 0x73, 0x68, 0x6f, 0x70,
+	0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_crosspkg_shop_shop_proto_rawDescOnce sync.Once
+	file_crosspkg_shop_shop_proto_rawDescData = file_crosspkg_shop_shop_proto_rawDesc
+)
+
+func file_crosspkg_shop_shop_proto_rawDescGZIP() []byte {
+	file_crosspkg_shop_shop_proto_rawDescOnce.Do(func() {
+		file_crosspkg_shop_shop_proto_rawDescData = protoimpl.X.CompressGZIP(file_crosspkg_shop_shop_proto_rawDescData)
+	})
+	return file_crosspkg_shop_shop_proto_rawDescData
+}
+
+var file_crosspkg_shop_shop_proto_msgTypes = make([]protoimpl.MessageInfo, 3)
+var file_crosspkg_shop_shop_proto_goTypes = []interface{}{
+	(*PaintRequest)(nil),      // 0: twitch.twirp.example.shop.PaintRequest
+	(*PaintAllRequest)(nil),   // 1: twitch.twirp.example.shop.PaintAllRequest
+	(*PaintAllResponse)(nil),  // 2: twitch.twirp.example.shop.PaintAllResponse
+	(*common.Color)(nil),      // 3: twitch.twirp.example.common.Color
+	(*twirpgo.ItemError)(nil), // 4: twirpgo.ItemError
+}
+var file_crosspkg_shop_shop_proto_depIdxs = []int32{
+	3, // 0: twitch.twirp.example.shop.PaintRequest.color:type_name -> twitch.twirp.example.common.Color
+	0, // 1: twitch.twirp.example.shop.PaintAllRequest.items:type_name -> twitch.twirp.example.shop.PaintRequest
+	3, // 2: twitch.twirp.example.shop.PaintAllResponse.colors:type_name -> twitch.twirp.example.common.Color
+	4, // 3: twitch.twirp.example.shop.PaintAllResponse.errors:type_name -> twirpgo.ItemError
+	0, // 4: twitch.twirp.example.shop.Shop.Paint:input_type -> twitch.twirp.example.shop.PaintRequest
+	3, // 5: twitch.twirp.example.shop.Shop.Match:input_type -> twitch.twirp.example.common.Color
+	1, // 6: twitch.twirp.example.shop.Shop.PaintAll:input_type -> twitch.twirp.example.shop.PaintAllRequest
+	3, // 7: twitch.twirp.example.shop.Shop.Paint:output_type -> twitch.twirp.example.common.Color
+	3, // 8: twitch.twirp.example.shop.Shop.Match:output_type -> twitch.twirp.example.common.Color
+	2, // 9: twitch.twirp.example.shop.Shop.PaintAll:output_type -> twitch.twirp.example.shop.PaintAllResponse
+	7, // [7:10] is the sub-list for method output_type
+	4, // [4:7] is the sub-list for method input_type
+	4, // [4:4] is the sub-list for extension type_name
+	4, // [4:4] is the sub-list for extension extendee
+	0, // [0:4] is the sub-list for field type_name
+}
+
+func init() { file_crosspkg_shop_shop_proto_init() }
+func file_crosspkg_shop_shop_proto_init() {
+	if File_crosspkg_shop_shop_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_crosspkg_shop_shop_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*PaintRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_crosspkg_shop_shop_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*PaintAllRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_crosspkg_shop_shop_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*PaintAllResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_crosspkg_shop_shop_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   3,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_crosspkg_shop_shop_proto_goTypes,
+		DependencyIndexes: file_crosspkg_shop_shop_proto_depIdxs,
+		MessageInfos:      file_crosspkg_shop_shop_proto_msgTypes,
+	}.Build()
+	File_crosspkg_shop_shop_proto = out.File
+	file_crosspkg_shop_shop_proto_rawDesc = nil
+	file_crosspkg_shop_shop_proto_goTypes = nil
+	file_crosspkg_shop_shop_proto_depIdxs = nil
+}
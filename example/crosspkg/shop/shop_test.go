@@ -0,0 +1,201 @@
+package shop
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/twitchtv/twirp"
+
+	"github.com/bakins/protoc-gen-twirp-go/example/crosspkg/common"
+)
+
+type testColors struct{}
+
+func (testColors) Mix(ctx context.Context, color *common.Color) (*common.Color, error) {
+	return &common.Color{Name: "light " + color.Name}, nil
+}
+
+type testShop struct{}
+
+func (testShop) Paint(ctx context.Context, req *PaintRequest) (*common.Color, error) {
+	return req.Color, nil
+}
+
+func (testShop) Match(ctx context.Context, color *common.Color) (*common.Color, error) {
+	// matches never change, so the name of the color is a valid ETag
+	_ = SetTwirpETag(ctx, color.Name)
+	return color, nil
+}
+
+func (testShop) PaintAll(ctx context.Context, req *PaintAllRequest) (*PaintAllResponse, error) {
+	resp := &PaintAllResponse{}
+	for i, item := range req.Items {
+		switch {
+		case item.Color == nil:
+			resp.AddTwirpError(i, twirp.RequiredArgumentError("color").WithMeta("item", item.Item))
+		case item.Color.Name == "invisible":
+			resp.AddTwirpError(i, errors.New("out of invisible paint"))
+		default:
+			resp.Colors = append(resp.Colors, item.Color)
+		}
+	}
+
+	return resp, nil
+}
+
+func TestCombinedHandler(t *testing.T) {
+	handler := NewTwirpCombinedHandler(
+		NewShopTwirpServer(testShop{}),
+		common.NewColorsTwirpServer(testColors{}),
+	)
+	svr := httptest.NewServer(handler)
+	defer svr.Close()
+
+	shop, err := NewShopTwirpClient(svr.URL, http.DefaultTransport)
+	require.NoError(t, err)
+
+	color, err := shop.Paint(context.Background(), &PaintRequest{Item: "hat", Color: &common.Color{Name: "red"}})
+	require.NoError(t, err)
+	require.Equal(t, "red", color.Name)
+
+	colors, err := common.NewColorsTwirpClient(svr.URL, http.DefaultTransport)
+	require.NoError(t, err)
+
+	color, err = colors.Mix(context.Background(), &common.Color{Name: "red"})
+	require.NoError(t, err)
+	require.Equal(t, "light red", color.Name)
+
+	resp, err := http.Post(svr.URL+"/twirp/other.Service/Method", "application/json", nil)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestItemErrors(t *testing.T) {
+	svr := httptest.NewServer(NewShopTwirpServer(testShop{}))
+	defer svr.Close()
+
+	shop, err := NewShopTwirpClient(svr.URL, http.DefaultTransport)
+	require.NoError(t, err)
+
+	resp, err := shop.PaintAll(context.Background(), &PaintAllRequest{
+		Items: []*PaintRequest{
+			{Item: "hat", Color: &common.Color{Name: "red"}},
+			{Item: "scarf"},
+			{Item: "cape", Color: &common.Color{Name: "invisible"}},
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, resp.Colors, 1)
+	require.Equal(t, "red", resp.Colors[0].Name)
+
+	errs := resp.TwirpErrors()
+	require.Len(t, errs, 2)
+
+	require.Equal(t, twirp.InvalidArgument, errs[1].Code())
+	require.Equal(t, "color is required", errs[1].Msg())
+	require.Equal(t, "scarf", errs[1].Meta("item"))
+	require.Equal(t, "color", errs[1].Meta("argument"))
+
+	require.Equal(t, twirp.Internal, errs[2].Code())
+	require.Equal(t, "out of invisible paint", errs[2].Msg())
+}
+
+func TestETag(t *testing.T) {
+	var statuses []int
+	var ifNoneMatch []string
+	ts := NewShopTwirpServer(testShop{})
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := httptest.NewRecorder()
+		ts.ServeHTTP(rec, r)
+		statuses = append(statuses, rec.Code)
+		ifNoneMatch = append(ifNoneMatch, r.Header.Get("If-None-Match"))
+
+		for k, v := range rec.Header() {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(rec.Code)
+		_, _ = w.Write(rec.Body.Bytes())
+	}))
+	defer svr.Close()
+
+	c, err := NewShopTwirpClient(svr.URL, http.DefaultTransport)
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		color, err := c.Match(context.Background(), &common.Color{Name: "red"})
+		require.NoError(t, err)
+		require.Equal(t, "red", color.Name)
+	}
+
+	color, err := c.Match(context.Background(), &common.Color{Name: "blue"})
+	require.NoError(t, err)
+	require.Equal(t, "blue", color.Name)
+
+	// only cacheable methods send If-None-Match
+	_, err = c.Paint(context.Background(), &PaintRequest{Item: "hat", Color: &common.Color{Name: "red"}})
+	require.NoError(t, err)
+
+	require.Equal(t, []int{http.StatusOK, http.StatusNotModified, http.StatusOK, http.StatusOK}, statuses)
+	require.Equal(t, []string{"", `"red"`, "", ""}, ifNoneMatch)
+
+	statuses, ifNoneMatch = nil, nil
+
+	c, err = NewShopTwirpClient(svr.URL, http.DefaultTransport, WithTwirpClientETagCacheSize(0))
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		_, err = c.Match(context.Background(), &common.Color{Name: "red"})
+		require.NoError(t, err)
+	}
+	require.Equal(t, []int{http.StatusOK, http.StatusOK}, statuses)
+
+	statuses = nil
+
+	// the least recently used response is evicted
+	c, err = NewShopTwirpClient(svr.URL, http.DefaultTransport, WithTwirpClientETagCacheSize(1))
+	require.NoError(t, err)
+
+	for _, name := range []string{"red", "blue", "red", "red"} {
+		_, err = c.Match(context.Background(), &common.Color{Name: name})
+		require.NoError(t, err)
+	}
+	require.Equal(t, []int{http.StatusOK, http.StatusOK, http.StatusOK, http.StatusNotModified}, statuses)
+
+	// SetTwirpETag fails for methods that are not cacheable
+	require.Error(t, SetTwirpETag(context.Background(), "red"))
+}
+
+func TestCombinedHandlerDuplicate(t *testing.T) {
+	require.Panics(t, func() {
+		NewTwirpCombinedHandler(NewShopTwirpServer(testShop{}), NewShopTwirpServer(testShop{}))
+	})
+}
+
+func TestBuilders(t *testing.T) {
+	svr := httptest.NewServer(NewShopTwirpServer(testShop{}))
+	defer svr.Close()
+
+	c, err := NewShopTwirpClient(svr.URL, http.DefaultTransport)
+	require.NoError(t, err)
+
+	red := NewColorBuilder().WithName("red").Build()
+	item := NewPaintRequestBuilder().WithItem("hat").WithColor(red)
+
+	builder := NewPaintAllRequestBuilder().AddItems(item.Build())
+	first := builder.Build()
+	req := builder.AddItems(item.WithItem("scarf").Build()).Build()
+
+	// built messages are copies, so later changes to the builder do not modify them
+	require.Len(t, first.Items, 1)
+	require.Len(t, req.Items, 2)
+	require.Equal(t, "scarf", req.Items[1].Item)
+
+	resp, err := c.Call(context.Background(), "PaintAll", req)
+	require.NoError(t, err)
+	require.Len(t, resp.(*PaintAllResponse).Colors, 2)
+}
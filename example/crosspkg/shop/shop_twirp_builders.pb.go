@@ -0,0 +1,88 @@
+// Code generated by protoc-gen-twirp-go DO NOT EDIT.
+package shop
+
+import (
+	common "github.com/bakins/protoc-gen-twirp-go/example/crosspkg/common"
+	proto "google.golang.org/protobuf/proto"
+)
+
+// PaintRequestBuilder builds a PaintRequest one field at a time, such as for requests built by admin
+// tools that call methods with Call. It is a convenience over setting the fields of the struct
+// directly, which works as well.
+type PaintRequestBuilder struct {
+	msg *PaintRequest
+}
+
+// NewPaintRequestBuilder returns a builder for an empty PaintRequest.
+func NewPaintRequestBuilder() *PaintRequestBuilder {
+	return &PaintRequestBuilder{msg: &PaintRequest{}}
+}
+
+// WithItem sets the item field to v.
+func (b *PaintRequestBuilder) WithItem(v string) *PaintRequestBuilder {
+	b.msg.Item = v
+	return b
+}
+
+// WithColor sets the color field to v.
+func (b *PaintRequestBuilder) WithColor(v *common.Color) *PaintRequestBuilder {
+	b.msg.Color = v
+	return b
+}
+
+// Build returns a copy of the message built so far, so the builder can be used again.
+func (b *PaintRequestBuilder) Build() *PaintRequest {
+	return proto.Clone(b.msg).(*PaintRequest)
+}
+
+// ColorBuilder builds a common.Color one field at a time, such as for requests built by admin
+// tools that call methods with Call. It is a convenience over setting the fields of the struct
+// directly, which works as well.
+type ColorBuilder struct {
+	msg *common.Color
+}
+
+// NewColorBuilder returns a builder for an empty common.Color.
+func NewColorBuilder() *ColorBuilder {
+	return &ColorBuilder{msg: &common.Color{}}
+}
+
+// WithName sets the name field to v.
+func (b *ColorBuilder) WithName(v string) *ColorBuilder {
+	b.msg.Name = v
+	return b
+}
+
+// Build returns a copy of the message built so far, so the builder can be used again.
+func (b *ColorBuilder) Build() *common.Color {
+	return proto.Clone(b.msg).(*common.Color)
+}
+
+// PaintAllRequestBuilder builds a PaintAllRequest one field at a time, such as for requests built by admin
+// tools that call methods with Call. It is a convenience over setting the fields of the struct
+// directly, which works as well.
+type PaintAllRequestBuilder struct {
+	msg *PaintAllRequest
+}
+
+// NewPaintAllRequestBuilder returns a builder for an empty PaintAllRequest.
+func NewPaintAllRequestBuilder() *PaintAllRequestBuilder {
+	return &PaintAllRequestBuilder{msg: &PaintAllRequest{}}
+}
+
+// WithItems sets the items field to values.
+func (b *PaintAllRequestBuilder) WithItems(values ...*PaintRequest) *PaintAllRequestBuilder {
+	b.msg.Items = values
+	return b
+}
+
+// AddItems appends values to the items field.
+func (b *PaintAllRequestBuilder) AddItems(values ...*PaintRequest) *PaintAllRequestBuilder {
+	b.msg.Items = append(b.msg.Items, values...)
+	return b
+}
+
+// Build returns a copy of the message built so far, so the builder can be used again.
+func (b *PaintAllRequestBuilder) Build() *PaintAllRequest {
+	return proto.Clone(b.msg).(*PaintAllRequest)
+}
@@ -0,0 +1,228 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.26.0
+// 	protoc        v3.15.6
+// source: grpccompat/grpccompat.proto
+
+package grpccompat
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// CountRequest asks a Counter to count up to a number.
+type CountRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// The number to count to.
+	To int32 `protobuf:"varint,1,opt,name=to,proto3" json:"to,omitempty"`
+}
+
+func (x *CountRequest) Reset() {
+	*x = CountRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_grpccompat_grpccompat_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *CountRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CountRequest) ProtoMessage() {}
+
+func (x *CountRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_grpccompat_grpccompat_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CountRequest.ProtoReflect.Descriptor instead.
+func (*CountRequest) Descriptor() ([]byte, []int) {
+	return file_grpccompat_grpccompat_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *CountRequest) GetTo() int32 {
+	if x != nil {
+		return x.To
+	}
+	return 0
+}
+
+// A Number sent by a Counter.
+type Number struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Value int32 `protobuf:"varint,1,opt,name=value,proto3" json:"value,omitempty"`
+}
+
+func (x *Number) Reset() {
+	*x = Number{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_grpccompat_grpccompat_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Number) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Number) ProtoMessage() {}
+
+func (x *Number) ProtoReflect() protoreflect.Message {
+	mi := &file_grpccompat_grpccompat_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Number.ProtoReflect.Descriptor instead.
+func (*Number) Descriptor() ([]byte, []int) {
+	return file_grpccompat_grpccompat_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Number) GetValue() int32 {
+	if x != nil {
+		return x.Value
+	}
+	return 0
+}
+
+var File_grpccompat_grpccompat_proto protoreflect.FileDescriptor
+
+var file_grpccompat_grpccompat_proto_rawDesc = []byte{
+	0x0a, 0x1b, 0x67, 0x72, 0x70, 0x63, 0x63, 0x6f, 0x6d, 0x70, 0x61, 0x74, 0x2f, 0x67, 0x72, 0x70,
+	0x63, 0x63, 0x6f, 0x6d, 0x70, 0x61, 0x74, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x1f, 0x74,
+	0x77, 0x69, 0x74, 0x63, 0x68, 0x2e, 0x74, 0x77, 0x69, 0x72, 0x70, 0x2e, 0x65, 0x78, 0x61, 0x6d,
+	0x70, 0x6c, 0x65, 0x2e, 0x67, 0x72, 0x70, 0x63, 0x63, 0x6f, 0x6d, 0x70, 0x61, 0x74, 0x22, 0x1e,
+	0x0a, 0x0c, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x0e,
+	0x0a, 0x02, 0x74, 0x6f, 0x18, 0x01, 0x20, 0x01, 0x28, 0x05, 0x52, 0x02, 0x74, 0x6f, 0x22, 0x1e,
+	0x0a, 0x06, 0x4e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75,
+	0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x05, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x32, 0xc8,
+	0x01, 0x0a, 0x07, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x65, 0x72, 0x12, 0x5a, 0x0a, 0x06, 0x53, 0x71,
+	0x75, 0x61, 0x72, 0x65, 0x12, 0x27, 0x2e, 0x74, 0x77, 0x69, 0x74, 0x63, 0x68, 0x2e, 0x74, 0x77,
+	0x69, 0x72, 0x70, 0x2e, 0x65, 0x78, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x2e, 0x67, 0x72, 0x70, 0x63,
+	0x63, 0x6f, 0x6d, 0x70, 0x61, 0x74, 0x2e, 0x4e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x1a, 0x27, 0x2e,
+	0x74, 0x77, 0x69, 0x74, 0x63, 0x68, 0x2e, 0x74, 0x77, 0x69, 0x72, 0x70, 0x2e, 0x65, 0x78, 0x61,
+	0x6d, 0x70, 0x6c, 0x65, 0x2e, 0x67, 0x72, 0x70, 0x63, 0x63, 0x6f, 0x6d, 0x70, 0x61, 0x74, 0x2e,
+	0x4e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x12, 0x61, 0x0a, 0x05, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x12,
+	0x2d, 0x2e, 0x74, 0x77, 0x69, 0x74, 0x63, 0x68, 0x2e, 0x74, 0x77, 0x69, 0x72, 0x70, 0x2e, 0x65,
+	0x78, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x2e, 0x67, 0x72, 0x70, 0x63, 0x63, 0x6f, 0x6d, 0x70, 0x61,
+	0x74, 0x2e, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x27,
+	0x2e, 0x74, 0x77, 0x69, 0x74, 0x63, 0x68, 0x2e, 0x74, 0x77, 0x69, 0x72, 0x70, 0x2e, 0x65, 0x78,
+	0x61, 0x6d, 0x70, 0x6c, 0x65, 0x2e, 0x67, 0x72, 0x70, 0x63, 0x63, 0x6f, 0x6d, 0x70, 0x61, 0x74,
+	0x2e, 0x4e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x30, 0x01, 0x42, 0x3a, 0x5a, 0x38, 0x67, 0x69, 0x74,
+	0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x62, 0x61, 0x6b, 0x69, 0x6e, 0x73, 0x2f, 0x70,
+	0x72, 0x6f, 0x74, 0x6f, 0x63, 0x2d, 0x67, 0x65, 0x6e, 0x2d, 0x74, 0x77, 0x69, 0x72, 0x70, 0x2d,
+	0x67, 0x6f, 0x2f, 0x65, 0x78, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x2f, 0x67, 0x72, 0x70, 0x63, 0x63,
+	0x6f, 0x6d, 0x70, 0x61, 0x74, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_grpccompat_grpccompat_proto_rawDescOnce sync.Once
+	file_grpccompat_grpccompat_proto_rawDescData = file_grpccompat_grpccompat_proto_rawDesc
+)
+
+func file_grpccompat_grpccompat_proto_rawDescGZIP() []byte {
+	file_grpccompat_grpccompat_proto_rawDescOnce.Do(func() {
+		file_grpccompat_grpccompat_proto_rawDescData = protoimpl.X.CompressGZIP(file_grpccompat_grpccompat_proto_rawDescData)
+	})
+	return file_grpccompat_grpccompat_proto_rawDescData
+}
+
+var file_grpccompat_grpccompat_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_grpccompat_grpccompat_proto_goTypes = []interface{}{
+	(*CountRequest)(nil), // 0: twitch.twirp.example.grpccompat.CountRequest
+	(*Number)(nil),       // 1: twitch.twirp.example.grpccompat.Number
+}
+var file_grpccompat_grpccompat_proto_depIdxs = []int32{
+	1, // 0: twitch.twirp.example.grpccompat.Counter.Square:input_type -> twitch.twirp.example.grpccompat.Number
+	0, // 1: twitch.twirp.example.grpccompat.Counter.Count:input_type -> twitch.twirp.example.grpccompat.CountRequest
+	1, // 2: twitch.twirp.example.grpccompat.Counter.Square:output_type -> twitch.twirp.example.grpccompat.Number
+	1, // 3: twitch.twirp.example.grpccompat.Counter.Count:output_type -> twitch.twirp.example.grpccompat.Number
+	2, // [2:4] is the sub-list for method output_type
+	0, // [0:2] is the sub-list for method input_type
+	0, // [0:0] is the sub-list for extension type_name
+	0, // [0:0] is the sub-list for extension extendee
+	0, // [0:0] is the sub-list for field type_name
+}
+
+func init() { file_grpccompat_grpccompat_proto_init() }
+func file_grpccompat_grpccompat_proto_init() {
+	if File_grpccompat_grpccompat_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_grpccompat_grpccompat_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*CountRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_grpccompat_grpccompat_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Number); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_grpccompat_grpccompat_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   2,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_grpccompat_grpccompat_proto_goTypes,
+		DependencyIndexes: file_grpccompat_grpccompat_proto_depIdxs,
+		MessageInfos:      file_grpccompat_grpccompat_proto_msgTypes,
+	}.Build()
+	File_grpccompat_grpccompat_proto = out.File
+	file_grpccompat_grpccompat_proto_rawDesc = nil
+	file_grpccompat_grpccompat_proto_goTypes = nil
+	file_grpccompat_grpccompat_proto_depIdxs = nil
+}
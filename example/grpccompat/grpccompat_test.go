@@ -0,0 +1,129 @@
+package grpccompat
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/twitchtv/twirp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+type testCounter struct{}
+
+func (testCounter) Square(ctx context.Context, n *Number) (*Number, error) {
+	if n.Value < 0 {
+		return nil, twirp.InvalidArgumentError("value", "must not be negative")
+	}
+	return &Number{Value: n.Value * n.Value}, nil
+}
+
+func (testCounter) Count(ctx context.Context, req *CountRequest, send func(*Number) error) error {
+	if method, _ := twirp.MethodName(ctx); method != "Count" {
+		return twirp.InternalError("unexpected method name " + method)
+	}
+
+	if req.To < 0 {
+		return twirp.InvalidArgumentError("to", "must not be negative")
+	}
+
+	for i := int32(1); i <= req.To; i++ {
+		if err := send(&Number{Value: i}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// dialCounter starts a gRPC server with a testCounter and returns a connection to it.
+func dialCounter(t *testing.T) *grpc.ClientConn {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	server := grpc.NewServer()
+	RegisterCounterGRPCServer(server, testCounter{})
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return conn
+}
+
+// count calls Count with a real gRPC server streaming client, and returns the received numbers.
+func count(ctx context.Context, conn *grpc.ClientConn, req *CountRequest) ([]int32, error) {
+	desc := &grpc.StreamDesc{StreamName: "Count", ServerStreams: true}
+	stream, err := conn.NewStream(ctx, desc, "/twitch.twirp.example.grpccompat.Counter/Count")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := stream.SendMsg(req); err != nil {
+		return nil, err
+	}
+	if err := stream.CloseSend(); err != nil {
+		return nil, err
+	}
+
+	var values []int32
+	for {
+		var n Number
+		err := stream.RecvMsg(&n)
+		if err == io.EOF {
+			return values, nil
+		}
+		if err != nil {
+			return values, err
+		}
+		values = append(values, n.Value)
+	}
+}
+
+func TestGRPCUnary(t *testing.T) {
+	conn := dialCounter(t)
+
+	var out Number
+	err := conn.Invoke(context.Background(), "/twitch.twirp.example.grpccompat.Counter/Square", &Number{Value: 3}, &out)
+	require.NoError(t, err)
+	require.Equal(t, int32(9), out.Value)
+
+	err = conn.Invoke(context.Background(), "/twitch.twirp.example.grpccompat.Counter/Square", &Number{Value: -1}, &out)
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestGRPCServerStreaming(t *testing.T) {
+	conn := dialCounter(t)
+
+	values, err := count(context.Background(), conn, &CountRequest{To: 3})
+	require.NoError(t, err)
+	require.Equal(t, []int32{1, 2, 3}, values)
+
+	_, err = count(context.Background(), conn, &CountRequest{To: -1})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestTwirpServerStreaming(t *testing.T) {
+	svr := httptest.NewServer(NewCounterTwirpServer(testCounter{}))
+	defer svr.Close()
+
+	client, err := NewCounterTwirpClient(svr.URL, http.DefaultTransport)
+	require.NoError(t, err)
+
+	var values []int32
+	err = client.Count(context.Background(), &CountRequest{To: 3}, func(n *Number) error {
+		values = append(values, n.Value)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, []int32{1, 2, 3}, values)
+}
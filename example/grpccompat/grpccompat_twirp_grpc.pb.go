@@ -0,0 +1,134 @@
+// Code generated by protoc-gen-twirp-go DO NOT EDIT.
+package grpccompat
+
+import (
+	"context"
+	"errors"
+
+	"github.com/twitchtv/twirp"
+	"github.com/twitchtv/twirp/ctxsetters"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// twirpGRPCCodes maps Twirp error codes to gRPC codes. Twirp codes without a gRPC
+// equivalent are mapped to the closest one.
+var twirpGRPCCodes = map[twirp.ErrorCode]codes.Code{
+	twirp.Canceled:           codes.Canceled,
+	twirp.Unknown:            codes.Unknown,
+	twirp.InvalidArgument:    codes.InvalidArgument,
+	twirp.Malformed:          codes.InvalidArgument,
+	twirp.DeadlineExceeded:   codes.DeadlineExceeded,
+	twirp.NotFound:           codes.NotFound,
+	twirp.BadRoute:           codes.Unimplemented,
+	twirp.AlreadyExists:      codes.AlreadyExists,
+	twirp.PermissionDenied:   codes.PermissionDenied,
+	twirp.Unauthenticated:    codes.Unauthenticated,
+	twirp.ResourceExhausted:  codes.ResourceExhausted,
+	twirp.FailedPrecondition: codes.FailedPrecondition,
+	twirp.Aborted:            codes.Aborted,
+	twirp.OutOfRange:         codes.OutOfRange,
+	twirp.Unimplemented:      codes.Unimplemented,
+	twirp.Internal:           codes.Internal,
+	twirp.Unavailable:        codes.Unavailable,
+	twirp.DataLoss:           codes.DataLoss,
+}
+
+// twirpGRPCError converts errors returned by Twirp implementations to gRPC status errors.
+// Error metadata is not sent. Errors that already are gRPC status errors, such as those
+// returned when sending to a stream fails, are returned as they are.
+func twirpGRPCError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var twerr twirp.Error
+	if !errors.As(err, &twerr) {
+		if _, ok := status.FromError(err); ok {
+			return err
+		}
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	code, ok := twirpGRPCCodes[twerr.Code()]
+	if !ok {
+		code = codes.Unknown
+	}
+
+	return status.Error(code, twerr.Msg())
+}
+
+// RegisterCounterGRPCServer registers implementation with registrar, such as a *grpc.Server,
+// as the gRPC service twitch.twirp.example.grpccompat.Counter, so that the same implementation serves both Twirp
+// and gRPC requests. Errors returned by the implementation are converted to gRPC status errors
+// with the matching code. Server streaming methods are registered as gRPC server streams.
+func RegisterCounterGRPCServer(registrar grpc.ServiceRegistrar, implementation CounterTwirpService) {
+	registrar.RegisterService(&twirpCounterGRPCServiceDesc, implementation)
+}
+
+var twirpCounterGRPCServiceDesc = grpc.ServiceDesc{
+	ServiceName: "twitch.twirp.example.grpccompat.Counter",
+	HandlerType: (*CounterTwirpService)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Square",
+			Handler:    twirpCounterSquareGRPCHandler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Count",
+			Handler:       twirpCounterCountGRPCStreamHandler,
+			ServerStreams: true,
+		},
+	},
+}
+
+func twirpCounterSquareGRPCHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Number)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+
+	ctx = ctxsetters.WithPackageName(ctx, "twitch.twirp.example.grpccompat")
+	ctx = ctxsetters.WithServiceName(ctx, "Counter")
+	ctx = ctxsetters.WithMethodName(ctx, "Square")
+
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		resp, err := srv.(CounterTwirpService).Square(ctx, req.(*Number))
+		if err != nil {
+			return nil, twirpGRPCError(err)
+		}
+		return resp, nil
+	}
+
+	if interceptor == nil {
+		return handler(ctx, in)
+	}
+
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/twitch.twirp.example.grpccompat.Counter/Square",
+	}
+
+	return interceptor(ctx, in, info, handler)
+}
+
+func twirpCounterCountGRPCStreamHandler(srv interface{}, stream grpc.ServerStream) error {
+	in := new(CountRequest)
+	if err := stream.RecvMsg(in); err != nil {
+		return err
+	}
+
+	ctx := stream.Context()
+	ctx = ctxsetters.WithPackageName(ctx, "twitch.twirp.example.grpccompat")
+	ctx = ctxsetters.WithServiceName(ctx, "Counter")
+	ctx = ctxsetters.WithMethodName(ctx, "Count")
+
+	send := func(msg *Number) error {
+		return stream.SendMsg(msg)
+	}
+
+	return twirpGRPCError(srv.(CounterTwirpService).Count(ctx, in, send))
+}
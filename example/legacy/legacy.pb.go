@@ -0,0 +1,269 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.26.0
+// 	protoc        v3.15.6
+// source: legacy/legacy.proto
+
+package legacy
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoiface "google.golang.org/protobuf/runtime/protoiface"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// An Order from a legacy system. Other teams attach their own fields to it
+// with extensions.
+type Order struct {
+	state           protoimpl.MessageState
+	sizeCache       protoimpl.SizeCache
+	unknownFields   protoimpl.UnknownFields
+	extensionFields protoimpl.ExtensionFields
+
+	Id *string `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
+}
+
+func (x *Order) Reset() {
+	*x = Order{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_legacy_legacy_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Order) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Order) ProtoMessage() {}
+
+func (x *Order) ProtoReflect() protoreflect.Message {
+	mi := &file_legacy_legacy_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Order.ProtoReflect.Descriptor instead.
+func (*Order) Descriptor() ([]byte, []int) {
+	return file_legacy_legacy_proto_rawDescGZIP(), []int{0}
+}
+
+var extRange_Order = []protoiface.ExtensionRangeV1{
+	{Start: 100, End: 199},
+}
+
+// Deprecated: Use Order.ProtoReflect.Descriptor.ExtensionRanges instead.
+func (*Order) ExtensionRangeArray() []protoiface.ExtensionRangeV1 {
+	return extRange_Order
+}
+
+func (x *Order) GetId() string {
+	if x != nil && x.Id != nil {
+		return *x.Id
+	}
+	return ""
+}
+
+// A Receipt for an Order.
+type Receipt struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// The order, with its extensions.
+	Order *Order `protobuf:"bytes,1,opt,name=order" json:"order,omitempty"`
+	// The gift note of the order, if it has one.
+	GiftNote *string `protobuf:"bytes,2,opt,name=gift_note,json=giftNote" json:"gift_note,omitempty"`
+}
+
+func (x *Receipt) Reset() {
+	*x = Receipt{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_legacy_legacy_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Receipt) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Receipt) ProtoMessage() {}
+
+func (x *Receipt) ProtoReflect() protoreflect.Message {
+	mi := &file_legacy_legacy_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Receipt.ProtoReflect.Descriptor instead.
+func (*Receipt) Descriptor() ([]byte, []int) {
+	return file_legacy_legacy_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Receipt) GetOrder() *Order {
+	if x != nil {
+		return x.Order
+	}
+	return nil
+}
+
+func (x *Receipt) GetGiftNote() string {
+	if x != nil && x.GiftNote != nil {
+		return *x.GiftNote
+	}
+	return ""
+}
+
+var file_legacy_legacy_proto_extTypes = []protoimpl.ExtensionInfo{
+	{
+		ExtendedType:  (*Order)(nil),
+		ExtensionType: (*string)(nil),
+		Field:         100,
+		Name:          "twitch.twirp.example.legacy.gift_note",
+		Tag:           "bytes,100,opt,name=gift_note",
+		Filename:      "legacy/legacy.proto",
+	},
+}
+
+// Extension fields to Order.
+var (
+	// optional string gift_note = 100;
+	E_GiftNote = &file_legacy_legacy_proto_extTypes[0]
+)
+
+var File_legacy_legacy_proto protoreflect.FileDescriptor
+
+var file_legacy_legacy_proto_rawDesc = []byte{
+	0x0a, 0x13, 0x6c, 0x65, 0x67, 0x61, 0x63, 0x79, 0x2f, 0x6c, 0x65, 0x67, 0x61, 0x63, 0x79, 0x2e,
+	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x1b, 0x74, 0x77, 0x69, 0x74, 0x63, 0x68, 0x2e, 0x74, 0x77,
+	0x69, 0x72, 0x70, 0x2e, 0x65, 0x78, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x2e, 0x6c, 0x65, 0x67, 0x61,
+	0x63, 0x79, 0x22, 0x1e, 0x0a, 0x05, 0x4f, 0x72, 0x64, 0x65, 0x72, 0x12, 0x0e, 0x0a, 0x02, 0x69,
+	0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x2a, 0x05, 0x08, 0x64, 0x10,
+	0xc8, 0x01, 0x22, 0x60, 0x0a, 0x07, 0x52, 0x65, 0x63, 0x65, 0x69, 0x70, 0x74, 0x12, 0x38, 0x0a,
+	0x05, 0x6f, 0x72, 0x64, 0x65, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x22, 0x2e, 0x74,
+	0x77, 0x69, 0x74, 0x63, 0x68, 0x2e, 0x74, 0x77, 0x69, 0x72, 0x70, 0x2e, 0x65, 0x78, 0x61, 0x6d,
+	0x70, 0x6c, 0x65, 0x2e, 0x6c, 0x65, 0x67, 0x61, 0x63, 0x79, 0x2e, 0x4f, 0x72, 0x64, 0x65, 0x72,
+	0x52, 0x05, 0x6f, 0x72, 0x64, 0x65, 0x72, 0x12, 0x1b, 0x0a, 0x09, 0x67, 0x69, 0x66, 0x74, 0x5f,
+	0x6e, 0x6f, 0x74, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x67, 0x69, 0x66, 0x74,
+	0x4e, 0x6f, 0x74, 0x65, 0x32, 0x60, 0x0a, 0x08, 0x52, 0x65, 0x67, 0x69, 0x73, 0x74, 0x65, 0x72,
+	0x12, 0x54, 0x0a, 0x08, 0x43, 0x68, 0x65, 0x63, 0x6b, 0x6f, 0x75, 0x74, 0x12, 0x22, 0x2e, 0x74,
+	0x77, 0x69, 0x74, 0x63, 0x68, 0x2e, 0x74, 0x77, 0x69, 0x72, 0x70, 0x2e, 0x65, 0x78, 0x61, 0x6d,
+	0x70, 0x6c, 0x65, 0x2e, 0x6c, 0x65, 0x67, 0x61, 0x63, 0x79, 0x2e, 0x4f, 0x72, 0x64, 0x65, 0x72,
+	0x1a, 0x24, 0x2e, 0x74, 0x77, 0x69, 0x74, 0x63, 0x68, 0x2e, 0x74, 0x77, 0x69, 0x72, 0x70, 0x2e,
+	0x65, 0x78, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x2e, 0x6c, 0x65, 0x67, 0x61, 0x63, 0x79, 0x2e, 0x52,
+	0x65, 0x63, 0x65, 0x69, 0x70, 0x74, 0x3a, 0x3f, 0x0a, 0x09, 0x67, 0x69, 0x66, 0x74, 0x5f, 0x6e,
+	0x6f, 0x74, 0x65, 0x12, 0x22, 0x2e, 0x74, 0x77, 0x69, 0x74, 0x63, 0x68, 0x2e, 0x74, 0x77, 0x69,
+	0x72, 0x70, 0x2e, 0x65, 0x78, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x2e, 0x6c, 0x65, 0x67, 0x61, 0x63,
+	0x79, 0x2e, 0x4f, 0x72, 0x64, 0x65, 0x72, 0x18, 0x64, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x67,
+	0x69, 0x66, 0x74, 0x4e, 0x6f, 0x74, 0x65, 0x42, 0x36, 0x5a, 0x34, 0x67, 0x69, 0x74, 0x68, 0x75,
+	0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x62, 0x61, 0x6b, 0x69, 0x6e, 0x73, 0x2f, 0x70, 0x72, 0x6f,
+	0x74, 0x6f, 0x63, 0x2d, 0x67, 0x65, 0x6e, 0x2d, 0x74, 0x77, 0x69, 0x72, 0x70, 0x2d, 0x67, 0x6f,
+	0x2f, 0x65, 0x78, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x2f, 0x6c, 0x65, 0x67, 0x61, 0x63, 0x79,
+}
+
+var (
+	file_legacy_legacy_proto_rawDescOnce sync.Once
+	file_legacy_legacy_proto_rawDescData = file_legacy_legacy_proto_rawDesc
+)
+
+func file_legacy_legacy_proto_rawDescGZIP() []byte {
+	file_legacy_legacy_proto_rawDescOnce.Do(func() {
+		file_legacy_legacy_proto_rawDescData = protoimpl.X.CompressGZIP(file_legacy_legacy_proto_rawDescData)
+	})
+	return file_legacy_legacy_proto_rawDescData
+}
+
+var file_legacy_legacy_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_legacy_legacy_proto_goTypes = []interface{}{
+	(*Order)(nil),   // 0: twitch.twirp.example.legacy.Order
+	(*Receipt)(nil), // 1: twitch.twirp.example.legacy.Receipt
+}
+var file_legacy_legacy_proto_depIdxs = []int32{
+	0, // 0: twitch.twirp.example.legacy.Receipt.order:type_name -> twitch.twirp.example.legacy.Order
+	0, // 1: twitch.twirp.example.legacy.gift_note:extendee -> twitch.twirp.example.legacy.Order
+	0, // 2: twitch.twirp.example.legacy.Register.Checkout:input_type -> twitch.twirp.example.legacy.Order
+	1, // 3: twitch.twirp.example.legacy.Register.Checkout:output_type -> twitch.twirp.example.legacy.Receipt
+	3, // [3:4] is the sub-list for method output_type
+	2, // [2:3] is the sub-list for method input_type
+	2, // [2:2] is the sub-list for extension type_name
+	1, // [1:2] is the sub-list for extension extendee
+	0, // [0:1] is the sub-list for field type_name
+}
+
+func init() { file_legacy_legacy_proto_init() }
+func file_legacy_legacy_proto_init() {
+	if File_legacy_legacy_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_legacy_legacy_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Order); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			case 3:
+				return &v.extensionFields
+			default:
+				return nil
+			}
+		}
+		file_legacy_legacy_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Receipt); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_legacy_legacy_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   2,
+			NumExtensions: 1,
+			NumServices:   1,
+		},
+		GoTypes:           file_legacy_legacy_proto_goTypes,
+		DependencyIndexes: file_legacy_legacy_proto_depIdxs,
+		MessageInfos:      file_legacy_legacy_proto_msgTypes,
+		ExtensionInfos:    file_legacy_legacy_proto_extTypes,
+	}.Build()
+	File_legacy_legacy_proto = out.File
+	file_legacy_legacy_proto_rawDesc = nil
+	file_legacy_legacy_proto_goTypes = nil
+	file_legacy_legacy_proto_depIdxs = nil
+}
@@ -0,0 +1,169 @@
+package legacy
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/twitchtv/twirp"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+type testRegister struct{}
+
+func (testRegister) Checkout(ctx context.Context, order *Order) (*Receipt, error) {
+	receipt := &Receipt{Order: order}
+	if proto.HasExtension(order, E_GiftNote) {
+		receipt.GiftNote = proto.String(proto.GetExtension(order, E_GiftNote).(string))
+	}
+	return receipt, nil
+}
+
+func TestExtensions(t *testing.T) {
+	svr := httptest.NewServer(NewRegisterTwirpServer(testRegister{}))
+	defer svr.Close()
+
+	// a field number in the extension range that no extension is registered for
+	unknown := protowire.AppendTag(nil, 150, protowire.BytesType)
+	unknown = protowire.AppendString(unknown, "wrapped")
+
+	for _, tt := range []struct {
+		name  string
+		codec TwirpCodec
+		// unknown extensions are kept by protobuf, but cannot be written as JSON
+		unknown []byte
+	}{
+		{"protobuf", DefaultTwirpCodecProtobuf, unknown},
+		{"json", DefaultTwirpCodecJson, nil},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewRegisterTwirpClient(svr.URL, http.DefaultTransport, WithTwirpClientCodec(tt.codec))
+			require.NoError(t, err)
+
+			order := &Order{Id: proto.String("1")}
+			proto.SetExtension(order, E_GiftNote, "happy birthday")
+			order.ProtoReflect().SetUnknown(unknown)
+
+			receipt, err := c.Checkout(context.Background(), order)
+			require.NoError(t, err)
+			require.Equal(t, "happy birthday", receipt.GetGiftNote())
+			require.Equal(t, "1", receipt.GetOrder().GetId())
+			require.Equal(t, "happy birthday", proto.GetExtension(receipt.GetOrder(), E_GiftNote))
+			require.True(t, bytes.Equal(tt.unknown, receipt.GetOrder().ProtoReflect().GetUnknown()))
+		})
+	}
+}
+
+type memoryStore struct {
+	mu     sync.Mutex
+	values map[string][]byte
+}
+
+func (m *memoryStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	value, ok := m.values[key]
+	return value, ok, nil
+}
+
+func (m *memoryStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.values[key] = value
+	return nil
+}
+
+// countingRegister counts checkouts, and blocks them until release is closed if it is set.
+type countingRegister struct {
+	mu      sync.Mutex
+	calls   int
+	started chan struct{}
+	release chan struct{}
+}
+
+func (r *countingRegister) Checkout(ctx context.Context, order *Order) (*Receipt, error) {
+	r.mu.Lock()
+	r.calls++
+	r.mu.Unlock()
+
+	if r.release != nil {
+		close(r.started)
+		<-r.release
+	}
+
+	if order.GetId() == "" {
+		return nil, twirp.RequiredArgumentError("id")
+	}
+	return &Receipt{Order: order}, nil
+}
+
+func (r *countingRegister) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.calls
+}
+
+func TestIdempotencyStore(t *testing.T) {
+	register := &countingRegister{}
+	store := &memoryStore{values: map[string][]byte{}}
+	svr := httptest.NewServer(NewRegisterTwirpServer(register, WithTwirpServerIdempotencyStore(store, time.Minute)))
+	defer svr.Close()
+
+	c, err := NewRegisterTwirpClient(svr.URL, http.DefaultTransport)
+	require.NoError(t, err)
+
+	withKey := func(key string) context.Context {
+		header := http.Header{}
+		header.Set(TwirpIdempotencyKeyHeader, key)
+		ctx, err := twirp.WithHTTPRequestHeaders(context.Background(), header)
+		require.NoError(t, err)
+		return ctx
+	}
+
+	// retries with the same key get the first receipt
+	for i := 0; i < 3; i++ {
+		receipt, err := c.Checkout(withKey("a"), &Order{Id: proto.String("1")})
+		require.NoError(t, err)
+		require.Equal(t, "1", receipt.GetOrder().GetId())
+	}
+	require.Equal(t, 1, register.count())
+
+	// other keys, and requests without a key, are not deduped
+	_, err = c.Checkout(withKey("b"), &Order{Id: proto.String("2")})
+	require.NoError(t, err)
+	_, err = c.Checkout(context.Background(), &Order{Id: proto.String("3")})
+	require.NoError(t, err)
+	_, err = c.Checkout(context.Background(), &Order{Id: proto.String("3")})
+	require.NoError(t, err)
+	require.Equal(t, 4, register.count())
+
+	// errors are not kept
+	for i := 0; i < 2; i++ {
+		_, err = c.Checkout(withKey("c"), &Order{})
+		require.Equal(t, twirp.InvalidArgument, err.(twirp.Error).Code())
+	}
+	require.Equal(t, 6, register.count())
+
+	// duplicates of a request in progress are rejected
+	register.started = make(chan struct{})
+	register.release = make(chan struct{})
+	errs := make(chan error, 1)
+	go func() {
+		_, err := c.Checkout(withKey("d"), &Order{Id: proto.String("4")})
+		errs <- err
+	}()
+	<-register.started
+
+	_, err = c.Checkout(withKey("d"), &Order{Id: proto.String("4")})
+	require.Equal(t, twirp.Aborted, err.(twirp.Error).Code())
+
+	close(register.release)
+	require.NoError(t, <-errs)
+	require.Equal(t, 7, register.count())
+}
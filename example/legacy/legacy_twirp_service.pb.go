@@ -0,0 +1,4397 @@
+// Code generated by protoc-gen-twirp-go DO NOT EDIT.
+package legacy
+
+import (
+	"bytes"
+	"compress/gzip"
+	"container/list"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"io/ioutil"
+	mathrand "math/rand"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/twitchtv/twirp"
+	"github.com/twitchtv/twirp/ctxsetters"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	jsoniter "github.com/json-iterator/go"
+)
+
+var jsonCodec = jsoniter.ConfigCompatibleWithStandardLibrary
+
+var twirpBufferPool = sync.Pool{
+	New: func() interface{} {
+		return &bytes.Buffer{}
+	},
+}
+
+type TwirpCodec interface {
+	ContentType() string
+	MarshalTo(context.Context, proto.Message, io.Writer) error
+	UnmarshalFrom(context.Context, proto.Message, io.Reader) error
+}
+
+// twirpMaxPrealloc limits how much memory is allocated up front for a body of a known size,
+// so that a large Content-Length cannot allocate memory before the body is sent.
+const twirpMaxPrealloc = 4 << 20
+
+// twirpSizedReader is a reader that knows how many bytes it will return, like bytes.Reader.
+type twirpSizedReader struct {
+	io.Reader
+	size int64
+}
+
+func (r *twirpSizedReader) Size() int64 {
+	return r.size
+}
+
+// twirpBodyReader returns r, as a reader with the given size if it is known. Codecs use the
+// size to grow their buffer once, instead of doubling it while the body is read.
+func twirpBodyReader(r io.Reader, size int64) io.Reader {
+	if size <= 0 {
+		return r
+	}
+
+	return &twirpSizedReader{Reader: r, size: size}
+}
+
+// twirpReadBody reads r into buff. If r has a Size method, like bytes.Reader and the bodies
+// passed to codecs by clients and servers, buff is grown to fit it before reading, up to
+// twirpMaxPrealloc bytes. Protobuf can only decode complete messages, so the body is still
+// read in full, but without the copies and the up to twice as large buffer of growing it.
+func twirpReadBody(buff *bytes.Buffer, r io.Reader) error {
+	if sized, ok := r.(interface{ Size() int64 }); ok {
+		size := sized.Size()
+		if size > twirpMaxPrealloc {
+			size = twirpMaxPrealloc
+		}
+
+		if size > 0 {
+			// bytes.Buffer needs MinRead spare bytes to read the final EOF without growing
+			buff.Grow(int(size) + bytes.MinRead)
+		}
+	}
+
+	_, err := io.Copy(buff, r)
+	return err
+}
+
+type TwirpCodecProtobuf struct {
+	proto.UnmarshalOptions
+	proto.MarshalOptions
+}
+
+var DefaultTwirpCodecProtobuf = &TwirpCodecProtobuf{}
+
+func (t *TwirpCodecProtobuf) ContentType() string {
+	return "application/protobuf"
+}
+
+func (t *TwirpCodecProtobuf) MarshalTo(_ context.Context, m proto.Message, w io.Writer) error {
+	data, err := t.MarshalOptions.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(data)
+	return err
+}
+
+func (t *TwirpCodecProtobuf) UnmarshalFrom(_ context.Context, m proto.Message, r io.Reader) error {
+	buff := twirpBufferPool.Get().(*bytes.Buffer)
+	defer twirpBufferPool.Put(buff)
+
+	buff.Reset()
+
+	if err := twirpReadBody(buff, r); err != nil {
+		return err
+	}
+
+	return t.UnmarshalOptions.Unmarshal(buff.Bytes(), m)
+}
+
+// TwirpMarshaler is a serialization format for messages, such as a custom binary format.
+// Use NewTwirpCodec to create a TwirpCodec from it.
+type TwirpMarshaler interface {
+	Marshal(proto.Message) ([]byte, error)
+	Unmarshal([]byte, proto.Message) error
+}
+
+// NewTwirpCodec returns a codec that uses marshaler to encode messages sent with contentType,
+// such as "application/x-legacy". Register it with WithTwirpServerCodec so that servers accept
+// requests with that Content-Type, and use it with WithTwirpClientCodec to send them.
+func NewTwirpCodec(contentType string, marshaler TwirpMarshaler) TwirpCodec {
+	return &twirpMarshalerCodec{contentType: contentType, marshaler: marshaler}
+}
+
+type twirpMarshalerCodec struct {
+	contentType string
+	marshaler   TwirpMarshaler
+}
+
+func (t *twirpMarshalerCodec) ContentType() string {
+	return t.contentType
+}
+
+func (t *twirpMarshalerCodec) MarshalTo(_ context.Context, m proto.Message, w io.Writer) error {
+	data, err := t.marshaler.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(data)
+	return err
+}
+
+func (t *twirpMarshalerCodec) UnmarshalFrom(_ context.Context, m proto.Message, r io.Reader) error {
+	buff := twirpBufferPool.Get().(*bytes.Buffer)
+	defer twirpBufferPool.Put(buff)
+
+	buff.Reset()
+
+	if err := twirpReadBody(buff, r); err != nil {
+		return err
+	}
+
+	return t.marshaler.Unmarshal(buff.Bytes(), m)
+}
+
+// twirpContentTypeCodec is a TwirpCodec that uses a different Content-Type than the codec it wraps.
+type twirpContentTypeCodec struct {
+	TwirpCodec
+	contentType string
+}
+
+func (t *twirpContentTypeCodec) ContentType() string {
+	return t.contentType
+}
+
+type TwirpCodecJson struct {
+	protojson.MarshalOptions
+	protojson.UnmarshalOptions
+}
+
+var DefaultTwirpCodecJson = &TwirpCodecJson{
+	MarshalOptions: protojson.MarshalOptions{
+		UseProtoNames:   true,
+		EmitUnpopulated: true,
+	},
+}
+
+func (t *TwirpCodecJson) ContentType() string {
+	return "application/json"
+}
+
+func (t *TwirpCodecJson) MarshalTo(_ context.Context, m proto.Message, w io.Writer) error {
+	data, err := t.MarshalOptions.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(data)
+	return err
+}
+
+// UnmarshalFrom reads r into a pooled buffer before decoding it. protojson does not expose
+// its decoder, so the decoder itself cannot be reused between requests.
+func (t *TwirpCodecJson) UnmarshalFrom(_ context.Context, m proto.Message, r io.Reader) error {
+	buff := twirpBufferPool.Get().(*bytes.Buffer)
+	defer twirpBufferPool.Put(buff)
+
+	buff.Reset()
+
+	if err := twirpReadBody(buff, r); err != nil {
+		return err
+	}
+
+	return t.UnmarshalOptions.Unmarshal(buff.Bytes(), m)
+}
+
+// TwirpCodecPrototext encodes messages in the protobuf text format, which is easier to read and
+// write by hand than JSON for some messages, but is not stable: its output may change between
+// versions of google.golang.org/protobuf. Servers only accept it when it is added with
+// WithTwirpServerCodec.
+type TwirpCodecPrototext struct {
+	prototext.MarshalOptions
+	prototext.UnmarshalOptions
+}
+
+var DefaultTwirpCodecPrototext = &TwirpCodecPrototext{}
+
+func (t *TwirpCodecPrototext) ContentType() string {
+	return "application/protobuf-text"
+}
+
+func (t *TwirpCodecPrototext) MarshalTo(_ context.Context, m proto.Message, w io.Writer) error {
+	data, err := t.MarshalOptions.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(data)
+	return err
+}
+
+func (t *TwirpCodecPrototext) UnmarshalFrom(_ context.Context, m proto.Message, r io.Reader) error {
+	buff := twirpBufferPool.Get().(*bytes.Buffer)
+	defer twirpBufferPool.Put(buff)
+
+	buff.Reset()
+
+	if err := twirpReadBody(buff, r); err != nil {
+		return err
+	}
+
+	return t.UnmarshalOptions.Unmarshal(buff.Bytes(), m)
+}
+
+type TwirpServerOptions struct {
+	codecs               map[string]TwirpCodec
+	enforceDeadline      bool
+	bodyDumper           TwirpBodyDumper
+	sampler              func(string) bool
+	requestIDHeader      string
+	errorEncoder         func(twirp.Error) []byte
+	requestValidator     func(context.Context, string, proto.Message) error
+	responseTransformer  func(context.Context, string, proto.Message) (proto.Message, error)
+	rawBodyValidator     func(context.Context, string, []byte) error
+	schemaMismatch       func(context.Context, string, string) error
+	unknownMethod        func(http.ResponseWriter, *http.Request, string)
+	peerCertificateCheck func(context.Context, string, *x509.Certificate) error
+	cors                 *TwirpCORSConfig
+	fieldMask            bool
+	timeoutHeader        string
+	requireContentType   bool
+	defaultContentType   string
+	gzip                 bool
+	compressionThreshold int
+	httpErrorHandler     func(http.ResponseWriter, *http.Request, twirp.Error)
+	errorEnricher        func(context.Context, twirp.Error) twirp.Error
+	retryAfter           func(context.Context, twirp.Error) time.Duration
+	methodEnabled        func(string) bool
+	methodConcurrency    map[string]int
+	singleflight         bool
+	idempotency          *twirpIdempotency
+	methodTimeouts       map[string]time.Duration
+	defaultTimeout       time.Duration
+	maxHeaderBytes       int
+	maxResponseBytes     int64
+	auditSink            func(context.Context, TwirpAuditEntry)
+	afterResponse        func(context.Context, string, error)
+	trailers             bool
+	headerAllowlist      map[string]func(string) (string, error)
+	routeTemplate        string
+	tenant               *TwirpTenantConfig
+	hooks                []*twirp.ServerHooks
+}
+
+type TwirpServerOption func(*TwirpServerOptions)
+
+// WithTwirpServerCodec adds codec for requests sent with its content type. Responses are encoded
+// with the codec of the first content type in the Accept header of the request that the server
+// has a codec for, or with the codec of the request if there is none.
+func WithTwirpServerCodec(codec TwirpCodec) TwirpServerOption {
+	return func(o *TwirpServerOptions) {
+		o.codecs[codec.ContentType()] = codec
+	}
+}
+
+// WithTwirpServerEnforceDeadline makes the server respond with twirp.DeadlineExceeded
+// as soon as the request context deadline passes, rather than waiting for the handler
+// to return. The handler keeps running in its own goroutine until it returns, so a
+// handler that ignores its context will continue to use resources after the
+// response has been written.
+func WithTwirpServerEnforceDeadline() TwirpServerOption {
+	return func(o *TwirpServerOptions) {
+		o.enforceDeadline = true
+	}
+}
+
+// WithTwirpServerBodyDumper sets a function that is called with the raw request and response
+// bodies. It is intended for debugging only: bodies may contain sensitive data.
+func WithTwirpServerBodyDumper(dumper TwirpBodyDumper) TwirpServerOption {
+	return func(o *TwirpServerOptions) {
+		o.bodyDumper = dumper
+	}
+}
+
+// WithTwirpServerRequestSampler sets a function that picks the requests to log in detail, such as
+// 1% of them. It is called once per request after routing, with the method name, such as "MakeHat",
+// and the decision is kept in the request context, where TwirpSampled reports it to hooks and
+// handlers. Only sampled requests are passed to the body dumper of WithTwirpServerBodyDumper, and
+// the generated slog logger skips the other requests unless they fail. Custom loggers should check
+// TwirpSampled themselves. Without a sampler, every request is passed to the body dumper and the
+// slog logger, and TwirpSampled reports false.
+func WithTwirpServerRequestSampler(sampler func(method string) bool) TwirpServerOption {
+	return func(o *TwirpServerOptions) {
+		o.sampler = sampler
+	}
+}
+
+type twirpSampledKey struct{}
+
+// TwirpSampled reports whether the request in ctx was picked by the sampler of
+// WithTwirpServerRequestSampler, for server hooks and handlers that add detailed logging.
+func TwirpSampled(ctx context.Context) bool {
+	sampled, _ := ctx.Value(twirpSampledKey{}).(bool)
+	return sampled
+}
+
+// twirpLogDetail reports whether the request in ctx is logged in detail, by the body dumper and the
+// generated loggers: always, unless the server has a sampler that did not pick it.
+func twirpLogDetail(ctx context.Context) bool {
+	sampled, ok := ctx.Value(twirpSampledKey{}).(bool)
+	return !ok || sampled
+}
+
+// TwirpRequestIDHeader is the default header used by WithTwirpServerRequestID.
+const TwirpRequestIDHeader = "X-Request-Id"
+
+// WithTwirpServerRequestID assigns a request ID to every request. The ID is read from the
+// given request header, or TwirpRequestIDHeader if header is empty, and a random ID is
+// generated when the header is missing. The ID is written to the same response header and
+// is available to handlers with TwirpRequestID.
+//
+// The ID is also added to the context using twirp.WithHTTPRequestHeaders, so Twirp clients
+// called with the handler's context forward it to downstream services.
+func WithTwirpServerRequestID(header string) TwirpServerOption {
+	return func(o *TwirpServerOptions) {
+		if header == "" {
+			header = TwirpRequestIDHeader
+		}
+		o.requestIDHeader = http.CanonicalHeaderKey(header)
+	}
+}
+
+// WithTwirpServerLegacyErrorFormat sets a function that encodes the JSON body of error
+// responses, replacing the standard Twirp {"code": ..., "msg": ...} body. The status code and
+// Content-Type are unchanged, as are successful responses. It is intended for migrating
+// legacy clients that expect a different error format. It breaks standard Twirp clients,
+// including the ones generated here: they cannot parse the custom body, so they treat the
+// error as coming from an intermediary and guess the code from the HTTP status.
+func WithTwirpServerLegacyErrorFormat(encode func(twirp.Error) []byte) TwirpServerOption {
+	return func(o *TwirpServerOptions) {
+		o.errorEncoder = encode
+	}
+}
+
+// WithTwirpServerHTTPErrorHandler sets a function that writes error responses in place of the
+// server, for example as RFC 7807 application/problem+json bodies. It is called with the request
+// and the error, and must write the status code and body itself. Successful responses are
+// unchanged. Like WithTwirpServerLegacyErrorFormat, which it replaces, it breaks standard Twirp
+// clients unless the handler writes Twirp errors.
+func WithTwirpServerHTTPErrorHandler(handler func(w http.ResponseWriter, r *http.Request, err twirp.Error)) TwirpServerOption {
+	return func(o *TwirpServerOptions) {
+		o.httpErrorHandler = handler
+	}
+}
+
+// twirpStatusRecorder records the status code written to a http.ResponseWriter.
+type twirpStatusRecorder struct {
+	http.ResponseWriter
+	statusCode int
+}
+
+func (w *twirpStatusRecorder) WriteHeader(statusCode int) {
+	w.statusCode = statusCode
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *twirpStatusRecorder) Write(b []byte) (int, error) {
+	if w.statusCode == 0 {
+		w.statusCode = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// twirpHandleError calls the hooks for err like twirpWriteError, but has handler write the response.
+func twirpHandleError(ctx context.Context, resp http.ResponseWriter, req *http.Request, err error, hooks *twirp.ServerHooks, handler func(http.ResponseWriter, *http.Request, twirp.Error)) {
+	twerr, ok := err.(twirp.Error)
+	if !ok {
+		twerr = twirp.InternalErrorWith(err)
+	}
+
+	ctx = ctxsetters.WithStatusCode(ctx, twirp.ServerHTTPStatusFromErrorCode(twerr.Code()))
+	ctx = twirpCallError(ctx, hooks, twerr)
+
+	w := &twirpStatusRecorder{ResponseWriter: resp}
+	handler(w, req.WithContext(ctx), twerr)
+
+	if w.statusCode != 0 {
+		ctx = ctxsetters.WithStatusCode(ctx, w.statusCode)
+	}
+
+	twirpCallResponseSent(ctx, hooks)
+}
+
+// WithTwirpServerErrorMetadataEnricher sets a function that is called with every error the server
+// sends, including errors from routing, decoding and recovered panics, before it is passed to error
+// hooks and written. It returns the error to send, usually err with metadata added with WithMeta,
+// such as the service and method names from twirp.ServiceName and twirp.MethodName, which are set
+// in ctx for routed requests. Metadata it sets replaces metadata of the same key, such as "cause".
+func WithTwirpServerErrorMetadataEnricher(enricher func(ctx context.Context, err twirp.Error) twirp.Error) TwirpServerOption {
+	return func(o *TwirpServerOptions) {
+		o.errorEnricher = enricher
+	}
+}
+
+// WithTwirpServerRetryAfter sets a function that is called with every twirp.ResourceExhausted error
+// the server sends, such as from WithTwirpServerMethodConcurrency, to tell the client how long to
+// wait before trying again. When it returns more than zero, the response has a Retry-After header
+// with the duration as a number of seconds, rounded up. Generated clients add it to the error as
+// the "retry_after" metadata, see TwirpRetryAfter.
+func WithTwirpServerRetryAfter(retryAfter func(ctx context.Context, err twirp.Error) time.Duration) TwirpServerOption {
+	return func(o *TwirpServerOptions) {
+		o.retryAfter = retryAfter
+	}
+}
+
+// TwirpRetryAfter returns how long the server asked the client to wait before retrying the call that
+// failed with err, from the Retry-After header of a twirp.ResourceExhausted or twirp.Unavailable
+// response. It returns false if the server did not say.
+func TwirpRetryAfter(err error) (time.Duration, bool) {
+	var twerr twirp.Error
+	if !errors.As(err, &twerr) {
+		return 0, false
+	}
+
+	seconds, convErr := strconv.Atoi(twerr.Meta("retry_after"))
+	if convErr != nil || seconds < 0 {
+		return 0, false
+	}
+
+	return time.Duration(seconds) * time.Second, true
+}
+
+// twirpParseRetryAfter returns the number of seconds to wait in a Retry-After header, which is a
+// number of seconds or an HTTP date, or false if there is no valid header.
+func twirpParseRetryAfter(header string) (int, bool) {
+	if header == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(header); err == nil {
+		return seconds, seconds >= 0
+	}
+
+	date, err := http.ParseTime(header)
+	if err != nil {
+		return 0, false
+	}
+
+	wait := time.Until(date)
+	if wait < 0 {
+		return 0, true
+	}
+
+	return int((wait + time.Second - 1) / time.Second), true
+}
+
+// WithTwirpServerMethodEnabled sets a function that is called with the method name of every
+// routed request, such as "MakeHat". Requests to methods it returns false for fail with a
+// twirp.Unavailable error without calling the handler, so methods can be disabled at runtime,
+// for example from a feature flag during an incident. It runs on every request, so it must be
+// cheap and must not block. All methods are enabled if it is not set.
+func WithTwirpServerMethodEnabled(enabled func(method string) bool) TwirpServerOption {
+	return func(o *TwirpServerOptions) {
+		o.methodEnabled = enabled
+	}
+}
+
+// WithTwirpServerMaxHeaderBytes rejects requests whose headers, counted as in the HTTP/1.1 wire
+// format, are larger than n bytes with a twirp.Malformed error. It protects servers embedded in an
+// http.Server whose MaxHeaderBytes is not under our control; the headers have already been read
+// when it runs, so it limits what reaches the handler rather than what is read from the network.
+// Zero or less means no limit, which is the default.
+func WithTwirpServerMaxHeaderBytes(n int) TwirpServerOption {
+	return func(o *TwirpServerOptions) {
+		o.maxHeaderBytes = n
+	}
+}
+
+// WithTwirpServerMaxResponseBytes fails calls whose response, once encoded and before compression, is
+// larger than n bytes with a twirp.Internal error, which reaches the server hooks and so the logs, instead
+// of sending it. It protects clients and egress from handlers that return enormous responses by mistake.
+// Responses are always marshalled into a buffer, so their size is known before anything is sent, but the
+// memory for the response has already been used when it is checked. Server-sent events are not limited.
+// Zero or less means no limit, which is the default.
+func WithTwirpServerMaxResponseBytes(n int64) TwirpServerOption {
+	return func(o *TwirpServerOptions) {
+		o.maxResponseBytes = n
+	}
+}
+
+// WithTwirpServerRouteTemplate serves methods at the paths made from tmpl instead of the Twirp
+// paths, such as "/api/{service}/{method}" for a gateway with its own routing scheme. The template
+// must start with "/" and end with "{method}"; "{package}" and "{service}" are replaced with the
+// proto package and service name, and "{version}", which versioned services must use, with each
+// of their versions. The path prefix of twirp.WithServerPathPrefix is not used. Creating a server
+// with an invalid template panics.
+func WithTwirpServerRouteTemplate(tmpl string) TwirpServerOption {
+	return func(o *TwirpServerOptions) {
+		o.routeTemplate = tmpl
+	}
+}
+
+// TwirpAuditEntry records a call of a method with the (twirpgo.auditable) option.
+type TwirpAuditEntry struct {
+	// Service is the full name of the service, such as "twitch.twirp.example.Haberdasher".
+	Service string
+	// Method is the name of the method, such as "MakeHat".
+	Method string
+	// Time is when the server started handling the call.
+	Time time.Time
+	// Request is the body of the request, encoded as sent by the client. It is nil if the call
+	// failed before the body was read, and for calls made with Invoke.
+	Request []byte
+	// Response is the body of the response before compression. It is nil if the call failed, and
+	// for calls made with Invoke.
+	Response []byte
+	// RequestMessage is the decoded request, or nil if the call failed before it was decoded.
+	RequestMessage proto.Message
+	// ResponseMessage is the response returned by the handler, or nil if the call failed.
+	ResponseMessage proto.Message
+	// Error is the error returned to the client, or nil if the call succeeded.
+	Error twirp.Error
+}
+
+// WithTwirpServerAuditSink calls sink with an entry for every call of a method with the
+// (twirpgo.auditable) option, after its response has been sent, including calls that fail. Calls
+// made with Invoke are passed to sink when the method returns, with nil Request and Response. sink
+// is called on the goroutine handling the request, so it must not block: sinks that write to a store
+// should queue entries and write them from another goroutine. The entry is owned by sink.
+func WithTwirpServerAuditSink(sink func(ctx context.Context, entry TwirpAuditEntry)) TwirpServerOption {
+	return func(o *TwirpServerOptions) {
+		o.auditSink = sink
+	}
+}
+
+type twirpAuditKey struct{}
+
+// twirpAuditHooks records the error of audited calls, and passes their entry to sink once the
+// response has been sent.
+func twirpAuditHooks(sink func(context.Context, TwirpAuditEntry)) *twirp.ServerHooks {
+	return &twirp.ServerHooks{
+		Error: func(ctx context.Context, err twirp.Error) context.Context {
+			if entry, ok := ctx.Value(twirpAuditKey{}).(*TwirpAuditEntry); ok {
+				entry.Error = err
+			}
+			return ctx
+		},
+		ResponseSent: func(ctx context.Context) {
+			if entry, ok := ctx.Value(twirpAuditKey{}).(*TwirpAuditEntry); ok {
+				sink(ctx, *entry)
+			}
+		},
+	}
+}
+
+// WithTwirpServerAfterResponse calls fn once ServeHTTP has finished writing the response and flushed
+// it, for every request, including those that fail before reaching a method, for example to release
+// resources the call used. method is the name of the method called, or "" if the request was not
+// for one, and err is the error sent to the client, or nil. fn is also called when the client
+// disconnects while the response is written, in which case err is nil if the method succeeded.
+// ctx is the context of the request and may be done. fn is called on the goroutine handling the
+// request, before ServeHTTP returns.
+func WithTwirpServerAfterResponse(fn func(ctx context.Context, method string, err error)) TwirpServerOption {
+	return func(o *TwirpServerOptions) {
+		o.afterResponse = fn
+	}
+}
+
+type twirpAfterResponseKey struct{}
+
+// twirpAfterResponseHooks records the error of the call for the function set with
+// WithTwirpServerAfterResponse.
+var twirpAfterResponseHooks = &twirp.ServerHooks{
+	Error: func(ctx context.Context, err twirp.Error) context.Context {
+		if twerr, ok := ctx.Value(twirpAfterResponseKey{}).(*twirp.Error); ok {
+			*twerr = err
+		}
+		return ctx
+	},
+}
+
+// WithTwirpServerTrailers lets handlers, interceptors and server hooks send HTTP trailers with the
+// response, with TwirpSetTrailer, for values only known once the response has been produced, such as
+// the cost of the call. Responses are sent without a Content-Length so that HTTP/1.1 uses chunked
+// encoding, which trailers require. Error responses keep their Content-Length, so their trailers are
+// only sent over HTTP/2. Many proxies drop trailers, so clients should not depend on them.
+func WithTwirpServerTrailers() TwirpServerOption {
+	return func(o *TwirpServerOptions) {
+		o.trailers = true
+	}
+}
+
+type twirpTrailerKey struct{}
+
+// twirpTrailer holds the trailers set for a response. Handlers may set them from other goroutines.
+type twirpTrailer struct {
+	mu     sync.Mutex
+	header http.Header
+}
+
+// TwirpSetTrailer sets the HTTP trailer key to value for the response of the call in ctx. Trailers
+// set until the server hooks called once the response is sent return are sent. It fails with
+// twirp.Internal if the server was not created with WithTwirpServerTrailers.
+func TwirpSetTrailer(ctx context.Context, key string, value string) error {
+	trailer, ok := ctx.Value(twirpTrailerKey{}).(*twirpTrailer)
+	if !ok {
+		return twirp.InternalError("trailers are not enabled, see WithTwirpServerTrailers")
+	}
+
+	trailer.mu.Lock()
+	defer trailer.mu.Unlock()
+
+	trailer.header.Set(key, value)
+	return nil
+}
+
+// write adds the trailers to the header of resp, which sends them after the body.
+func (t *twirpTrailer) write(resp http.ResponseWriter) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for key, values := range t.header {
+		resp.Header()[http.TrailerPrefix+key] = values
+	}
+}
+
+// WithTwirpServerRequestHeaderAllowlist makes the request headers named by the keys of allowlist
+// available to handlers with TwirpRequestHeader. Names are matched case-insensitively, and when a
+// header is sent more than once, only its first value is used. Each value is passed to the function
+// for its name, if it is not nil, which returns the normalized value, or an error to reject the
+// request with twirp.InvalidArgument. Headers that are not sent are not passed to it. Headers not
+// in allowlist are ignored. Later calls replace earlier ones.
+func WithTwirpServerRequestHeaderAllowlist(allowlist map[string]func(value string) (string, error)) TwirpServerOption {
+	headers := make(map[string]func(string) (string, error), len(allowlist))
+	for name, normalize := range allowlist {
+		headers[http.CanonicalHeaderKey(name)] = normalize
+	}
+
+	return func(o *TwirpServerOptions) {
+		o.headerAllowlist = headers
+	}
+}
+
+type twirpHeadersKey struct{}
+
+// TwirpRequestHeader returns the value of the request header name, as normalized by the function
+// set for it with WithTwirpServerRequestHeaderAllowlist. It returns false if the header was not
+// sent or is not in the allowlist. name is case-insensitive.
+func TwirpRequestHeader(ctx context.Context, name string) (string, bool) {
+	headers, _ := ctx.Value(twirpHeadersKey{}).(map[string]string)
+	value, ok := headers[http.CanonicalHeaderKey(name)]
+	return value, ok
+}
+
+// twirpAllowedHeaders returns the first value of each header in allowlist, normalized.
+func twirpAllowedHeaders(allowlist map[string]func(string) (string, error), header http.Header) (map[string]string, error) {
+	values := make(map[string]string, len(allowlist))
+	for name, normalize := range allowlist {
+		vv, ok := header[name]
+		if !ok || len(vv) == 0 {
+			continue
+		}
+
+		value := vv[0]
+		if normalize != nil {
+			var err error
+			value, err = normalize(value)
+			if err != nil {
+				return nil, twirp.InvalidArgumentError(name, err.Error())
+			}
+		}
+		values[name] = value
+	}
+	return values, nil
+}
+
+// TwirpTenantConfig configures where servers created with WithTwirpServerTenantExtractor find
+// the tenant of a request.
+type TwirpTenantConfig struct {
+	// Header is the request header holding the tenant, such as "X-Tenant".
+	Header string
+	// PathSegment, if positive, is the position, counting from 1, of the path segment holding the
+	// tenant, such as 1 for "/acme/twirp/<package>.<Service>/<Method>". The segment is removed from
+	// the path before the request is routed.
+	PathSegment int
+	// Required rejects requests without a tenant with twirp.InvalidArgument.
+	Required bool
+}
+
+// WithTwirpServerTenantExtractor makes the tenant of each request, taken from the header or path
+// segment set in config, available to handlers with TwirpTenant. When both are set and a request
+// has both, they must be equal. A request with a tenant path segment must be sent to the server
+// itself, since handlers that route by path prefix, such as NewTwirpCombinedHandler, see the
+// path with the segment.
+func WithTwirpServerTenantExtractor(config TwirpTenantConfig) TwirpServerOption {
+	return func(o *TwirpServerOptions) {
+		o.tenant = &config
+	}
+}
+
+type twirpTenantKey struct{}
+
+// TwirpTenant returns the tenant of the request, found as configured with
+// WithTwirpServerTenantExtractor. It returns false if the request has no tenant.
+func TwirpTenant(ctx context.Context) (string, bool) {
+	tenant, ok := ctx.Value(twirpTenantKey{}).(string)
+	return tenant, ok
+}
+
+// twirpTenant returns the tenant of req, which is empty if there is none, and req with the path
+// segment of the tenant removed.
+func twirpTenant(config *TwirpTenantConfig, req *http.Request) (string, *http.Request, twirp.Error) {
+	var tenant string
+	if config.PathSegment > 0 {
+		// the path starts with "/", so the first element is empty
+		segments := strings.SplitN(req.URL.Path, "/", config.PathSegment+2)
+		if len(segments) == config.PathSegment+2 {
+			tenant = segments[config.PathSegment]
+
+			u := *req.URL
+			u.Path = strings.Join(segments[:config.PathSegment], "/") + "/" + segments[config.PathSegment+1]
+			u.RawPath = ""
+
+			r := new(http.Request)
+			*r = *req
+			r.URL = &u
+			req = r
+		}
+	}
+
+	if config.Header != "" {
+		if value := req.Header.Get(config.Header); value != "" {
+			if tenant != "" && tenant != value {
+				return "", req, twirp.InvalidArgumentError("tenant", "the header and path of the request have different tenants")
+			}
+			tenant = value
+		}
+	}
+
+	if tenant == "" && config.Required {
+		return "", req, twirp.RequiredArgumentError("tenant")
+	}
+
+	return tenant, req, nil
+}
+
+// twirpHeaderSize returns the size of header as sent in HTTP/1.1, with a ": " separator and a
+// CRLF for each value.
+func twirpHeaderSize(header http.Header) int {
+	size := 0
+	for k, vv := range header {
+		for _, v := range vv {
+			size += len(k) + len(v) + 4
+		}
+	}
+	return size
+}
+
+// TwirpObserver is notified when calls start and end, so that tracing, such as OpenTelemetry
+// spans, can be added without the generated code depending on a tracing library. method is the
+// full name of the method, such as "twitch.twirp.example.Haberdasher/MakeHat".
+type TwirpObserver interface {
+	// StartRPC is called when a call starts and returns the context used for the rest of the call,
+	// which is then passed to EndRPC.
+	StartRPC(ctx context.Context, method string) context.Context
+	// EndRPC is called when a call ends with the error of the call, or nil if it succeeded.
+	EndRPC(ctx context.Context, method string, err twirp.Error)
+}
+
+type twirpObserverKey struct{}
+
+type twirpObserverState struct {
+	method string
+	err    twirp.Error
+}
+
+// twirpObserverMethod returns the full name of the method in ctx.
+func twirpObserverMethod(ctx context.Context) string {
+	pkg, _ := twirp.PackageName(ctx)
+	service, _ := twirp.ServiceName(ctx)
+	method, _ := twirp.MethodName(ctx)
+	if pkg != "" {
+		service = pkg + "." + service
+	}
+	return service + "/" + method
+}
+
+// WithTwirpServerObserver notifies observer when each routed request starts and when its
+// response has been sent.
+func WithTwirpServerObserver(observer TwirpObserver) TwirpServerOption {
+	hooks := &twirp.ServerHooks{
+		RequestRouted: func(ctx context.Context) (context.Context, error) {
+			method := twirpObserverMethod(ctx)
+			ctx = observer.StartRPC(ctx, method)
+			return context.WithValue(ctx, twirpObserverKey{}, &twirpObserverState{method: method}), nil
+		},
+		Error: func(ctx context.Context, err twirp.Error) context.Context {
+			if state, ok := ctx.Value(twirpObserverKey{}).(*twirpObserverState); ok {
+				state.err = err
+			}
+			return ctx
+		},
+		ResponseSent: func(ctx context.Context) {
+			if state, ok := ctx.Value(twirpObserverKey{}).(*twirpObserverState); ok {
+				observer.EndRPC(ctx, state.method, state.err)
+			}
+		},
+	}
+
+	return func(o *TwirpServerOptions) {
+		o.hooks = append(o.hooks, hooks)
+	}
+}
+
+// WithTwirpServerRequestValidator sets a function that is called with every decoded request
+// before it is passed to interceptors and the handler. method is the name of the RPC method and
+// req is the concrete request message, so validators may use a type assertion or switch.
+//
+// If the validator returns a twirp.Error, it is returned to the client unchanged. Any other
+// error is returned as a twirp.InvalidArgument error with the error text as its message.
+func WithTwirpServerRequestValidator(validator func(ctx context.Context, method string, req proto.Message) error) TwirpServerOption {
+	return func(o *TwirpServerOptions) {
+		o.requestValidator = validator
+	}
+}
+
+// WithTwirpServerResponseTransformer sets a function that is called with the response of every
+// successful call before it is marshalled, such as to set a field on every response or to clear
+// internal fields. method is the name of the RPC method and resp is the concrete response message,
+// which the transformer may modify or replace; the message it returns is sent. It only runs for
+// HTTP requests, including those made through Facade, and not for Invoke or server streaming methods.
+//
+// If the transformer returns a twirp.Error, it is returned to the client unchanged. Any other
+// error is returned as a twirp.Internal error with the error text as its message.
+func WithTwirpServerResponseTransformer(transformer func(ctx context.Context, method string, resp proto.Message) (proto.Message, error)) TwirpServerOption {
+	return func(o *TwirpServerOptions) {
+		o.responseTransformer = transformer
+	}
+}
+
+// WithTwirpServerRawBodyValidator sets a function that is called with the body of every routed
+// request, exactly as it was received, before it is decoded, such as to verify an HMAC signature
+// of the payload sent in a header. method is the name of the RPC method. The body is read once,
+// and the same bytes are then decoded. Servers do not decompress request bodies, so a body sent
+// with a Content-Encoding is passed as it was sent. It runs after the body dumper, and before the
+// request validator, which gets the decoded message.
+//
+// If the validator returns a twirp.Error, it is returned to the client unchanged. Any other
+// error is returned as a twirp.Unauthenticated error with the error text as its message.
+func WithTwirpServerRawBodyValidator(validator func(ctx context.Context, method string, raw []byte) error) TwirpServerOption {
+	return func(o *TwirpServerOptions) {
+		o.rawBodyValidator = validator
+	}
+}
+
+// twirpValidateRawBody reads all of r, passes it to validator, and returns a reader for the same bytes.
+func twirpValidateRawBody(ctx context.Context, validator func(context.Context, string, []byte) error, method string, r io.Reader) (io.Reader, twirp.Error) {
+	buff := &bytes.Buffer{}
+	if err := twirpReadBody(buff, r); err != nil {
+		twerr := twirp.NewError(twirp.Malformed, "the request could not be decoded")
+		return nil, twerr.WithMeta("cause", err.Error())
+	}
+
+	if err := validator(ctx, method, buff.Bytes()); err != nil {
+		var twerr twirp.Error
+		if errors.As(err, &twerr) {
+			return nil, twerr
+		}
+		return nil, twirp.WrapError(twirp.NewError(twirp.Unauthenticated, err.Error()), err)
+	}
+
+	return bytes.NewReader(buff.Bytes()), nil
+}
+
+// TwirpCORSConfig configures the CORS headers written by servers created with WithTwirpServerCORS.
+type TwirpCORSConfig struct {
+	// AllowedOrigins lists the origins, such as "https://example.com", allowed to call the server.
+	// "*" allows any origin.
+	AllowedOrigins []string
+	// AllowedHeaders lists the request headers allowed in addition to Content-Type.
+	AllowedHeaders []string
+	// ExposedHeaders lists the response headers that browsers expose to callers.
+	ExposedHeaders []string
+	// AllowCredentials allows requests with cookies or other credentials. The allowed origin is
+	// then always written explicitly, even if AllowedOrigins contains "*".
+	AllowCredentials bool
+	// MaxAge is how long browsers may cache the result of a preflight request. Zero leaves it
+	// to the browser.
+	MaxAge time.Duration
+}
+
+// WithTwirpServerCORS makes the server answer CORS preflight (OPTIONS) requests and add CORS
+// headers to responses for requests from allowed origins, so browsers can call the server
+// directly. HEAD requests get a 405 Method Not Allowed response without a body. POST requests
+// are handled as before.
+func WithTwirpServerCORS(config TwirpCORSConfig) TwirpServerOption {
+	return func(o *TwirpServerOptions) {
+		o.cors = &config
+	}
+}
+
+// twirpCORS writes CORS headers for req and reports whether the request was fully handled.
+func twirpCORS(config *TwirpCORSConfig, resp http.ResponseWriter, req *http.Request, routed bool) bool {
+	header := resp.Header()
+
+	if origin := req.Header.Get("Origin"); origin != "" {
+		header.Add("Vary", "Origin")
+
+		allowed := ""
+		for _, o := range config.AllowedOrigins {
+			if o == origin || o == "*" {
+				allowed = o
+				break
+			}
+		}
+
+		if allowed != "" {
+			if allowed == "*" && config.AllowCredentials {
+				allowed = origin
+			}
+			header.Set("Access-Control-Allow-Origin", allowed)
+
+			if config.AllowCredentials {
+				header.Set("Access-Control-Allow-Credentials", "true")
+			}
+
+			if len(config.ExposedHeaders) > 0 {
+				header.Set("Access-Control-Expose-Headers", strings.Join(config.ExposedHeaders, ", "))
+			}
+
+			if req.Method == http.MethodOptions {
+				header.Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+				header.Set("Access-Control-Allow-Headers", strings.Join(append([]string{"Content-Type"}, config.AllowedHeaders...), ", "))
+				if config.MaxAge > 0 {
+					header.Set("Access-Control-Max-Age", strconv.Itoa(int(config.MaxAge.Seconds())))
+				}
+			}
+		}
+	}
+
+	if !routed {
+		return false
+	}
+
+	switch req.Method {
+	case http.MethodOptions:
+		resp.WriteHeader(http.StatusNoContent)
+		return true
+	case http.MethodHead:
+		header.Set("Allow", "POST, OPTIONS")
+		resp.WriteHeader(http.StatusMethodNotAllowed)
+		return true
+	}
+
+	return false
+}
+
+// WithTwirpServerUnknownMethodHandler sets a function that writes the response to POST requests to
+// a path prefix of the server with a method the service does not have, such as a removed method,
+// instead of the standard twirp.BadRoute error, for example to tell clients what replaced it.
+// method is the name from the path, such as "MakeHat". Requests to other paths still fail with
+// twirp.BadRoute. handler writes the whole response, so the Error and ResponseSent server hooks are
+// not called for these requests.
+func WithTwirpServerUnknownMethodHandler(handler func(w http.ResponseWriter, r *http.Request, method string)) TwirpServerOption {
+	return func(o *TwirpServerOptions) {
+		o.unknownMethod = handler
+	}
+}
+
+// twirpUnknownMethod returns the method name of path, if it is a method of a service with one of
+// pathPrefixes.
+func twirpUnknownMethod(pathPrefixes []string, path string) (string, bool) {
+	for _, pathPrefix := range pathPrefixes {
+		method := strings.TrimPrefix(path, pathPrefix)
+		if method != path && method != "" && !strings.Contains(method, "/") {
+			return method, true
+		}
+	}
+	return "", false
+}
+
+// TwirpSchemaFingerprintHeader is the request header in which clients send the schema fingerprint
+// of their service, such as HaberdasherTwirpSchemaFingerprint.
+const TwirpSchemaFingerprintHeader = "Twirp-Schema-Fingerprint"
+
+// WithTwirpServerSchemaMismatchHandler sets a function that is called when a request has a
+// schema fingerprint in the TwirpSchemaFingerprintHeader that differs from the server's, because
+// the client was generated from another version of the schema. It is called with the fingerprints
+// of the client and the server, before the request is decoded, to log or count deploy skew. If it
+// returns nil the request is handled as usual. To reject mismatched requests, return an error:
+// a twirp.Error is returned to the client unchanged, and any other error is returned as a
+// twirp.FailedPrecondition error with the error text as its message. Requests without the header,
+// such as from clients that are not generated by this plugin, are not checked.
+func WithTwirpServerSchemaMismatchHandler(handler func(ctx context.Context, clientFingerprint string, serverFingerprint string) error) TwirpServerOption {
+	return func(o *TwirpServerOptions) {
+		o.schemaMismatch = handler
+	}
+}
+
+// WithTwirpServerPeerCertificateCheck sets a function that authorizes requests by the TLS client
+// certificate of the connection, for mTLS. It is called after routing with the method name, such
+// as "MakeHat", and the client's leaf certificate, so that policies can differ by method. If it
+// returns an error the request is rejected: a twirp.Error is returned to the client unchanged,
+// and any other error is returned as a twirp.PermissionDenied error with the error text as its
+// message.
+//
+// It is not called for requests without a client certificate, such as plain HTTP requests or TLS
+// connections where the client sent none, which are handled as usual. Configure the server's
+// tls.Config with tls.RequireAndVerifyClientCert to reject those, and to verify certificates
+// against ClientCAs: the check is given the certificate as presented, so with
+// tls.RequestClientCert or tls.RequireAnyClientCert it may be self-signed.
+func WithTwirpServerPeerCertificateCheck(check func(ctx context.Context, method string, cert *x509.Certificate) error) TwirpServerOption {
+	return func(o *TwirpServerOptions) {
+		o.peerCertificateCheck = check
+	}
+}
+
+// TwirpFieldMaskHeader is the request header that holds the field mask used by WithTwirpServerFieldMask.
+const TwirpFieldMaskHeader = "Twirp-Field-Mask"
+
+// WithTwirpServerFieldMask makes the server apply the field mask in the TwirpFieldMaskHeader
+// request header to JSON responses. The mask is a comma separated list of field paths, such as
+// "size,color" or "hat.size", using either proto or JSON field names. Fields that are not in the
+// mask are cleared before the response is marshalled. Protobuf responses are never masked.
+//
+// Masked responses are marshalled without unpopulated fields, even if the JSON codec has
+// EmitUnpopulated set, so that fields outside the mask are left out rather than written
+// as zero values. Fields in the mask that have zero values are left out as well.
+func WithTwirpServerFieldMask() TwirpServerOption {
+	return func(o *TwirpServerOptions) {
+		o.fieldMask = true
+	}
+}
+
+// TwirpWithFieldMask returns a context that makes clients send paths as the field mask of
+// requests, for servers created with WithTwirpServerFieldMask.
+func TwirpWithFieldMask(ctx context.Context, paths ...string) (context.Context, error) {
+	headers := make(http.Header)
+	if h, ok := twirp.HTTPRequestHeaders(ctx); ok {
+		headers = h.Clone()
+	}
+	headers.Set(TwirpFieldMaskHeader, strings.Join(paths, ","))
+
+	return twirp.WithHTTPRequestHeaders(ctx, headers)
+}
+
+// twirpMaskResponse returns a masked copy of m, and a codec that omits unpopulated fields,
+// if req has a field mask and codec is a JSON codec. Otherwise it returns codec and m.
+func twirpMaskResponse(req *http.Request, codec TwirpCodec, m proto.Message) (TwirpCodec, proto.Message) {
+	jc, ok := codec.(*TwirpCodecJson)
+	if !ok {
+		return codec, m
+	}
+
+	header := req.Header.Get(TwirpFieldMaskHeader)
+	if header == "" {
+		return codec, m
+	}
+
+	var paths [][]string
+	for _, path := range strings.Split(header, ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			paths = append(paths, strings.Split(path, "."))
+		}
+	}
+
+	m = proto.Clone(m)
+	twirpApplyFieldMask(m.ProtoReflect(), paths)
+
+	masked := *jc
+	masked.EmitUnpopulated = false
+
+	return &masked, m
+}
+
+// twirpApplyFieldMask clears the fields of m that are not in paths.
+func twirpApplyFieldMask(m protoreflect.Message, paths [][]string) {
+	var clear []protoreflect.FieldDescriptor
+
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		keep := false
+		var sub [][]string
+		for _, path := range paths {
+			if path[0] != string(fd.Name()) && path[0] != fd.JSONName() {
+				continue
+			}
+			if len(path) == 1 {
+				keep = true
+				break
+			}
+			sub = append(sub, path[1:])
+		}
+
+		switch {
+		case keep:
+		case len(sub) > 0 && fd.Message() != nil && !fd.IsList() && !fd.IsMap():
+			twirpApplyFieldMask(v.Message(), sub)
+		default:
+			clear = append(clear, fd)
+		}
+
+		return true
+	})
+
+	for _, fd := range clear {
+		m.Clear(fd)
+	}
+}
+
+// TwirpTimeoutHeader is the default header used by WithTwirpServerTimeoutHeader and
+// WithTwirpClientTimeoutHeader. Its value is the remaining time of the request, as an
+// integer number of milliseconds.
+const TwirpTimeoutHeader = "Twirp-Timeout"
+
+// WithTwirpServerTimeoutHeader applies the timeout in the given request header, or
+// TwirpTimeoutHeader if header is empty, to the request context. Values that are not a
+// positive integer number of milliseconds are ignored. Combine it with
+// WithTwirpServerEnforceDeadline to respond as soon as the timeout expires.
+func WithTwirpServerTimeoutHeader(header string) TwirpServerOption {
+	return func(o *TwirpServerOptions) {
+		if header == "" {
+			header = TwirpTimeoutHeader
+		}
+		o.timeoutHeader = header
+	}
+}
+
+// WithTwirpServerRequireContentType makes the server reject requests without a Content-Type
+// header with a twirp.Malformed error. Without it, such requests are decoded with the codec set
+// by WithTwirpServerDefaultContentType, or rejected with a twirp.BadRoute error if there is none.
+func WithTwirpServerRequireContentType() TwirpServerOption {
+	return func(o *TwirpServerOptions) {
+		o.requireContentType = true
+	}
+}
+
+// WithTwirpServerDefaultContentType decodes requests without a Content-Type header as if it was
+// contentType, such as "application/protobuf". It has no effect with WithTwirpServerRequireContentType.
+func WithTwirpServerDefaultContentType(contentType string) TwirpServerOption {
+	return func(o *TwirpServerOptions) {
+		o.defaultContentType = contentType
+	}
+}
+
+// TwirpDefaultCompressionThreshold is the size, in bytes, below which responses are not compressed
+// unless it is changed with WithTwirpServerResponseCompressionThreshold.
+const TwirpDefaultCompressionThreshold = 1024
+
+// WithTwirpServerGzip compresses responses with gzip for clients that send an Accept-Encoding
+// header that allows it. Responses smaller than TwirpDefaultCompressionThreshold, or the
+// threshold set with WithTwirpServerResponseCompressionThreshold, are never compressed.
+func WithTwirpServerGzip() TwirpServerOption {
+	return func(o *TwirpServerOptions) {
+		o.gzip = true
+	}
+}
+
+// WithTwirpServerResponseCompressionThreshold sets the size, in bytes, below which responses are
+// sent uncompressed even when the client accepts gzip. Compressing small messages costs CPU and
+// can make them larger. It only has an effect with WithTwirpServerGzip.
+func WithTwirpServerResponseCompressionThreshold(n int) TwirpServerOption {
+	return func(o *TwirpServerOptions) {
+		o.compressionThreshold = n
+	}
+}
+
+var twirpGzipWriterPool = sync.Pool{
+	New: func() interface{} {
+		return gzip.NewWriter(nil)
+	},
+}
+
+// twirpAcceptsGzip reports whether the Accept-Encoding header of req allows gzip.
+func twirpAcceptsGzip(req *http.Request) bool {
+	for _, header := range req.Header.Values("Accept-Encoding") {
+		for _, coding := range strings.Split(header, ",") {
+			params := ""
+			if i := strings.Index(coding, ";"); i != -1 {
+				coding, params = coding[:i], coding[i+1:]
+			}
+
+			if strings.ToLower(strings.TrimSpace(coding)) != "gzip" {
+				continue
+			}
+
+			q := 1.0
+			for _, param := range strings.Split(params, ";") {
+				kv := strings.SplitN(param, "=", 2)
+				if len(kv) == 2 && strings.TrimSpace(kv[0]) == "q" {
+					q, _ = strconv.ParseFloat(strings.TrimSpace(kv[1]), 64)
+				}
+			}
+
+			return q > 0
+		}
+	}
+
+	return false
+}
+
+// twirpGzip compresses data into w.
+func twirpGzip(w io.Writer, data []byte) error {
+	zw := twirpGzipWriterPool.Get().(*gzip.Writer)
+	defer twirpGzipWriterPool.Put(zw)
+
+	zw.Reset(w)
+
+	if _, err := zw.Write(data); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+// WithTwirpServerMethodTimeouts limits requests to the methods in timeouts, keyed by method name
+// such as "MakeHat", to the given duration. A zero duration exempts a method from the timeout set
+// with WithTwirpServerDefaultTimeout. The timeout only shortens the request deadline: a shorter
+// deadline, such as one from WithTwirpServerTimeoutHeader, is kept. Handlers must honor the
+// context, or the server must also use WithTwirpServerEnforceDeadline, for it to take effect.
+func WithTwirpServerMethodTimeouts(timeouts map[string]time.Duration) TwirpServerOption {
+	return func(o *TwirpServerOptions) {
+		o.methodTimeouts = make(map[string]time.Duration, len(timeouts))
+		for method, timeout := range timeouts {
+			o.methodTimeouts[method] = timeout
+		}
+	}
+}
+
+// WithTwirpServerDefaultTimeout limits requests to methods without a timeout set with
+// WithTwirpServerMethodTimeouts to timeout.
+func WithTwirpServerDefaultTimeout(timeout time.Duration) TwirpServerOption {
+	return func(o *TwirpServerOptions) {
+		o.defaultTimeout = timeout
+	}
+}
+
+// WithTwirpServerMethodConcurrency limits the number of requests to the methods in limits, keyed by
+// method name such as "MakeHat", that are handled at the same time. Requests over a method's limit
+// fail at once with twirp.ResourceExhausted rather than waiting. Each method has its own limit, and
+// methods not in limits, or with a limit of zero or less, are unlimited.
+func WithTwirpServerMethodConcurrency(limits map[string]int) TwirpServerOption {
+	return func(o *TwirpServerOptions) {
+		o.methodConcurrency = make(map[string]int, len(limits))
+		for method, limit := range limits {
+			o.methodConcurrency[method] = limit
+		}
+	}
+}
+
+// twirpDrain tracks the requests being handled by a server, so that it can be drained.
+type twirpDrain struct {
+	// mu orders start and wait, since active must not be added to once it is waited for
+	mu       sync.RWMutex
+	draining bool
+	active   sync.WaitGroup
+}
+
+// start adds a request, and returns false if the server is draining. done must be called when
+// the request completes if it returns true.
+func (d *twirpDrain) start() bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if d.draining {
+		return false
+	}
+	d.active.Add(1)
+	return true
+}
+
+func (d *twirpDrain) done() {
+	d.active.Done()
+}
+
+// wait rejects new requests and waits for the active ones, or until ctx is done.
+func (d *twirpDrain) wait(ctx context.Context) error {
+	d.mu.Lock()
+	d.draining = true
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.active.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func twirpDrainingError() twirp.Error {
+	return twirp.NewError(twirp.Unavailable, "the server is draining")
+}
+
+// WithTwirpServerSingleflight makes concurrent requests to an idempotent method with identical
+// request messages share one call of the implementation. The first request calls it, and the
+// others wait for it and get a copy of its response or its error, unless their context is done
+// first. Responses and errors are only shared while the call is in flight and are never cached.
+// The call runs with the context of the first request. If it fails once that context is done, the
+// error is not shared, and the others call the implementation again, sharing a new call. Methods
+// with the (twirpgo.cacheable) option are not shared, since each call of the implementation sets
+// the ETag of its own response.
+func WithTwirpServerSingleflight() TwirpServerOption {
+	return func(o *TwirpServerOptions) {
+		o.singleflight = true
+	}
+}
+
+// TwirpIdempotencyKeyHeader is the request header read by servers created with
+// WithTwirpServerIdempotencyStore.
+const TwirpIdempotencyKeyHeader = "Idempotency-Key"
+
+// TwirpDefaultIdempotencyTTL is how long WithTwirpServerIdempotencyStore keeps keys by default.
+const TwirpDefaultIdempotencyTTL = 24 * time.Hour
+
+// TwirpIdempotencyStore keeps the records of WithTwirpServerIdempotencyStore, such as in Redis or
+// memcached. Get returns false if key is not set or has expired, and Set replaces the value of key,
+// which expires after ttl.
+type TwirpIdempotencyStore interface {
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+}
+
+// WithTwirpServerIdempotencyStore dedupes retried requests to mutating methods, those without an
+// idempotency_level, that have the same TwirpIdempotencyKeyHeader. The first request with a key
+// calls the implementation, and its response is kept in store for ttl, or
+// TwirpDefaultIdempotencyTTL if ttl is not positive. Later requests with the key get the kept
+// response without calling the implementation, and requests sent while the first one is still
+// running fail with twirp.Aborted. Errors are not kept, so a request that failed can be retried
+// with the same key. Keys are scoped to the method, and requests without the header are not
+// deduped.
+//
+// Store errors fail the request with twirp.Unavailable rather than risk calling the implementation
+// twice. The store has no atomic set-if-absent, so servers sharing a store can still both call the
+// implementation for duplicates that arrive at the same time.
+func WithTwirpServerIdempotencyStore(store TwirpIdempotencyStore, ttl time.Duration) TwirpServerOption {
+	return func(o *TwirpServerOptions) {
+		if ttl <= 0 {
+			ttl = TwirpDefaultIdempotencyTTL
+		}
+		o.idempotency = &twirpIdempotency{store: store, ttl: ttl}
+	}
+}
+
+// The first byte of the records kept by twirpIdempotency. An empty record is a released key.
+const (
+	twirpIdempotencyInProgress byte = 1
+	twirpIdempotencyDone       byte = 2
+)
+
+type twirpIdempotency struct {
+	store TwirpIdempotencyStore
+	ttl   time.Duration
+}
+
+// begin claims key for a call. It returns true if the call already completed, after decoding its
+// response into out, and an error if the call is in progress or the store fails.
+func (i *twirpIdempotency) begin(ctx context.Context, key string, out proto.Message) (bool, error) {
+	record, ok, err := i.store.Get(ctx, key)
+	if err != nil {
+		return false, twirp.WrapError(twirp.NewError(twirp.Unavailable, "idempotency store failed"), err)
+	}
+
+	if ok && len(record) > 0 {
+		switch record[0] {
+		case twirpIdempotencyInProgress:
+			return false, twirp.NewError(twirp.Aborted, "a request with the same idempotency key is in progress")
+		case twirpIdempotencyDone:
+			if err := proto.Unmarshal(record[1:], out); err != nil {
+				return false, twirp.InternalErrorWith(err)
+			}
+			return true, nil
+		}
+	}
+
+	if err := i.store.Set(ctx, key, []byte{twirpIdempotencyInProgress}, i.ttl); err != nil {
+		return false, twirp.WrapError(twirp.NewError(twirp.Unavailable, "idempotency store failed"), err)
+	}
+
+	return false, nil
+}
+
+// end records the result of a call claimed with begin. The key is released if the call failed, so
+// it can be retried. Store errors are ignored, since the call has already been made.
+func (i *twirpIdempotency) end(ctx context.Context, key string, out proto.Message, err error) {
+	// the caller's context may be done, and the record must still be written
+	ctx = twirpWithoutCancel(ctx)
+
+	var record []byte
+	if err == nil && out.ProtoReflect().IsValid() {
+		b, err := proto.Marshal(out)
+		if err == nil {
+			record = append([]byte{twirpIdempotencyDone}, b...)
+		}
+	}
+
+	_ = i.store.Set(ctx, key, record, i.ttl)
+}
+
+// twirpWithoutCancel returns a context with the values of ctx that is never done, like
+// context.WithoutCancel.
+func twirpWithoutCancel(ctx context.Context) context.Context {
+	return twirpValuesContext{ctx}
+}
+
+type twirpValuesContext struct {
+	values context.Context
+}
+
+func (twirpValuesContext) Deadline() (time.Time, bool)         { return time.Time{}, false }
+func (twirpValuesContext) Done() <-chan struct{}               { return nil }
+func (twirpValuesContext) Err() error                          { return nil }
+func (c twirpValuesContext) Value(key interface{}) interface{} { return c.values.Value(key) }
+
+// twirpMethodSemaphores returns a semaphore for each method with a positive limit.
+func twirpMethodSemaphores(limits map[string]int) map[string]chan struct{} {
+	semaphores := make(map[string]chan struct{}, len(limits))
+	for method, limit := range limits {
+		if limit > 0 {
+			semaphores[method] = make(chan struct{}, limit)
+		}
+	}
+
+	return semaphores
+}
+
+// twirpAcquireMethod takes a slot of the semaphore of method, if it has one, and returns the func
+// that releases it. It fails without waiting if all slots are taken.
+func twirpAcquireMethod(semaphores map[string]chan struct{}, method string) (func(), twirp.Error) {
+	semaphore, ok := semaphores[method]
+	if !ok {
+		return func() {}, nil
+	}
+
+	select {
+	case semaphore <- struct{}{}:
+		return func() { <-semaphore }, nil
+	default:
+		return nil, twirp.NewError(twirp.ResourceExhausted, "too many concurrent requests to method "+method)
+	}
+}
+
+// twirpMethodTimeout returns the timeout of method, or 0 if it has none.
+func twirpMethodTimeout(timeouts map[string]time.Duration, defaultTimeout time.Duration, method string) time.Duration {
+	if timeout, ok := timeouts[method]; ok {
+		return timeout
+	}
+
+	return defaultTimeout
+}
+
+// twirpTimeoutFromHeader parses a timeout in milliseconds. It returns false for malformed values.
+func twirpTimeoutFromHeader(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	ms, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || ms <= 0 {
+		return 0, false
+	}
+
+	return time.Duration(ms) * time.Millisecond, true
+}
+
+type TwirpClientOptions struct {
+	codec               TwirpCodec
+	bodyDumper          TwirpBodyDumper
+	expectContinue      bool
+	responseValidator   func(string, proto.Message) error
+	connCallback        func(string, httptrace.GotConnInfo)
+	timingCallback      func(string, TwirpTimings)
+	timeout             time.Duration
+	timeoutHeader       string
+	version             string
+	protobufContentType string
+	jsonFallback        bool
+	acceptEncodings     []string
+	canaryURL           string
+	canaryWeight        float64
+	tokenSource         func(context.Context) (string, error)
+	hedgeDelay          time.Duration
+	hedgeExtra          int
+	observer            TwirpObserver
+	etagCacheSize       int
+	singleflight        bool
+	routeTemplate       string
+	recorder            func() (twirpCallRecorder, error)
+	metrics             func(string, time.Duration, error)
+}
+
+type TwirpClientOption func(*TwirpClientOptions)
+
+func WithTwirpClientCodec(codec TwirpCodec) TwirpClientOption {
+	return func(o *TwirpClientOptions) {
+		o.codec = codec
+	}
+}
+
+// WithTwirpClientProtobufContentType sets the Content-Type sent with protobuf requests, for servers
+// that expect a spelling other than the default "application/protobuf", such as
+// "application/x-protobuf". It has no effect when the client uses another codec.
+func WithTwirpClientProtobufContentType(contentType string) TwirpClientOption {
+	return func(o *TwirpClientOptions) {
+		o.protobufContentType = contentType
+	}
+}
+
+// WithTwirpClientJSONFallback makes the client send a request again as JSON when the server
+// responds to it with 415 Unsupported Media Type, for deployments where some servers or proxies
+// only accept JSON. The request is encoded again from the request message, and the response is
+// decoded as JSON. It is sent to the same server, and the client's hooks are not called again for
+// it. Calls that fall back take two round trips, and every call tries the client's codec first, so
+// prefer WithTwirpClientCodec for servers known to only support JSON. Standard Twirp servers reject
+// unknown content types with a bad_route error rather than a 415, which is returned as is.
+func WithTwirpClientJSONFallback() TwirpClientOption {
+	return func(o *TwirpClientOptions) {
+		o.jsonFallback = true
+	}
+}
+
+// WithTwirpClientAcceptEncoding makes the client send an Accept-Encoding header listing encodings,
+// "gzip" if none are given, and decode responses according to their Content-Encoding header, such
+// as from servers created with WithTwirpServerGzip. Responses without a Content-Encoding or with
+// "identity" are read as is, and responses with any other encoding fail with a twirp.Internal error.
+// Only "gzip" and "identity" are supported; the client constructor returns an error for others.
+//
+// An *http.Transport already asks for gzip and decompresses responses itself when the request has
+// no Accept-Encoding header, unless its DisableCompression is set. This option is for other
+// transports, and for transports with compression disabled, such as to compress only some clients.
+func WithTwirpClientAcceptEncoding(encodings ...string) TwirpClientOption {
+	if len(encodings) == 0 {
+		encodings = []string{"gzip"}
+	}
+
+	return func(o *TwirpClientOptions) {
+		o.acceptEncodings = encodings
+	}
+}
+
+// WithTwirpClientCanary sends a fraction, weight, of the client's calls to the canary base URL instead
+// of the base URLs of the client, for canary deployments. weight must be between 0 and 1, or the client
+// constructor returns an error. Calls are sent to the canary at random, using math/rand's default
+// source, unless their context has a session set with TwirpWithCanarySession. Connection errors of
+// calls sent to the canary are not failed over, and calls sent to the other base URLs are not failed
+// over to the canary. Server streaming methods are routed the same way.
+func WithTwirpClientCanary(baseUrl string, weight float64) TwirpClientOption {
+	return func(o *TwirpClientOptions) {
+		o.canaryURL = baseUrl
+		o.canaryWeight = weight
+	}
+}
+
+type twirpCanarySessionKey struct{}
+
+// TwirpWithCanarySession returns a context whose calls are routed by session, such as a user or
+// request ID, by clients created with WithTwirpClientCanary: calls with the same session all go
+// to the canary, or none do. The target is chosen from a hash of session, so it is the same for
+// every client with the same weight, in every process, and raising the weight only moves sessions
+// to the canary. An empty session routes calls at random.
+func TwirpWithCanarySession(ctx context.Context, session string) context.Context {
+	return context.WithValue(ctx, twirpCanarySessionKey{}, session)
+}
+
+// twirpCanary reports whether a call with ctx goes to the canary of a client with weight.
+func twirpCanary(ctx context.Context, weight float64) bool {
+	session, _ := ctx.Value(twirpCanarySessionKey{}).(string)
+	if session == "" {
+		return mathrand.Float64() < weight
+	}
+
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(session))
+
+	// FNV-1a spreads similar sessions, like "user-1" and "user-2", poorly over its high bits, so
+	// they are mixed with the finalizer of MurmurHash3 first
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+
+	// the top 53 bits of the hash as a float in [0, 1)
+	return float64(x>>11)/(1<<53) < weight
+}
+
+// twirpDecodeResponse replaces the body of resp with its content decoded according to its
+// Content-Encoding header, for clients created with WithTwirpClientAcceptEncoding.
+func twirpDecodeResponse(resp *http.Response) error {
+	coding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	switch coding {
+	case "", "identity":
+		return nil
+	case "gzip":
+		zr, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			twerr := twirp.NewError(twirp.Internal, "failed to decompress response")
+			return twirp.WrapError(twerr, err)
+		}
+
+		// like an *http.Transport that decompresses the response itself
+		resp.Body = &twirpGzipBody{Reader: zr, body: resp.Body}
+		resp.Header.Del("Content-Encoding")
+		resp.Header.Del("Content-Length")
+		resp.ContentLength = -1
+		resp.Uncompressed = true
+
+		return nil
+	}
+
+	return twirp.NewError(twirp.Internal, fmt.Sprintf("unsupported response Content-Encoding %q", coding))
+}
+
+// twirpGzipBody is a decompressed response body.
+type twirpGzipBody struct {
+	*gzip.Reader
+	body io.ReadCloser
+}
+
+func (b *twirpGzipBody) Close() error {
+	return b.body.Close()
+}
+
+// WithTwirpClientTokenSource sets a function that fetches a bearer token, which is sent in the
+// Authorization header of every request. The token is cached and shared by all calls of the
+// client until a call fails with twirp.Unauthenticated; then one new token is fetched and the
+// call is sent once more with it. A call is never retried more than once: if the new token is
+// rejected too, the error is returned, and the next call fetches another token. Errors from
+// source fail the call as twirp.Unauthenticated.
+func WithTwirpClientTokenSource(source func(ctx context.Context) (string, error)) TwirpClientOption {
+	return func(o *TwirpClientOptions) {
+		o.tokenSource = source
+	}
+}
+
+// WithTwirpClientObserver notifies observer when each call starts and ends. Retries and
+// hedged requests are part of the same call.
+func WithTwirpClientObserver(observer TwirpObserver) TwirpClientOption {
+	return func(o *TwirpClientOptions) {
+		o.observer = observer
+	}
+}
+
+// WithTwirpClientMetrics sets a function that is called after every call with the name of the RPC
+// method, such as "MakeHat", how long the call took, and its error, which is nil if it succeeded.
+// It is called once for each call, with the total duration of a call that was retried or hedged.
+// Calls are not timed when it is not set.
+func WithTwirpClientMetrics(metrics func(method string, duration time.Duration, err error)) TwirpClientOption {
+	return func(o *TwirpClientOptions) {
+		o.metrics = metrics
+	}
+}
+
+// TwirpCallOption configures a single call made with a <Method>WithOptions client method.
+type TwirpCallOption func(*twirpCallOptions)
+
+type twirpCallOptions struct {
+	header  http.Header
+	timeout time.Duration
+	noRetry bool
+	codec   TwirpCodec
+	trailer *http.Header
+}
+
+// WithTwirpCallHeader adds a request header to the call, in addition to those set in the context
+// with twirp.WithHTTPRequestHeaders. Headers used by Twirp itself, such as Content-Type, cannot be
+// set, and fail the call with twirp.Internal.
+func WithTwirpCallHeader(key string, value string) TwirpCallOption {
+	return func(o *twirpCallOptions) {
+		if o.header == nil {
+			o.header = http.Header{}
+		}
+		o.header.Add(key, value)
+	}
+}
+
+// WithTwirpCallTimeout limits the call to timeout, instead of the client's timeout. A deadline of
+// the context that is earlier still applies.
+func WithTwirpCallTimeout(timeout time.Duration) TwirpCallOption {
+	return func(o *twirpCallOptions) {
+		o.timeout = timeout
+	}
+}
+
+// WithTwirpCallNoRetry sends the call's request once: it is not hedged, not failed over to another
+// base URL of a balanced client, and not retried with a new token after a twirp.Unauthenticated
+// error.
+func WithTwirpCallNoRetry() TwirpCallOption {
+	return func(o *twirpCallOptions) {
+		o.noRetry = true
+	}
+}
+
+// WithTwirpCallCodec sends the call's request encoded with codec, such as DefaultTwirpCodecJson,
+// instead of the client's codec, and decodes the response with it, so that one client can use
+// protobuf for some calls and JSON for others. It takes precedence over WithTwirpClientCodec and
+// WithTwirpClientProtobufContentType, and a call sent with a codec other than JSON still falls back
+// to JSON with WithTwirpClientJSONFallback.
+func WithTwirpCallCodec(codec TwirpCodec) TwirpCallOption {
+	return func(o *twirpCallOptions) {
+		o.codec = codec
+	}
+}
+
+// WithTwirpCallTrailer sets *trailer to the HTTP trailers of the call's response, which the client
+// reads after the whole body, when the call succeeds. Servers only send trailers when created with
+// WithTwirpServerTrailers, and proxies may drop them, so *trailer may be empty.
+func WithTwirpCallTrailer(trailer *http.Header) TwirpCallOption {
+	return func(o *twirpCallOptions) {
+		o.trailer = trailer
+	}
+}
+
+type twirpNoRetryKey struct{}
+
+type twirpCallTrailerKey struct{}
+
+// twirpCodecKey is set in the context of calls whose request is sent with another codec than the
+// client's, by WithTwirpCallCodec.
+type twirpCodecKey struct{}
+
+// twirpWithCallOptions returns ctx with opts applied. The returned cancel func must always be called.
+func twirpWithCallOptions(ctx context.Context, opts []TwirpCallOption) (context.Context, context.CancelFunc, error) {
+	var o twirpCallOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	cancel := func() {}
+	if o.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, o.timeout)
+	}
+
+	if o.header != nil {
+		header, _ := twirp.HTTPRequestHeaders(ctx)
+		header = header.Clone()
+		if header == nil {
+			header = http.Header{}
+		}
+		for key, values := range o.header {
+			for _, value := range values {
+				header.Add(key, value)
+			}
+		}
+
+		var err error
+		ctx, err = twirp.WithHTTPRequestHeaders(ctx, header)
+		if err != nil {
+			return ctx, cancel, twirp.InternalErrorWith(err)
+		}
+	}
+
+	if o.noRetry {
+		ctx = context.WithValue(ctx, twirpNoRetryKey{}, true)
+	}
+
+	if o.codec != nil {
+		ctx = context.WithValue(ctx, twirpCodecKey{}, o.codec)
+	}
+
+	if o.trailer != nil {
+		ctx = context.WithValue(ctx, twirpCallTrailerKey{}, o.trailer)
+	}
+
+	return ctx, cancel, nil
+}
+
+// TwirpDefaultETagCacheSize is the number of responses of cacheable methods a client keeps by default.
+const TwirpDefaultETagCacheSize = 256
+
+// WithTwirpClientETagCacheSize sets how many responses with an ETag the client keeps for methods
+// with the (twirpgo.cacheable) option, evicting the least recently used. A response is reused when
+// the server answers a request with the same body with 304 Not Modified. Zero or less disables
+// the cache, so no If-None-Match header is sent. The default is TwirpDefaultETagCacheSize.
+func WithTwirpClientETagCacheSize(size int) TwirpClientOption {
+	return func(o *TwirpClientOptions) {
+		o.etagCacheSize = size
+	}
+}
+
+// WithTwirpClientSingleflight makes concurrent calls of an idempotent method with identical requests
+// share a single request to the server. The first call sends the request, and the others wait for
+// it and get a copy of its response or its error, unless their context is done first. Responses
+// and errors are only shared while the request is in flight and are never cached. If the request
+// fails once the context of the first call is done, the error is not shared, and the others send
+// the request again. Requests are compared by method and serialized request message.
+func WithTwirpClientSingleflight() TwirpClientOption {
+	return func(o *TwirpClientOptions) {
+		o.singleflight = true
+	}
+}
+
+// twirpFlight is a request in flight in a twirpFlightGroup.
+type twirpFlight struct {
+	done chan struct{}
+	resp proto.Message
+	err  error
+	// abandoned is set if fn failed after the context of its caller was done
+	abandoned bool
+}
+
+// twirpFlightGroup coalesces concurrent calls with the same key, like
+// golang.org/x/sync/singleflight, without the dependency.
+type twirpFlightGroup struct {
+	mu      sync.Mutex
+	flights map[string]*twirpFlight
+}
+
+// do calls fn unless a call with key is already in flight, in which case it waits for that call
+// and returns its results. shared is false for the caller that called fn. The response must not be
+// modified by callers that shared it. If fn fails once ctx is done, the callers waiting for it do
+// not get its error, and make another call instead.
+func (g *twirpFlightGroup) do(ctx context.Context, key string, fn func() (proto.Message, error)) (resp proto.Message, shared bool, err error) {
+	g.mu.Lock()
+	for {
+		f, ok := g.flights[key]
+		if !ok {
+			break
+		}
+		g.mu.Unlock()
+
+		select {
+		case <-f.done:
+			if !f.abandoned {
+				return f.resp, true, f.err
+			}
+		case <-ctx.Done():
+			return nil, true, twirpContextError(ctx.Err())
+		}
+
+		// the caller of fn gave up, which says nothing about this caller, so join or start a new call
+		g.mu.Lock()
+	}
+
+	f := &twirpFlight{
+		done: make(chan struct{}),
+		err:  twirp.InternalError("shared request did not complete"),
+	}
+	g.flights[key] = f
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		delete(g.flights, key)
+		g.mu.Unlock()
+		close(f.done)
+	}()
+
+	f.resp, f.err = fn()
+	f.abandoned = f.err != nil && ctx.Err() != nil
+	return f.resp, false, f.err
+}
+
+// twirpCallRecorder records or replays the unary calls of a client instead of only sending them,
+// like the cassette of WithTwirpClientCassette, generated with the generate_testhelpers option.
+type twirpCallRecorder interface {
+	do(ctx context.Context, method string, in proto.Message, out proto.Message, fn func() (context.Context, error)) (context.Context, error)
+}
+
+type twirpETagEntry struct {
+	key  string
+	etag string
+	body []byte
+}
+
+// twirpETagCache is a least recently used cache of responses with an ETag, keyed by the
+// path and body of the request.
+type twirpETagCache struct {
+	mu      sync.Mutex
+	size    int
+	entries map[string]*list.Element
+	order   *list.List
+}
+
+func newTwirpETagCache(size int) *twirpETagCache {
+	return &twirpETagCache{
+		size:    size,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+func (c *twirpETagCache) get(key string) (*twirpETagEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	return element.Value.(*twirpETagEntry), true
+}
+
+func (c *twirpETagCache) put(key string, etag string, body []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := &twirpETagEntry{key: key, etag: etag, body: body}
+	if element, ok := c.entries[key]; ok {
+		element.Value = entry
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(entry)
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*twirpETagEntry).key)
+	}
+}
+
+// twirpTokenCache caches the token returned by a token source until it is invalidated.
+type twirpTokenCache struct {
+	source func(context.Context) (string, error)
+	mu     sync.Mutex
+	token  string
+}
+
+// get returns the cached token, fetching one if there is none. Concurrent callers wait for
+// a single fetch.
+func (t *twirpTokenCache) get(ctx context.Context) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.token == "" {
+		token, err := t.source(ctx)
+		if err != nil {
+			twerr := twirp.NewError(twirp.Unauthenticated, "failed to get token")
+			return "", twirp.WrapError(twerr, err)
+		}
+		t.token = token
+	}
+
+	return t.token, nil
+}
+
+// invalidate clears token from the cache, unless another call has already replaced it.
+func (t *twirpTokenCache) invalidate(token string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.token == token {
+		t.token = ""
+	}
+}
+
+// twirpWithToken returns a context that makes clients send token in the Authorization header.
+func twirpWithToken(ctx context.Context, token string) (context.Context, error) {
+	headers := make(http.Header)
+	if h, ok := twirp.HTTPRequestHeaders(ctx); ok {
+		headers = h.Clone()
+	}
+	headers.Set("Authorization", "Bearer "+token)
+
+	return twirp.WithHTTPRequestHeaders(ctx, headers)
+}
+
+// WithTwirpClientBodyDumper sets a function that is called with the raw request and response
+// bodies. It is intended for debugging only: bodies may contain sensitive data.
+func WithTwirpClientBodyDumper(dumper TwirpBodyDumper) TwirpClientOption {
+	return func(o *TwirpClientOptions) {
+		o.bodyDumper = dumper
+	}
+}
+
+// WithTwirpClientExpectContinue sends requests with an "Expect: 100-continue" header, so the
+// request body is only sent once the server has accepted the request headers. Servers created
+// with New<Service>TwirpServer reject requests in the RequestReceived and RequestRouted hooks
+// before reading the body, so a rejected request does not upload its body.
+//
+// The transport must support the header: an *http.Transport only waits for the server's
+// response if its ExpectContinueTimeout is set, as it is for http.DefaultTransport. Otherwise
+// the body is sent immediately.
+func WithTwirpClientExpectContinue() TwirpClientOption {
+	return func(o *TwirpClientOptions) {
+		o.expectContinue = true
+	}
+}
+
+// WithTwirpClientResponseValidator sets a function that is called with every decoded response
+// before it is returned to the caller. method is the name of the RPC method and resp is the
+// concrete response message, so validators may use a type assertion or switch.
+//
+// If the validator returns a twirp.Error, it is returned to the caller unchanged. Any other
+// error is returned as a twirp.Internal error that wraps it.
+func WithTwirpClientResponseValidator(validator func(method string, resp proto.Message) error) TwirpClientOption {
+	return func(o *TwirpClientOptions) {
+		o.responseValidator = validator
+	}
+}
+
+// WithTwirpClientConnCallback sets a function that is called with the connection obtained for
+// every request, as reported by httptrace.ClientTrace.GotConn. info.Reused reports whether the
+// connection was reused from the transport's pool or newly dialed. method is the name of the RPC
+// method.
+//
+// callback is called on the request path, so it must be cheap and must not block: update a
+// counter rather than, for example, logging. Requests are only traced when this option is set.
+func WithTwirpClientConnCallback(callback func(method string, info httptrace.GotConnInfo)) TwirpClientOption {
+	return func(o *TwirpClientOptions) {
+		o.connCallback = callback
+	}
+}
+
+// TwirpTimings is the time a client request spent in each phase, as reported by httptrace.
+// Phases that did not happen are zero: DNS, Connect and TLS for a reused connection, TLS for
+// plain HTTP, and FirstByte for a request that failed before a response arrived. When a request
+// is retried or hedged, the phases are those of the last connection to finish them.
+type TwirpTimings struct {
+	// DNS is the time spent looking up the server's host name.
+	DNS time.Duration
+	// Connect is the time spent dialing the server, without DNS and TLS.
+	Connect time.Duration
+	// TLS is the time spent on the TLS handshake.
+	TLS time.Duration
+	// FirstByte is the time from sending the request until the first byte of the response.
+	FirstByte time.Duration
+	// Total is the time from sending the request until its response was read, or it failed.
+	Total time.Duration
+	// Reused reports whether the connection was reused from the transport's pool.
+	Reused bool
+}
+
+// WithTwirpClientTimingCallback sets a function that is called with the TwirpTimings of every
+// request after its response has been read or it has failed. method is the name of the RPC method.
+// Requests are only traced when this option is set, since tracing adds overhead to every request.
+//
+// callback is called on the request path, so it must be cheap and must not block.
+func WithTwirpClientTimingCallback(callback func(method string, timings TwirpTimings)) TwirpClientOption {
+	return func(o *TwirpClientOptions) {
+		o.timingCallback = callback
+	}
+}
+
+// twirpTimingTrace collects TwirpTimings. Hedged requests trace concurrently, so it is locked.
+type twirpTimingTrace struct {
+	mu           sync.Mutex
+	start        time.Time
+	dnsStart     time.Time
+	connectStart time.Time
+	tlsStart     time.Time
+	timings      TwirpTimings
+}
+
+func (t *twirpTimingTrace) begin(start *time.Time) {
+	t.mu.Lock()
+	*start = time.Now()
+	t.mu.Unlock()
+}
+
+func (t *twirpTimingTrace) end(start *time.Time, d *time.Duration) {
+	t.mu.Lock()
+	if !start.IsZero() {
+		*d = time.Since(*start)
+	}
+	t.mu.Unlock()
+}
+
+func (t *twirpTimingTrace) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			t.begin(&t.dnsStart)
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.end(&t.dnsStart, &t.timings.DNS)
+		},
+		ConnectStart: func(string, string) {
+			t.begin(&t.connectStart)
+		},
+		ConnectDone: func(string, string, error) {
+			t.end(&t.connectStart, &t.timings.Connect)
+		},
+		TLSHandshakeStart: func() {
+			t.begin(&t.tlsStart)
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.end(&t.tlsStart, &t.timings.TLS)
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			t.timings.Reused = info.Reused
+			t.mu.Unlock()
+		},
+		GotFirstResponseByte: func() {
+			t.end(&t.start, &t.timings.FirstByte)
+		},
+	}
+}
+
+// done returns the timings of the request, which ends now.
+func (t *twirpTimingTrace) done() TwirpTimings {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	timings := t.timings
+	timings.Total = time.Since(t.start)
+	return timings
+}
+
+// WithTwirpClientTimeout limits each call to d when the caller's context has no deadline.
+// Calls that time out return a twirp.DeadlineExceeded error. A context that already has a
+// deadline is used as is.
+func WithTwirpClientTimeout(d time.Duration) TwirpClientOption {
+	return func(o *TwirpClientOptions) {
+		o.timeout = d
+	}
+}
+
+// WithTwirpClientTimeoutHeader sends the time remaining until the context deadline in the given
+// request header, or TwirpTimeoutHeader if header is empty, as an integer number of
+// milliseconds. Requests without a deadline do not have the header.
+func WithTwirpClientTimeoutHeader(header string) TwirpClientOption {
+	return func(o *TwirpClientOptions) {
+		if header == "" {
+			header = TwirpTimeoutHeader
+		}
+		o.timeoutHeader = header
+	}
+}
+
+// WithTwirpClientRouteTemplate sends requests to the paths made from tmpl instead of the Twirp
+// paths, for servers created with the same WithTwirpServerRouteTemplate. The path prefix of
+// twirp.WithClientPathPrefix is not used. Creating a client with an invalid template fails.
+func WithTwirpClientRouteTemplate(tmpl string) TwirpClientOption {
+	return func(o *TwirpClientOptions) {
+		o.routeTemplate = tmpl
+	}
+}
+
+// WithTwirpClientVersion sends requests to the given version of the service, one of the values
+// of its (twirpgo.version) options. Clients of versioned services use the first version by
+// default. Creating a client with a version the service does not have fails.
+func WithTwirpClientVersion(version string) TwirpClientOption {
+	return func(o *TwirpClientOptions) {
+		o.version = version
+	}
+}
+
+// WithTwirpClientHedging sends up to maxExtra additional copies of a request to an idempotent
+// method, one with an idempotency_level of IDEMPOTENT or NO_SIDE_EFFECTS, each one delay after
+// the previous one while no response has arrived. The first response is used and the other
+// requests are canceled. If a request fails before a response arrives, the next copy is sent
+// right away. Requests to other methods are never hedged. With a balanced client, each copy
+// is sent to the next base URL.
+//
+// Hedging trades load for latency: every call may send up to maxExtra+1 requests. Choose a
+// delay near a high percentile of the method's latency, such as the 95th, so that only slow
+// calls are hedged, and make sure the servers can absorb the extra load.
+func WithTwirpClientHedging(delay time.Duration, maxExtra int) TwirpClientOption {
+	return func(o *TwirpClientOptions) {
+		o.hedgeDelay = delay
+		o.hedgeExtra = maxExtra
+	}
+}
+
+type twirpHedgeResult struct {
+	index int
+	resp  *http.Response
+	err   error
+}
+
+// twirpCancelOnClose calls cancel once the response body is closed.
+type twirpCancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *twirpCancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// twirpDoHedged sends req with body to requests[target], and up to extra copies to the requests
+// after it, each delay after the previous one, until one of them returns a response.
+func twirpDoHedged(client *http.Client, req *http.Request, body []byte, requests []*http.Request, target int, delay time.Duration, extra int) (*http.Response, error) {
+	ctx := req.Context()
+
+	// buffered so that requests that lose can always send their result
+	results := make(chan twirpHedgeResult, extra+1)
+	cancels := make([]context.CancelFunc, 0, extra+1)
+
+	send := func() {
+		index := len(cancels)
+		next := requests[(target+index)%len(requests)]
+
+		attemptCtx, cancel := context.WithCancel(ctx)
+		cancels = append(cancels, cancel)
+
+		attempt := req.Clone(attemptCtx)
+		attempt.URL = next.URL
+		attempt.Host = next.Host
+		attempt.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		go func() {
+			resp, err := client.Do(attempt)
+			results <- twirpHedgeResult{index: index, resp: resp, err: err}
+		}()
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	send()
+	pending := 1
+
+	for {
+		select {
+		case r := <-results:
+			pending--
+
+			if r.err == nil {
+				for i, cancel := range cancels {
+					if i != r.index {
+						cancel()
+					}
+				}
+
+				// close the bodies of requests that also got a response
+				go func(pending int) {
+					for ; pending > 0; pending-- {
+						if loser := <-results; loser.resp != nil {
+							_ = loser.resp.Body.Close()
+						}
+					}
+				}(pending)
+
+				r.resp.Body = &twirpCancelOnClose{ReadCloser: r.resp.Body, cancel: cancels[r.index]}
+				return r.resp, nil
+			}
+
+			cancels[r.index]()
+
+			if ctx.Err() == nil && len(cancels) <= extra {
+				send()
+				pending++
+
+				if !timer.Stop() {
+					<-timer.C
+				}
+				timer.Reset(delay)
+			} else if pending == 0 {
+				return nil, r.err
+			}
+		case <-timer.C:
+			if ctx.Err() == nil && len(cancels) <= extra {
+				send()
+				pending++
+				timer.Reset(delay)
+			}
+		}
+	}
+}
+
+// NewTwirpHTTP2Transport returns a transport for clients that prefer HTTP/2, to send concurrent
+// requests over one connection to each server. HTTP/2 is negotiated with ALPN during the TLS
+// handshake, and requests to servers that do not offer it, or to http:// URLs, use HTTP/1.1.
+// tlsConfig, which may be nil, configures TLS, such as the certificates to trust; an
+// *http.Transport with its own TLS config only attempts HTTP/2 if ForceAttemptHTTP2 is set,
+// as it is here. The other settings match http.DefaultTransport.
+func NewTwirpHTTP2Transport(tlsConfig *tls.Config) *http.Transport {
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+
+	if tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig.Clone()
+	}
+
+	return transport
+}
+
+// TwirpBalancer chooses which base URL a balanced client sends a request to.
+// It must be safe for concurrent use.
+type TwirpBalancer interface {
+	// Pick returns the index of the base URL to use, in the range [0, n).
+	Pick(n int) int
+}
+
+type twirpRoundRobinBalancer struct {
+	next uint32
+}
+
+// NewTwirpRoundRobinBalancer returns a TwirpBalancer that uses each base URL in turn.
+func NewTwirpRoundRobinBalancer() TwirpBalancer {
+	return &twirpRoundRobinBalancer{}
+}
+
+func (b *twirpRoundRobinBalancer) Pick(n int) int {
+	return int((atomic.AddUint32(&b.next, 1) - 1) % uint32(n))
+}
+
+type twirpRandomBalancer struct{}
+
+// NewTwirpRandomBalancer returns a TwirpBalancer that picks a base URL at random.
+func NewTwirpRandomBalancer() TwirpBalancer {
+	return twirpRandomBalancer{}
+}
+
+func (twirpRandomBalancer) Pick(n int) int {
+	return mathrand.Intn(n)
+}
+
+// TwirpBodyDumper is called with the raw bytes of a request or response body, exactly as
+// they are sent or received. direction is either "request" or "response" and method is
+// the name of the RPC method.
+type TwirpBodyDumper func(direction string, method string, body []byte)
+
+// twirpDumpBody reads all of r, passes it to dumper, and returns a reader for the same bytes.
+func twirpDumpBody(ctx context.Context, dumper TwirpBodyDumper, direction string, r io.Reader) (io.Reader, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	method, _ := twirp.MethodName(ctx)
+	dumper(direction, method, data)
+
+	return bytes.NewReader(data), nil
+}
+
+type twirpETagKey struct{}
+
+// twirpETag holds the ETag set by the handler of a cacheable method.
+type twirpETag struct {
+	value string
+}
+
+// SetTwirpETag sets the ETag of the response to a call of a method with the (twirpgo.cacheable)
+// option. Handlers compute it, for example from a version or a hash of the data, and must change
+// it whenever the response changes. If the If-None-Match header of the request matches it, the
+// server responds with 304 Not Modified and no body instead of the response. etag is quoted if it
+// is not already, as in "v1" or W/"v1". It returns an error for other methods, and for calls made
+// with Invoke.
+func SetTwirpETag(ctx context.Context, etag string) error {
+	holder, ok := ctx.Value(twirpETagKey{}).(*twirpETag)
+	if !ok {
+		return errors.New("ETags can only be set for methods with the (twirpgo.cacheable) option")
+	}
+
+	if !strings.HasPrefix(etag, `"`) && !strings.HasPrefix(etag, `W/"`) {
+		etag = strconv.Quote(etag)
+	}
+	holder.value = etag
+	return nil
+}
+
+// twirpETagMatch reports whether the If-None-Match header value matches etag, using the weak
+// comparison that If-None-Match requires.
+func twirpETagMatch(header string, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+type twirpRequestIDKey struct{}
+
+// TwirpRequestID returns the request ID assigned by a server created with WithTwirpServerRequestID.
+func TwirpRequestID(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(twirpRequestIDKey{}).(string)
+	return id, ok
+}
+
+func twirpNewRequestID() string {
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(id[:])
+}
+
+func twirpWithRequestID(ctx context.Context, header string, resp http.ResponseWriter, req *http.Request) context.Context {
+	id := req.Header.Get(header)
+	if id == "" {
+		id = twirpNewRequestID()
+	}
+
+	resp.Header().Set(header, id)
+	ctx = context.WithValue(ctx, twirpRequestIDKey{}, id)
+
+	headers := make(http.Header)
+	if h, ok := twirp.HTTPRequestHeaders(ctx); ok {
+		headers = h.Clone()
+	}
+	headers.Set(header, id)
+
+	if withHeaders, err := twirp.WithHTTPRequestHeaders(ctx, headers); err == nil {
+		ctx = withHeaders
+	}
+
+	return ctx
+}
+
+// twirpValidationError returns err if it is a twirp.Error and otherwise wraps it as twirp.InvalidArgument.
+func twirpValidationError(err error) twirp.Error {
+	var twerr twirp.Error
+	if errors.As(err, &twerr) {
+		return twerr
+	}
+	return twirp.WrapError(twirp.NewError(twirp.InvalidArgument, err.Error()), err)
+}
+
+// twirpResponseTransformError returns err if it is a twirp.Error and otherwise wraps it as twirp.Internal.
+func twirpResponseTransformError(err error) twirp.Error {
+	var twerr twirp.Error
+	if errors.As(err, &twerr) {
+		return twerr
+	}
+	return twirp.WrapError(twirp.NewError(twirp.Internal, err.Error()), err)
+}
+
+func twirpCallRequestReceived(ctx context.Context, h *twirp.ServerHooks) (context.Context, error) {
+	if h == nil || h.RequestReceived == nil {
+		return ctx, nil
+	}
+	return h.RequestReceived(ctx)
+}
+
+func twirpCallRequestRouted(ctx context.Context, h *twirp.ServerHooks) (context.Context, error) {
+	if h == nil || h.RequestRouted == nil {
+		return ctx, nil
+	}
+	return h.RequestRouted(ctx)
+}
+
+func twirpErrFromPanic(p interface{}) error {
+	if err, ok := p.(error); ok {
+		return err
+	}
+	return fmt.Errorf("panic: %v", p)
+}
+
+func twirpPanicInterceptor(method twirp.Method) twirp.Method {
+	return func(ctx context.Context, request interface{}) (resp interface{}, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				panicError := twirpErrFromPanic(r)
+				twerr := twirp.NewError(twirp.Internal, "internal service panic")
+				twerr = twerr.WithMeta("cause", panicError.Error())
+
+				resp = nil
+				err = twerr
+			}
+		}()
+
+		resp, err = method(ctx, request)
+		return resp, err
+	}
+}
+
+func twirpContextInterceptor(method twirp.Method) twirp.Method {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		resp, err := method(ctx, request)
+
+		if errors.Is(err, context.Canceled) {
+			twerr := twirp.NewError(twirp.Canceled, "context cancelled")
+			twerr = twerr.WithMeta("cause", err.Error())
+			return nil, twerr
+		}
+
+		if errors.Is(err, context.DeadlineExceeded) {
+			twerr := twirp.NewError(twirp.DeadlineExceeded, "context deadline exceeded")
+			twerr = twerr.WithMeta("cause", err.Error())
+			return nil, twerr
+		}
+
+		return resp, err
+	}
+}
+
+type twirpDeadlineResult struct {
+	resp interface{}
+	err  error
+}
+
+func twirpDeadlineInterceptor(method twirp.Method) twirp.Method {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		if _, ok := ctx.Deadline(); !ok {
+			return method(ctx, request)
+		}
+
+		// buffered so the handler goroutine can always exit
+		done := make(chan twirpDeadlineResult, 1)
+
+		go func() {
+			resp, err := method(ctx, request)
+			done <- twirpDeadlineResult{resp: resp, err: err}
+		}()
+
+		select {
+		case r := <-done:
+			return r.resp, r.err
+		case <-ctx.Done():
+		}
+
+		return nil, twirpContextError(ctx.Err())
+	}
+}
+
+// twirpContextError converts err, the error of a done context, to a twirp.DeadlineExceeded
+// or twirp.Canceled error that wraps it.
+func twirpContextError(err error) twirp.Error {
+	var twerr twirp.Error
+	if errors.Is(err, context.DeadlineExceeded) {
+		twerr = twirp.NewError(twirp.DeadlineExceeded, "context deadline exceeded")
+	} else {
+		twerr = twirp.NewError(twirp.Canceled, "context cancelled")
+	}
+
+	twerr = twerr.WithMeta("cause", err.Error())
+	return twirp.WrapError(twerr, err)
+}
+
+func twirpWriteError(ctx context.Context, resp http.ResponseWriter, err error, hooks *twirp.ServerHooks, encode func(twirp.Error) []byte) {
+	twerr, ok := err.(twirp.Error)
+	if !ok {
+		twerr = twirp.InternalErrorWith(err)
+	}
+
+	statusCode := twirp.ServerHTTPStatusFromErrorCode(twerr.Code())
+	ctx = ctxsetters.WithStatusCode(ctx, statusCode)
+	ctx = twirpCallError(ctx, hooks, twerr)
+
+	if encode == nil {
+		encode = twirpMarshalErrorToJSON
+	}
+
+	respBody := encode(twerr)
+
+	resp.Header()["Content-Type"] = []string{"application/json"}
+	resp.Header()["Content-Length"] = []string{strconv.Itoa(len(respBody))}
+	resp.WriteHeader(statusCode)
+
+	_, _ = resp.Write(respBody)
+
+	twirpCallResponseSent(ctx, hooks)
+}
+
+func twirpCallError(ctx context.Context, h *twirp.ServerHooks, err twirp.Error) context.Context {
+	if h == nil || h.Error == nil {
+		return ctx
+	}
+	return h.Error(ctx, err)
+}
+
+func twirpCallResponseSent(ctx context.Context, h *twirp.ServerHooks) {
+	if h == nil || h.ResponseSent == nil {
+		return
+	}
+	h.ResponseSent(ctx)
+}
+
+type twirpErrorJSON struct {
+	Meta map[string]string `json:"meta,omitempty"`
+	Code string            `json:"code"`
+	Msg  string            `json:"msg"`
+}
+
+func twirpMarshalErrorToJSON(twerr twirp.Error) []byte {
+	// make sure that msg is not too large
+	msg := twerr.Msg()
+	if len(msg) > 1e6 {
+		msg = msg[:1e6]
+	}
+
+	tj := twirpErrorJSON{
+		Code: string(twerr.Code()),
+		Msg:  msg,
+		Meta: twerr.MetaMap(),
+	}
+
+	buf, err := jsonCodec.Marshal(&tj)
+	if err != nil {
+		buf = []byte("{\"type\": \"" + twirp.Internal + "\", \"msg\": \"There was an error but it could not be serialized into JSON\"}") // fallback
+	}
+
+	return buf
+}
+
+func twirpCallResponsePrepared(ctx context.Context, h *twirp.ServerHooks) context.Context {
+	if h == nil || h.ResponsePrepared == nil {
+		return ctx
+	}
+	return h.ResponsePrepared(ctx)
+}
+
+func twirpCallClientResponseReceived(ctx context.Context, h *twirp.ClientHooks) {
+	if h == nil || h.ResponseReceived == nil {
+		return
+	}
+	h.ResponseReceived(ctx)
+}
+
+func twirpCallClientRequestPrepared(ctx context.Context, h *twirp.ClientHooks, req *http.Request) (context.Context, error) {
+	if h == nil || h.RequestPrepared == nil {
+		return ctx, nil
+	}
+	return h.RequestPrepared(ctx, req)
+}
+
+func twirpCallClientError(ctx context.Context, h *twirp.ClientHooks, err twirp.Error) {
+	if h == nil || h.Error == nil {
+		return
+	}
+	h.Error(ctx, err)
+}
+
+func twirpErrorFromResponse(resp *http.Response) twirp.Error {
+	statusCode := resp.StatusCode
+	statusText := http.StatusText(statusCode)
+
+	if statusCode >= 300 && statusCode <= 399 {
+		location := resp.Header.Get("Location")
+		msg := fmt.Sprintf("unexpected HTTP status code %d %q received, Location=%q", statusCode, statusText, location)
+		twerr := twirp.NewError(twirp.Internal, msg)
+		twerr = twerr.WithMeta("location", location)
+		twerr = twerr.WithMeta("http_error_from_intermediary", "true")
+		twerr = twerr.WithMeta("status_code", strconv.Itoa(statusCode))
+		return twerr
+	}
+
+	var tj twirpErrorJSON
+	d := jsonCodec.NewDecoder(resp.Body)
+	if err := d.Decode(&tj); err != nil || tj.Code == "" {
+		msg := fmt.Sprintf("error from intermediary with HTTP status code %d %q", statusCode, statusText)
+		var code twirp.ErrorCode
+		switch statusCode {
+		case 400: // Bad Request
+			code = twirp.Internal
+		case 401: // Unauthorized
+			code = twirp.Unauthenticated
+		case 403: // Forbidden
+			code = twirp.PermissionDenied
+		case 404: // Not Found
+			code = twirp.BadRoute
+		case 429: // Too Many Requests
+			code = twirp.ResourceExhausted
+		case 502, 503, 504: // Bad Gateway, Service Unavailable, Gateway Timeout
+			code = twirp.Unavailable
+		default: // All other codes
+			code = twirp.Unknown
+		}
+
+		twerr := twirp.NewError(code, msg)
+		if err != nil {
+			twerr = twirp.WrapError(twerr, err)
+		}
+		twerr = twerr.WithMeta("http_error_from_intermediary", "true")
+		twerr = twerr.WithMeta("status_code", strconv.Itoa(statusCode))
+		return twirpWithRetryAfter(twerr, resp)
+	}
+
+	errorCode := twirp.ErrorCode(tj.Code)
+	if !twirp.IsValidErrorCode(errorCode) {
+		msg := "invalid type returned from server error response: " + tj.Code
+		return twirp.InternalError(msg)
+	}
+
+	twerr := twirp.NewError(errorCode, tj.Msg)
+	for k, v := range tj.Meta {
+		twerr = twerr.WithMeta(k, v)
+	}
+	return twirpWithRetryAfter(twerr, resp)
+}
+
+// twirpWithRetryAfter adds the Retry-After header of resp to twerr as the "retry_after" metadata
+// in seconds, if twerr is twirp.ResourceExhausted or twirp.Unavailable.
+func twirpWithRetryAfter(twerr twirp.Error, resp *http.Response) twirp.Error {
+	if twerr.Code() != twirp.ResourceExhausted && twerr.Code() != twirp.Unavailable {
+		return twerr
+	}
+
+	seconds, ok := twirpParseRetryAfter(resp.Header.Get("Retry-After"))
+	if !ok {
+		return twerr
+	}
+
+	return twerr.WithMeta("retry_after", strconv.Itoa(seconds))
+}
+
+// twirpHandlerFacade implements the facades of services by serving calls as HTTP requests with
+// the server's handler.
+type twirpHandlerFacade struct {
+	handler    http.Handler
+	pathPrefix string
+}
+
+func (f *twirpHandlerFacade) Invoke(ctx context.Context, method string, in []byte, contentType string) ([]byte, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, f.pathPrefix+method, bytes.NewReader(in))
+	if err != nil {
+		return nil, "", twirp.NewError(twirp.BadRoute, fmt.Sprintf("invalid method %q", method))
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	resp := &twirpFacadeResponseWriter{header: make(http.Header)}
+	f.handler.ServeHTTP(resp, req)
+
+	out := resp.body.Bytes()
+	ct := resp.header.Get("Content-Type")
+	if resp.status != 0 && resp.status != http.StatusOK {
+		return out, ct, twirpErrorFromResponse(&http.Response{
+			StatusCode: resp.status,
+			Header:     resp.header,
+			Body:       ioutil.NopCloser(bytes.NewReader(out)),
+		})
+	}
+
+	return out, ct, nil
+}
+
+// twirpFacadeResponseWriter records the response to a facade call.
+type twirpFacadeResponseWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *twirpFacadeResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *twirpFacadeResponseWriter) WriteHeader(statusCode int) {
+	if w.status == 0 {
+		w.status = statusCode
+	}
+}
+
+func (w *twirpFacadeResponseWriter) Write(b []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.body.Write(b)
+}
+
+// twirpPathPrefixes returns the path prefix of service for each of versions, or only the
+// unversioned prefix if versions is empty.
+func twirpPathPrefixes(prefix string, versions []string, service string) []string {
+	if len(versions) == 0 {
+		return []string{path.Clean(path.Join("/", prefix, service)) + "/"}
+	}
+
+	prefixes := make([]string, 0, len(versions))
+	for _, version := range versions {
+		prefixes = append(prefixes, path.Clean(path.Join("/", prefix, version, service))+"/")
+	}
+
+	return prefixes
+}
+
+// twirpRoutePrefixes returns the path prefixes of the route template tmpl for service in pkg, in
+// the same order as versions.
+func twirpRoutePrefixes(tmpl string, pkg string, service string, versions []string) ([]string, error) {
+	if !strings.HasPrefix(tmpl, "/") {
+		return nil, fmt.Errorf("route template %q must start with \"/\"", tmpl)
+	}
+
+	prefix := strings.TrimSuffix(tmpl, "{method}")
+	if prefix == tmpl || strings.Contains(prefix, "{method}") {
+		return nil, fmt.Errorf("route template %q must end with its only {method}", tmpl)
+	}
+
+	rest := prefix
+	for {
+		start := strings.Index(rest, "{")
+		if start < 0 {
+			break
+		}
+
+		end := strings.Index(rest[start:], "}")
+		if end < 0 {
+			return nil, fmt.Errorf("route template %q has an unclosed placeholder", tmpl)
+		}
+
+		switch placeholder := rest[start : start+end+1]; placeholder {
+		case "{package}", "{service}":
+		case "{version}":
+			if len(versions) == 0 {
+				return nil, fmt.Errorf("route template %q has {version} but the service has no versions", tmpl)
+			}
+		default:
+			return nil, fmt.Errorf("route template %q has unknown placeholder %s", tmpl, placeholder)
+		}
+
+		rest = rest[start+end+1:]
+	}
+
+	prefix = strings.NewReplacer("{package}", pkg, "{service}", service).Replace(prefix)
+	if len(versions) == 0 {
+		return []string{prefix}, nil
+	}
+
+	if !strings.Contains(prefix, "{version}") {
+		return nil, fmt.Errorf("route template %q must have {version} for a service with versions", tmpl)
+	}
+
+	prefixes := make([]string, 0, len(versions))
+	for _, version := range versions {
+		prefixes = append(prefixes, strings.ReplaceAll(prefix, "{version}", version))
+	}
+
+	return prefixes, nil
+}
+
+type twirpVersionKey struct{}
+
+// TwirpVersion returns the version, set with the (twirpgo.version) service option, of the path
+// the request was sent to. It returns false for services without versions.
+func TwirpVersion(ctx context.Context) (string, bool) {
+	version, ok := ctx.Value(twirpVersionKey{}).(string)
+	return version, ok
+}
+
+// twirpVersionedHandler returns a handler that adds versions[i] to the context of h, if there are versions.
+func twirpVersionedHandler(versions []string, i int, h func(context.Context, http.ResponseWriter, *http.Request)) func(context.Context, http.ResponseWriter, *http.Request) {
+	if len(versions) == 0 {
+		return h
+	}
+
+	version := versions[i]
+	return func(ctx context.Context, resp http.ResponseWriter, req *http.Request) {
+		h(context.WithValue(ctx, twirpVersionKey{}, version), resp, req)
+	}
+}
+
+// TwirpCaller is implemented by clients created with New<Service>TwirpClient, including clients
+// generated in other packages, to call methods by name.
+type TwirpCaller interface {
+	Call(ctx context.Context, method string, req proto.Message) (proto.Message, error)
+}
+
+// TwirpHandler is implemented by servers created with New<Service>TwirpServer, including
+// servers generated in other packages.
+type TwirpHandler interface {
+	http.Handler
+	PathPrefix() string
+}
+
+// NewTwirpCombinedHandler returns a handler that serves all of servers, which may be
+// generated in different packages, routing requests by path prefix. Each server keeps its
+// own options, interceptors, and hooks. Requests for other paths get a twirp.BadRoute error.
+// It panics if two servers have the same path prefix.
+func NewTwirpCombinedHandler(servers ...TwirpHandler) http.Handler {
+	mux := http.NewServeMux()
+
+	for _, s := range servers {
+		if versioned, ok := s.(interface{ PathPrefixes() []string }); ok {
+			for _, prefix := range versioned.PathPrefixes() {
+				mux.Handle(prefix, s)
+			}
+			continue
+		}
+
+		mux.Handle(s.PathPrefix(), s)
+	}
+
+	mux.HandleFunc("/", func(resp http.ResponseWriter, req *http.Request) {
+		msg := fmt.Sprintf("no handler for path %q", req.URL.Path)
+		twerr := twirp.NewError(twirp.BadRoute, msg)
+		twerr = twerr.WithMeta("twirp_invalid_route", req.Method+" "+req.URL.Path)
+		twirpWriteError(req.Context(), resp, twerr, nil, nil)
+	})
+
+	return mux
+}
+
+// TwirpRouter is a router that Register<Service>TwirpHandler registers servers with, such as an
+// *http.ServeMux. Handle must send every request whose path begins with pattern, a path prefix
+// ending in "/", to handler, as *http.ServeMux does for such patterns, without rewriting the path
+// or filtering by HTTP method, so that the server routes, and rejects, requests itself. Routers
+// with other signatures, such as a grpc-gateway runtime.ServeMux, can be adapted with
+// TwirpRouterFunc.
+type TwirpRouter interface {
+	Handle(pattern string, handler http.Handler)
+}
+
+// TwirpRouterFunc adapts a function to a TwirpRouter.
+type TwirpRouterFunc func(pattern string, handler http.Handler)
+
+// Handle calls f(pattern, handler).
+func (f TwirpRouterFunc) Handle(pattern string, handler http.Handler) {
+	f(pattern, handler)
+}
+
+// RegisterDescriptor returns the descriptor of the twitch.twirp.example.legacy.Register service. Its
+// methods have the descriptors of their input and output messages, for tools that build
+// requests at runtime, like admin UIs.
+func RegisterDescriptor() protoreflect.ServiceDescriptor {
+	return File_legacy_legacy_proto.Services().ByName("Register")
+}
+
+// RegisterTwirpSchemaFingerprint is a hash of the methods of the twitch.twirp.example.legacy.Register
+// service and the messages and enums they use, as generated. Clients send it in the
+// TwirpSchemaFingerprintHeader, so that servers can detect clients generated from another version
+// of the schema. It is the same in every build of the same schema, and does not change with
+// comments and options.
+const RegisterTwirpSchemaFingerprint = "687c5699733917c180b216d5a66e0a6f"
+
+type RegisterTwirpService interface {
+	Checkout(context.Context, *Order) (*Receipt, error)
+}
+
+type RegisterTwirpServer struct {
+	implementation       RegisterTwirpService
+	interceptor          twirp.Interceptor
+	hooks                *twirp.ServerHooks
+	codecs               map[string]TwirpCodec
+	handlers             map[string]func(context.Context, http.ResponseWriter, *http.Request)
+	pathPrefixes         []string
+	bodyDumper           TwirpBodyDumper
+	sampler              func(string) bool
+	requestIDHeader      string
+	errorEncoder         func(twirp.Error) []byte
+	requestValidator     func(context.Context, string, proto.Message) error
+	responseTransformer  func(context.Context, string, proto.Message) (proto.Message, error)
+	rawBodyValidator     func(context.Context, string, []byte) error
+	schemaMismatch       func(context.Context, string, string) error
+	unknownMethod        func(http.ResponseWriter, *http.Request, string)
+	peerCertificateCheck func(context.Context, string, *x509.Certificate) error
+	cors                 *TwirpCORSConfig
+	fieldMask            bool
+	timeoutHeader        string
+	requireContentType   bool
+	defaultContentType   string
+	gzip                 bool
+	compressionThreshold int
+	httpErrorHandler     func(http.ResponseWriter, *http.Request, twirp.Error)
+	errorEnricher        func(context.Context, twirp.Error) twirp.Error
+	retryAfter           func(context.Context, twirp.Error) time.Duration
+	methodEnabled        func(string) bool
+	methodSemaphores     map[string]chan struct{}
+	flights              *twirpFlightGroup
+	idempotency          *twirpIdempotency
+	methodTimeouts       map[string]time.Duration
+	defaultTimeout       time.Duration
+	maxHeaderBytes       int
+	maxResponseBytes     int64
+	auditSink            func(context.Context, TwirpAuditEntry)
+	afterResponse        func(context.Context, string, error)
+	trailers             bool
+	headerAllowlist      map[string]func(string) (string, error)
+	tenant               *TwirpTenantConfig
+	drain                twirpDrain
+}
+
+func NewRegisterTwirpServer(implementation RegisterTwirpService, opts ...interface{}) *RegisterTwirpServer {
+	serverOpts := twirp.ServerOptions{}
+	twirpOpts := TwirpServerOptions{
+		codecs: map[string]TwirpCodec{
+			DefaultTwirpCodecJson.ContentType():     DefaultTwirpCodecJson,
+			DefaultTwirpCodecProtobuf.ContentType(): DefaultTwirpCodecProtobuf,
+			"application/x-protobuf":                &twirpContentTypeCodec{TwirpCodec: DefaultTwirpCodecProtobuf, contentType: "application/x-protobuf"},
+		},
+		compressionThreshold: TwirpDefaultCompressionThreshold,
+	}
+	for _, opt := range opts {
+		switch o := opt.(type) {
+		case twirp.ServerOption:
+			o(&serverOpts)
+		case TwirpServerOption:
+			o(&twirpOpts)
+		case nil:
+			continue
+		default:
+			panic(fmt.Sprintf("Invalid option type %T", o))
+		}
+	}
+
+	versions := []string{}
+	pathPrefixes := twirpPathPrefixes(serverOpts.PathPrefix(), versions, "twitch.twirp.example.legacy.Register")
+	if twirpOpts.routeTemplate != "" {
+		var err error
+		pathPrefixes, err = twirpRoutePrefixes(twirpOpts.routeTemplate, "twitch.twirp.example.legacy", "Register", versions)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	var interceptors []twirp.Interceptor
+
+	if twirpOpts.enforceDeadline {
+		interceptors = append(interceptors, twirpDeadlineInterceptor)
+	}
+
+	interceptors = append(interceptors, twirpPanicInterceptor, twirpContextInterceptor)
+
+	interceptors = append(interceptors, serverOpts.Interceptors...)
+
+	hooks := append([]*twirp.ServerHooks{serverOpts.Hooks}, twirpOpts.hooks...)
+	if twirpOpts.auditSink != nil {
+		hooks = append(hooks, twirpAuditHooks(twirpOpts.auditSink))
+	}
+	if twirpOpts.afterResponse != nil {
+		hooks = append(hooks, twirpAfterResponseHooks)
+	}
+
+	s := &RegisterTwirpServer{
+		implementation:       implementation,
+		interceptor:          twirp.ChainInterceptors(interceptors...),
+		hooks:                twirp.ChainHooks(hooks...),
+		pathPrefixes:         pathPrefixes,
+		codecs:               twirpOpts.codecs,
+		bodyDumper:           twirpOpts.bodyDumper,
+		sampler:              twirpOpts.sampler,
+		requestIDHeader:      twirpOpts.requestIDHeader,
+		errorEncoder:         twirpOpts.errorEncoder,
+		requestValidator:     twirpOpts.requestValidator,
+		responseTransformer:  twirpOpts.responseTransformer,
+		rawBodyValidator:     twirpOpts.rawBodyValidator,
+		schemaMismatch:       twirpOpts.schemaMismatch,
+		unknownMethod:        twirpOpts.unknownMethod,
+		peerCertificateCheck: twirpOpts.peerCertificateCheck,
+		cors:                 twirpOpts.cors,
+		fieldMask:            twirpOpts.fieldMask,
+		timeoutHeader:        twirpOpts.timeoutHeader,
+		requireContentType:   twirpOpts.requireContentType,
+		defaultContentType:   twirpOpts.defaultContentType,
+		gzip:                 twirpOpts.gzip,
+		compressionThreshold: twirpOpts.compressionThreshold,
+		httpErrorHandler:     twirpOpts.httpErrorHandler,
+		errorEnricher:        twirpOpts.errorEnricher,
+		retryAfter:           twirpOpts.retryAfter,
+		methodEnabled:        twirpOpts.methodEnabled,
+		methodSemaphores:     twirpMethodSemaphores(twirpOpts.methodConcurrency),
+		idempotency:          twirpOpts.idempotency,
+		methodTimeouts:       twirpOpts.methodTimeouts,
+		defaultTimeout:       twirpOpts.defaultTimeout,
+		maxHeaderBytes:       twirpOpts.maxHeaderBytes,
+		maxResponseBytes:     twirpOpts.maxResponseBytes,
+		auditSink:            twirpOpts.auditSink,
+		afterResponse:        twirpOpts.afterResponse,
+		trailers:             twirpOpts.trailers,
+		headerAllowlist:      twirpOpts.headerAllowlist,
+		tenant:               twirpOpts.tenant,
+		handlers:             map[string]func(context.Context, http.ResponseWriter, *http.Request){},
+	}
+
+	if twirpOpts.singleflight {
+		s.flights = &twirpFlightGroup{flights: make(map[string]*twirpFlight)}
+	}
+
+	for i, pathPrefix := range pathPrefixes {
+		s.handlers[pathPrefix+"Checkout"] = twirpVersionedHandler(versions, i, s.callCheckout)
+	}
+
+	return s
+}
+
+// RegisterRegisterTwirpHandler creates a server for implementation with opts, as
+// NewRegisterTwirpServer does, and registers it with router under each of its path
+// prefixes, to mount the service in an existing routing stack. It returns the server, such as
+// to Drain it.
+func RegisterRegisterTwirpHandler(router TwirpRouter, implementation RegisterTwirpService, opts ...interface{}) *RegisterTwirpServer {
+	s := NewRegisterTwirpServer(implementation, opts...)
+	for _, prefix := range s.pathPrefixes {
+		router.Handle(prefix, s)
+	}
+	return s
+}
+
+// PathPrefix returns the path prefix of the server. For services with several
+// (twirpgo.version) options, it is the prefix of the first version.
+func (s *RegisterTwirpServer) PathPrefix() string {
+	return s.pathPrefixes[0]
+}
+
+// PathPrefixes returns the path prefixes of the server, one for each (twirpgo.version)
+// option of the service, or only the unversioned prefix if it has none.
+func (s *RegisterTwirpServer) PathPrefixes() []string {
+	return append([]string(nil), s.pathPrefixes...)
+}
+
+// Drain stops the server from accepting requests, which then fail with twirp.Unavailable, and
+// waits until the requests it is handling, including calls of Invoke, have completed. If ctx is
+// done first, it returns ctx.Err() and the remaining requests keep running. The server does not
+// accept requests again after Drain, even if it returned an error.
+func (s *RegisterTwirpServer) Drain(ctx context.Context) error {
+	return s.drain.wait(ctx)
+}
+
+func (s *RegisterTwirpServer) writeError(ctx context.Context, resp http.ResponseWriter, req *http.Request, err error) {
+	if s.errorEnricher != nil || s.retryAfter != nil {
+		twerr := s.enrichError(ctx, err)
+		if s.retryAfter != nil && twerr.Code() == twirp.ResourceExhausted {
+			if wait := s.retryAfter(ctx, twerr); wait > 0 {
+				resp.Header().Set("Retry-After", strconv.FormatInt(int64((wait+time.Second-1)/time.Second), 10))
+			}
+		}
+		err = twerr
+	}
+
+	if s.httpErrorHandler != nil {
+		twirpHandleError(ctx, resp, req, err, s.hooks, s.httpErrorHandler)
+		return
+	}
+
+	twirpWriteError(ctx, resp, err, s.hooks, s.errorEncoder)
+}
+
+// enrichError returns err as a twirp.Error, passed through the error metadata enricher, if any.
+func (s *RegisterTwirpServer) enrichError(ctx context.Context, err error) twirp.Error {
+	twerr, ok := err.(twirp.Error)
+	if !ok {
+		twerr = twirp.InternalErrorWith(err)
+	}
+
+	if s.errorEnricher != nil {
+		if enriched := s.errorEnricher(ctx, twerr); enriched != nil {
+			twerr = enriched
+		}
+	}
+
+	return twerr
+}
+
+func (s *RegisterTwirpServer) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	ctx = ctxsetters.WithPackageName(ctx, "twitch.twirp.example.legacy")
+	ctx = ctxsetters.WithServiceName(ctx, "Register")
+	ctx = ctxsetters.WithResponseWriter(ctx, resp)
+
+	if s.afterResponse != nil {
+		var twerr twirp.Error
+		ctx = context.WithValue(ctx, twirpAfterResponseKey{}, &twerr)
+		defer func() {
+			s.callAfterResponse(ctx, resp, req, twerr)
+		}()
+	}
+
+	if !s.drain.start() {
+		s.writeError(ctx, resp, req, twirpDrainingError())
+		return
+	}
+	defer s.drain.done()
+
+	// the tenant's path segment is removed before anything uses the path
+	var tenant string
+	var tenantErr twirp.Error
+	if s.tenant != nil {
+		tenant, req, tenantErr = twirpTenant(s.tenant, req)
+	}
+
+	if s.cors != nil {
+		_, routed := s.handlers[req.URL.Path]
+		if twirpCORS(s.cors, resp, req, routed) {
+			return
+		}
+	}
+
+	if s.requestIDHeader != "" {
+		ctx = twirpWithRequestID(ctx, s.requestIDHeader, resp, req)
+	}
+
+	if s.timeoutHeader != "" {
+		if timeout, ok := twirpTimeoutFromHeader(req.Header.Get(s.timeoutHeader)); ok {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+	}
+
+	ctx, err := twirpCallRequestReceived(ctx, s.hooks)
+	if err != nil {
+		s.writeError(ctx, resp, req, err)
+		return
+	}
+
+	if s.maxHeaderBytes > 0 && twirpHeaderSize(req.Header) > s.maxHeaderBytes {
+		s.writeError(ctx, resp, req, twirp.NewError(twirp.Malformed, "request headers are too large"))
+		return
+	}
+
+	if tenantErr != nil {
+		s.writeError(ctx, resp, req, tenantErr)
+		return
+	}
+	if tenant != "" {
+		ctx = context.WithValue(ctx, twirpTenantKey{}, tenant)
+	}
+
+	if req.Method != http.MethodPost {
+		msg := fmt.Sprintf("unsupported method %q (only POST is allowed)", req.Method)
+		twerr := twirp.NewError(twirp.BadRoute, msg)
+		twerr = twerr.WithMeta("twirp_invalid_route", req.Method+" "+req.URL.Path)
+		s.writeError(ctx, resp, req, twerr)
+		return
+	}
+
+	handler, ok := s.handlers[req.URL.Path]
+	if !ok {
+		if s.unknownMethod != nil {
+			if method, ok := twirpUnknownMethod(s.pathPrefixes, req.URL.Path); ok {
+				s.unknownMethod(resp, req, method)
+				return
+			}
+		}
+
+		msg := fmt.Sprintf("no handler for path %q", req.URL.Path)
+		twerr := twirp.NewError(twirp.BadRoute, msg)
+		twerr = twerr.WithMeta("twirp_invalid_route", req.Method+" "+req.URL.Path)
+		s.writeError(ctx, resp, req, twerr)
+		return
+	}
+
+	if s.headerAllowlist != nil {
+		headers, err := twirpAllowedHeaders(s.headerAllowlist, req.Header)
+		if err != nil {
+			s.writeError(ctx, resp, req, err)
+			return
+		}
+		ctx = context.WithValue(ctx, twirpHeadersKey{}, headers)
+	}
+
+	if s.schemaMismatch != nil {
+		fingerprint := req.Header.Get(TwirpSchemaFingerprintHeader)
+		if fingerprint != "" && fingerprint != RegisterTwirpSchemaFingerprint {
+			if err := s.schemaMismatch(ctx, fingerprint, RegisterTwirpSchemaFingerprint); err != nil {
+				var twerr twirp.Error
+				if !errors.As(err, &twerr) {
+					twerr = twirp.WrapError(twirp.NewError(twirp.FailedPrecondition, err.Error()), err)
+				}
+				s.writeError(ctx, resp, req, twerr)
+				return
+			}
+		}
+	}
+
+	if s.sampler != nil {
+		ctx = context.WithValue(ctx, twirpSampledKey{}, s.sampler(path.Base(req.URL.Path)))
+	}
+
+	if s.peerCertificateCheck != nil && req.TLS != nil && len(req.TLS.PeerCertificates) > 0 {
+		method := path.Base(req.URL.Path)
+		if err := s.peerCertificateCheck(ctx, method, req.TLS.PeerCertificates[0]); err != nil {
+			var twerr twirp.Error
+			if !errors.As(err, &twerr) {
+				twerr = twirp.WrapError(twirp.NewError(twirp.PermissionDenied, err.Error()), err)
+			}
+			s.writeError(ctx, resp, req, twerr)
+			return
+		}
+	}
+
+	if s.trailers {
+		trailer := &twirpTrailer{header: http.Header{}}
+		ctx = context.WithValue(ctx, twirpTrailerKey{}, trailer)
+		defer trailer.write(resp)
+	}
+
+	handler(ctx, resp, req)
+}
+
+// callAfterResponse flushes resp and calls the function set with WithTwirpServerAfterResponse.
+func (s *RegisterTwirpServer) callAfterResponse(ctx context.Context, resp http.ResponseWriter, req *http.Request, twerr twirp.Error) {
+	if f, ok := resp.(http.Flusher); ok {
+		f.Flush()
+	}
+
+	var method string
+	if _, ok := s.handlers[req.URL.Path]; ok {
+		method = path.Base(req.URL.Path)
+	}
+
+	var err error
+	if twerr != nil {
+		err = twerr
+	}
+
+	s.afterResponse(ctx, method, err)
+}
+
+// responseCodec returns the codec for the first content type in the Accept header of req that
+// the server has a codec for, or codec, the codec of the request, if there is none.
+func (s *RegisterTwirpServer) responseCodec(req *http.Request, codec TwirpCodec) TwirpCodec {
+	for _, header := range req.Header.Values("Accept") {
+		for _, contentType := range strings.Split(header, ",") {
+			if i := strings.Index(contentType, ";"); i != -1 {
+				contentType = contentType[:i]
+			}
+
+			if accepted, ok := s.codecs[strings.TrimSpace(strings.ToLower(contentType))]; ok && accepted != nil {
+				return accepted
+			}
+		}
+	}
+
+	return codec
+}
+
+func (s *RegisterTwirpServer) getCodec(req *http.Request) (TwirpCodec, error) {
+	header := req.Header.Get("Content-Type")
+	if i := strings.Index(header, ";"); i != -1 {
+		header = header[:i]
+	}
+
+	header = strings.TrimSpace(strings.ToLower(header))
+
+	if header == "" {
+		if s.requireContentType {
+			return nil, twirp.NewError(twirp.Malformed, "missing Content-Type")
+		}
+
+		header = strings.ToLower(s.defaultContentType)
+	}
+
+	codec, ok := s.codecs[header]
+	if !ok || codec == nil {
+		msg := fmt.Sprintf("unexpected Content-Type: %q", req.Header.Get("Content-Type"))
+		twerr := twirp.NewError(twirp.BadRoute, msg)
+		twerr = twerr.WithMeta("twirp_invalid_route", req.Method+" "+req.URL.Path)
+		return nil, twerr
+	}
+
+	return codec, nil
+}
+
+// Invoke calls the method with the given name, such as "Checkout", on the implementation
+// without going through HTTP. req must have the input type of the method. The method-enabled check,
+// method timeouts, request validator and interceptors are applied as for HTTP requests. Server hooks
+// and HTTP-only options, such as CORS, codecs, compression and the HTTP error handler, are skipped, so
+// any authentication done in hooks is bypassed and Invoke should only be used by trusted callers.
+// Calls of methods with the (twirpgo.auditable) option are still passed to the audit sink, with nil
+// Request and Response bodies. Unknown methods fail with a twirp.BadRoute error, and requests of the
+// wrong type with a twirp.InvalidArgument error.
+func (s *RegisterTwirpServer) Invoke(ctx context.Context, method string, req proto.Message) (resp proto.Message, err error) {
+	if !s.drain.start() {
+		return nil, twirpDrainingError()
+	}
+	defer s.drain.done()
+
+	ctx = ctxsetters.WithPackageName(ctx, "twitch.twirp.example.legacy")
+	ctx = ctxsetters.WithServiceName(ctx, "Register")
+
+	switch method {
+	case "Checkout":
+		in, ok := req.(*Order)
+		if !ok {
+			return nil, twirp.NewError(twirp.InvalidArgument, fmt.Sprintf("invalid request type %T for Checkout, expected *Order", req))
+		}
+
+		ctx = ctxsetters.WithMethodName(ctx, "Checkout")
+		ctx, cancel, err := s.prepareInvoke(ctx, "Checkout", in)
+		defer cancel()
+		if err != nil {
+			return nil, err
+		}
+		out, err := s.handleCheckout(ctx, in)
+		if err != nil {
+			return nil, err
+		}
+		if out == nil {
+			return nil, twirp.InternalError("received a nil *Receipt and nil error while calling Checkout. nil responses are not supported")
+		}
+		return out, nil
+	}
+
+	return nil, twirp.NewError(twirp.BadRoute, fmt.Sprintf("unknown method %q", method))
+}
+
+// auditInvoke completes the audit entry of an Invoke call with its result and passes it to the
+// audit sink.
+func (s *RegisterTwirpServer) auditInvoke(ctx context.Context, entry *TwirpAuditEntry, resp proto.Message, err error) {
+	if err != nil {
+		twerr, ok := err.(twirp.Error)
+		if !ok {
+			twerr = twirp.InternalErrorWith(err)
+		}
+		entry.Error = twerr
+	} else {
+		entry.ResponseMessage = resp
+	}
+	s.auditSink(ctx, *entry)
+}
+
+// prepareInvoke applies the method-enabled check, the method concurrency limit, the method timeout
+// and the request validator for Invoke. The returned cancel func must always be called.
+func (s *RegisterTwirpServer) prepareInvoke(ctx context.Context, method string, req proto.Message) (context.Context, context.CancelFunc, error) {
+	cancel := func() {}
+	if s.methodEnabled != nil && !s.methodEnabled(method) {
+		return ctx, cancel, twirp.NewError(twirp.Unavailable, "method "+method+" is disabled")
+	}
+
+	release, twerr := twirpAcquireMethod(s.methodSemaphores, method)
+	if twerr != nil {
+		return ctx, cancel, twerr
+	}
+	cancel = release
+
+	if timeout := twirpMethodTimeout(s.methodTimeouts, s.defaultTimeout, method); timeout > 0 {
+		var cancelTimeout context.CancelFunc
+		ctx, cancelTimeout = context.WithTimeout(ctx, timeout)
+		cancel = func() {
+			cancelTimeout()
+			release()
+		}
+	}
+
+	if s.requestValidator != nil {
+		if err := s.requestValidator(ctx, method, req); err != nil {
+			return ctx, cancel, twirpValidationError(err)
+		}
+	}
+
+	return ctx, cancel, nil
+}
+
+// RegisterTwirpFacade calls the methods of Register with encoded messages, for generic
+// proxies and routers that do not have its Go types.
+type RegisterTwirpFacade interface {
+	// Invoke calls method, such as "Checkout", with in, encoded with contentType, such as
+	// "application/json", and returns the encoded response and its content type. Failed calls
+	// return the encoded error response, with its content type, and the error as a twirp.Error.
+	Invoke(ctx context.Context, method string, in []byte, contentType string) (out []byte, ct string, err error)
+}
+
+// Facade returns a RegisterTwirpFacade that serves calls as if they were sent to s over HTTP, so
+// that its codecs, hooks, interceptors and error encoding apply, unlike with Invoke. Calls to
+// server streaming methods are not supported.
+func (s *RegisterTwirpServer) Facade() RegisterTwirpFacade {
+	return &twirpHandlerFacade{handler: s, pathPrefix: s.pathPrefixes[0]}
+}
+
+func (s *RegisterTwirpServer) callCheckout(ctx context.Context, resp http.ResponseWriter, req *http.Request) {
+	codec, err := s.getCodec(req)
+	if err != nil {
+		s.writeError(ctx, resp, req, err)
+		return
+	}
+
+	ctx = ctxsetters.WithMethodName(ctx, "Checkout")
+	ctx, err = twirpCallRequestRouted(ctx, s.hooks)
+	if err != nil {
+		s.writeError(ctx, resp, req, err)
+		return
+	}
+
+	if s.methodEnabled != nil && !s.methodEnabled("Checkout") {
+		s.writeError(ctx, resp, req, twirp.NewError(twirp.Unavailable, "method Checkout is disabled"))
+		return
+	}
+
+	release, twerr := twirpAcquireMethod(s.methodSemaphores, "Checkout")
+	if twerr != nil {
+		s.writeError(ctx, resp, req, twerr)
+		return
+	}
+	defer release()
+
+	if timeout := twirpMethodTimeout(s.methodTimeouts, s.defaultTimeout, "Checkout"); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	reqContent := new(Order)
+
+	body := twirpBodyReader(req.Body, req.ContentLength)
+	if s.bodyDumper != nil && twirpLogDetail(ctx) {
+		body, err = twirpDumpBody(ctx, s.bodyDumper, "request", body)
+		if err != nil {
+			twerr := twirp.NewError(twirp.Malformed, "the request could not be decoded")
+			twerr = twerr.WithMeta("cause", err.Error())
+			s.writeError(ctx, resp, req, twerr)
+			return
+		}
+	}
+
+	if s.rawBodyValidator != nil {
+		var twerr twirp.Error
+		body, twerr = twirpValidateRawBody(ctx, s.rawBodyValidator, "Checkout", body)
+		if twerr != nil {
+			s.writeError(ctx, resp, req, twerr)
+			return
+		}
+	}
+
+	if err := codec.UnmarshalFrom(ctx, reqContent, body); err != nil {
+		twerr := twirp.NewError(twirp.Malformed, "the request could not be decoded")
+		twerr = twerr.WithMeta("cause", err.Error())
+		s.writeError(ctx, resp, req, twerr)
+		return
+	}
+
+	if s.requestValidator != nil {
+		if err := s.requestValidator(ctx, "Checkout", reqContent); err != nil {
+			s.writeError(ctx, resp, req, twirpValidationError(err))
+			return
+		}
+	}
+	respContent, err := s.dedupeCheckout(ctx, req.Header.Get(TwirpIdempotencyKeyHeader), reqContent)
+
+	if err != nil {
+		s.writeError(ctx, resp, req, err)
+		return
+	}
+
+	if respContent == nil {
+		s.writeError(ctx, resp, req, twirp.InternalError("received a nil *Receipt and nil error while calling Checkout. nil responses are not supported"))
+		return
+	}
+
+	var respMessage proto.Message = respContent
+	if s.responseTransformer != nil {
+		respMessage, err = s.responseTransformer(ctx, "Checkout", respContent)
+		if err != nil {
+			s.writeError(ctx, resp, req, twirpResponseTransformError(err))
+			return
+		}
+
+		if respMessage == nil {
+			s.writeError(ctx, resp, req, twirp.InternalError("the response transformer returned a nil response for Checkout"))
+			return
+		}
+	}
+
+	ctx = twirpCallResponsePrepared(ctx, s.hooks)
+
+	buff := twirpBufferPool.Get().(*bytes.Buffer)
+	defer twirpBufferPool.Put(buff)
+
+	buff.Reset()
+
+	codec = s.responseCodec(req, codec)
+
+	if s.fieldMask {
+		codec, respMessage = twirpMaskResponse(req, codec, respMessage)
+	}
+
+	if err := codec.MarshalTo(ctx, respMessage, buff); err != nil {
+		twerr := twirp.InternalError("failed to marshal response")
+		twerr = twerr.WithMeta("cause", err.Error())
+		s.writeError(ctx, resp, req, twerr)
+		return
+	}
+
+	if s.maxResponseBytes > 0 && int64(buff.Len()) > s.maxResponseBytes {
+		twerr := twirp.InternalError("response is too large")
+		twerr = twerr.WithMeta("response_bytes", strconv.Itoa(buff.Len()))
+		s.writeError(ctx, resp, req, twerr)
+		return
+	}
+
+	respBody := buff
+	if s.gzip {
+		resp.Header().Add("Vary", "Accept-Encoding")
+
+		if buff.Len() >= s.compressionThreshold && twirpAcceptsGzip(req) {
+			compressed := twirpBufferPool.Get().(*bytes.Buffer)
+			defer twirpBufferPool.Put(compressed)
+
+			compressed.Reset()
+
+			if err := twirpGzip(compressed, buff.Bytes()); err != nil {
+				twerr := twirp.InternalError("failed to compress response")
+				twerr = twerr.WithMeta("cause", err.Error())
+				s.writeError(ctx, resp, req, twerr)
+				return
+			}
+
+			resp.Header()["Content-Encoding"] = []string{"gzip"}
+			respBody = compressed
+		}
+	}
+
+	if s.bodyDumper != nil && twirpLogDetail(ctx) {
+		s.bodyDumper("response", "Checkout", respBody.Bytes())
+	}
+
+	// the response is always buffered, so proxies get its length instead of a chunked body, unless
+	// it may have trailers, which need one
+	ctx = ctxsetters.WithStatusCode(ctx, http.StatusOK)
+	resp.Header()["Content-Type"] = []string{codec.ContentType()}
+	if !s.trailers {
+		resp.Header()["Content-Length"] = []string{strconv.Itoa(respBody.Len())}
+	}
+	resp.WriteHeader(http.StatusOK)
+
+	if _, err := io.Copy(resp, respBody); err != nil {
+		msg := fmt.Sprintf("failed to write response: %s", err.Error())
+		twerr := twirp.NewError(twirp.Unknown, msg)
+		ctx = twirpCallError(ctx, s.hooks, twerr)
+	}
+
+	// net/http sets the Content-Length of short responses itself unless they are flushed
+	if f, ok := resp.(http.Flusher); ok && s.trailers {
+		f.Flush()
+	}
+
+	twirpCallResponseSent(ctx, s.hooks)
+}
+
+// handleCheckout calls the implementation through the interceptors of the server.
+func (s *RegisterTwirpServer) handleCheckout(ctx context.Context, req *Order) (*Receipt, error) {
+	if s.interceptor == nil {
+		return s.implementation.Checkout(ctx, req)
+	}
+
+	resp, err := s.interceptor(
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			typedReq, ok := req.(*Order)
+			if !ok {
+				return nil, twirp.InternalError("failed type assertion req.(*Order) when calling interceptor")
+			}
+			return s.implementation.Checkout(ctx, typedReq)
+		},
+	)(ctx, req)
+	if resp != nil {
+		typedResp, ok := resp.(*Receipt)
+		if !ok {
+			return nil, twirp.InternalError("failed type assertion resp.(*Receipt) when calling interceptor")
+		}
+		return typedResp, err
+	}
+	return nil, err
+}
+
+// dedupeCheckout calls handleCheckout once per idempotency key when the server is created
+// with WithTwirpServerIdempotencyStore.
+func (s *RegisterTwirpServer) dedupeCheckout(ctx context.Context, key string, req *Order) (*Receipt, error) {
+	if s.idempotency == nil || key == "" {
+		return s.handleCheckout(ctx, req)
+	}
+
+	key = "twitch.twirp.example.legacy.Register/Checkout\x00" + key
+
+	cached := &Receipt{}
+	done, err := s.idempotency.begin(ctx, key, cached)
+	if err != nil {
+		return nil, err
+	}
+	if done {
+		return cached, nil
+	}
+
+	out, err := s.handleCheckout(ctx, req)
+	s.idempotency.end(ctx, key, out, err)
+	return out, err
+}
+
+type RegisterTwirpClient struct {
+	client      *http.Client
+	codec       TwirpCodec
+	hooks       *twirp.ClientHooks
+	interceptor twirp.Interceptor
+	// requests holds a prepared request for each method and base URL, indexed by method first.
+	requests          [][]*http.Request
+	balancer          TwirpBalancer
+	bodyDumper        TwirpBodyDumper
+	expectContinue    bool
+	responseValidator func(string, proto.Message) error
+	connCallback      func(string, httptrace.GotConnInfo)
+	timingCallback    func(string, TwirpTimings)
+	timeout           time.Duration
+	timeoutHeader     string
+	hedgeDelay        time.Duration
+	hedgeExtra        int
+	tokens            *twirpTokenCache
+	observer          TwirpObserver
+	etags             *twirpETagCache
+	flights           *twirpFlightGroup
+	recorder          twirpCallRecorder
+	metrics           func(string, time.Duration, error)
+	jsonFallback      bool
+	acceptEncoding    string
+	// canary is set if the last request for each method in requests and streamRequests is for the
+	// canary base URL.
+	canary       bool
+	canaryWeight float64
+}
+
+func NewRegisterTwirpClient(baseUrl string, transport http.RoundTripper, opts ...interface{}) (*RegisterTwirpClient, error) {
+	return NewRegisterTwirpClientBalanced([]string{baseUrl}, transport, nil, opts...)
+}
+
+// NewRegisterTwirpClientBalanced creates a client that distributes requests across baseUrls,
+// using balancer to choose the base URL for each request. A nil balancer defaults to
+// NewTwirpRoundRobinBalancer.
+//
+// When sending a request fails with a connection error, requests to idempotent methods,
+// those with an idempotency_level of IDEMPOTENT or NO_SIDE_EFFECTS, are sent to the next
+// base URL, until every base URL has been tried once. Requests that receive a response,
+// including an error response, are never sent again.
+func NewRegisterTwirpClientBalanced(baseUrls []string, transport http.RoundTripper, balancer TwirpBalancer, opts ...interface{}) (*RegisterTwirpClient, error) {
+	if len(baseUrls) == 0 {
+		return nil, errors.New("at least one base URL is required")
+	}
+
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	if balancer == nil {
+		balancer = NewTwirpRoundRobinBalancer()
+	}
+
+	clientOpts := twirp.ClientOptions{}
+	twirpOpts := TwirpClientOptions{
+		codec:         DefaultTwirpCodecProtobuf,
+		etagCacheSize: TwirpDefaultETagCacheSize,
+	}
+
+	for _, opt := range opts {
+		switch o := opt.(type) {
+		case twirp.ClientOption:
+			o(&clientOpts)
+		case TwirpClientOption:
+			o(&twirpOpts)
+		case nil:
+			continue
+		default:
+			return nil, fmt.Errorf("invalid option type %T", o)
+		}
+	}
+
+	for _, encoding := range twirpOpts.acceptEncodings {
+		if encoding != "gzip" && encoding != "identity" {
+			return nil, fmt.Errorf("unsupported response encoding %q", encoding)
+		}
+	}
+
+	if twirpOpts.canaryURL != "" {
+		if !(twirpOpts.canaryWeight >= 0 && twirpOpts.canaryWeight <= 1) {
+			return nil, fmt.Errorf("canary weight %v is not between 0 and 1", twirpOpts.canaryWeight)
+		}
+
+		baseUrls = append(baseUrls[:len(baseUrls):len(baseUrls)], twirpOpts.canaryURL)
+	}
+
+	if twirpOpts.protobufContentType != "" && twirpOpts.codec.ContentType() == DefaultTwirpCodecProtobuf.ContentType() {
+		twirpOpts.codec = &twirpContentTypeCodec{TwirpCodec: twirpOpts.codec, contentType: twirpOpts.protobufContentType}
+	}
+
+	c := RegisterTwirpClient{
+		balancer:          balancer,
+		codec:             twirpOpts.codec,
+		bodyDumper:        twirpOpts.bodyDumper,
+		expectContinue:    twirpOpts.expectContinue,
+		responseValidator: twirpOpts.responseValidator,
+		connCallback:      twirpOpts.connCallback,
+		timingCallback:    twirpOpts.timingCallback,
+		metrics:           twirpOpts.metrics,
+		jsonFallback:      twirpOpts.jsonFallback,
+		acceptEncoding:    strings.Join(twirpOpts.acceptEncodings, ", "),
+		canary:            twirpOpts.canaryURL != "",
+		canaryWeight:      twirpOpts.canaryWeight,
+		timeout:           twirpOpts.timeout,
+		timeoutHeader:     twirpOpts.timeoutHeader,
+		hedgeDelay:        twirpOpts.hedgeDelay,
+		hedgeExtra:        twirpOpts.hedgeExtra,
+		observer:          twirpOpts.observer,
+		hooks:             clientOpts.Hooks,
+		interceptor:       twirp.ChainInterceptors(clientOpts.Interceptors...),
+		client: &http.Client{
+			Transport: transport,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+
+	if twirpOpts.tokenSource != nil {
+		c.tokens = &twirpTokenCache{source: twirpOpts.tokenSource}
+	}
+
+	if twirpOpts.etagCacheSize > 0 {
+		c.etags = newTwirpETagCache(twirpOpts.etagCacheSize)
+	}
+
+	if twirpOpts.singleflight {
+		c.flights = &twirpFlightGroup{flights: make(map[string]*twirpFlight)}
+	}
+
+	if twirpOpts.recorder != nil {
+		var err error
+		c.recorder, err = twirpOpts.recorder()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	versions := []string{}
+	pathPrefixes := twirpPathPrefixes(clientOpts.PathPrefix(), versions, "twitch.twirp.example.legacy.Register")
+	if twirpOpts.routeTemplate != "" {
+		var err error
+		pathPrefixes, err = twirpRoutePrefixes(twirpOpts.routeTemplate, "twitch.twirp.example.legacy", "Register", versions)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	pathPrefix := pathPrefixes[0]
+	if twirpOpts.version != "" {
+		pathPrefix = ""
+		for i, version := range versions {
+			if version == twirpOpts.version {
+				pathPrefix = pathPrefixes[i]
+			}
+		}
+
+		if pathPrefix == "" {
+			return nil, fmt.Errorf("unknown version %q", twirpOpts.version)
+		}
+	}
+
+	methods := []string{"Checkout"}
+	c.requests = make([][]*http.Request, len(methods))
+
+	for _, baseUrl := range baseUrls {
+		u, err := url.Parse(baseUrl)
+		if err != nil {
+			return nil, err
+		}
+
+		if u.Scheme == "" {
+			u.Scheme = "http"
+		}
+
+		baseUrl = strings.TrimRight(u.String(), "/")
+
+		for i, method := range methods {
+			request, err := http.NewRequest(http.MethodPost, baseUrl+pathPrefix+method, nil)
+			if err != nil {
+				return nil, err
+			}
+			request.ContentLength = -1
+			request.Header.Del("Content-Length")
+			request.Header.Set("Content-Type", c.codec.ContentType())
+			request.Header.Set("Accept", c.codec.ContentType())
+			request.Header.Set(TwirpSchemaFingerprintHeader, RegisterTwirpSchemaFingerprint)
+			c.requests[i] = append(c.requests[i], request)
+		}
+	}
+
+	return &c, nil
+}
+
+// doAuthorizedRequest calls doRequest with a token from the token source, if the client has one.
+// Requests rejected as unauthenticated are sent once more with a new token.
+func (c *RegisterTwirpClient) doAuthorizedRequest(ctx context.Context, requests []*http.Request, failover bool, cacheable bool, in proto.Message, out proto.Message) (context.Context, error) {
+	noRetry, _ := ctx.Value(twirpNoRetryKey{}).(bool)
+	if noRetry {
+		failover = false
+	}
+
+	if c.tokens == nil {
+		return c.doRequest(ctx, requests, failover, cacheable, in, out)
+	}
+
+	for attempt := 1; ; attempt++ {
+		token, err := c.tokens.get(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		tokenCtx, err := twirpWithToken(ctx, token)
+		if err != nil {
+			return nil, twirp.InternalErrorWith(err)
+		}
+
+		respCtx, err := c.doRequest(tokenCtx, requests, failover, cacheable, in, out)
+
+		var twerr twirp.Error
+		if errors.As(err, &twerr) && twerr.Code() == twirp.Unauthenticated {
+			c.tokens.invalidate(token)
+			if attempt == 1 && !noRetry {
+				continue
+			}
+		}
+
+		return respCtx, err
+	}
+}
+
+// doSharedRequest calls doAuthorizedRequest, sharing one request between concurrent calls with
+// identical requests to an idempotent method when the client is created with
+// WithTwirpClientSingleflight. Clients with a recorder, such as a cassette, record or replay the
+// call instead.
+func (c *RegisterTwirpClient) doSharedRequest(ctx context.Context, requests []*http.Request, idempotent bool, cacheable bool, in proto.Message, out proto.Message) (context.Context, error) {
+	if c.recorder != nil {
+		method, _ := twirp.MethodName(ctx)
+		return c.recorder.do(ctx, method, in, out, func() (context.Context, error) {
+			return c.doAuthorizedRequest(ctx, requests, idempotent, cacheable, in, out)
+		})
+	}
+
+	if c.flights == nil || !idempotent {
+		return c.doAuthorizedRequest(ctx, requests, idempotent, cacheable, in, out)
+	}
+
+	key, err := proto.MarshalOptions{Deterministic: true}.Marshal(in)
+	if err != nil {
+		return c.doAuthorizedRequest(ctx, requests, idempotent, cacheable, in, out)
+	}
+
+	var respCtx context.Context
+	resp, shared, err := c.flights.do(ctx, requests[0].URL.Path+"\x00"+string(key), func() (proto.Message, error) {
+		var err error
+		respCtx, err = c.doAuthorizedRequest(ctx, requests, idempotent, cacheable, in, out)
+		if err != nil {
+			return nil, err
+		}
+		// the caller owns out, so the others get a copy that it cannot modify
+		return proto.Clone(out), nil
+	})
+	if !shared {
+		return respCtx, err
+	}
+	if err != nil {
+		return ctx, err
+	}
+
+	proto.Merge(out, resp)
+	return ctx, nil
+}
+
+// route returns the requests, of those for a method, that a call with ctx may be sent to, and the
+// index of the one to send it to: the canary, or one of the others chosen by the balancer.
+func (c *RegisterTwirpClient) route(ctx context.Context, requests []*http.Request) ([]*http.Request, int) {
+	if c.canary {
+		n := len(requests) - 1
+		if twirpCanary(ctx, c.canaryWeight) {
+			return requests[n:], 0
+		}
+		requests = requests[:n]
+	}
+
+	if len(requests) > 1 {
+		return requests, c.balancer.Pick(len(requests))
+	}
+
+	return requests, 0
+}
+
+// doRequest sends in to one of requests, chosen by route, and decodes the response into out.
+// If failover is set, connection errors are retried with the remaining requests route allows.
+func (c *RegisterTwirpClient) doRequest(ctx context.Context, requests []*http.Request, failover bool, cacheable bool, in proto.Message, out proto.Message) (context.Context, error) {
+	buff := twirpBufferPool.Get().(*bytes.Buffer)
+	defer twirpBufferPool.Put(buff)
+	buff.Reset()
+
+	codec := c.codec
+	if override, ok := ctx.Value(twirpCodecKey{}).(TwirpCodec); ok {
+		codec = override
+	}
+
+	if err := codec.MarshalTo(ctx, in, buff); err != nil {
+		twerr := twirp.NewError(twirp.Internal, "failed to marshal request")
+		twerr = twerr.WithMeta("cause", err.Error())
+		return nil, twerr
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, twirpContextError(err)
+	}
+
+	if c.bodyDumper != nil {
+		method, _ := twirp.MethodName(ctx)
+		c.bodyDumper("request", method, buff.Bytes())
+	}
+
+	targets, target := c.route(ctx, requests)
+
+	req := targets[target].Clone(ctx)
+	if codec.ContentType() != c.codec.ContentType() {
+		req.Header.Set("Content-Type", codec.ContentType())
+		req.Header.Set("Accept", codec.ContentType())
+	}
+
+	if c.expectContinue {
+		req.Header.Set("Expect", "100-continue")
+	}
+
+	if c.acceptEncoding != "" {
+		req.Header.Set("Accept-Encoding", c.acceptEncoding)
+	}
+
+	if deadline, ok := ctx.Deadline(); ok && c.timeoutHeader != "" {
+		ms := time.Until(deadline).Milliseconds()
+		if ms < 1 {
+			ms = 1
+		}
+		req.Header.Set(c.timeoutHeader, strconv.FormatInt(ms, 10))
+	}
+
+	var cacheKey string
+	var cached *twirpETagEntry
+	if cacheable && c.etags != nil {
+		cacheKey = targets[0].URL.Path + "\x00" + buff.String()
+		if entry, ok := c.etags.get(cacheKey); ok {
+			cached = entry
+			req.Header.Set("If-None-Match", entry.etag)
+		}
+	}
+
+	if c.connCallback != nil {
+		method, _ := twirp.MethodName(ctx)
+		trace := &httptrace.ClientTrace{
+			GotConn: func(info httptrace.GotConnInfo) {
+				c.connCallback(method, info)
+			},
+		}
+		req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+	}
+
+	var timings *twirpTimingTrace
+	if c.timingCallback != nil {
+		timings = &twirpTimingTrace{}
+		req = req.WithContext(httptrace.WithClientTrace(req.Context(), timings.clientTrace()))
+	}
+
+	if header, ok := twirp.HTTPRequestHeaders(ctx); ok {
+		for k, vv := range header {
+			for _, v := range vv {
+				req.Header.Add(k, v)
+			}
+		}
+	}
+
+	ctx, err := twirpCallClientRequestPrepared(ctx, c.hooks, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if timings != nil {
+		// deferred before the body is closed, so that Total includes reading it
+		method, _ := twirp.MethodName(ctx)
+		timings.start = time.Now()
+		defer func() {
+			c.timingCallback(method, timings.done())
+		}()
+	}
+
+	var resp *http.Response
+	if failover && c.hedgeDelay > 0 {
+		// hedged requests may still be sending the body after this returns, so they
+		// cannot use the pooled buffer
+		body := append([]byte(nil), buff.Bytes()...)
+		resp, err = twirpDoHedged(c.client, req, body, targets, target, c.hedgeDelay, c.hedgeExtra)
+	} else {
+		for attempt := 1; ; attempt++ {
+			req.Body = ioutil.NopCloser(bytes.NewReader(buff.Bytes()))
+
+			resp, err = c.client.Do(req)
+			if err == nil || !failover || attempt == len(targets) || ctx.Err() != nil {
+				break
+			}
+
+			next := targets[(target+attempt)%len(targets)]
+
+			req = req.Clone(req.Context())
+			req.URL = next.URL
+			req.Host = next.Host
+		}
+	}
+
+	if err == nil && resp.StatusCode == http.StatusUnsupportedMediaType && c.jsonFallback && codec.ContentType() != DefaultTwirpCodecJson.ContentType() {
+		_, _ = io.Copy(ioutil.Discard, resp.Body)
+		_ = resp.Body.Close()
+
+		// sent again as JSON to the server that rejected it, without routing the call or
+		// calling the hooks again
+		codec = DefaultTwirpCodecJson
+		buff.Reset()
+		if err := codec.MarshalTo(ctx, in, buff); err != nil {
+			twerr := twirp.NewError(twirp.Internal, "failed to marshal request")
+			twerr = twerr.WithMeta("cause", err.Error())
+			return nil, twerr
+		}
+
+		if c.bodyDumper != nil {
+			method, _ := twirp.MethodName(ctx)
+			c.bodyDumper("request", method, buff.Bytes())
+		}
+
+		// hedged requests have their own context, which is canceled once their body is closed
+		rejected := resp.Request
+		req = req.Clone(req.Context())
+		if rejected != nil {
+			req.URL = rejected.URL
+			req.Host = rejected.Host
+		}
+		req.Header.Set("Content-Type", codec.ContentType())
+		req.Header.Set("Accept", codec.ContentType())
+		req.Header.Del("If-None-Match")
+
+		cacheKey, cached = "", nil
+		if cacheable && c.etags != nil {
+			cacheKey = targets[0].URL.Path + "\x00" + buff.String()
+			if entry, ok := c.etags.get(cacheKey); ok {
+				cached = entry
+				req.Header.Set("If-None-Match", entry.etag)
+			}
+		}
+
+		req.Body = ioutil.NopCloser(bytes.NewReader(buff.Bytes()))
+		resp, err = c.client.Do(req)
+	}
+
+	if err != nil {
+		// the transport aborts the request when the context is done
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, twirpContextError(ctxErr)
+		}
+
+		twerr := twirp.NewError(twirp.Internal, "failed to do request")
+		twerr = twirp.WrapError(twerr, err)
+		return nil, twerr
+	}
+
+	defer func() {
+		_, _ = io.Copy(ioutil.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}()
+
+	// dumped before it is decompressed, as it was received
+	if c.bodyDumper != nil && resp.StatusCode == http.StatusOK {
+		body, err := twirpDumpBody(ctx, c.bodyDumper, "response", twirpBodyReader(resp.Body, resp.ContentLength))
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return nil, twirpContextError(ctxErr)
+			}
+
+			twerr := twirp.NewError(twirp.Internal, "failed to read response")
+			twerr = twirp.WrapError(twerr, err)
+			return nil, twerr
+		}
+
+		_ = resp.Body.Close()
+		resp.Body = ioutil.NopCloser(body)
+	}
+
+	if c.acceptEncoding != "" && resp.StatusCode != http.StatusNotModified {
+		if err := twirpDecodeResponse(resp); err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return nil, twirpContextError(ctxErr)
+			}
+
+			return nil, err
+		}
+	}
+
+	var body io.Reader
+	switch {
+	case resp.StatusCode == http.StatusNotModified && cached != nil:
+		body = bytes.NewReader(cached.body)
+	case resp.StatusCode != http.StatusOK:
+		return nil, twirpErrorFromResponse(resp)
+	default:
+		body = twirpBodyReader(resp.Body, resp.ContentLength)
+	}
+
+	// the body of a response with an ETag is kept, and cached once it is known to be valid
+	var etag string
+	var etagBody []byte
+	if cacheKey != "" && resp.StatusCode == http.StatusOK {
+		if etag = resp.Header.Get("ETag"); etag != "" {
+			etagBody, err = ioutil.ReadAll(body)
+			if err != nil {
+				if ctxErr := ctx.Err(); ctxErr != nil {
+					return nil, twirpContextError(ctxErr)
+				}
+
+				twerr := twirp.NewError(twirp.Internal, "failed to read response")
+				twerr = twirp.WrapError(twerr, err)
+				return nil, twerr
+			}
+			body = bytes.NewReader(etagBody)
+		}
+	}
+
+	if err := codec.UnmarshalFrom(ctx, out, body); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, twirpContextError(ctxErr)
+		}
+
+		twerr := twirp.NewError(twirp.Internal, "failed to unmarshal response")
+		twerr = twirp.WrapError(twerr, err)
+		return nil, twerr
+	}
+
+	// trailers are only known once the body has been read to the end
+	if trailer, ok := ctx.Value(twirpCallTrailerKey{}).(*http.Header); ok && resp.StatusCode == http.StatusOK {
+		if _, err := io.Copy(ioutil.Discard, resp.Body); err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return nil, twirpContextError(ctxErr)
+			}
+
+			twerr := twirp.NewError(twirp.Internal, "failed to read response")
+			twerr = twirp.WrapError(twerr, err)
+			return nil, twerr
+		}
+		*trailer = resp.Trailer.Clone()
+	}
+
+	if c.responseValidator != nil {
+		method, _ := twirp.MethodName(ctx)
+		if err := c.responseValidator(method, out); err != nil {
+			var twerr twirp.Error
+			if errors.As(err, &twerr) {
+				return nil, twerr
+			}
+			twerr = twirp.NewError(twirp.Internal, "invalid response: "+err.Error())
+			return nil, twirp.WrapError(twerr, err)
+		}
+	}
+
+	if etag != "" {
+		c.etags.put(cacheKey, etag, etagBody)
+	}
+
+	twirpCallClientResponseReceived(ctx, c.hooks)
+
+	return ctx, nil
+
+}
+
+var _ TwirpCaller = (*RegisterTwirpClient)(nil)
+
+// Call calls the method with the given name, such as "Checkout", with req, which must have
+// the input type of the method, for tools that call methods by name, like admin UIs built with
+// RegisterDescriptor. Unknown methods fail with a twirp.BadRoute error, and requests of the
+// wrong type with a twirp.InvalidArgument error, without sending a request.
+func (c *RegisterTwirpClient) Call(ctx context.Context, method string, req proto.Message) (proto.Message, error) {
+	switch method {
+	case "Checkout":
+		in, ok := req.(*Order)
+		if !ok {
+			return nil, twirp.NewError(twirp.InvalidArgument, fmt.Sprintf("invalid request type %T for Checkout, expected *Order", req))
+		}
+
+		out, err := c.Checkout(ctx, in)
+		if err != nil {
+			return nil, err
+		}
+		return out, nil
+	}
+
+	return nil, twirp.NewError(twirp.BadRoute, fmt.Sprintf("unknown method %q", method))
+}
+
+func (c *RegisterTwirpClient) Checkout(ctx context.Context, in *Order) (*Receipt, error) {
+	ctx = ctxsetters.WithPackageName(ctx, "twitch.twirp.example.legacy")
+	ctx = ctxsetters.WithServiceName(ctx, "Register")
+	ctx = ctxsetters.WithMethodName(ctx, "Checkout")
+
+	if _, ok := ctx.Deadline(); !ok && c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	caller := c.callCheckout
+	if c.interceptor != nil {
+		caller = func(ctx context.Context, req *Order) (*Receipt, error) {
+			resp, err := c.interceptor(
+				func(ctx context.Context, req interface{}) (interface{}, error) {
+					typedReq, ok := req.(*Order)
+					if !ok {
+						return nil, twirp.InternalError("failed type assertion req.(*Order) when calling interceptor")
+					}
+					return c.callCheckout(ctx, typedReq)
+				},
+			)(ctx, req)
+			if resp != nil {
+				typedResp, ok := resp.(*Receipt)
+				if !ok {
+					return nil, twirp.InternalError("failed type assertion resp.(*Receipt) when calling interceptor")
+				}
+				return typedResp, err
+			}
+			return nil, err
+		}
+	}
+
+	return caller(ctx, in)
+
+}
+
+// CheckoutWithOptions calls Checkout with opts applied to this call only, such as
+// WithTwirpCallHeader, WithTwirpCallTimeout and WithTwirpCallNoRetry.
+func (c *RegisterTwirpClient) CheckoutWithOptions(ctx context.Context, in *Order, opts ...TwirpCallOption) (*Receipt, error) {
+	ctx, cancel, err := twirpWithCallOptions(ctx, opts)
+	defer cancel()
+	if err != nil {
+		return nil, err
+	}
+
+	return c.Checkout(ctx, in)
+}
+
+func (c *RegisterTwirpClient) callCheckout(ctx context.Context, in *Order) (_ *Receipt, err error) {
+	out := new(Receipt)
+
+	if c.metrics != nil {
+		start := time.Now()
+		defer func() {
+			c.metrics("Checkout", time.Since(start), err)
+		}()
+	}
+
+	// doAuthorizedRequest does not return a context on all errors, so the observer is
+	// ended with the context it returned
+	observed := ctx
+	if c.observer != nil {
+		observed = c.observer.StartRPC(ctx, "twitch.twirp.example.legacy.Register/Checkout")
+	}
+
+	ctx, err = c.doSharedRequest(observed, c.requests[0], false, false, in, out)
+	if err != nil {
+		twerr, ok := err.(twirp.Error)
+		if !ok {
+			twerr = twirp.InternalErrorWith(err)
+		}
+		twirpCallClientError(ctx, c.hooks, twerr)
+		if c.observer != nil {
+			c.observer.EndRPC(observed, "twitch.twirp.example.legacy.Register/Checkout", twerr)
+		}
+		return nil, err
+	}
+
+	twirpCallClientResponseReceived(ctx, c.hooks)
+	if c.observer != nil {
+		c.observer.EndRPC(observed, "twitch.twirp.example.legacy.Register/Checkout", nil)
+	}
+
+	return out, nil
+}
@@ -0,0 +1,68 @@
+package prometheus
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"github.com/twitchtv/twirp"
+)
+
+type testEchoer struct{}
+
+func (testEchoer) Echo(ctx context.Context, m *Message) (*Message, error) {
+	if m.Text == "" {
+		return nil, twirp.InvalidArgumentError("text", "must not be empty")
+	}
+	return m, nil
+}
+
+func TestPrometheus(t *testing.T) {
+	reg := prometheus.NewRegistry()
+
+	opt, err := WithTwirpServerPrometheus(reg)
+	require.NoError(t, err)
+
+	svr := httptest.NewServer(NewEchoerTwirpServer(testEchoer{}, opt))
+	defer svr.Close()
+
+	client, err := NewEchoerTwirpClient(svr.URL, http.DefaultTransport)
+	require.NoError(t, err)
+
+	_, err = client.Echo(context.Background(), &Message{Text: "hello"})
+	require.NoError(t, err)
+
+	_, err = client.Echo(context.Background(), &Message{})
+	require.Error(t, err)
+
+	expected := `
+# HELP twirp_requests_total Total number of Twirp requests handled by the server.
+# TYPE twirp_requests_total counter
+twirp_requests_total{code="invalid_argument",method="Echo",service="Echoer"} 1
+twirp_requests_total{code="ok",method="Echo",service="Echoer"} 1
+# HELP twirp_requests_in_flight Number of Twirp requests currently being handled by the server.
+# TYPE twirp_requests_in_flight gauge
+twirp_requests_in_flight{method="Echo",service="Echoer"} 0
+`
+	require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(expected), "twirp_requests_total", "twirp_requests_in_flight"))
+
+	// servers registered with the same registerer share the collectors
+	_, err = WithTwirpServerPrometheus(reg)
+	require.NoError(t, err)
+}
+
+func TestPrometheusRegistrationError(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "twirp_requests_total",
+		Help: "A counter without labels.",
+	}))
+
+	_, err := WithTwirpServerPrometheus(reg)
+	require.Error(t, err)
+}
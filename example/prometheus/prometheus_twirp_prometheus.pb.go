@@ -0,0 +1,159 @@
+// Code generated by protoc-gen-twirp-go DO NOT EDIT.
+package prometheus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/twitchtv/twirp"
+)
+
+type twirpPrometheusKey struct{}
+
+type twirpPrometheusState struct {
+	start  time.Time
+	method string
+	code   twirp.ErrorCode
+}
+
+type twirpPrometheusCollectors struct {
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	inFlight *prometheus.GaugeVec
+}
+
+// twirpPrometheusRegister registers c with reg, returning the existing collector if an
+// identical one was already registered, such as by another service in the same process.
+func twirpPrometheusRegister(reg prometheus.Registerer, c prometheus.Collector) (prometheus.Collector, error) {
+	err := reg.Register(c)
+	if err == nil {
+		return c, nil
+	}
+
+	var are prometheus.AlreadyRegisteredError
+	if errors.As(err, &are) {
+		return are.ExistingCollector, nil
+	}
+
+	return nil, err
+}
+
+// twirpPrometheusTypeError is the error for an existing collector registered under the name of one
+// of the server's collectors, which is not of the same type.
+func twirpPrometheusTypeError(existing prometheus.Collector, c prometheus.Collector) error {
+	return fmt.Errorf("a %T is already registered instead of a %T", existing, c)
+}
+
+func newTwirpPrometheusCollectors(reg prometheus.Registerer) (*twirpPrometheusCollectors, error) {
+	requests := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "twirp_requests_total",
+			Help: "Total number of Twirp requests handled by the server.",
+		},
+		[]string{"service", "method", "code"},
+	)
+
+	duration := prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "twirp_request_duration_seconds",
+			Help:    "Duration of Twirp requests handled by the server.",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"service", "method", "code"},
+	)
+
+	inFlight := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "twirp_requests_in_flight",
+			Help: "Number of Twirp requests currently being handled by the server.",
+		},
+		[]string{"service", "method"},
+	)
+
+	var c twirpPrometheusCollectors
+	var ok bool
+
+	existing, err := twirpPrometheusRegister(reg, requests)
+	if err != nil {
+		return nil, err
+	}
+	if c.requests, ok = existing.(*prometheus.CounterVec); !ok {
+		return nil, twirpPrometheusTypeError(existing, requests)
+	}
+
+	existing, err = twirpPrometheusRegister(reg, duration)
+	if err != nil {
+		return nil, err
+	}
+	if c.duration, ok = existing.(*prometheus.HistogramVec); !ok {
+		return nil, twirpPrometheusTypeError(existing, duration)
+	}
+
+	existing, err = twirpPrometheusRegister(reg, inFlight)
+	if err != nil {
+		return nil, err
+	}
+	if c.inFlight, ok = existing.(*prometheus.GaugeVec); !ok {
+		return nil, twirpPrometheusTypeError(existing, inFlight)
+	}
+
+	return &c, nil
+}
+
+// WithTwirpServerPrometheus registers request count, request duration, and in-flight request
+// collectors with reg and records every routed request in them. The collectors are labeled
+// by service, method, and Twirp error code ("ok" for successful requests). Collectors are
+// shared by all servers registered with the same reg. It returns an error if reg rejects a
+// collector, such as when a different collector with the same name is registered.
+func WithTwirpServerPrometheus(reg prometheus.Registerer) (TwirpServerOption, error) {
+	c, err := newTwirpPrometheusCollectors(reg)
+	if err != nil {
+		return nil, err
+	}
+
+	hooks := &twirp.ServerHooks{
+		RequestRouted: func(ctx context.Context) (context.Context, error) {
+			service, _ := twirp.ServiceName(ctx)
+			method, _ := twirp.MethodName(ctx)
+
+			c.inFlight.WithLabelValues(service, method).Inc()
+
+			state := &twirpPrometheusState{
+				start:  time.Now(),
+				method: method,
+			}
+
+			return context.WithValue(ctx, twirpPrometheusKey{}, state), nil
+		},
+		Error: func(ctx context.Context, err twirp.Error) context.Context {
+			if state, ok := ctx.Value(twirpPrometheusKey{}).(*twirpPrometheusState); ok {
+				state.code = err.Code()
+			}
+			return ctx
+		},
+		ResponseSent: func(ctx context.Context) {
+			state, ok := ctx.Value(twirpPrometheusKey{}).(*twirpPrometheusState)
+			if !ok {
+				return
+			}
+
+			service, _ := twirp.ServiceName(ctx)
+
+			code := "ok"
+			if state.code != twirp.NoError {
+				code = string(state.code)
+			}
+
+			c.inFlight.WithLabelValues(service, state.method).Dec()
+			c.requests.WithLabelValues(service, state.method, code).Inc()
+			c.duration.WithLabelValues(service, state.method, code).Observe(time.Since(state.start).Seconds())
+		},
+	}
+
+	return func(o *TwirpServerOptions) {
+		o.hooks = append(o.hooks, hooks)
+	}, nil
+}
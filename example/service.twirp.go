@@ -1,11 +1,12 @@
-// Code generated by protoc-gen-twirp v7.1.1, DO NOT EDIT.
+// Code generated by protoc-gen-twirp v7.2.0, DO NOT EDIT.
 // source: service.proto
 
 /*
 Package example is a generated twirp stub package.
-This code was generated with github.com/twitchtv/twirp/protoc-gen-twirp v7.1.1.
+This code was generated with github.com/twitchtv/twirp/protoc-gen-twirp v7.2.0.
 
 It is generated from these files:
+
 	service.proto
 */
 package example
@@ -258,6 +259,19 @@ func (s *haberdasherServer) writeError(ctx context.Context, resp http.ResponseWr
 	writeError(ctx, resp, err, s.hooks)
 }
 
+// handleRequestBodyError is used to handle error when the twirp server cannot read request
+func (s *haberdasherServer) handleRequestBodyError(ctx context.Context, resp http.ResponseWriter, msg string, err error) {
+	if context.Canceled == ctx.Err() {
+		s.writeError(ctx, resp, twirp.NewError(twirp.Canceled, "failed to read request: context canceled"))
+		return
+	}
+	if context.DeadlineExceeded == ctx.Err() {
+		s.writeError(ctx, resp, twirp.NewError(twirp.DeadlineExceeded, "failed to read request: deadline exceeded"))
+		return
+	}
+	s.writeError(ctx, resp, twirp.WrapError(malformedRequestError(msg), err))
+}
+
 // HaberdasherPathPrefix is a convenience constant that could used to identify URL paths.
 // Should be used with caution, it only matches routes generated by Twirp Go clients,
 // that add a "/twirp" prefix by default, and use CamelCase service and method names.
@@ -337,7 +351,7 @@ func (s *haberdasherServer) serveMakeHatJSON(ctx context.Context, resp http.Resp
 	reqContent := new(Size)
 	unmarshaler := jsonpb.Unmarshaler{AllowUnknownFields: true}
 	if err = unmarshaler.Unmarshal(req.Body, reqContent); err != nil {
-		s.writeError(ctx, resp, malformedRequestError("the json request could not be decoded"))
+		s.handleRequestBodyError(ctx, resp, "the json request could not be decoded", err)
 		return
 	}
 
@@ -414,7 +428,7 @@ func (s *haberdasherServer) serveMakeHatProtobuf(ctx context.Context, resp http.
 
 	buf, err := ioutil.ReadAll(req.Body)
 	if err != nil {
-		s.writeError(ctx, resp, wrapInternal(err, "failed to read request body"))
+		s.handleRequestBodyError(ctx, resp, "failed to read request body", err)
 		return
 	}
 	reqContent := new(Size)
@@ -487,7 +501,7 @@ func (s *haberdasherServer) ServiceDescriptor() ([]byte, int) {
 }
 
 func (s *haberdasherServer) ProtocGenTwirpVersion() string {
-	return "v7.1.1"
+	return "v7.2.0"
 }
 
 // PathPrefix returns the base service path, in the form: "/<prefix>/<package>.<Service>/"
@@ -497,6 +511,481 @@ func (s *haberdasherServer) PathPrefix() string {
 	return baseServicePath(s.pathPrefix, "twitch.twirp.example", "Haberdasher")
 }
 
+// =================
+// HatRack Interface
+// =================
+
+// A HatRack holds the hats made by a Haberdasher.
+type HatRack interface {
+	// ListHats returns the hats on the rack, a page at a time.
+	ListHats(context.Context, *ListHatsRequest) (*ListHatsResponse, error)
+}
+
+// =======================
+// HatRack Protobuf Client
+// =======================
+
+type hatRackProtobufClient struct {
+	client      HTTPClient
+	urls        [1]string
+	interceptor twirp.Interceptor
+	opts        twirp.ClientOptions
+}
+
+// NewHatRackProtobufClient creates a Protobuf client that implements the HatRack interface.
+// It communicates using Protobuf and can be configured with a custom HTTPClient.
+func NewHatRackProtobufClient(baseURL string, client HTTPClient, opts ...twirp.ClientOption) HatRack {
+	if c, ok := client.(*http.Client); ok {
+		client = withoutRedirects(c)
+	}
+
+	clientOpts := twirp.ClientOptions{}
+	for _, o := range opts {
+		o(&clientOpts)
+	}
+
+	// Build method URLs: <baseURL>[<prefix>]/<package>.<Service>/<Method>
+	serviceURL := sanitizeBaseURL(baseURL)
+	serviceURL += baseServicePath(clientOpts.PathPrefix(), "twitch.twirp.example", "HatRack")
+	urls := [1]string{
+		serviceURL + "ListHats",
+	}
+
+	return &hatRackProtobufClient{
+		client:      client,
+		urls:        urls,
+		interceptor: twirp.ChainInterceptors(clientOpts.Interceptors...),
+		opts:        clientOpts,
+	}
+}
+
+func (c *hatRackProtobufClient) ListHats(ctx context.Context, in *ListHatsRequest) (*ListHatsResponse, error) {
+	ctx = ctxsetters.WithPackageName(ctx, "twitch.twirp.example")
+	ctx = ctxsetters.WithServiceName(ctx, "HatRack")
+	ctx = ctxsetters.WithMethodName(ctx, "ListHats")
+	caller := c.callListHats
+	if c.interceptor != nil {
+		caller = func(ctx context.Context, req *ListHatsRequest) (*ListHatsResponse, error) {
+			resp, err := c.interceptor(
+				func(ctx context.Context, req interface{}) (interface{}, error) {
+					typedReq, ok := req.(*ListHatsRequest)
+					if !ok {
+						return nil, twirp.InternalError("failed type assertion req.(*ListHatsRequest) when calling interceptor")
+					}
+					return c.callListHats(ctx, typedReq)
+				},
+			)(ctx, req)
+			if resp != nil {
+				typedResp, ok := resp.(*ListHatsResponse)
+				if !ok {
+					return nil, twirp.InternalError("failed type assertion resp.(*ListHatsResponse) when calling interceptor")
+				}
+				return typedResp, err
+			}
+			return nil, err
+		}
+	}
+	return caller(ctx, in)
+}
+
+func (c *hatRackProtobufClient) callListHats(ctx context.Context, in *ListHatsRequest) (*ListHatsResponse, error) {
+	out := new(ListHatsResponse)
+	ctx, err := doProtobufRequest(ctx, c.client, c.opts.Hooks, c.urls[0], in, out)
+	if err != nil {
+		twerr, ok := err.(twirp.Error)
+		if !ok {
+			twerr = twirp.InternalErrorWith(err)
+		}
+		callClientError(ctx, c.opts.Hooks, twerr)
+		return nil, err
+	}
+
+	callClientResponseReceived(ctx, c.opts.Hooks)
+
+	return out, nil
+}
+
+// ===================
+// HatRack JSON Client
+// ===================
+
+type hatRackJSONClient struct {
+	client      HTTPClient
+	urls        [1]string
+	interceptor twirp.Interceptor
+	opts        twirp.ClientOptions
+}
+
+// NewHatRackJSONClient creates a JSON client that implements the HatRack interface.
+// It communicates using JSON and can be configured with a custom HTTPClient.
+func NewHatRackJSONClient(baseURL string, client HTTPClient, opts ...twirp.ClientOption) HatRack {
+	if c, ok := client.(*http.Client); ok {
+		client = withoutRedirects(c)
+	}
+
+	clientOpts := twirp.ClientOptions{}
+	for _, o := range opts {
+		o(&clientOpts)
+	}
+
+	// Build method URLs: <baseURL>[<prefix>]/<package>.<Service>/<Method>
+	serviceURL := sanitizeBaseURL(baseURL)
+	serviceURL += baseServicePath(clientOpts.PathPrefix(), "twitch.twirp.example", "HatRack")
+	urls := [1]string{
+		serviceURL + "ListHats",
+	}
+
+	return &hatRackJSONClient{
+		client:      client,
+		urls:        urls,
+		interceptor: twirp.ChainInterceptors(clientOpts.Interceptors...),
+		opts:        clientOpts,
+	}
+}
+
+func (c *hatRackJSONClient) ListHats(ctx context.Context, in *ListHatsRequest) (*ListHatsResponse, error) {
+	ctx = ctxsetters.WithPackageName(ctx, "twitch.twirp.example")
+	ctx = ctxsetters.WithServiceName(ctx, "HatRack")
+	ctx = ctxsetters.WithMethodName(ctx, "ListHats")
+	caller := c.callListHats
+	if c.interceptor != nil {
+		caller = func(ctx context.Context, req *ListHatsRequest) (*ListHatsResponse, error) {
+			resp, err := c.interceptor(
+				func(ctx context.Context, req interface{}) (interface{}, error) {
+					typedReq, ok := req.(*ListHatsRequest)
+					if !ok {
+						return nil, twirp.InternalError("failed type assertion req.(*ListHatsRequest) when calling interceptor")
+					}
+					return c.callListHats(ctx, typedReq)
+				},
+			)(ctx, req)
+			if resp != nil {
+				typedResp, ok := resp.(*ListHatsResponse)
+				if !ok {
+					return nil, twirp.InternalError("failed type assertion resp.(*ListHatsResponse) when calling interceptor")
+				}
+				return typedResp, err
+			}
+			return nil, err
+		}
+	}
+	return caller(ctx, in)
+}
+
+func (c *hatRackJSONClient) callListHats(ctx context.Context, in *ListHatsRequest) (*ListHatsResponse, error) {
+	out := new(ListHatsResponse)
+	ctx, err := doJSONRequest(ctx, c.client, c.opts.Hooks, c.urls[0], in, out)
+	if err != nil {
+		twerr, ok := err.(twirp.Error)
+		if !ok {
+			twerr = twirp.InternalErrorWith(err)
+		}
+		callClientError(ctx, c.opts.Hooks, twerr)
+		return nil, err
+	}
+
+	callClientResponseReceived(ctx, c.opts.Hooks)
+
+	return out, nil
+}
+
+// ======================
+// HatRack Server Handler
+// ======================
+
+type hatRackServer struct {
+	HatRack
+	interceptor      twirp.Interceptor
+	hooks            *twirp.ServerHooks
+	pathPrefix       string // prefix for routing
+	jsonSkipDefaults bool   // do not include unpopulated fields (default values) in the response
+}
+
+// NewHatRackServer builds a TwirpServer that can be used as an http.Handler to handle
+// HTTP requests that are routed to the right method in the provided svc implementation.
+// The opts are twirp.ServerOption modifiers, for example twirp.WithServerHooks(hooks).
+func NewHatRackServer(svc HatRack, opts ...interface{}) TwirpServer {
+	serverOpts := twirp.ServerOptions{}
+	for _, opt := range opts {
+		switch o := opt.(type) {
+		case twirp.ServerOption:
+			o(&serverOpts)
+		case *twirp.ServerHooks: // backwards compatibility, allow to specify hooks as an argument
+			twirp.WithServerHooks(o)(&serverOpts)
+		case nil: // backwards compatibility, allow nil value for the argument
+			continue
+		default:
+			panic(fmt.Sprintf("Invalid option type %T on NewHatRackServer", o))
+		}
+	}
+
+	return &hatRackServer{
+		HatRack:          svc,
+		pathPrefix:       serverOpts.PathPrefix(),
+		interceptor:      twirp.ChainInterceptors(serverOpts.Interceptors...),
+		hooks:            serverOpts.Hooks,
+		jsonSkipDefaults: serverOpts.JSONSkipDefaults,
+	}
+}
+
+// writeError writes an HTTP response with a valid Twirp error format, and triggers hooks.
+// If err is not a twirp.Error, it will get wrapped with twirp.InternalErrorWith(err)
+func (s *hatRackServer) writeError(ctx context.Context, resp http.ResponseWriter, err error) {
+	writeError(ctx, resp, err, s.hooks)
+}
+
+// handleRequestBodyError is used to handle error when the twirp server cannot read request
+func (s *hatRackServer) handleRequestBodyError(ctx context.Context, resp http.ResponseWriter, msg string, err error) {
+	if context.Canceled == ctx.Err() {
+		s.writeError(ctx, resp, twirp.NewError(twirp.Canceled, "failed to read request: context canceled"))
+		return
+	}
+	if context.DeadlineExceeded == ctx.Err() {
+		s.writeError(ctx, resp, twirp.NewError(twirp.DeadlineExceeded, "failed to read request: deadline exceeded"))
+		return
+	}
+	s.writeError(ctx, resp, twirp.WrapError(malformedRequestError(msg), err))
+}
+
+// HatRackPathPrefix is a convenience constant that could used to identify URL paths.
+// Should be used with caution, it only matches routes generated by Twirp Go clients,
+// that add a "/twirp" prefix by default, and use CamelCase service and method names.
+// More info: https://twitchtv.github.io/twirp/docs/routing.html
+const HatRackPathPrefix = "/twirp/twitch.twirp.example.HatRack/"
+
+func (s *hatRackServer) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	ctx = ctxsetters.WithPackageName(ctx, "twitch.twirp.example")
+	ctx = ctxsetters.WithServiceName(ctx, "HatRack")
+	ctx = ctxsetters.WithResponseWriter(ctx, resp)
+
+	var err error
+	ctx, err = callRequestReceived(ctx, s.hooks)
+	if err != nil {
+		s.writeError(ctx, resp, err)
+		return
+	}
+
+	if req.Method != "POST" {
+		msg := fmt.Sprintf("unsupported method %q (only POST is allowed)", req.Method)
+		s.writeError(ctx, resp, badRouteError(msg, req.Method, req.URL.Path))
+		return
+	}
+
+	// Verify path format: [<prefix>]/<package>.<Service>/<Method>
+	prefix, pkgService, method := parseTwirpPath(req.URL.Path)
+	if pkgService != "twitch.twirp.example.HatRack" {
+		msg := fmt.Sprintf("no handler for path %q", req.URL.Path)
+		s.writeError(ctx, resp, badRouteError(msg, req.Method, req.URL.Path))
+		return
+	}
+	if prefix != s.pathPrefix {
+		msg := fmt.Sprintf("invalid path prefix %q, expected %q, on path %q", prefix, s.pathPrefix, req.URL.Path)
+		s.writeError(ctx, resp, badRouteError(msg, req.Method, req.URL.Path))
+		return
+	}
+
+	switch method {
+	case "ListHats":
+		s.serveListHats(ctx, resp, req)
+		return
+	default:
+		msg := fmt.Sprintf("no handler for path %q", req.URL.Path)
+		s.writeError(ctx, resp, badRouteError(msg, req.Method, req.URL.Path))
+		return
+	}
+}
+
+func (s *hatRackServer) serveListHats(ctx context.Context, resp http.ResponseWriter, req *http.Request) {
+	header := req.Header.Get("Content-Type")
+	i := strings.Index(header, ";")
+	if i == -1 {
+		i = len(header)
+	}
+	switch strings.TrimSpace(strings.ToLower(header[:i])) {
+	case "application/json":
+		s.serveListHatsJSON(ctx, resp, req)
+	case "application/protobuf":
+		s.serveListHatsProtobuf(ctx, resp, req)
+	default:
+		msg := fmt.Sprintf("unexpected Content-Type: %q", req.Header.Get("Content-Type"))
+		twerr := badRouteError(msg, req.Method, req.URL.Path)
+		s.writeError(ctx, resp, twerr)
+	}
+}
+
+func (s *hatRackServer) serveListHatsJSON(ctx context.Context, resp http.ResponseWriter, req *http.Request) {
+	var err error
+	ctx = ctxsetters.WithMethodName(ctx, "ListHats")
+	ctx, err = callRequestRouted(ctx, s.hooks)
+	if err != nil {
+		s.writeError(ctx, resp, err)
+		return
+	}
+
+	reqContent := new(ListHatsRequest)
+	unmarshaler := jsonpb.Unmarshaler{AllowUnknownFields: true}
+	if err = unmarshaler.Unmarshal(req.Body, reqContent); err != nil {
+		s.handleRequestBodyError(ctx, resp, "the json request could not be decoded", err)
+		return
+	}
+
+	handler := s.HatRack.ListHats
+	if s.interceptor != nil {
+		handler = func(ctx context.Context, req *ListHatsRequest) (*ListHatsResponse, error) {
+			resp, err := s.interceptor(
+				func(ctx context.Context, req interface{}) (interface{}, error) {
+					typedReq, ok := req.(*ListHatsRequest)
+					if !ok {
+						return nil, twirp.InternalError("failed type assertion req.(*ListHatsRequest) when calling interceptor")
+					}
+					return s.HatRack.ListHats(ctx, typedReq)
+				},
+			)(ctx, req)
+			if resp != nil {
+				typedResp, ok := resp.(*ListHatsResponse)
+				if !ok {
+					return nil, twirp.InternalError("failed type assertion resp.(*ListHatsResponse) when calling interceptor")
+				}
+				return typedResp, err
+			}
+			return nil, err
+		}
+	}
+
+	// Call service method
+	var respContent *ListHatsResponse
+	func() {
+		defer ensurePanicResponses(ctx, resp, s.hooks)
+		respContent, err = handler(ctx, reqContent)
+	}()
+
+	if err != nil {
+		s.writeError(ctx, resp, err)
+		return
+	}
+	if respContent == nil {
+		s.writeError(ctx, resp, twirp.InternalError("received a nil *ListHatsResponse and nil error while calling ListHats. nil responses are not supported"))
+		return
+	}
+
+	ctx = callResponsePrepared(ctx, s.hooks)
+
+	var buf bytes.Buffer
+	marshaler := &jsonpb.Marshaler{OrigName: true, EmitDefaults: !s.jsonSkipDefaults}
+	if err = marshaler.Marshal(&buf, respContent); err != nil {
+		s.writeError(ctx, resp, wrapInternal(err, "failed to marshal json response"))
+		return
+	}
+
+	ctx = ctxsetters.WithStatusCode(ctx, http.StatusOK)
+	respBytes := buf.Bytes()
+	resp.Header().Set("Content-Type", "application/json")
+	resp.Header().Set("Content-Length", strconv.Itoa(len(respBytes)))
+	resp.WriteHeader(http.StatusOK)
+
+	if n, err := resp.Write(respBytes); err != nil {
+		msg := fmt.Sprintf("failed to write response, %d of %d bytes written: %s", n, len(respBytes), err.Error())
+		twerr := twirp.NewError(twirp.Unknown, msg)
+		ctx = callError(ctx, s.hooks, twerr)
+	}
+	callResponseSent(ctx, s.hooks)
+}
+
+func (s *hatRackServer) serveListHatsProtobuf(ctx context.Context, resp http.ResponseWriter, req *http.Request) {
+	var err error
+	ctx = ctxsetters.WithMethodName(ctx, "ListHats")
+	ctx, err = callRequestRouted(ctx, s.hooks)
+	if err != nil {
+		s.writeError(ctx, resp, err)
+		return
+	}
+
+	buf, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		s.handleRequestBodyError(ctx, resp, "failed to read request body", err)
+		return
+	}
+	reqContent := new(ListHatsRequest)
+	if err = proto.Unmarshal(buf, reqContent); err != nil {
+		s.writeError(ctx, resp, malformedRequestError("the protobuf request could not be decoded"))
+		return
+	}
+
+	handler := s.HatRack.ListHats
+	if s.interceptor != nil {
+		handler = func(ctx context.Context, req *ListHatsRequest) (*ListHatsResponse, error) {
+			resp, err := s.interceptor(
+				func(ctx context.Context, req interface{}) (interface{}, error) {
+					typedReq, ok := req.(*ListHatsRequest)
+					if !ok {
+						return nil, twirp.InternalError("failed type assertion req.(*ListHatsRequest) when calling interceptor")
+					}
+					return s.HatRack.ListHats(ctx, typedReq)
+				},
+			)(ctx, req)
+			if resp != nil {
+				typedResp, ok := resp.(*ListHatsResponse)
+				if !ok {
+					return nil, twirp.InternalError("failed type assertion resp.(*ListHatsResponse) when calling interceptor")
+				}
+				return typedResp, err
+			}
+			return nil, err
+		}
+	}
+
+	// Call service method
+	var respContent *ListHatsResponse
+	func() {
+		defer ensurePanicResponses(ctx, resp, s.hooks)
+		respContent, err = handler(ctx, reqContent)
+	}()
+
+	if err != nil {
+		s.writeError(ctx, resp, err)
+		return
+	}
+	if respContent == nil {
+		s.writeError(ctx, resp, twirp.InternalError("received a nil *ListHatsResponse and nil error while calling ListHats. nil responses are not supported"))
+		return
+	}
+
+	ctx = callResponsePrepared(ctx, s.hooks)
+
+	respBytes, err := proto.Marshal(respContent)
+	if err != nil {
+		s.writeError(ctx, resp, wrapInternal(err, "failed to marshal proto response"))
+		return
+	}
+
+	ctx = ctxsetters.WithStatusCode(ctx, http.StatusOK)
+	resp.Header().Set("Content-Type", "application/protobuf")
+	resp.Header().Set("Content-Length", strconv.Itoa(len(respBytes)))
+	resp.WriteHeader(http.StatusOK)
+	if n, err := resp.Write(respBytes); err != nil {
+		msg := fmt.Sprintf("failed to write response, %d of %d bytes written: %s", n, len(respBytes), err.Error())
+		twerr := twirp.NewError(twirp.Unknown, msg)
+		ctx = callError(ctx, s.hooks, twerr)
+	}
+	callResponseSent(ctx, s.hooks)
+}
+
+func (s *hatRackServer) ServiceDescriptor() ([]byte, int) {
+	return twirpFileDescriptor0, 1
+}
+
+func (s *hatRackServer) ProtocGenTwirpVersion() string {
+	return "v7.2.0"
+}
+
+// PathPrefix returns the base service path, in the form: "/<prefix>/<package>.<Service>/"
+// that is everything in a Twirp route except for the <Method>. This can be used for routing,
+// for example to identify the requests that are targeted to this service in a mux.
+func (s *hatRackServer) PathPrefix() string {
+	return baseServicePath(s.pathPrefix, "twitch.twirp.example", "HatRack")
+}
+
 // =====
 // Utils
 // =====
@@ -603,9 +1092,12 @@ func sanitizeBaseURL(baseURL string) string {
 
 // baseServicePath composes the path prefix for the service (without <Method>).
 // e.g.: baseServicePath("/twirp", "my.pkg", "MyService")
-//       returns => "/twirp/my.pkg.MyService/"
+//
+//	returns => "/twirp/my.pkg.MyService/"
+//
 // e.g.: baseServicePath("", "", "MyService")
-//       returns => "/MyService/"
+//
+//	returns => "/MyService/"
 func baseServicePath(prefix, pkg, service string) string {
 	fullServiceName := service
 	if pkg != "" {
@@ -660,7 +1152,7 @@ func newRequest(ctx context.Context, url string, reqBody io.Reader, contentType
 	}
 	req.Header.Set("Accept", contentType)
 	req.Header.Set("Content-Type", contentType)
-	req.Header.Set("Twirp-Version", "v7.1.1")
+	req.Header.Set("Twirp-Version", "v7.2.0")
 	return req, nil
 }
 
@@ -1044,19 +1536,45 @@ func callClientError(ctx context.Context, h *twirp.ClientHooks, err twirp.Error)
 }
 
 var twirpFileDescriptor0 = []byte{
-	// 215 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x6c, 0x8f, 0x4f, 0x4b, 0xc4, 0x30,
-	0x10, 0xc5, 0xa9, 0xfb, 0x47, 0x1c, 0xf1, 0x12, 0x16, 0xa9, 0x7b, 0x90, 0x65, 0x4f, 0x7b, 0x49,
-	0x02, 0xfa, 0x05, 0x44, 0x2f, 0xbd, 0x88, 0xb0, 0xde, 0xbc, 0x4d, 0xe2, 0xd0, 0x84, 0xb6, 0x49,
-	0x49, 0xa2, 0x15, 0x3f, 0xbd, 0x24, 0xf6, 0xd8, 0xdb, 0x7b, 0x79, 0xf9, 0xbd, 0x99, 0x81, 0x9b,
-	0x48, 0xe1, 0xdb, 0x6a, 0x12, 0x63, 0xf0, 0xc9, 0xb3, 0x5d, 0x9a, 0x6c, 0xd2, 0x46, 0xa4, 0xc9,
-	0x86, 0x51, 0xd0, 0x0f, 0x0e, 0x63, 0x4f, 0xc7, 0x17, 0x58, 0x35, 0x98, 0x18, 0x83, 0x75, 0xb4,
-	0xbf, 0x54, 0x57, 0x87, 0xea, 0xb4, 0x39, 0x17, 0xcd, 0x76, 0xb0, 0xd1, 0xbe, 0xf7, 0xa1, 0xbe,
-	0x38, 0x54, 0xa7, 0xab, 0xf3, 0xbf, 0xc9, 0x3f, 0x1d, 0x0e, 0x54, 0xaf, 0xca, 0x63, 0xd1, 0xc7,
-	0x7b, 0x58, 0xbf, 0x67, 0xe2, 0x16, 0xb6, 0xd6, 0x69, 0x43, 0x71, 0xee, 0x99, 0xdd, 0xc3, 0x1b,
-	0x5c, 0x37, 0xa8, 0x28, 0x7c, 0x62, 0x34, 0x14, 0xd8, 0x13, 0x5c, 0xbe, 0x62, 0x47, 0x79, 0xee,
-	0x5e, 0x2c, 0x6d, 0x25, 0x72, 0xdb, 0xfe, 0x6e, 0x39, 0x6b, 0x30, 0x3d, 0xcb, 0x0f, 0xde, 0xda,
-	0x64, 0xbe, 0x94, 0xd0, 0x7e, 0x90, 0x0a, 0x3b, 0xeb, 0xa2, 0x2c, 0x67, 0x6a, 0xde, 0x92, 0xe3,
-	0x85, 0xe0, 0xad, 0x97, 0x33, 0xa4, 0xb6, 0x25, 0x7c, 0xfc, 0x0b, 0x00, 0x00, 0xff, 0xff, 0x4b,
-	0x9a, 0x35, 0xbf, 0x14, 0x01, 0x00, 0x00,
+	// 629 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x53, 0xcd, 0x6e, 0xd3, 0x4a,
+	0x18, 0xbd, 0xce, 0x4f, 0x9b, 0x7c, 0x55, 0x6f, 0x73, 0xe7, 0xa6, 0x57, 0xae, 0xaf, 0x28, 0x95,
+	0x25, 0x4a, 0x55, 0x14, 0x07, 0x05, 0x84, 0x04, 0x12, 0x8b, 0x86, 0x18, 0x12, 0x35, 0x6d, 0xaa,
+	0x49, 0x10, 0x88, 0x05, 0xd6, 0xd8, 0x1e, 0x9c, 0x51, 0x12, 0x8f, 0x19, 0x4f, 0xfa, 0xc3, 0x0a,
+	0x89, 0x0d, 0xcb, 0x3e, 0x1b, 0x4f, 0xd0, 0xb2, 0xe4, 0x29, 0xd0, 0x8c, 0x13, 0x15, 0xa5, 0xad,
+	0x10, 0xbb, 0xc9, 0xf9, 0xce, 0x77, 0x72, 0xce, 0x99, 0x31, 0xac, 0xa6, 0x54, 0x1c, 0xb3, 0x80,
+	0x3a, 0x89, 0xe0, 0x92, 0xa3, 0xaa, 0x3c, 0x61, 0x32, 0x18, 0x3a, 0xf2, 0x84, 0x89, 0xc4, 0xa1,
+	0xa7, 0x64, 0x92, 0x8c, 0xa9, 0xb5, 0x19, 0x71, 0x1e, 0x8d, 0x69, 0x5d, 0x73, 0xfc, 0xe9, 0x87,
+	0x7a, 0x38, 0x15, 0x44, 0x32, 0x1e, 0x67, 0x5b, 0xd6, 0xdd, 0xc5, 0xb9, 0x64, 0x13, 0x9a, 0x4a,
+	0x32, 0x49, 0x66, 0x84, 0x75, 0xad, 0x17, 0xf1, 0x3a, 0x4f, 0xd4, 0x5a, 0x9a, 0xc1, 0xf6, 0x37,
+	0x03, 0xf2, 0x6d, 0x22, 0x11, 0x82, 0x42, 0xca, 0x3e, 0x51, 0xd3, 0xd8, 0x32, 0x76, 0x8a, 0x58,
+	0x9f, 0x51, 0x15, 0x8a, 0x01, 0x1f, 0x73, 0x61, 0xe6, 0xb6, 0x8c, 0x9d, 0x32, 0xce, 0x7e, 0x28,
+	0x66, 0x4c, 0x26, 0xd4, 0xcc, 0x6b, 0x50, 0x9f, 0xd1, 0x53, 0x80, 0x90, 0x8e, 0xd9, 0x31, 0x15,
+	0x9e, 0x7f, 0x66, 0x16, 0xb6, 0x8c, 0x9d, 0x95, 0x86, 0xe5, 0x64, 0x96, 0x9c, 0xb9, 0x25, 0x67,
+	0x30, 0xb7, 0x84, 0xcb, 0x33, 0x76, 0xf3, 0x0c, 0x3d, 0x81, 0xf2, 0x98, 0x92, 0xd0, 0x53, 0x7e,
+	0xcd, 0xa2, 0xde, 0xdc, 0xb8, 0xb6, 0xd9, 0x9a, 0x85, 0xc5, 0x25, 0xc5, 0x55, 0x3a, 0xc8, 0x82,
+	0xa2, 0x3f, 0x3d, 0xa3, 0xc2, 0x5c, 0x52, 0x3e, 0x9a, 0x85, 0xaf, 0x97, 0xa6, 0x81, 0x33, 0xc8,
+	0x8e, 0xa1, 0xd0, 0x57, 0x01, 0x1e, 0xc0, 0x12, 0x8b, 0x83, 0x21, 0x4d, 0xb3, 0x58, 0xcd, 0x7f,
+	0x7f, 0x5c, 0x98, 0x6b, 0xc7, 0x64, 0xcc, 0x42, 0x22, 0xe9, 0x33, 0x3b, 0x92, 0xcf, 0x1f, 0xda,
+	0x78, 0x46, 0x59, 0xc8, 0x90, 0xfb, 0x83, 0x0c, 0xf6, 0x17, 0x03, 0xd6, 0xba, 0x2c, 0x95, 0x6d,
+	0x22, 0x53, 0x4c, 0x3f, 0x4e, 0x69, 0x2a, 0xd1, 0xff, 0x50, 0x4e, 0x48, 0x44, 0xbd, 0x5f, 0x5a,
+	0x2d, 0x29, 0x40, 0x1b, 0xbb, 0x03, 0xa0, 0x87, 0x92, 0x8f, 0x68, 0x3c, 0xab, 0x57, 0xd3, 0x07,
+	0x0a, 0x40, 0x8f, 0xa1, 0xc8, 0x45, 0x48, 0x85, 0xee, 0xf8, 0xef, 0xc6, 0xa6, 0x73, 0xd3, 0x93,
+	0x70, 0xda, 0x44, 0xf6, 0x14, 0x0b, 0x67, 0x64, 0x9b, 0x41, 0xe5, 0xca, 0x44, 0x9a, 0xf0, 0x38,
+	0xa5, 0xa8, 0x06, 0x85, 0x21, 0x91, 0x2a, 0x7f, 0x5e, 0x17, 0x7b, 0x9b, 0x10, 0xd6, 0x34, 0xb4,
+	0x0d, 0x6b, 0x31, 0x3d, 0x95, 0xde, 0x35, 0x73, 0xab, 0x0a, 0x3e, 0x9a, 0x1b, 0xdc, 0xc5, 0x50,
+	0x9a, 0xff, 0x3b, 0xda, 0x80, 0xf5, 0xf6, 0xde, 0xc0, 0xeb, 0xe1, 0x96, 0x8b, 0xbd, 0xd7, 0x87,
+	0xfd, 0x23, 0xf7, 0x45, 0xe7, 0x65, 0xc7, 0x6d, 0x55, 0xfe, 0x42, 0x55, 0xa8, 0x5c, 0x8d, 0x0e,
+	0xdd, 0x37, 0x6e, 0x7f, 0x50, 0x31, 0xd0, 0x3a, 0xfc, 0x73, 0x85, 0x76, 0xf7, 0xf0, 0x2b, 0x05,
+	0xe7, 0x76, 0x8f, 0xa0, 0xec, 0x0a, 0xc1, 0xc5, 0x3e, 0x8b, 0x43, 0x64, 0xc1, 0x7f, 0x2e, 0xc6,
+	0x3d, 0xec, 0xed, 0x77, 0x0e, 0x5b, 0x0b, 0xaa, 0xf7, 0x61, 0x55, 0xed, 0x0f, 0x7a, 0x3d, 0xaf,
+	0x7f, 0xb0, 0xd7, 0xed, 0x56, 0x0c, 0xab, 0xfa, 0xfd, 0xc2, 0xac, 0xb0, 0x58, 0x5f, 0xad, 0x47,
+	0x44, 0x34, 0x9d, 0xd0, 0x58, 0x36, 0xde, 0xc2, 0x4a, 0x9b, 0xf8, 0x54, 0x84, 0x24, 0x1d, 0x52,
+	0x81, 0x3a, 0xb0, 0x7c, 0x40, 0x46, 0x54, 0xbd, 0x76, 0xeb, 0xe6, 0x22, 0xd4, 0xdd, 0x58, 0xb7,
+	0x97, 0x64, 0x2f, 0x9f, 0xe7, 0x72, 0x9f, 0x2f, 0x4d, 0xa3, 0xc1, 0x60, 0x59, 0x95, 0x46, 0x82,
+	0x11, 0x7a, 0x0f, 0xa5, 0x79, 0xeb, 0xe8, 0xde, 0xcd, 0xab, 0x0b, 0x4f, 0xc3, 0xda, 0xfe, 0x1d,
+	0x2d, 0xbb, 0x3c, 0x3b, 0x7f, 0x9e, 0x33, 0x9a, 0xf5, 0x77, 0xb5, 0x88, 0xc9, 0xe1, 0xd4, 0x77,
+	0x02, 0x3e, 0xa9, 0xfb, 0x64, 0xc4, 0xe2, 0x34, 0xfb, 0xca, 0x83, 0x5a, 0x44, 0xe3, 0x9a, 0xd6,
+	0xa8, 0x45, 0xbc, 0x3e, 0x93, 0xf1, 0x97, 0xf4, 0xf0, 0xd1, 0xcf, 0x01, 0x00, 0xfa, 0xf7, 0x9b,
+	0x82, 0x57, 0x04, 0x00, 0x00,
 }
@@ -0,0 +1,83 @@
+//go:build go1.21
+// +build go1.21
+
+package example
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerSlogLogger(t *testing.T) {
+	var buff bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buff, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	ts := NewHaberdasherTwirpServer(&testHaberdasher{}, WithTwirpServerSlogLogger(logger, slog.LevelDebug, slog.LevelWarn))
+	svr := httptest.NewServer(ts)
+	defer svr.Close()
+
+	c, err := NewHaberdasherTwirpClient(svr.URL, http.DefaultTransport)
+	require.NoError(t, err)
+
+	_, err = c.MakeHat(context.Background(), &Size{Inches: 14})
+	require.NoError(t, err)
+
+	_, err = c.MakeHat(context.Background(), &Size{Inches: -1})
+	require.Error(t, err)
+
+	var entries []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buff.String()), "\n") {
+		var entry map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &entry))
+		entries = append(entries, entry)
+	}
+
+	require.Len(t, entries, 4)
+
+	require.Equal(t, "twirp request started", entries[0]["msg"])
+	require.Equal(t, "MakeHat", entries[0]["method"])
+
+	require.Equal(t, "twirp request finished", entries[1]["msg"])
+	require.Equal(t, "DEBUG", entries[1]["level"])
+	require.Equal(t, "ok", entries[1]["code"])
+	require.Contains(t, entries[1], "duration")
+
+	require.Equal(t, "WARN", entries[3]["level"])
+	require.Equal(t, "invalid_argument", entries[3]["code"])
+}
+
+func TestServerSlogLoggerSampled(t *testing.T) {
+	var buff bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buff, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	ts := NewHaberdasherTwirpServer(&testHaberdasher{},
+		WithTwirpServerSlogLogger(logger, slog.LevelDebug, slog.LevelWarn),
+		WithTwirpServerRequestSampler(func(method string) bool { return false }),
+	)
+	svr := httptest.NewServer(ts)
+	defer svr.Close()
+
+	c, err := NewHaberdasherTwirpClient(svr.URL, http.DefaultTransport)
+	require.NoError(t, err)
+
+	_, err = c.MakeHat(context.Background(), &Size{Inches: 14})
+	require.NoError(t, err)
+	require.Empty(t, buff.String())
+
+	// failures are logged even when the request was not sampled
+	_, err = c.MakeHat(context.Background(), &Size{Inches: -1})
+	require.Error(t, err)
+
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal(buff.Bytes(), &entry))
+	require.Equal(t, "twirp request finished", entry["msg"])
+	require.Equal(t, "invalid_argument", entry["code"])
+}
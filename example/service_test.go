@@ -2,18 +2,47 @@ package example
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"html"
+	"io"
 	"io/ioutil"
+	"math"
+	"math/big"
 	"math/rand"
 	"net/http"
 	"net/http/httptest"
+	"net/http/httptrace"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
+	"unsafe"
 
 	"github.com/stretchr/testify/require"
 	twirp "github.com/twitchtv/twirp"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/encoding/protowire"
 	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 func doTests(t *testing.T, client Haberdasher) {
@@ -50,36 +79,3260 @@ func TestClient(t *testing.T) {
 	doTests(t, c)
 }
 
+func TestProtobufContentType(t *testing.T) {
+	var requestType, responseType string
+
+	ts := NewHaberdasherTwirpServer(&testHaberdasher{})
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestType = r.Header.Get("Content-Type")
+		ts.ServeHTTP(w, r)
+		responseType = w.Header().Get("Content-Type")
+	}))
+	defer svr.Close()
+
+	for _, contentType := range []string{"application/protobuf", "application/x-protobuf"} {
+		t.Run(contentType, func(t *testing.T) {
+			c, err := NewHaberdasherTwirpClient(svr.URL, http.DefaultTransport, WithTwirpClientProtobufContentType(contentType))
+			require.NoError(t, err)
+
+			hat, err := c.MakeHat(context.Background(), &Size{Inches: 14})
+			require.NoError(t, err)
+			require.Equal(t, int32(14), hat.Size)
+			require.Equal(t, contentType, requestType)
+			require.Equal(t, contentType, responseType)
+		})
+	}
+
+	// the option does not apply to other codecs
+	c, err := NewHaberdasherTwirpClient(svr.URL, http.DefaultTransport, WithTwirpClientCodec(DefaultTwirpCodecJson), WithTwirpClientProtobufContentType("application/x-protobuf"))
+	require.NoError(t, err)
+
+	_, err = c.MakeHat(context.Background(), &Size{Inches: 14})
+	require.NoError(t, err)
+	require.Equal(t, "application/json", requestType)
+}
+
+// legacyMarshaler is a stand-in for a custom binary format: protobuf with a prefix.
+type legacyMarshaler struct{}
+
+func (legacyMarshaler) Marshal(m proto.Message) ([]byte, error) {
+	data, err := proto.Marshal(m)
+	return append([]byte("LEGACY"), data...), err
+}
+
+func (legacyMarshaler) Unmarshal(data []byte, m proto.Message) error {
+	if !bytes.HasPrefix(data, []byte("LEGACY")) {
+		return errors.New("missing prefix")
+	}
+	return proto.Unmarshal(data[len("LEGACY"):], m)
+}
+
+func TestCustomCodec(t *testing.T) {
+	legacy := NewTwirpCodec("application/x-legacy", legacyMarshaler{})
+
+	ts := NewHaberdasherTwirpServer(&testHaberdasher{}, WithTwirpServerCodec(legacy))
+	svr := httptest.NewServer(ts)
+	defer svr.Close()
+
+	c, err := NewHaberdasherTwirpClient(svr.URL, http.DefaultTransport, WithTwirpClientCodec(legacy))
+	require.NoError(t, err)
+	doTests(t, c)
+
+	// the default codecs still work
+	c, err = NewHaberdasherTwirpClient(svr.URL, http.DefaultTransport)
+	require.NoError(t, err)
+	doTests(t, c)
+
+	// the response codec is negotiated with the Accept header
+	body, err := proto.Marshal(&Size{Inches: 14})
+	require.NoError(t, err)
+
+	for _, tt := range []struct {
+		accept      string
+		contentType string
+	}{
+		{"", "application/protobuf"},
+		{"application/x-legacy", "application/x-legacy"},
+		{"text/html, application/x-legacy;q=0.9", "application/x-legacy"},
+		{"*/*", "application/protobuf"},
+	} {
+		req, err := http.NewRequest(http.MethodPost, svr.URL+ts.PathPrefix()+"MakeHat", bytes.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/protobuf")
+		req.Header.Set("Accept", tt.accept)
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		data, err := ioutil.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, tt.contentType, resp.Header.Get("Content-Type"), tt.accept)
+		require.Equal(t, tt.contentType == "application/x-legacy", bytes.HasPrefix(data, []byte("LEGACY")), tt.accept)
+	}
+}
+
+func TestPrototextCodec(t *testing.T) {
+	ts := NewHaberdasherTwirpServer(&testHaberdasher{}, WithTwirpServerCodec(DefaultTwirpCodecPrototext))
+	svr := httptest.NewServer(ts)
+	defer svr.Close()
+
+	c, err := NewHaberdasherTwirpClient(svr.URL, http.DefaultTransport, WithTwirpClientCodec(DefaultTwirpCodecPrototext))
+	require.NoError(t, err)
+	doTests(t, c)
+
+	req, err := http.NewRequest(http.MethodPost, svr.URL+ts.PathPrefix()+"MakeHat", strings.NewReader("inches: 14"))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/protobuf-text")
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	data, err := ioutil.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	require.NoError(t, err)
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "application/protobuf-text", resp.Header.Get("Content-Type"))
+
+	var hat Hat
+	require.NoError(t, prototext.Unmarshal(data, &hat))
+	require.Equal(t, int32(14), hat.Size)
+
+	// servers only accept the text format when the codec is added
+	svr2 := httptest.NewServer(NewHaberdasherTwirpServer(&testHaberdasher{}))
+	defer svr2.Close()
+
+	c, err = NewHaberdasherTwirpClient(svr2.URL, http.DefaultTransport, WithTwirpClientCodec(DefaultTwirpCodecPrototext))
+	require.NoError(t, err)
+
+	_, err = c.MakeHat(context.Background(), &Size{Inches: 14})
+	require.Error(t, err)
+}
+
+func TestBodyDumper(t *testing.T) {
+	var serverDumps, clientDumps []string
+
+	ts := NewHaberdasherTwirpServer(&testHaberdasher{}, WithTwirpServerBodyDumper(func(direction string, method string, body []byte) {
+		serverDumps = append(serverDumps, direction+" "+method)
+	}))
+	svr := httptest.NewServer(ts)
+	defer svr.Close()
+
+	var hat Hat
+
+	c, err := NewHaberdasherTwirpClient(svr.URL, http.DefaultTransport, WithTwirpClientBodyDumper(func(direction string, method string, body []byte) {
+		clientDumps = append(clientDumps, direction+" "+method)
+		if direction == "response" {
+			require.NoError(t, proto.Unmarshal(body, &hat))
+		}
+	}))
+	require.NoError(t, err)
+
+	resp, err := c.MakeHat(context.Background(), &Size{Inches: 14})
+	require.NoError(t, err)
+	require.Equal(t, resp.Color, hat.Color)
+
+	require.Equal(t, []string{"request MakeHat", "response MakeHat"}, serverDumps)
+	require.Equal(t, []string{"request MakeHat", "response MakeHat"}, clientDumps)
+}
+
+func TestBodyDumperGzip(t *testing.T) {
+	var serverResponse, clientResponse []byte
+
+	ts := NewHaberdasherTwirpServer(&namedHaberdasher{},
+		WithTwirpServerGzip(),
+		WithTwirpServerResponseCompressionThreshold(0),
+		WithTwirpServerBodyDumper(func(direction string, method string, body []byte) {
+			if direction == "response" {
+				// the body is only valid until the dumper returns
+				serverResponse = append([]byte(nil), body...)
+			}
+		}),
+	)
+	svr := httptest.NewServer(ts)
+	defer svr.Close()
+
+	// the default transport asks for gzip and decompresses responses itself
+	c, err := NewHaberdasherTwirpClient(svr.URL, http.DefaultTransport)
+	require.NoError(t, err)
+
+	_, err = c.MakeHat(context.Background(), &Size{Inches: 14})
+	require.NoError(t, err)
+
+	// responses are dumped as they are sent, compressed
+	zr, err := gzip.NewReader(bytes.NewReader(serverResponse))
+	require.NoError(t, err)
+	data, err := ioutil.ReadAll(zr)
+	require.NoError(t, err)
+
+	var hat Hat
+	require.NoError(t, proto.Unmarshal(data, &hat))
+	require.Len(t, hat.Name, 14)
+
+	// clients that decompress responses themselves dump them as they are received
+	c, err = NewHaberdasherTwirpClient(svr.URL, http.DefaultTransport, WithTwirpClientAcceptEncoding(), WithTwirpClientBodyDumper(func(direction string, method string, body []byte) {
+		if direction == "response" {
+			clientResponse = append([]byte(nil), body...)
+		}
+	}))
+	require.NoError(t, err)
+
+	resp, err := c.MakeHat(context.Background(), &Size{Inches: 14})
+	require.NoError(t, err)
+	require.Len(t, resp.Name, 14)
+	require.Equal(t, serverResponse, clientResponse)
+}
+
+func TestRequestSampler(t *testing.T) {
+	var dumps int
+	var sampled []bool
+	calls := 0
+
+	ts := NewHaberdasherTwirpServer(&testHaberdasher{},
+		WithTwirpServerBodyDumper(func(direction string, method string, body []byte) {
+			dumps++
+		}),
+		// sample every other request
+		WithTwirpServerRequestSampler(func(method string) bool {
+			require.Equal(t, "MakeHat", method)
+			calls++
+			return calls%2 == 1
+		}),
+		twirp.WithServerHooks(&twirp.ServerHooks{
+			ResponseSent: func(ctx context.Context) {
+				sampled = append(sampled, TwirpSampled(ctx))
+			},
+		}),
+	)
+	svr := httptest.NewServer(ts)
+	defer svr.Close()
+
+	c, err := NewHaberdasherTwirpClient(svr.URL, http.DefaultTransport)
+	require.NoError(t, err)
+
+	for i := 0; i < 4; i++ {
+		_, err = c.MakeHat(context.Background(), &Size{Inches: 14})
+		require.NoError(t, err)
+	}
+
+	require.Equal(t, 4, calls)
+	require.Equal(t, []bool{true, false, true, false}, sampled)
+	// the request and response of the sampled requests
+	require.Equal(t, 4, dumps)
+}
+
+func TestNonPostMethods(t *testing.T) {
+	// the implementation panics, so requests that reach it fail with internal instead of bad_route
+	ts := NewHaberdasherTwirpServer(&panicHaberdasher{})
+	svr := httptest.NewServer(ts)
+	defer svr.Close()
+
+	for _, method := range []string{http.MethodGet, http.MethodPut, http.MethodDelete, http.MethodPatch} {
+		t.Run(method, func(t *testing.T) {
+			req, err := http.NewRequest(method, svr.URL+ts.PathPrefix()+"MakeHat", bytes.NewBufferString(`{"inches":14}`))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			require.Equal(t, http.StatusNotFound, resp.StatusCode)
+			require.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+
+			var body struct {
+				Code string            `json:"code"`
+				Msg  string            `json:"msg"`
+				Meta map[string]string `json:"meta"`
+			}
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+			require.Equal(t, string(twirp.BadRoute), body.Code)
+			require.Equal(t, fmt.Sprintf("unsupported method %q (only POST is allowed)", method), body.Msg)
+			require.Equal(t, method+" "+ts.PathPrefix()+"MakeHat", body.Meta["twirp_invalid_route"])
+		})
+	}
+}
+
+func TestLegacyErrorFormat(t *testing.T) {
+	ts := NewHaberdasherTwirpServer(&testHaberdasher{}, WithTwirpServerLegacyErrorFormat(func(twerr twirp.Error) []byte {
+		return []byte(fmt.Sprintf(`{"error_code":%q,"error_message":%q}`, twerr.Code(), twerr.Msg()))
+	}))
+	svr := httptest.NewServer(ts)
+	defer svr.Close()
+
+	resp, err := http.Post(svr.URL+ts.PathPrefix()+"MakeHat", "application/json", bytes.NewBufferString(`{"inches":-1}`))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	require.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	require.Equal(t, `{"error_code":"invalid_argument","error_message":"Inches I can't make a hat that small!"}`, string(body))
+
+	// successful responses are unchanged
+	c, err := NewHaberdasherTwirpClient(svr.URL, http.DefaultTransport)
+	require.NoError(t, err)
+
+	hat, err := c.MakeHat(context.Background(), &Size{Inches: 14})
+	require.NoError(t, err)
+	require.Equal(t, int32(14), hat.Size)
+}
+
+func TestHTTPErrorHandler(t *testing.T) {
+	var sentStatus int
+
+	ts := NewHaberdasherTwirpServer(&testHaberdasher{},
+		WithTwirpServerHTTPErrorHandler(func(w http.ResponseWriter, r *http.Request, err twirp.Error) {
+			w.Header().Set("Content-Type", "application/problem+json")
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			_, _ = fmt.Fprintf(w, `{"type":"about:blank","title":%q,"status":422,"detail":%q,"instance":%q}`, err.Code(), err.Msg(), r.URL.Path)
+		}),
+		twirp.WithServerHooks(&twirp.ServerHooks{
+			ResponseSent: func(ctx context.Context) {
+				status, _ := twirp.StatusCode(ctx)
+				sentStatus, _ = strconv.Atoi(status)
+			},
+		}),
+	)
+	svr := httptest.NewServer(ts)
+	defer svr.Close()
+
+	resp, err := http.Post(svr.URL+ts.PathPrefix()+"MakeHat", "application/json", bytes.NewBufferString(`{"inches":-1}`))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+	require.Equal(t, "application/problem+json", resp.Header.Get("Content-Type"))
+	require.JSONEq(t, `{"type":"about:blank","title":"invalid_argument","status":422,"detail":"Inches I can't make a hat that small!","instance":"/twirp/twitch.twirp.example.Haberdasher/MakeHat"}`, string(body))
+	require.Equal(t, http.StatusUnprocessableEntity, sentStatus)
+
+	// successful responses are unchanged
+	c, err := NewHaberdasherTwirpClient(svr.URL, http.DefaultTransport)
+	require.NoError(t, err)
+
+	hat, err := c.MakeHat(context.Background(), &Size{Inches: 14})
+	require.NoError(t, err)
+	require.Equal(t, int32(14), hat.Size)
+	require.Equal(t, http.StatusOK, sentStatus)
+}
+
+func TestMethodEnabled(t *testing.T) {
+	var disabled int32
+
+	ts := NewHaberdasherTwirpServer(&testHaberdasher{}, WithTwirpServerMethodEnabled(func(method string) bool {
+		return method != "MakeHat" || atomic.LoadInt32(&disabled) == 0
+	}))
+	svr := httptest.NewServer(ts)
+	defer svr.Close()
+
+	c, err := NewHaberdasherTwirpClient(svr.URL, http.DefaultTransport)
+	require.NoError(t, err)
+
+	_, err = c.MakeHat(context.Background(), &Size{Inches: 14})
+	require.NoError(t, err)
+
+	atomic.StoreInt32(&disabled, 1)
+
+	_, err = c.MakeHat(context.Background(), &Size{Inches: 14})
+	require.Error(t, err)
+	twerr, ok := err.(twirp.Error)
+	require.True(t, ok)
+	require.Equal(t, twirp.Unavailable, twerr.Code())
+	require.Equal(t, "method MakeHat is disabled", twerr.Msg())
+
+	atomic.StoreInt32(&disabled, 0)
+
+	_, err = c.MakeHat(context.Background(), &Size{Inches: 14})
+	require.NoError(t, err)
+}
+
+func TestTokenSource(t *testing.T) {
+	var valid atomic.Value
+	valid.Store("token-1")
+
+	ts := NewHaberdasherTwirpServer(&testHaberdasher{}, twirp.WithServerHooks(&twirp.ServerHooks{
+		RequestReceived: func(ctx context.Context) (context.Context, error) {
+			if auth, _ := ctx.Value(testAuthKey{}).(string); auth != "Bearer "+valid.Load().(string) {
+				return ctx, twirp.NewError(twirp.Unauthenticated, "invalid token")
+			}
+			return ctx, nil
+		},
+	}))
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), testAuthKey{}, r.Header.Get("Authorization"))
+		ts.ServeHTTP(w, r.WithContext(ctx))
+	}))
+	defer svr.Close()
+
+	var fetches int32
+	c, err := NewHaberdasherTwirpClient(svr.URL, http.DefaultTransport, WithTwirpClientTokenSource(func(ctx context.Context) (string, error) {
+		return "token-" + strconv.Itoa(int(atomic.AddInt32(&fetches, 1))), nil
+	}))
+	require.NoError(t, err)
+
+	// the token is fetched once and reused
+	for i := 0; i < 3; i++ {
+		_, err = c.MakeHat(context.Background(), &Size{Inches: 14})
+		require.NoError(t, err)
+	}
+	require.Equal(t, int32(1), atomic.LoadInt32(&fetches))
+
+	// an expired token is refreshed once
+	valid.Store("token-2")
+
+	_, err = c.MakeHat(context.Background(), &Size{Inches: 14})
+	require.NoError(t, err)
+	require.Equal(t, int32(2), atomic.LoadInt32(&fetches))
+
+	// a token that is rejected again is not retried
+	valid.Store("never")
+
+	_, err = c.MakeHat(context.Background(), &Size{Inches: 14})
+	require.Error(t, err)
+	twerr, ok := err.(twirp.Error)
+	require.True(t, ok)
+	require.Equal(t, twirp.Unauthenticated, twerr.Code())
+	require.Equal(t, int32(3), atomic.LoadInt32(&fetches))
+
+	// errors from the token source fail the call
+	c, err = NewHaberdasherTwirpClient(svr.URL, http.DefaultTransport, WithTwirpClientTokenSource(func(ctx context.Context) (string, error) {
+		return "", errors.New("token service down")
+	}))
+	require.NoError(t, err)
+
+	_, err = c.MakeHat(context.Background(), &Size{Inches: 14})
+	require.Error(t, err)
+	twerr, ok = err.(twirp.Error)
+	require.True(t, ok)
+	require.Equal(t, twirp.Unauthenticated, twerr.Code())
+}
+
+func TestRecordingClient(t *testing.T) {
+	ts := NewHaberdasherTwirpServer(&testHaberdasher{})
+	svr := httptest.NewServer(ts)
+	defer svr.Close()
+
+	c, err := NewHaberdasherTwirpClient(svr.URL, http.DefaultTransport)
+	require.NoError(t, err)
+
+	rec := NewRecordingHaberdasherClient(c)
+
+	size := &Size{Inches: 14}
+	_, err = rec.MakeHat(context.Background(), size)
+	require.NoError(t, err)
+
+	_, err = rec.MakeHat(context.Background(), &Size{Inches: -1})
+	require.Error(t, err)
+
+	// later changes to the request do not affect the recorded copy
+	size.Inches = 20
+
+	calls := rec.Calls()
+	require.Len(t, calls, 2)
+	require.Equal(t, "MakeHat", calls[0].Method)
+	require.True(t, proto.Equal(&Size{Inches: 14}, calls[0].Request))
+	require.True(t, proto.Equal(&Size{Inches: -1}, calls[1].Request))
+
+	rec.Reset()
+	require.Empty(t, rec.Calls())
+}
+
+func TestCassette(t *testing.T) {
+	for _, codec := range []TwirpCodec{DefaultTwirpCodecProtobuf, DefaultTwirpCodecJson} {
+		path := filepath.Join(t.TempDir(), "cassette.json")
+
+		svr := httptest.NewServer(NewHaberdasherTwirpServer(&testHaberdasher{}))
+
+		c, err := NewHaberdasherTwirpClient(svr.URL, http.DefaultTransport, WithTwirpClientCodec(codec), WithTwirpClientCassette(path))
+		require.NoError(t, err)
+
+		recorded, err := c.MakeHat(context.Background(), &Size{Inches: 14})
+		require.NoError(t, err)
+
+		_, err = c.MakeHat(context.Background(), &Size{Inches: -1})
+		require.Error(t, err)
+
+		svr.Close()
+
+		// replayed without the server, by a client of either codec
+		for _, replayCodec := range []TwirpCodec{DefaultTwirpCodecProtobuf, DefaultTwirpCodecJson} {
+			c, err = NewHaberdasherTwirpClient(svr.URL, http.DefaultTransport, WithTwirpClientCodec(replayCodec), WithTwirpClientCassette(path))
+			require.NoError(t, err)
+
+			hat, err := c.MakeHat(context.Background(), &Size{Inches: 14})
+			require.NoError(t, err)
+			require.True(t, proto.Equal(recorded, hat))
+
+			_, err = c.MakeHat(context.Background(), &Size{Inches: -1})
+			twerr, ok := err.(twirp.Error)
+			require.True(t, ok)
+			require.Equal(t, twirp.InvalidArgument, twerr.Code())
+
+			_, err = c.MakeHat(context.Background(), &Size{Inches: 15})
+			twerr, ok = err.(twirp.Error)
+			require.True(t, ok)
+			require.Equal(t, twirp.Internal, twerr.Code())
+		}
+	}
+}
+
+func TestBalancedClient(t *testing.T) {
+	var counts [2]int
+
+	var urls []string
+	for i := range counts {
+		i := i
+		ts := NewHaberdasherTwirpServer(&testHaberdasher{}, twirp.WithServerHooks(&twirp.ServerHooks{
+			RequestRouted: func(ctx context.Context) (context.Context, error) {
+				counts[i]++
+				return ctx, nil
+			},
+		}))
+		svr := httptest.NewServer(ts)
+		defer svr.Close()
+
+		urls = append(urls, svr.URL)
+	}
+
+	c, err := NewHaberdasherTwirpClientBalanced(urls, http.DefaultTransport, NewTwirpRoundRobinBalancer())
+	require.NoError(t, err)
+
+	for i := 0; i < 4; i++ {
+		_, err := c.MakeHat(context.Background(), &Size{Inches: 14})
+		require.NoError(t, err)
+	}
+
+	require.Equal(t, [2]int{2, 2}, counts)
+}
+
+func TestBalancedClientFailover(t *testing.T) {
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	up := httptest.NewServer(NewHaberdasherTwirpServer(&testHaberdasher{}))
+	defer up.Close()
+
+	c, err := NewHaberdasherTwirpClientBalanced([]string{down.URL, up.URL}, http.DefaultTransport, NewTwirpRandomBalancer())
+	require.NoError(t, err)
+
+	// MakeHat is idempotent, so requests sent to the closed server are retried on the other.
+	for i := 0; i < 4; i++ {
+		_, err := c.MakeHat(context.Background(), &Size{Inches: 14})
+		require.NoError(t, err)
+	}
+
+	_, err = NewHaberdasherTwirpClientBalanced(nil, http.DefaultTransport, nil)
+	require.Error(t, err)
+}
+
+func TestCallOptions(t *testing.T) {
+	h := &headerHaberdasher{}
+	header := WithTwirpServerRequestHeaderAllowlist(map[string]func(string) (string, error){"X-Tenant": nil})
+	ts := NewHaberdasherTwirpServer(h, header)
+	svr := httptest.NewServer(ts)
+	defer svr.Close()
+
+	c, err := NewHaberdasherTwirpClient(svr.URL, http.DefaultTransport)
+	require.NoError(t, err)
+
+	_, err = c.MakeHatWithOptions(context.Background(), &Size{Inches: 14}, WithTwirpCallHeader("X-Tenant", "downtown"))
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"X-Tenant": "downtown"}, h.headers)
+
+	_, err = c.MakeHatWithOptions(context.Background(), &Size{Inches: 14}, WithTwirpCallHeader("Content-Type", "text/plain"))
+	require.Error(t, err)
+
+	release := make(chan struct{})
+	slow := httptest.NewServer(NewHaberdasherTwirpServer(&slowHaberdasher{release: release}))
+	defer slow.Close()
+	defer close(release)
+
+	c, err = NewHaberdasherTwirpClient(slow.URL, http.DefaultTransport)
+	require.NoError(t, err)
+
+	_, err = c.MakeHatWithOptions(context.Background(), &Size{Inches: 14}, WithTwirpCallTimeout(10*time.Millisecond))
+	twerr, ok := err.(twirp.Error)
+	require.True(t, ok)
+	require.Equal(t, twirp.DeadlineExceeded, twerr.Code())
+
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	up := httptest.NewServer(NewHaberdasherTwirpServer(&testHaberdasher{}))
+	defer up.Close()
+
+	c, err = NewHaberdasherTwirpClientBalanced([]string{down.URL, up.URL}, http.DefaultTransport, NewTwirpRoundRobinBalancer())
+	require.NoError(t, err)
+
+	// the first request goes to the closed server, and is not failed over to the other
+	_, err = c.MakeHatWithOptions(context.Background(), &Size{Inches: 14}, WithTwirpCallNoRetry())
+	require.Error(t, err)
+
+	_, err = c.MakeHatWithOptions(context.Background(), &Size{Inches: 14})
+	require.NoError(t, err)
+
+	_, err = c.MakeHat(context.Background(), &Size{Inches: 14})
+	require.NoError(t, err)
+}
+
+func TestCallCodec(t *testing.T) {
+	ts := NewHaberdasherTwirpServer(&testHaberdasher{})
+
+	var contentTypes []string
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ts.ServeHTTP(w, r)
+		contentTypes = append(contentTypes, r.Header.Get("Content-Type")+" "+w.Header().Get("Content-Type"))
+	}))
+	defer svr.Close()
+
+	c, err := NewHaberdasherTwirpClient(svr.URL, http.DefaultTransport)
+	require.NoError(t, err)
+
+	hat, err := c.MakeHatWithOptions(context.Background(), &Size{Inches: 14}, WithTwirpCallCodec(DefaultTwirpCodecJson))
+	require.NoError(t, err)
+	require.Equal(t, int32(14), hat.Size)
+
+	hat, err = c.MakeHat(context.Background(), &Size{Inches: 15})
+	require.NoError(t, err)
+	require.Equal(t, int32(15), hat.Size)
+
+	require.Equal(t, []string{"application/json application/json", "application/protobuf application/protobuf"}, contentTypes)
+}
+
+func TestClientCanary(t *testing.T) {
+	ts := NewHaberdasherTwirpServer(&testHaberdasher{})
+
+	hosts := map[string]int{}
+	transport := NewTwirpInMemoryTransport(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hosts[r.Host]++
+		ts.ServeHTTP(w, r)
+	}))
+
+	newClient := func(weight float64) *HaberdasherTwirpClient {
+		c, err := NewHaberdasherTwirpClientBalanced([]string{"http://primary-1", "http://primary-2"}, transport, nil, WithTwirpClientCanary("http://canary", weight))
+		require.NoError(t, err)
+		return c
+	}
+
+	for _, weight := range []float64{0, 0.25, 1} {
+		hosts = map[string]int{}
+
+		c := newClient(weight)
+		for i := 0; i < 2000; i++ {
+			_, err := c.MakeHat(context.Background(), &Size{Inches: 14})
+			require.NoError(t, err)
+		}
+
+		require.InDelta(t, weight, float64(hosts["canary"])/2000, 0.05, fmt.Sprintf("weight %v", weight))
+		require.InDelta(t, hosts["primary-1"], hosts["primary-2"], 1, fmt.Sprintf("weight %v", weight))
+	}
+
+	// calls in a session all go to the same target
+	c := newClient(0.5)
+	canary := 0
+	for i := 0; i < 100; i++ {
+		ctx := TwirpWithCanarySession(context.Background(), fmt.Sprintf("user-%d", i))
+
+		hosts = map[string]int{}
+		for j := 0; j < 4; j++ {
+			_, err := c.MakeHat(ctx, &Size{Inches: 14})
+			require.NoError(t, err)
+		}
+
+		require.Contains(t, []int{0, 4}, hosts["canary"])
+		if hosts["canary"] > 0 {
+			canary++
+		}
+	}
+	require.InDelta(t, 50, canary, 20)
+
+	for _, weight := range []float64{-0.1, 1.5, math.NaN()} {
+		_, err := NewHaberdasherTwirpClient("http://primary", transport, WithTwirpClientCanary("http://canary", weight))
+		require.Error(t, err)
+	}
+}
+
+func TestClientHedging(t *testing.T) {
+	var calls int32
+	canceled := make(chan struct{})
+
+	ts := NewHaberdasherTwirpServer(&testHaberdasher{})
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the first request hangs until the client cancels it
+		if atomic.AddInt32(&calls, 1) == 1 {
+			// the server only notices the client went away once the body is read
+			_, _ = ioutil.ReadAll(r.Body)
+			<-r.Context().Done()
+			close(canceled)
+			return
+		}
+		ts.ServeHTTP(w, r)
+	}))
+	defer svr.Close()
+
+	c, err := NewHaberdasherTwirpClient(svr.URL, http.DefaultTransport, WithTwirpClientHedging(10*time.Millisecond, 1))
+	require.NoError(t, err)
+
+	hat, err := c.MakeHat(context.Background(), &Size{Inches: 14})
+	require.NoError(t, err)
+	require.Equal(t, int32(14), hat.Size)
+	require.Equal(t, int32(2), atomic.LoadInt32(&calls))
+
+	select {
+	case <-canceled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("slow request was not canceled")
+	}
+}
+
+type testAuthKey struct{}
+
+func TestExpectContinue(t *testing.T) {
+	var expect string
+
+	ts := NewHaberdasherTwirpServer(&testHaberdasher{}, twirp.WithServerHooks(&twirp.ServerHooks{
+		RequestReceived: func(ctx context.Context) (context.Context, error) {
+			if auth, _ := ctx.Value(testAuthKey{}).(string); auth == "" {
+				return ctx, twirp.NewError(twirp.Unauthenticated, "missing credentials")
+			}
+			return ctx, nil
+		},
+	}))
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		expect = r.Header.Get("Expect")
+
+		ctx := context.WithValue(r.Context(), testAuthKey{}, r.Header.Get("Authorization"))
+		ts.ServeHTTP(w, r.WithContext(ctx))
+	}))
+	defer svr.Close()
+
+	c, err := NewHaberdasherTwirpClient(svr.URL, http.DefaultTransport, WithTwirpClientExpectContinue())
+	require.NoError(t, err)
+
+	_, err = c.MakeHat(context.Background(), &Size{Inches: 14})
+	require.Error(t, err)
+	require.Equal(t, "100-continue", expect)
+
+	var twerr twirp.Error
+	require.True(t, errors.As(err, &twerr))
+	require.Equal(t, twirp.Unauthenticated, twerr.Code())
+
+	ctx, err := twirp.WithHTTPRequestHeaders(context.Background(), http.Header{"Authorization": []string{"Bearer token"}})
+	require.NoError(t, err)
+
+	hat, err := c.MakeHat(ctx, &Size{Inches: 14})
+	require.NoError(t, err)
+	require.Equal(t, int32(14), hat.Size)
+}
+
+func TestRequestValidator(t *testing.T) {
+	ts := NewHaberdasherTwirpServer(&testHaberdasher{}, WithTwirpServerRequestValidator(func(ctx context.Context, method string, req proto.Message) error {
+		size, ok := req.(*Size)
+		if !ok {
+			return fmt.Errorf("unexpected request type %T for %s", req, method)
+		}
+		switch {
+		case size.Inches > 100:
+			return errors.New("size is too large")
+		case size.Inches == 42:
+			return twirp.NewError(twirp.PermissionDenied, "that size is reserved")
+		}
+		return nil
+	}))
+	svr := httptest.NewServer(ts)
+	defer svr.Close()
+
+	c, err := NewHaberdasherTwirpClient(svr.URL, http.DefaultTransport)
+	require.NoError(t, err)
+
+	_, err = c.MakeHat(context.Background(), &Size{Inches: 14})
+	require.NoError(t, err)
+
+	_, err = c.MakeHat(context.Background(), &Size{Inches: 101})
+	var twerr twirp.Error
+	require.True(t, errors.As(err, &twerr))
+	require.Equal(t, twirp.InvalidArgument, twerr.Code())
+	require.Equal(t, "size is too large", twerr.Msg())
+
+	_, err = c.MakeHat(context.Background(), &Size{Inches: 42})
+	require.True(t, errors.As(err, &twerr))
+	require.Equal(t, twirp.PermissionDenied, twerr.Code())
+}
+
+func TestResponseTransformer(t *testing.T) {
+	var calls int32
+	ts := NewHaberdasherTwirpServer(&testHaberdasher{}, WithTwirpServerResponseTransformer(func(ctx context.Context, method string, resp proto.Message) (proto.Message, error) {
+		atomic.AddInt32(&calls, 1)
+		hat, ok := resp.(*Hat)
+		if !ok {
+			return nil, fmt.Errorf("unexpected response type %T for %s", resp, method)
+		}
+		switch hat.Size {
+		case 20:
+			return nil, errors.New("cannot transform")
+		case 42:
+			return nil, twirp.NewError(twirp.FailedPrecondition, "that size is reserved")
+		case 43:
+			return &Hat{Size: hat.Size, Name: "replaced"}, nil
+		}
+		hat.Color = "transformed by " + method
+		return hat, nil
+	}))
+	svr := httptest.NewServer(ts)
+	defer svr.Close()
+
+	c, err := NewHaberdasherTwirpClient(svr.URL, http.DefaultTransport)
+	require.NoError(t, err)
+
+	hat, err := c.MakeHat(context.Background(), &Size{Inches: 14})
+	require.NoError(t, err)
+	require.Equal(t, "transformed by MakeHat", hat.Color)
+
+	hat, err = c.MakeHat(context.Background(), &Size{Inches: 43})
+	require.NoError(t, err)
+	require.Equal(t, "replaced", hat.Name)
+
+	_, err = c.MakeHat(context.Background(), &Size{Inches: 20})
+	var twerr twirp.Error
+	require.True(t, errors.As(err, &twerr))
+	require.Equal(t, twirp.Internal, twerr.Code())
+	require.Equal(t, "cannot transform", twerr.Msg())
+
+	_, err = c.MakeHat(context.Background(), &Size{Inches: 42})
+	require.True(t, errors.As(err, &twerr))
+	require.Equal(t, twirp.FailedPrecondition, twerr.Code())
+
+	// failed calls are not transformed
+	_, err = c.MakeHat(context.Background(), &Size{Inches: -1})
+	require.True(t, errors.As(err, &twerr))
+	require.Equal(t, twirp.InvalidArgument, twerr.Code())
+	require.Equal(t, int32(4), atomic.LoadInt32(&calls))
+}
+
+func TestTaggedStructs(t *testing.T) {
+	field, ok := reflect.TypeOf(SizeTagged{}).FieldByName("Inches")
+	require.True(t, ok)
+	require.Equal(t, "inches", field.Tag.Get("json"))
+	require.Equal(t, "inches", field.Tag.Get("yaml"))
+	require.Equal(t, "gt=0", field.Tag.Get("validate"))
+
+	hat := &Hat{Size: 14, Color: "red", Name: "bowler"}
+	require.True(t, proto.Equal(hat, NewHatTagged(hat).Proto()))
+	require.Nil(t, NewHatTagged(nil))
+}
+
+func TestResponseValidator(t *testing.T) {
+	svr := httptest.NewServer(NewHaberdasherTwirpServer(&testHaberdasher{}))
+	defer svr.Close()
+
+	var methods []string
+	c, err := NewHaberdasherTwirpClient(svr.URL, http.DefaultTransport, WithTwirpClientResponseValidator(func(method string, resp proto.Message) error {
+		methods = append(methods, method)
+		if hat, ok := resp.(*Hat); ok && hat.Size > 20 {
+			return errors.New("hat is too large")
+		}
+		return nil
+	}))
+	require.NoError(t, err)
+
+	_, err = c.MakeHat(context.Background(), &Size{Inches: 14})
+	require.NoError(t, err)
+
+	_, err = c.MakeHat(context.Background(), &Size{Inches: 21})
+	var twerr twirp.Error
+	require.True(t, errors.As(err, &twerr))
+	require.Equal(t, twirp.Internal, twerr.Code())
+	require.Equal(t, "invalid response: hat is too large", twerr.Msg())
+
+	require.Equal(t, []string{"MakeHat", "MakeHat"}, methods)
+}
+
+func TestConnCallback(t *testing.T) {
+	svr := httptest.NewServer(NewHaberdasherTwirpServer(&testHaberdasher{}))
+	defer svr.Close()
+
+	transport := &http.Transport{}
+	defer transport.CloseIdleConnections()
+
+	var reused []bool
+	c, err := NewHaberdasherTwirpClient(svr.URL, transport, WithTwirpClientConnCallback(func(method string, info httptrace.GotConnInfo) {
+		require.Equal(t, "MakeHat", method)
+		reused = append(reused, info.Reused)
+	}))
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		_, err = c.MakeHat(context.Background(), &Size{Inches: 14})
+		require.NoError(t, err)
+	}
+
+	require.Equal(t, []bool{false, true}, reused)
+}
+
+func TestClientMetrics(t *testing.T) {
+	svr := httptest.NewServer(NewHaberdasherTwirpServer(&testHaberdasher{}))
+	defer svr.Close()
+
+	type call struct {
+		method   string
+		duration time.Duration
+		err      error
+	}
+	var calls []call
+	metrics := WithTwirpClientMetrics(func(method string, duration time.Duration, err error) {
+		calls = append(calls, call{method, duration, err})
+	})
+
+	c, err := NewHaberdasherTwirpClient(svr.URL, http.DefaultTransport, metrics)
+	require.NoError(t, err)
+
+	_, err = c.MakeHat(context.Background(), &Size{Inches: 14})
+	require.NoError(t, err)
+
+	_, err = c.MakeHat(context.Background(), &Size{Inches: -1})
+	require.Error(t, err)
+
+	require.Len(t, calls, 2)
+	require.Equal(t, "MakeHat", calls[0].method)
+	require.Greater(t, int64(calls[0].duration), int64(0))
+	require.NoError(t, calls[0].err)
+	require.Equal(t, err, calls[1].err)
+}
+
+func TestErrorRateTracking(t *testing.T) {
+	svr := httptest.NewServer(NewHaberdasherTwirpServer(&testHaberdasher{}))
+	defer svr.Close()
+
+	c, err := NewHaberdasherTwirpClient(svr.URL, http.DefaultTransport, WithTwirpClientErrorRateTracking(200*time.Millisecond))
+	require.NoError(t, err)
+	require.Equal(t, float64(0), c.ErrorRate("MakeHat"))
+
+	for _, inches := range []int32{14, 14, 14, -1} {
+		_, _ = c.MakeHat(context.Background(), &Size{Inches: inches})
+	}
+	require.Equal(t, 0.25, c.ErrorRate("MakeHat"))
+	require.Equal(t, float64(0), c.ErrorRate("MakeCap"))
+
+	// calls leave the window
+	time.Sleep(250 * time.Millisecond)
+	require.Equal(t, float64(0), c.ErrorRate("MakeHat"))
+
+	// clients without the option track nothing
+	c, err = NewHaberdasherTwirpClient(svr.URL, http.DefaultTransport)
+	require.NoError(t, err)
+	_, _ = c.MakeHat(context.Background(), &Size{Inches: -1})
+	require.Equal(t, float64(0), c.ErrorRate("MakeHat"))
+}
+
+func TestJSONFallback(t *testing.T) {
+	server := NewHaberdasherTwirpServer(&testHaberdasher{})
+
+	// a proxy in front of the server that only accepts JSON
+	var contentTypes []string
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentTypes = append(contentTypes, r.Header.Get("Content-Type"))
+		if r.Header.Get("Content-Type") != "application/json" {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+		server.ServeHTTP(w, r)
+	}))
+	defer svr.Close()
+
+	c, err := NewHaberdasherTwirpClient(svr.URL, http.DefaultTransport)
+	require.NoError(t, err)
+
+	_, err = c.MakeHat(context.Background(), &Size{Inches: 14})
+	require.Error(t, err)
+
+	contentTypes = nil
+	c, err = NewHaberdasherTwirpClient(svr.URL, http.DefaultTransport, WithTwirpClientJSONFallback())
+	require.NoError(t, err)
+
+	hat, err := c.MakeHat(context.Background(), &Size{Inches: 14})
+	require.NoError(t, err)
+	require.Equal(t, int32(14), hat.Size)
+	require.Equal(t, []string{"application/protobuf", "application/json"}, contentTypes)
+
+	// errors from the JSON request are returned
+	_, err = c.MakeHat(context.Background(), &Size{Inches: -1})
+	require.Equal(t, twirp.InvalidArgument, err.(twirp.Error).Code())
+
+	// the JSON request is sent to the server that rejected the protobuf request, and the request
+	// hooks are only called once
+	var hosts []string
+	transport := NewTwirpInMemoryTransport(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hosts = append(hosts, r.Host)
+		if r.Header.Get("Content-Type") != "application/json" {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+		server.ServeHTTP(w, r)
+	}))
+
+	prepared := 0
+	hooks := &twirp.ClientHooks{
+		RequestPrepared: func(ctx context.Context, r *http.Request) (context.Context, error) {
+			prepared++
+			return ctx, nil
+		},
+	}
+
+	c, err = NewHaberdasherTwirpClientBalanced([]string{"http://primary-1", "http://primary-2"}, transport, nil, WithTwirpClientJSONFallback(), WithTwirpClientCanary("http://canary", 0.5), twirp.WithClientHooks(hooks))
+	require.NoError(t, err)
+
+	for i := 0; i < 20; i++ {
+		hosts, prepared = nil, 0
+
+		_, err = c.MakeHat(context.Background(), &Size{Inches: 14})
+		require.NoError(t, err)
+		require.Len(t, hosts, 2)
+		require.Equal(t, hosts[0], hosts[1])
+		require.Equal(t, 1, prepared)
+	}
+}
+
+// clientCertificate returns a self-signed client certificate with the common name name.
+func clientCertificate(t *testing.T, name string) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.New(rand.NewSource(1)))
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.New(rand.NewSource(1)), template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestPeerCertificateCheck(t *testing.T) {
+	var methods []string
+	check := WithTwirpServerPeerCertificateCheck(func(ctx context.Context, method string, cert *x509.Certificate) error {
+		methods = append(methods, method)
+		switch cert.Subject.CommonName {
+		case "billing":
+			return errors.New("billing may not make hats")
+		case "banned":
+			return twirp.NewError(twirp.Unauthenticated, "banned")
+		}
+		return nil
+	})
+
+	svr := httptest.NewUnstartedServer(NewHaberdasherTwirpServer(&testHaberdasher{}, check))
+	svr.TLS = &tls.Config{ClientAuth: tls.RequestClientCert}
+	svr.StartTLS()
+	defer svr.Close()
+
+	for _, tt := range []struct {
+		name string
+		code twirp.ErrorCode
+	}{
+		{"shop", twirp.NoError},
+		{"billing", twirp.PermissionDenied},
+		{"banned", twirp.Unauthenticated},
+		// without a certificate the request is not checked
+		{"", twirp.NoError},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			transport := svr.Client().Transport.(*http.Transport).Clone()
+			defer transport.CloseIdleConnections()
+			if tt.name != "" {
+				transport.TLSClientConfig.Certificates = []tls.Certificate{clientCertificate(t, tt.name)}
+			}
+
+			c, err := NewHaberdasherTwirpClient(svr.URL, transport)
+			require.NoError(t, err)
+
+			_, err = c.MakeHat(context.Background(), &Size{Inches: 14})
+			if tt.code == twirp.NoError {
+				require.NoError(t, err)
+				return
+			}
+			require.Equal(t, tt.code, err.(twirp.Error).Code())
+		})
+	}
+
+	require.Equal(t, []string{"MakeHat", "MakeHat", "MakeHat"}, methods)
+}
+
+func TestTimingCallback(t *testing.T) {
+	svr := httptest.NewTLSServer(NewHaberdasherTwirpServer(&testHaberdasher{}))
+	defer svr.Close()
+
+	transport := svr.Client().Transport.(*http.Transport)
+	defer transport.CloseIdleConnections()
+
+	var timings []TwirpTimings
+	c, err := NewHaberdasherTwirpClient(svr.URL, transport, WithTwirpClientTimingCallback(func(method string, timing TwirpTimings) {
+		require.Equal(t, "MakeHat", method)
+		timings = append(timings, timing)
+	}))
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		_, err = c.MakeHat(context.Background(), &Size{Inches: 14})
+		require.NoError(t, err)
+	}
+
+	require.Len(t, timings, 2)
+
+	first := timings[0]
+	require.False(t, first.Reused)
+	require.Greater(t, int64(first.Connect), int64(0))
+	require.Greater(t, int64(first.TLS), int64(0))
+	require.Greater(t, int64(first.FirstByte), int64(first.TLS))
+	require.GreaterOrEqual(t, int64(first.Total), int64(first.FirstByte))
+
+	second := timings[1]
+	require.True(t, second.Reused)
+	require.Equal(t, time.Duration(0), second.Connect)
+	require.Equal(t, time.Duration(0), second.TLS)
+	require.Greater(t, int64(second.FirstByte), int64(0))
+}
+
+func TestCORS(t *testing.T) {
+	ts := NewHaberdasherTwirpServer(&testHaberdasher{}, WithTwirpServerCORS(TwirpCORSConfig{
+		AllowedOrigins: []string{"https://example.com"},
+		AllowedHeaders: []string{"Authorization"},
+		MaxAge:         time.Hour,
+	}))
+	svr := httptest.NewServer(ts)
+	defer svr.Close()
+
+	url := svr.URL + ts.PathPrefix() + "MakeHat"
+
+	do := func(method string, origin string, body string) *http.Response {
+		req, err := http.NewRequest(method, url, bytes.NewBufferString(body))
+		require.NoError(t, err)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+
+		return resp
+	}
+
+	resp := do(http.MethodOptions, "https://example.com", "")
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	require.Equal(t, "https://example.com", resp.Header.Get("Access-Control-Allow-Origin"))
+	require.Equal(t, "POST, OPTIONS", resp.Header.Get("Access-Control-Allow-Methods"))
+	require.Equal(t, "Content-Type, Authorization", resp.Header.Get("Access-Control-Allow-Headers"))
+	require.Equal(t, "3600", resp.Header.Get("Access-Control-Max-Age"))
+
+	resp = do(http.MethodOptions, "https://evil.example.com", "")
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	require.Empty(t, resp.Header.Get("Access-Control-Allow-Origin"))
+
+	resp = do(http.MethodHead, "https://example.com", "")
+	require.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+
+	resp = do(http.MethodPost, "https://example.com", `{"inches":14}`)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "https://example.com", resp.Header.Get("Access-Control-Allow-Origin"))
+}
+
+func TestFieldMask(t *testing.T) {
+	svr := httptest.NewServer(NewHaberdasherTwirpServer(&testHaberdasher{}, WithTwirpServerFieldMask()))
+	defer svr.Close()
+
+	ctx, err := TwirpWithFieldMask(context.Background(), "size", "name")
+	require.NoError(t, err)
+
+	var body []byte
+	c, err := NewHaberdasherTwirpClient(svr.URL, http.DefaultTransport,
+		WithTwirpClientCodec(DefaultTwirpCodecJson),
+		WithTwirpClientBodyDumper(func(direction string, method string, b []byte) {
+			if direction == "response" {
+				body = b
+			}
+		}),
+	)
+	require.NoError(t, err)
+
+	hat, err := c.MakeHat(ctx, &Size{Inches: 14})
+	require.NoError(t, err)
+	require.Equal(t, int32(14), hat.Size)
+	require.Empty(t, hat.Color)
+	require.NotEmpty(t, hat.Name)
+	require.NotContains(t, string(body), "color")
+
+	// protobuf responses are not masked
+	c, err = NewHaberdasherTwirpClient(svr.URL, http.DefaultTransport)
+	require.NoError(t, err)
+
+	hat, err = c.MakeHat(ctx, &Size{Inches: 14})
+	require.NoError(t, err)
+	require.NotEmpty(t, hat.Color)
+}
+
+func TestInterningCodec(t *testing.T) {
+	interner := NewTwirpStringInterner(10)
+	codec := NewTwirpInterningCodec(DefaultTwirpCodecJson, interner)
+
+	var hats []*Hat
+	for i := 0; i < 2; i++ {
+		var hat Hat
+		require.NoError(t, codec.UnmarshalFrom(context.Background(), &hat, bytes.NewBufferString(`{"color":"red","name":"bowler"}`)))
+		hats = append(hats, &hat)
+	}
+
+	require.Equal(t, "red", hats[0].Color)
+	require.Equal(t, "red", hats[1].Color)
+	require.Equal(t,
+		(*reflect.StringHeader)(unsafe.Pointer(&hats[0].Color)).Data,
+		(*reflect.StringHeader)(unsafe.Pointer(&hats[1].Color)).Data,
+	)
+}
+
+// randomFastCodecMessage returns a message with random values in some of its fields, including
+// negative numbers, which are encoded in ten bytes, and unknown fields.
+func randomFastCodecMessage(r *rand.Rand) proto.Message {
+	ints := []int32{0, 1, -1, math.MaxInt32, math.MinInt32, r.Int31()}
+	int64s := []int64{0, -1, math.MaxInt64, math.MinInt64, r.Int63()}
+	strs := []string{"", "bowler", "héllo wörld", strings.Repeat("x", 200)}
+
+	timestamp := func() *timestamppb.Timestamp {
+		if r.Intn(3) == 0 {
+			return nil
+		}
+		return &timestamppb.Timestamp{Seconds: int64s[r.Intn(len(int64s))], Nanos: ints[r.Intn(len(ints))]}
+	}
+
+	var m proto.Message
+	switch r.Intn(3) {
+	case 0:
+		m = &Size{Inches: ints[r.Intn(len(ints))], DeliverBy: timestamp()}
+	case 1:
+		hat := &Hat{
+			Size:      ints[r.Intn(len(ints))],
+			Color:     strs[r.Intn(len(strs))],
+			Name:      strs[r.Intn(len(strs))],
+			DeliverBy: timestamp(),
+			Buyer:     strs[r.Intn(len(strs))],
+		}
+		if r.Intn(2) == 0 {
+			hat.LeadTime = &durationpb.Duration{Seconds: int64s[r.Intn(len(int64s))]}
+		}
+		m = hat
+	default:
+		m = &ListHatsRequest{PageSize: ints[r.Intn(len(ints))], PageToken: strs[r.Intn(len(strs))], Order: HatOrder(ints[r.Intn(len(ints))])}
+	}
+
+	if r.Intn(4) == 0 {
+		// field 100 as a varint
+		m.ProtoReflect().SetUnknown([]byte{0xa0, 0x06, byte(r.Intn(128))})
+	}
+
+	return m
+}
+
+// TestFastCodec compares the generated fast codec with proto.Marshal and proto.Unmarshal for random
+// messages, and for random changes to their encoding, which must fail to decode with both or decode
+// to equal messages.
+func TestFastCodec(t *testing.T) {
+	ctx := context.Background()
+	r := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 10000; i++ {
+		m := randomFastCodecMessage(r)
+
+		_, ok := twirpFastMarshal(nil, m)
+		require.True(t, ok, fmt.Sprintf("no fast path for %T", m))
+
+		want, err := proto.Marshal(m)
+		require.NoError(t, err)
+
+		var buff bytes.Buffer
+		require.NoError(t, DefaultTwirpFastCodec.MarshalTo(ctx, m, &buff))
+		// proto.Marshal returns an empty slice for an empty encoding, and buff.Bytes() nil
+		require.True(t, bytes.Equal(want, buff.Bytes()), fmt.Sprintf("encoding %v: %x != %x", m, want, buff.Bytes()))
+
+		data := append([]byte(nil), want...)
+		if len(data) > 0 {
+			switch r.Intn(3) {
+			case 0:
+				data[r.Intn(len(data))] = byte(r.Intn(256))
+			case 1:
+				data = data[:r.Intn(len(data))]
+			}
+		}
+
+		expected := m.ProtoReflect().New().Interface()
+		expectedErr := proto.Unmarshal(data, expected)
+
+		got := m.ProtoReflect().New().Interface()
+		err = DefaultTwirpFastCodec.UnmarshalFrom(ctx, got, bytes.NewReader(data))
+
+		if expectedErr != nil {
+			require.Error(t, err, fmt.Sprintf("decoding %x", data))
+			continue
+		}
+
+		require.NoError(t, err, fmt.Sprintf("decoding %x", data))
+		require.True(t, proto.Equal(expected, got), fmt.Sprintf("decoding %x: %v != %v", data, expected, got))
+	}
+}
+
+func TestFastCodecInvalidUTF8(t *testing.T) {
+	ctx := context.Background()
+
+	var buff bytes.Buffer
+	require.Error(t, DefaultTwirpFastCodec.MarshalTo(ctx, &Hat{Name: "\xff"}, &buff))
+
+	data := protowire.AppendString(protowire.AppendTag(nil, 3, protowire.BytesType), "\xff")
+	require.Error(t, DefaultTwirpFastCodec.UnmarshalFrom(ctx, &Hat{}, bytes.NewReader(data)))
+}
+
+// BenchmarkInterningCodec reports the heap retained by decoded messages with and without interning.
+func BenchmarkInterningCodec(b *testing.B) {
+	var buff bytes.Buffer
+	require.NoError(b, DefaultTwirpCodecProtobuf.MarshalTo(context.Background(), &Hat{Size: 14, Color: "a moderately long color name", Name: "a moderately long hat name"}, &buff))
+	data := buff.Bytes()
+
+	codecs := map[string]TwirpCodec{
+		"plain":    DefaultTwirpCodecProtobuf,
+		"interned": NewTwirpInterningCodec(DefaultTwirpCodecProtobuf, NewTwirpStringInterner(100)),
+	}
+
+	for name, codec := range codecs {
+		codec := codec
+		b.Run(name, func(b *testing.B) {
+			hats := make([]*Hat, b.N)
+
+			var before, after runtime.MemStats
+			runtime.GC()
+			runtime.ReadMemStats(&before)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := range hats {
+				hats[i] = new(Hat)
+				if err := codec.UnmarshalFrom(context.Background(), hats[i], bytes.NewReader(data)); err != nil {
+					b.Fatal(err)
+				}
+			}
+
+			b.StopTimer()
+			runtime.GC()
+			runtime.ReadMemStats(&after)
+			b.ReportMetric(float64(after.HeapAlloc-before.HeapAlloc)/float64(b.N), "retained-B/op")
+			runtime.KeepAlive(hats)
+		})
+	}
+}
+
+// BenchmarkReadBody compares reading a large body of known size, as sent with a Content-Length,
+// with reading a chunked body of unknown size, into a new buffer.
+func BenchmarkReadBody(b *testing.B) {
+	data, err := proto.Marshal(&Hat{Size: 14, Name: strings.Repeat("x", 1<<20)})
+	require.NoError(b, err)
+
+	b.Run("sized", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var buff bytes.Buffer
+			if err := twirpReadBody(&buff, twirpBodyReader(struct{ io.Reader }{bytes.NewReader(data)}, int64(len(data)))); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("chunked", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var buff bytes.Buffer
+			if err := twirpReadBody(&buff, twirpBodyReader(struct{ io.Reader }{bytes.NewReader(data)}, -1)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestServiceDescriptor(t *testing.T) {
+	sd := HaberdasherDescriptor()
+	require.Equal(t, "twitch.twirp.example.Haberdasher", string(sd.FullName()))
+
+	method := sd.Methods().ByName("MakeHat")
+	require.NotNil(t, method)
+	require.Equal(t, (&Size{}).ProtoReflect().Descriptor(), method.Input())
+	require.Equal(t, (&Hat{}).ProtoReflect().Descriptor(), method.Output())
+	require.NotNil(t, method.Input().Fields().ByName("inches"))
+}
+
+func TestClientCall(t *testing.T) {
+	svr := httptest.NewServer(NewHaberdasherTwirpServer(&testHaberdasher{}))
+	defer svr.Close()
+
+	c, err := NewHaberdasherTwirpClient(svr.URL, http.DefaultTransport)
+	require.NoError(t, err)
+
+	var caller TwirpCaller = c
+
+	// requests built from the descriptor, like an admin UI would
+	method := HaberdasherDescriptor().Methods().ByName("MakeHat")
+	req := Size{}
+	require.Equal(t, method.Input(), req.ProtoReflect().Descriptor())
+	req.Inches = 14
+
+	resp, err := caller.Call(context.Background(), string(method.Name()), &req)
+	require.NoError(t, err)
+	require.Equal(t, int32(14), resp.(*Hat).Size)
+
+	resp, err = caller.Call(context.Background(), "MakeHat", &Size{Inches: -1})
+	require.Error(t, err)
+	require.Nil(t, resp)
+
+	_, err = caller.Call(context.Background(), "MakeScarf", &Size{Inches: 14})
+	twerr, ok := err.(twirp.Error)
+	require.True(t, ok)
+	require.Equal(t, twirp.BadRoute, twerr.Code())
+
+	_, err = caller.Call(context.Background(), "MakeHat", &Hat{})
+	twerr, ok = err.(twirp.Error)
+	require.True(t, ok)
+	require.Equal(t, twirp.InvalidArgument, twerr.Code())
+	require.Contains(t, twerr.Msg(), "*example.Hat")
+}
+
+func TestFacade(t *testing.T) {
+	facade := NewHaberdasherTwirpServer(&testHaberdasher{}).Facade()
+	ctx := context.Background()
+
+	out, ct, err := facade.Invoke(ctx, "MakeHat", []byte(`{"inches":14}`), "application/json")
+	require.NoError(t, err)
+	require.Equal(t, "application/json", ct)
+	require.Contains(t, string(out), `"size":14`)
+
+	in, err := proto.Marshal(&Size{Inches: 12})
+	require.NoError(t, err)
+
+	out, ct, err = facade.Invoke(ctx, "MakeHat", in, "application/protobuf")
+	require.NoError(t, err)
+	require.Equal(t, "application/protobuf", ct)
+
+	var hat Hat
+	require.NoError(t, proto.Unmarshal(out, &hat))
+	require.Equal(t, int32(12), hat.Size)
+
+	out, ct, err = facade.Invoke(ctx, "MakeHat", []byte(`{"inches":-1}`), "application/json")
+	require.Equal(t, twirp.InvalidArgument, err.(twirp.Error).Code())
+	require.Equal(t, "application/json", ct)
+	require.Contains(t, string(out), `"code":"invalid_argument"`)
+
+	_, _, err = facade.Invoke(ctx, "MakeScarf", []byte(`{}`), "application/json")
+	require.Equal(t, twirp.BadRoute, err.(twirp.Error).Code())
+}
+
+func TestServerInvoke(t *testing.T) {
+	var intercepted string
+	ts := NewHaberdasherTwirpServer(&testHaberdasher{},
+		twirp.WithServerInterceptors(func(next twirp.Method) twirp.Method {
+			return func(ctx context.Context, req interface{}) (interface{}, error) {
+				intercepted, _ = twirp.MethodName(ctx)
+				return next(ctx, req)
+			}
+		}),
+		WithTwirpServerRequestValidator(func(ctx context.Context, method string, req proto.Message) error {
+			if req.(*Size).Inches > 100 {
+				return errors.New("too big")
+			}
+			return nil
+		}),
+	)
+
+	resp, err := ts.Invoke(context.Background(), "MakeHat", &Size{Inches: 14})
+	require.NoError(t, err)
+	require.Equal(t, int32(14), resp.(*Hat).Size)
+	require.Equal(t, "MakeHat", intercepted)
+
+	resp, err = ts.Invoke(context.Background(), "MakeHat", &Size{Inches: -1})
+	require.Nil(t, resp)
+	twerr, ok := err.(twirp.Error)
+	require.True(t, ok)
+	require.Equal(t, twirp.InvalidArgument, twerr.Code())
+
+	_, err = ts.Invoke(context.Background(), "MakeHat", &Size{Inches: 101})
+	twerr, ok = err.(twirp.Error)
+	require.True(t, ok)
+	require.Equal(t, twirp.InvalidArgument, twerr.Code())
+	require.Equal(t, "too big", twerr.Msg())
+
+	_, err = ts.Invoke(context.Background(), "MakeScarf", &Size{Inches: 14})
+	twerr, ok = err.(twirp.Error)
+	require.True(t, ok)
+	require.Equal(t, twirp.BadRoute, twerr.Code())
+
+	_, err = ts.Invoke(context.Background(), "MakeHat", &Hat{})
+	twerr, ok = err.(twirp.Error)
+	require.True(t, ok)
+	require.Equal(t, twirp.InvalidArgument, twerr.Code())
+	require.Contains(t, twerr.Msg(), "*example.Hat")
+
+	ts = NewHaberdasherTwirpServer(&testHaberdasher{}, WithTwirpServerMethodEnabled(func(method string) bool { return false }))
+	_, err = ts.Invoke(context.Background(), "MakeHat", &Size{Inches: 14})
+	twerr, ok = err.(twirp.Error)
+	require.True(t, ok)
+	require.Equal(t, twirp.Unavailable, twerr.Code())
+}
+
+type observerKey struct{}
+
+type recordingObserver struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (o *recordingObserver) StartRPC(ctx context.Context, method string) context.Context {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.events = append(o.events, "start "+method)
+	return context.WithValue(ctx, observerKey{}, method)
+}
+
+func (o *recordingObserver) EndRPC(ctx context.Context, method string, err twirp.Error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	code := "ok"
+	if err != nil {
+		code = string(err.Code())
+	}
+	started, _ := ctx.Value(observerKey{}).(string)
+	o.events = append(o.events, "end "+started+" "+code)
+}
+
+func TestObserver(t *testing.T) {
+	serverObserver := &recordingObserver{}
+	svr := httptest.NewServer(NewHaberdasherTwirpServer(&testHaberdasher{}, WithTwirpServerObserver(serverObserver)))
+	defer svr.Close()
+
+	clientObserver := &recordingObserver{}
+	c, err := NewHaberdasherTwirpClient(svr.URL, http.DefaultTransport, WithTwirpClientObserver(clientObserver))
+	require.NoError(t, err)
+
+	_, err = c.MakeHat(context.Background(), &Size{Inches: 14})
+	require.NoError(t, err)
+
+	_, err = c.MakeHat(context.Background(), &Size{Inches: -1})
+	require.Error(t, err)
+
+	expected := []string{
+		"start twitch.twirp.example.Haberdasher/MakeHat",
+		"end twitch.twirp.example.Haberdasher/MakeHat ok",
+		"start twitch.twirp.example.Haberdasher/MakeHat",
+		"end twitch.twirp.example.Haberdasher/MakeHat invalid_argument",
+	}
+	require.Equal(t, expected, clientObserver.events)
+	require.Equal(t, expected, serverObserver.events)
+
+	// requests that are not routed are not observed
+	resp, err := http.Post(svr.URL+"/twirp/twitch.twirp.example.Haberdasher/MakeScarf", "application/json", strings.NewReader("{}"))
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	require.Len(t, serverObserver.events, 4)
+}
+
+func TestErrorConstructor(t *testing.T) {
+	twerr := NewHatTooSmallError("I can't make a hat that small!")
+	require.Equal(t, twirp.InvalidArgument, twerr.Code())
+	require.Equal(t, "I can't make a hat that small!", twerr.Msg())
+	require.Equal(t, ErrorKind_HAT_TOO_SMALL.String(), twerr.Meta("kind"))
+}
+
+func TestRequestID(t *testing.T) {
+	downstream := &requestIDHaberdasher{}
+	downstreamSvr := httptest.NewServer(NewHaberdasherTwirpServer(downstream, WithTwirpServerRequestID("X-Trace-Id")))
+	defer downstreamSvr.Close()
+
+	downstreamClient, err := NewHaberdasherTwirpClient(downstreamSvr.URL, http.DefaultTransport)
+	require.NoError(t, err)
+
+	upstream := &requestIDHaberdasher{next: downstreamClient}
+	upstreamSvr := httptest.NewServer(NewHaberdasherTwirpServer(upstream, WithTwirpServerRequestID("X-Trace-Id")))
+	defer upstreamSvr.Close()
+
+	c, err := NewHaberdasherTwirpClient(upstreamSvr.URL, http.DefaultTransport)
+	require.NoError(t, err)
+
+	// generated when missing
+	_, err = c.MakeHat(context.Background(), &Size{Inches: 14})
+	require.NoError(t, err)
+	require.NotEmpty(t, upstream.id)
+	require.Equal(t, upstream.id, downstream.id)
+
+	// incoming header is preferred
+	ctx, err := twirp.WithHTTPRequestHeaders(context.Background(), http.Header{"X-Trace-Id": []string{"abc123"}})
+	require.NoError(t, err)
+
+	_, err = c.MakeHat(ctx, &Size{Inches: 14})
+	require.NoError(t, err)
+	require.Equal(t, "abc123", upstream.id)
+	require.Equal(t, "abc123", downstream.id)
+}
+
+type requestIDHaberdasher struct {
+	next *HaberdasherTwirpClient
+	id   string
+}
+
+func (h *requestIDHaberdasher) MakeHat(ctx context.Context, size *Size) (*Hat, error) {
+	h.id, _ = TwirpRequestID(ctx)
+	if h.next != nil {
+		return h.next.MakeHat(ctx, size)
+	}
+	return &Hat{Size: size.Inches}, nil
+}
+
+func TestMissingContentType(t *testing.T) {
+	body, err := proto.Marshal(&Size{Inches: 14})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		opts []interface{}
+		code int
+	}{
+		{"default", nil, http.StatusNotFound},
+		{"require", []interface{}{WithTwirpServerRequireContentType()}, http.StatusBadRequest},
+		{"lenient", []interface{}{WithTwirpServerDefaultContentType("application/protobuf")}, http.StatusOK},
+		{"require overrides lenient", []interface{}{WithTwirpServerRequireContentType(), WithTwirpServerDefaultContentType("application/protobuf")}, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := NewHaberdasherTwirpServer(&testHaberdasher{}, tt.opts...)
+
+			req := httptest.NewRequest(http.MethodPost, ts.PathPrefix()+"MakeHat", bytes.NewReader(body))
+			rec := httptest.NewRecorder()
+			ts.ServeHTTP(rec, req)
+
+			require.Equal(t, tt.code, rec.Code)
+			if tt.code == http.StatusOK {
+				var hat Hat
+				require.NoError(t, proto.Unmarshal(rec.Body.Bytes(), &hat))
+				require.Equal(t, int32(14), hat.Size)
+			}
+		})
+	}
+}
+
+func TestMaxHeaderBytes(t *testing.T) {
+	body, err := proto.Marshal(&Size{Inches: 14})
+	require.NoError(t, err)
+
+	for _, tt := range []struct {
+		name  string
+		limit int
+		extra int
+		code  int
+	}{
+		{"unlimited", 0, 1 << 20, http.StatusOK},
+		{"under", 1024, 512, http.StatusOK},
+		{"over", 1024, 1024, http.StatusBadRequest},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := NewHaberdasherTwirpServer(&testHaberdasher{}, WithTwirpServerMaxHeaderBytes(tt.limit))
+
+			req := httptest.NewRequest(http.MethodPost, ts.PathPrefix()+"MakeHat", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/protobuf")
+			req.Header.Set("X-Padding", strings.Repeat("x", tt.extra))
+
+			rec := httptest.NewRecorder()
+			ts.ServeHTTP(rec, req)
+			require.Equal(t, tt.code, rec.Code)
+			if tt.code != http.StatusOK {
+				require.Contains(t, rec.Body.String(), string(twirp.Malformed))
+			}
+		})
+	}
+}
+
+func TestMaxResponseBytes(t *testing.T) {
+	var hookErr twirp.Error
+	ts := NewHaberdasherTwirpServer(&namedHaberdasher{}, WithTwirpServerMaxResponseBytes(1024), twirp.WithServerHooks(&twirp.ServerHooks{
+		Error: func(ctx context.Context, err twirp.Error) context.Context {
+			hookErr = err
+			return ctx
+		},
+	}))
+
+	c, err := NewHaberdasherTwirpClient("http://twirp.test", NewTwirpInMemoryTransport(ts))
+	require.NoError(t, err)
+
+	hat, err := c.MakeHat(context.Background(), &Size{Inches: 512})
+	require.NoError(t, err)
+	require.Len(t, hat.Name, 512)
+	require.Nil(t, hookErr)
+
+	_, err = c.MakeHat(context.Background(), &Size{Inches: 1 << 20})
+	require.Error(t, err)
+	require.Equal(t, twirp.Internal, err.(twirp.Error).Code())
+	require.Equal(t, "response is too large", err.(twirp.Error).Msg())
+
+	// the error reaches the hooks, with the size of the response
+	require.NotNil(t, hookErr)
+	require.Equal(t, strconv.Itoa(proto.Size(&Hat{Size: 1 << 20, Name: strings.Repeat("x", 1<<20)})), hookErr.Meta("response_bytes"))
+}
+
+func TestRedact(t *testing.T) {
+	hat := &Hat{Size: 14, Name: "bowler", Buyer: "Jane Doe"}
+
+	redacted := hat.TwirpRedact().(*Hat)
+	require.True(t, proto.Equal(&Hat{Size: 14, Name: "bowler"}, redacted))
+	require.Equal(t, "Jane Doe", hat.Buyer)
+
+	page := &ListHatsResponse{Hats: []*Hat{hat}, NextPageToken: "next"}
+	redactedPage := TwirpRedact(page).(*ListHatsResponse)
+	require.Equal(t, "", redactedPage.Hats[0].Buyer)
+	require.Equal(t, "next", redactedPage.NextPageToken)
+	require.Equal(t, "Jane Doe", page.Hats[0].Buyer)
+
+	// messages without pii fields are copied
+	size := &Size{Inches: 14}
+	copied := TwirpRedact(size)
+	require.True(t, proto.Equal(size, copied))
+	require.True(t, size != copied.(*Size))
+
+	require.Nil(t, (*Hat)(nil).TwirpRedact().(*Hat))
+}
+
+func TestRouteTemplate(t *testing.T) {
+	const tmpl = "/api/{service}/{method}"
+
+	ts := NewHaberdasherTwirpServer(&testHaberdasher{}, WithTwirpServerRouteTemplate(tmpl))
+	require.Equal(t, "/api/Haberdasher/", ts.PathPrefix())
+
+	svr := httptest.NewServer(ts)
+	defer svr.Close()
+
+	resp, err := http.Post(svr.URL+"/twirp/twitch.twirp.example.Haberdasher/MakeHat", "application/json", strings.NewReader(`{"inches":14}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	c, err := NewHaberdasherTwirpClient(svr.URL, http.DefaultTransport, WithTwirpClientRouteTemplate(tmpl))
+	require.NoError(t, err)
+
+	hat, err := c.MakeHat(context.Background(), &Size{Inches: 14})
+	require.NoError(t, err)
+	require.Equal(t, int32(14), hat.Size)
+
+	for _, invalid := range []string{
+		"api/{service}/{method}",
+		"/api/{service}",
+		"/api/{method}/{service}/{method}",
+		"/api/{version}/{method}",
+		"/api/{name}/{method}",
+		"/api/{service/{method}",
+	} {
+		_, err := NewHaberdasherTwirpClient(svr.URL, http.DefaultTransport, WithTwirpClientRouteTemplate(invalid))
+		require.Error(t, err, invalid)
+		require.Panics(t, func() {
+			NewHaberdasherTwirpServer(&testHaberdasher{}, WithTwirpServerRouteTemplate(invalid))
+		}, invalid)
+	}
+}
+
+func TestAuditSink(t *testing.T) {
+	var entries []TwirpAuditEntry
+	sink := WithTwirpServerAuditSink(func(ctx context.Context, entry TwirpAuditEntry) {
+		entries = append(entries, entry)
+	})
+
+	ts := NewHaberdasherTwirpServer(&testHaberdasher{}, sink)
+
+	post := func(body string) {
+		req := httptest.NewRequest(http.MethodPost, ts.PathPrefix()+"MakeHat", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		ts.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	start := time.Now()
+	post(`{"inches":14}`)
+	post(`{"inches":-1}`)
+	post(`{"inches":`)
+
+	require.Len(t, entries, 3)
+
+	entry := entries[0]
+	require.Equal(t, "twitch.twirp.example.Haberdasher", entry.Service)
+	require.Equal(t, "MakeHat", entry.Method)
+	require.False(t, entry.Time.Before(start))
+	require.Equal(t, `{"inches":14}`, string(entry.Request))
+	require.Contains(t, string(entry.Response), `"size":14`)
+	require.True(t, proto.Equal(&Size{Inches: 14}, entry.RequestMessage))
+	require.Equal(t, int32(14), entry.ResponseMessage.(*Hat).Size)
+	require.Nil(t, entry.Error)
+
+	entry = entries[1]
+	require.Equal(t, `{"inches":-1}`, string(entry.Request))
+	require.Nil(t, entry.Response)
+	require.Nil(t, entry.ResponseMessage)
+	require.Equal(t, twirp.InvalidArgument, entry.Error.Code())
+
+	entry = entries[2]
+	require.Equal(t, `{"inches":`, string(entry.Request))
+	require.Nil(t, entry.RequestMessage)
+	require.Equal(t, twirp.Malformed, entry.Error.Code())
+
+	// Invoke calls are audited without bodies
+	entries = nil
+	out, err := ts.Invoke(context.Background(), "MakeHat", &Size{Inches: 12})
+	require.NoError(t, err)
+	_, err = ts.Invoke(context.Background(), "MakeHat", &Size{Inches: -1})
+	require.Error(t, err)
+
+	require.Len(t, entries, 2)
+
+	entry = entries[0]
+	require.Equal(t, "twitch.twirp.example.Haberdasher", entry.Service)
+	require.Equal(t, "MakeHat", entry.Method)
+	require.Nil(t, entry.Request)
+	require.Nil(t, entry.Response)
+	require.True(t, proto.Equal(&Size{Inches: 12}, entry.RequestMessage))
+	require.True(t, proto.Equal(out, entry.ResponseMessage))
+	require.Nil(t, entry.Error)
+
+	entry = entries[1]
+	require.Nil(t, entry.ResponseMessage)
+	require.Equal(t, twirp.InvalidArgument, entry.Error.Code())
+
+	// methods without the auditable option are not recorded
+	entries = nil
+	rack := NewHatRackTwirpServer(&pagedHatRack{pages: [][]int32{{1}}}, sink)
+
+	req := httptest.NewRequest(http.MethodPost, rack.PathPrefix()+"ListHats", strings.NewReader(`{}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	rack.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Empty(t, entries)
+}
+
+func TestAfterResponse(t *testing.T) {
+	type call struct {
+		method  string
+		err     error
+		flushed bool
+		body    string
+	}
+
+	var calls []call
+	var rec *httptest.ResponseRecorder
+
+	ts := NewHaberdasherTwirpServer(&testHaberdasher{}, WithTwirpServerAfterResponse(func(ctx context.Context, method string, err error) {
+		calls = append(calls, call{method: method, err: err, flushed: rec.Flushed, body: rec.Body.String()})
+	}))
+
+	post := func(path string, body string) {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec = httptest.NewRecorder()
+		ts.ServeHTTP(rec, req)
+	}
+
+	post(ts.PathPrefix()+"MakeHat", `{"inches":14}`)
+	post(ts.PathPrefix()+"MakeHat", `{"inches":-1}`)
+	post(ts.PathPrefix()+"Unknown", `{}`)
+
+	require.Len(t, calls, 3)
+
+	require.Equal(t, "MakeHat", calls[0].method)
+	require.NoError(t, calls[0].err)
+	require.True(t, calls[0].flushed)
+	require.Contains(t, calls[0].body, `"size":14`)
+
+	require.Equal(t, "MakeHat", calls[1].method)
+	require.Equal(t, twirp.InvalidArgument, calls[1].err.(twirp.Error).Code())
+	require.True(t, calls[1].flushed)
+	require.Contains(t, calls[1].body, `"invalid_argument"`)
+
+	require.Equal(t, "", calls[2].method)
+	require.Equal(t, twirp.BadRoute, calls[2].err.(twirp.Error).Code())
+}
+
+func TestRequestHeaderAllowlist(t *testing.T) {
+	h := &headerHaberdasher{}
+	ts := NewHaberdasherTwirpServer(h, WithTwirpServerRequestHeaderAllowlist(map[string]func(string) (string, error){
+		"x-tenant": func(value string) (string, error) {
+			value = strings.ToLower(strings.TrimSpace(value))
+			if value == "" {
+				return "", errors.New("must not be empty")
+			}
+			return value, nil
+		},
+		"X-Region": nil,
+	}))
+
+	post := func(header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, ts.PathPrefix()+"MakeHat", strings.NewReader(`{"inches":14}`))
+		req.Header = header
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		ts.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := post(http.Header{
+		"X-Tenant": []string{" ACME ", "other"},
+		"X-Region": []string{"us-east"},
+		"X-Other":  []string{"ignored"},
+	})
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, map[string]string{"X-Tenant": "acme", "x-region": "us-east"}, h.headers)
+
+	// headers that are not sent are not set
+	rec = post(http.Header{})
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Empty(t, h.headers)
+
+	rec = post(http.Header{"X-Tenant": []string{" "}})
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.Contains(t, rec.Body.String(), "X-Tenant must not be empty")
+}
+
+// headerHaberdasher records the allowed headers of each request.
+type headerHaberdasher struct {
+	headers map[string]string
+}
+
+func (h *headerHaberdasher) MakeHat(ctx context.Context, size *Size) (*Hat, error) {
+	h.headers = map[string]string{}
+	for _, name := range []string{"X-Tenant", "x-region", "X-Other", "Content-Type"} {
+		if value, ok := TwirpRequestHeader(ctx, name); ok {
+			h.headers[name] = value
+		}
+	}
+	return &Hat{Size: size.Inches}, nil
+}
+
+func TestPlayground(t *testing.T) {
+	ts := NewHaberdasherTwirpServer(&testHaberdasher{}, WithTwirpServerPlayground())
+	svr := httptest.NewServer(ts)
+	defer svr.Close()
+
+	resp, err := http.Get(svr.URL + ts.PathPrefix() + "_playground")
+	require.NoError(t, err)
+	page, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "text/html; charset=utf-8", resp.Header.Get("Content-Type"))
+	require.Contains(t, string(page), "<h2>MakeHat</h2>")
+	require.Contains(t, string(page), "MakeHat produces a hat of mysterious, randomly-selected color!")
+
+	// the example request is valid, and sent as the page does
+	match := regexp.MustCompile(`(?s)<textarea id="MakeHat-request">(.*?)</textarea>`).FindSubmatch(page)
+	require.NotNil(t, match)
+	example := html.UnescapeString(string(match[1]))
+	require.Contains(t, example, `"inches": 0`)
+
+	resp, err = http.Post(svr.URL+ts.PathPrefix()+"MakeHat", "application/json", strings.NewReader(example))
+	require.NoError(t, err)
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	// the example asks for a hat of size 0, which testHaberdasher rejects once it is decoded
+	require.Contains(t, string(body), string(twirp.InvalidArgument))
+
+	// the playground is off by default
+	rec := httptest.NewRecorder()
+	NewHaberdasherTwirpServer(&testHaberdasher{}).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, ts.PathPrefix()+"_playground", nil))
+	require.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestTenantExtractor(t *testing.T) {
+	h := &tenantHaberdasher{}
+	ts := NewHaberdasherTwirpServer(h, WithTwirpServerTenantExtractor(TwirpTenantConfig{
+		Header:      "X-Tenant",
+		PathSegment: 1,
+		Required:    true,
+	}))
+
+	post := func(path string, tenant string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"inches":14}`))
+		req.Header.Set("Content-Type", "application/json")
+		if tenant != "" {
+			req.Header.Set("X-Tenant", tenant)
+		}
+
+		rec := httptest.NewRecorder()
+		ts.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := post("/acme"+ts.PathPrefix()+"MakeHat", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.Equal(t, "acme", h.tenant)
+
+	rec = post("/acme"+ts.PathPrefix()+"MakeHat", "acme")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.Equal(t, "acme", h.tenant)
+
+	rec = post("/acme"+ts.PathPrefix()+"MakeHat", "globex")
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.Contains(t, rec.Body.String(), string(twirp.InvalidArgument))
+
+	// the first segment is always the tenant's
+	rec = post(ts.PathPrefix()+"MakeHat", "")
+	require.Equal(t, http.StatusNotFound, rec.Code)
+
+	h.tenant = ""
+	header := NewHaberdasherTwirpServer(h, WithTwirpServerTenantExtractor(TwirpTenantConfig{Header: "X-Tenant", Required: true}))
+
+	req := httptest.NewRequest(http.MethodPost, header.PathPrefix()+"MakeHat", strings.NewReader(`{"inches":14}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec = httptest.NewRecorder()
+	header.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.Contains(t, rec.Body.String(), "tenant is required")
+	require.Equal(t, "", h.tenant)
+
+	optional := NewHaberdasherTwirpServer(h, WithTwirpServerTenantExtractor(TwirpTenantConfig{Header: "X-Tenant"}))
+	rec = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodPost, optional.PathPrefix()+"MakeHat", strings.NewReader(`{"inches":14}`))
+	req.Header.Set("Content-Type", "application/json")
+	optional.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.Equal(t, "<none>", h.tenant)
+}
+
+// tenantHaberdasher records the tenant of the last request.
+type tenantHaberdasher struct {
+	tenant string
+}
+
+func (h *tenantHaberdasher) MakeHat(ctx context.Context, size *Size) (*Hat, error) {
+	tenant, ok := TwirpTenant(ctx)
+	if !ok {
+		tenant = "<none>"
+	}
+	h.tenant = tenant
+	return &Hat{Size: size.Inches}, nil
+}
+
+func TestResponseCompression(t *testing.T) {
+	ts := NewHaberdasherTwirpServer(&namedHaberdasher{}, WithTwirpServerGzip())
+
+	for _, tt := range []struct {
+		name       string
+		inches     int32
+		encoding   string
+		compressed bool
+	}{
+		{"small", 14, "gzip", false},
+		{"large", 4096, "gzip", true},
+		{"not accepted", 4096, "", false},
+		{"refused", 4096, "gzip;q=0, identity", false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := proto.Marshal(&Size{Inches: tt.inches})
+			require.NoError(t, err)
+
+			req := httptest.NewRequest(http.MethodPost, ts.PathPrefix()+"MakeHat", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/protobuf")
+			req.Header.Set("Accept-Encoding", tt.encoding)
+
+			rec := httptest.NewRecorder()
+			ts.ServeHTTP(rec, req)
+			require.Equal(t, http.StatusOK, rec.Code)
+			require.Equal(t, "Accept-Encoding", rec.Header().Get("Vary"))
+
+			data := rec.Body.Bytes()
+			if tt.compressed {
+				require.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+
+				zr, err := gzip.NewReader(rec.Body)
+				require.NoError(t, err)
+				data, err = ioutil.ReadAll(zr)
+				require.NoError(t, err)
+			} else {
+				require.Empty(t, rec.Header().Get("Content-Encoding"))
+			}
+
+			var hat Hat
+			require.NoError(t, proto.Unmarshal(data, &hat))
+			require.Len(t, hat.Name, int(tt.inches))
+		})
+	}
+
+	// the default transport asks for gzip and decompresses responses itself
+	svr := httptest.NewServer(NewHaberdasherTwirpServer(&namedHaberdasher{}, WithTwirpServerGzip(), WithTwirpServerResponseCompressionThreshold(0)))
+	defer svr.Close()
+
+	c, err := NewHaberdasherTwirpClient(svr.URL, http.DefaultTransport)
+	require.NoError(t, err)
+
+	hat, err := c.MakeHat(context.Background(), &Size{Inches: 14})
+	require.NoError(t, err)
+	require.Len(t, hat.Name, 14)
+}
+
+func TestClientAcceptEncoding(t *testing.T) {
+	var acceptEncoding string
+	gzipServer := NewHaberdasherTwirpServer(&namedHaberdasher{}, WithTwirpServerGzip(), WithTwirpServerResponseCompressionThreshold(0))
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		acceptEncoding = r.Header.Get("Accept-Encoding")
+
+		switch r.Header.Get("Test-Encoding") {
+		case "":
+			gzipServer.ServeHTTP(w, r)
+		case "gzip-error":
+			w.Header().Set("Content-Encoding", "gzip")
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			require.NoError(t, twirpGzip(w, []byte(`{"code":"not_found","msg":"no such hat"}`)))
+		default:
+			data, err := proto.Marshal(&Hat{Name: "plain"})
+			require.NoError(t, err)
+			w.Header().Set("Content-Encoding", r.Header.Get("Test-Encoding"))
+			w.Header().Set("Content-Type", "application/protobuf")
+			_, _ = w.Write(data)
+		}
+	}))
+	defer svr.Close()
+
+	// a transport that does not decompress responses itself
+	transport := &http.Transport{DisableCompression: true}
+	defer transport.CloseIdleConnections()
+
+	c, err := NewHaberdasherTwirpClient(svr.URL, transport, WithTwirpClientAcceptEncoding())
+	require.NoError(t, err)
+
+	hat, err := c.MakeHat(context.Background(), &Size{Inches: 14})
+	require.NoError(t, err)
+	require.Equal(t, "gzip", acceptEncoding)
+	require.Equal(t, strings.Repeat("x", 14), hat.Name)
+
+	callWithEncoding := func(encoding string) (*Hat, error) {
+		ctx, err := twirp.WithHTTPRequestHeaders(context.Background(), http.Header{"Test-Encoding": []string{encoding}})
+		require.NoError(t, err)
+		return c.MakeHat(ctx, &Size{Inches: 14})
+	}
+
+	hat, err = callWithEncoding("identity")
+	require.NoError(t, err)
+	require.Equal(t, "plain", hat.Name)
+
+	_, err = callWithEncoding("br")
+	var twerr twirp.Error
+	require.True(t, errors.As(err, &twerr))
+	require.Equal(t, twirp.Internal, twerr.Code())
+	require.Equal(t, `unsupported response Content-Encoding "br"`, twerr.Msg())
+
+	// error responses are decoded too
+	_, err = callWithEncoding("gzip-error")
+	require.True(t, errors.As(err, &twerr))
+	require.Equal(t, twirp.NotFound, twerr.Code())
+	require.Equal(t, "no such hat", twerr.Msg())
+
+	// without the option, nothing is asked for and responses are not compressed
+	c, err = NewHaberdasherTwirpClient(svr.URL, transport)
+	require.NoError(t, err)
+
+	hat, err = c.MakeHat(context.Background(), &Size{Inches: 14})
+	require.NoError(t, err)
+	require.Empty(t, acceptEncoding)
+	require.Equal(t, strings.Repeat("x", 14), hat.Name)
+
+	_, err = NewHaberdasherTwirpClient(svr.URL, transport, WithTwirpClientAcceptEncoding("br"))
+	require.Error(t, err)
+}
+
+func TestClientWithStatus(t *testing.T) {
+	svr := httptest.NewServer(NewHaberdasherTwirpServer(&testHaberdasher{}))
+	defer svr.Close()
+
+	c, err := NewHaberdasherTwirpClient(svr.URL, http.DefaultTransport)
+	require.NoError(t, err)
+
+	hat, status, err := c.MakeHatWithStatus(context.Background(), &Size{Inches: 14})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, int32(14), hat.Size)
+
+	_, status, err = c.MakeHatWithStatus(context.Background(), &Size{Inches: -1})
+	require.Error(t, err)
+	require.Equal(t, http.StatusBadRequest, status)
+
+	svr.Close()
+
+	_, status, err = c.MakeHatWithStatus(context.Background(), &Size{Inches: 14})
+	require.Error(t, err)
+	require.Equal(t, 0, status)
+}
+
+func TestConnectCompat(t *testing.T) {
+	svr := httptest.NewServer(NewHaberdasherTwirpServer(&testHaberdasher{}))
+	defer svr.Close()
+
+	post := func(t *testing.T, path string, contentType string, body []byte) (*http.Response, []byte) {
+		req, err := http.NewRequest(http.MethodPost, svr.URL+path, bytes.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("Connect-Protocol-Version", "1")
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		data, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp, data
+	}
+
+	t.Run("proto", func(t *testing.T) {
+		body, err := proto.Marshal(&Size{Inches: 14})
+		require.NoError(t, err)
+
+		resp, data := post(t, "/twitch.twirp.example.Haberdasher/MakeHat", "application/proto", body)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, "application/proto", resp.Header.Get("Content-Type"))
+
+		var hat Hat
+		require.NoError(t, proto.Unmarshal(data, &hat))
+		require.Equal(t, int32(14), hat.Size)
+	})
+
+	t.Run("json", func(t *testing.T) {
+		resp, data := post(t, "/twitch.twirp.example.Haberdasher/MakeHat", "application/json", []byte(`{"inches": 14}`))
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+		require.Contains(t, string(data), `"size":14`)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		resp, data := post(t, "/twitch.twirp.example.Haberdasher/MakeHat", "application/json", []byte(`{"inches": -1}`))
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		require.JSONEq(t, `{"code": "invalid_argument", "message": "Inches I can't make a hat that small!"}`, string(data))
+	})
+
+	t.Run("unknown method", func(t *testing.T) {
+		resp, data := post(t, "/twitch.twirp.example.Haberdasher/MakeScarf", "application/json", []byte(`{}`))
+		require.Equal(t, http.StatusNotImplemented, resp.StatusCode)
+		require.Contains(t, string(data), `"code":"unimplemented"`)
+	})
+
+	// Twirp requests are unchanged
+	c, err := NewHaberdasherTwirpClient(svr.URL, http.DefaultTransport)
+	require.NoError(t, err)
+	doTests(t, c)
+}
+
+func TestUnimplemented(t *testing.T) {
+	ts := NewHaberdasherTwirpServer(&struct{ UnimplementedHaberdasherTwirpService }{})
+	svr := httptest.NewServer(ts)
+	defer svr.Close()
+
+	c := NewHaberdasherProtobufClient(svr.URL, http.DefaultClient)
+
+	_, err := c.MakeHat(context.Background(), &Size{Inches: 14})
+	require.Error(t, err)
+	twerr, ok := err.(twirp.Error)
+	require.True(t, ok)
+	require.Equal(t, twirp.Unimplemented, twerr.Code())
+}
+
 func TestServerPanic(t *testing.T) {
 	ts := NewHaberdasherTwirpServer(&panicHaberdasher{})
 	svr := httptest.NewServer(ts)
 	defer svr.Close()
 
-	c := NewHaberdasherProtobufClient(svr.URL, http.DefaultClient)
+	c := NewHaberdasherProtobufClient(svr.URL, http.DefaultClient)
+
+	_, err := c.MakeHat(context.Background(), &Size{Inches: -1})
+	require.Error(t, err)
+	twerr, ok := err.(twirp.Error)
+	require.True(t, ok)
+	require.Equal(t, twirp.Internal, twerr.Code())
+	require.Equal(t, "internal service panic", twerr.Msg())
+	require.Equal(t, "very bad things happened", twerr.Meta("cause"))
+}
+
+func TestRawBodyValidator(t *testing.T) {
+	key := []byte("secret")
+	sign := func(body string) string {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(body))
+		return hex.EncodeToString(mac.Sum(nil))
+	}
+
+	var methods []string
+	validator := WithTwirpServerRawBodyValidator(func(ctx context.Context, method string, raw []byte) error {
+		methods = append(methods, method)
+
+		header, _ := TwirpRequestHeader(ctx, "X-Signature")
+		signature, err := hex.DecodeString(header)
+		if err != nil {
+			return err
+		}
+
+		mac := hmac.New(sha256.New, key)
+		mac.Write(raw)
+		if !hmac.Equal(signature, mac.Sum(nil)) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	})
+
+	ts := NewHaberdasherTwirpServer(&testHaberdasher{}, validator, WithTwirpServerRequestHeaderAllowlist(map[string]func(string) (string, error){"X-Signature": nil}))
+	svr := httptest.NewServer(ts)
+	defer svr.Close()
+
+	post := func(body string, signature string) *http.Response {
+		req, err := http.NewRequest(http.MethodPost, svr.URL+ts.PathPrefix()+"MakeHat", strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Signature", signature)
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		return resp
+	}
+
+	// the signature is over the bytes as sent, including whitespace
+	body := `{ "inches": 14 }`
+	resp := post(body, sign(body))
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, []string{"MakeHat"}, methods)
+
+	resp = post(body, sign(`{"inches":14}`))
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	var twerr struct {
+		Code string `json:"code"`
+		Msg  string `json:"msg"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&twerr))
+	require.Equal(t, "unauthenticated", twerr.Code)
+	require.Equal(t, "invalid signature", twerr.Msg)
+}
+
+func TestSchemaMismatchHandler(t *testing.T) {
+	var mismatches []string
+	warn := WithTwirpServerSchemaMismatchHandler(func(ctx context.Context, client string, server string) error {
+		require.Equal(t, HaberdasherTwirpSchemaFingerprint, server)
+		mismatches = append(mismatches, client)
+		return nil
+	})
+
+	ts := NewHaberdasherTwirpServer(&testHaberdasher{}, warn)
+	svr := httptest.NewServer(ts)
+	defer svr.Close()
+
+	// generated clients send the fingerprint of the schema they were generated from
+	c, err := NewHaberdasherTwirpClient(svr.URL, http.DefaultTransport)
+	require.NoError(t, err)
+	_, err = c.MakeHat(context.Background(), &Size{Inches: 14})
+	require.NoError(t, err)
+	require.Empty(t, mismatches)
+
+	post := func(svr *httptest.Server, fingerprint string) *http.Response {
+		req, err := http.NewRequest(http.MethodPost, svr.URL+ts.PathPrefix()+"MakeHat", bytes.NewBufferString(`{"inches":14}`))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		if fingerprint != "" {
+			req.Header.Set(TwirpSchemaFingerprintHeader, fingerprint)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp
+	}
+
+	resp := post(svr, "old")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, []string{"old"}, mismatches)
+
+	resp = post(svr, "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Len(t, mismatches, 1)
+
+	strict := WithTwirpServerSchemaMismatchHandler(func(ctx context.Context, client string, server string) error {
+		return errors.New("client schema " + client + " does not match " + server)
+	})
+	strictSvr := httptest.NewServer(NewHaberdasherTwirpServer(&testHaberdasher{}, strict))
+	defer strictSvr.Close()
+
+	resp = post(strictSvr, "old")
+	require.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+}
+
+func TestContentLength(t *testing.T) {
+	ts := NewHaberdasherTwirpServer(&namedHaberdasher{}, WithTwirpServerGzip())
+	svr := httptest.NewServer(ts)
+	defer svr.Close()
+
+	for _, tt := range []struct {
+		name        string
+		contentType string
+		gzip        bool
+		inches      int32
+		status      int
+	}{
+		// larger than the buffer net/http uses to set Content-Length by itself
+		{"protobuf", "application/protobuf", false, 10000, http.StatusOK},
+		{"json", "application/json", false, 10000, http.StatusOK},
+		{"gzip", "application/protobuf", true, 10000, http.StatusOK},
+		{"error", "application/json", false, 0, http.StatusBadRequest},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var body []byte
+			switch {
+			case tt.status != http.StatusOK:
+				body = []byte("{")
+			case tt.contentType == "application/json":
+				body = []byte(fmt.Sprintf(`{"inches":%d}`, tt.inches))
+			default:
+				var err error
+				body, err = proto.Marshal(&Size{Inches: tt.inches})
+				require.NoError(t, err)
+			}
+
+			req, err := http.NewRequest(http.MethodPost, svr.URL+ts.PathPrefix()+"MakeHat", bytes.NewReader(body))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", tt.contentType)
+			// set, so that the transport does not ask for gzip and decompress the response itself
+			req.Header.Set("Accept-Encoding", "identity")
+			if tt.gzip {
+				req.Header.Set("Accept-Encoding", "gzip")
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, tt.status, resp.StatusCode)
+			require.Empty(t, resp.TransferEncoding)
+
+			respBody, err := ioutil.ReadAll(resp.Body)
+			require.NoError(t, err)
+			require.Equal(t, strconv.Itoa(len(respBody)), resp.Header.Get("Content-Length"))
+		})
+	}
+}
+
+func TestDrain(t *testing.T) {
+	h := &gatedHaberdasher{
+		started: make(chan struct{}, 1),
+		release: make(chan struct{}),
+	}
+	ts := NewHaberdasherTwirpServer(h)
+	svr := httptest.NewServer(ts)
+	defer svr.Close()
+
+	c, err := NewHaberdasherTwirpClient(svr.URL, http.DefaultTransport)
+	require.NoError(t, err)
+
+	inFlight := make(chan error)
+	go func() {
+		_, err := c.MakeHat(context.Background(), &Size{Inches: 14})
+		inFlight <- err
+	}()
+	<-h.started
+
+	// the in-flight request keeps the server from draining
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	require.Equal(t, context.DeadlineExceeded, ts.Drain(ctx))
+
+	_, err = c.MakeHat(context.Background(), &Size{Inches: 14})
+	twerr, ok := err.(twirp.Error)
+	require.True(t, ok)
+	require.Equal(t, twirp.Unavailable, twerr.Code())
+
+	_, err = ts.Invoke(context.Background(), "MakeHat", &Size{Inches: 14})
+	require.Error(t, err)
+
+	drained := make(chan error)
+	go func() {
+		drained <- ts.Drain(context.Background())
+	}()
+
+	close(h.release)
+	require.NoError(t, <-inFlight)
+	require.NoError(t, <-drained)
+}
+
+func TestUnknownMethodHandler(t *testing.T) {
+	var methods []string
+	unknown := WithTwirpServerUnknownMethodHandler(func(w http.ResponseWriter, r *http.Request, method string) {
+		methods = append(methods, method)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusGone)
+		_, _ = w.Write([]byte(`{"code":"unimplemented","msg":"` + method + ` was removed, use MakeHat"}`))
+	})
+
+	ts := NewHaberdasherTwirpServer(&testHaberdasher{}, unknown)
+	svr := httptest.NewServer(ts)
+	defer svr.Close()
+
+	post := func(path string) *http.Response {
+		resp, err := http.Post(svr.URL+path, "application/json", bytes.NewBufferString(`{"inches":14}`))
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp
+	}
+
+	require.Equal(t, http.StatusGone, post(ts.PathPrefix()+"MakeCap").StatusCode)
+	require.Equal(t, []string{"MakeCap"}, methods)
+
+	// known methods and paths outside the service are unchanged
+	require.Equal(t, http.StatusOK, post(ts.PathPrefix()+"MakeHat").StatusCode)
+	require.Equal(t, http.StatusNotFound, post("/twirp/other.Service/MakeCap").StatusCode)
+	require.Equal(t, http.StatusNotFound, post(ts.PathPrefix()+"MakeCap/extra").StatusCode)
+	require.Len(t, methods, 1)
+}
+
+func TestClientBudget(t *testing.T) {
+	budget := WithTwirpServerClientBudget(TwirpClientKeyHeader("X-Api-Key"), NewTwirpClientBudgets(0.001, 2, 10))
+	svr := httptest.NewServer(NewHaberdasherTwirpServer(&testHaberdasher{}, budget))
+	defer svr.Close()
+
+	c, err := NewHaberdasherTwirpClient(svr.URL, http.DefaultTransport)
+	require.NoError(t, err)
+
+	call := func(key string) error {
+		ctx := context.Background()
+		if key != "" {
+			header := http.Header{}
+			header.Set("X-Api-Key", key)
+			ctx, err = twirp.WithHTTPRequestHeaders(ctx, header)
+			require.NoError(t, err)
+		}
+		_, err := c.MakeHat(ctx, &Size{Inches: 14})
+		return err
+	}
+
+	// each key has a burst of 2, and replenishes too slowly for the test to notice
+	require.NoError(t, call("a"))
+	require.NoError(t, call("a"))
+	err = call("a")
+	require.Equal(t, twirp.ResourceExhausted, err.(twirp.Error).Code())
+
+	require.NoError(t, call("b"))
+
+	// requests without a key are not limited
+	for i := 0; i < 3; i++ {
+		require.NoError(t, call(""))
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	bucket := NewTwirpTokenBucket(100, 1)
+	require.True(t, bucket.Allow())
+	require.False(t, bucket.Allow())
+
+	time.Sleep(20 * time.Millisecond)
+	require.True(t, bucket.Allow())
+}
+
+func TestRetryAfter(t *testing.T) {
+	retryAfter := WithTwirpServerRetryAfter(func(ctx context.Context, err twirp.Error) time.Duration {
+		return 1500 * time.Millisecond
+	})
+
+	ts := NewHaberdasherTwirpServer(&exhaustedHaberdasher{}, retryAfter)
+	svr := httptest.NewServer(ts)
+	defer svr.Close()
+
+	resp, err := http.Post(svr.URL+ts.PathPrefix()+"MakeHat", "application/json", bytes.NewBufferString(`{"inches":1}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	require.Equal(t, "2", resp.Header.Get("Retry-After"))
+
+	c, err := NewHaberdasherTwirpClient(svr.URL, http.DefaultTransport)
+	require.NoError(t, err)
+
+	_, err = c.MakeHat(context.Background(), &Size{Inches: 1})
+	wait, ok := TwirpRetryAfter(err)
+	require.True(t, ok)
+	require.Equal(t, 2*time.Second, wait)
+
+	// only resource_exhausted errors have the header
+	_, err = c.MakeHat(context.Background(), &Size{Inches: -1})
+	require.Error(t, err)
+	_, ok = TwirpRetryAfter(err)
+	require.False(t, ok)
+}
+
+func TestErrorMetadataEnricher(t *testing.T) {
+	enricher := WithTwirpServerErrorMetadataEnricher(func(ctx context.Context, err twirp.Error) twirp.Error {
+		service, _ := twirp.ServiceName(ctx)
+		method, _ := twirp.MethodName(ctx)
+		traceID, _ := TwirpRequestID(ctx)
+		return err.WithMeta("service", service).WithMeta("method", method).WithMeta("trace_id", traceID)
+	})
+
+	for _, tt := range []struct {
+		name  string
+		impl  HaberdasherTwirpService
+		code  twirp.ErrorCode
+		cause string
+	}{
+		{"handler", &testHaberdasher{}, twirp.InvalidArgument, ""},
+		{"panic", &panicHaberdasher{}, twirp.Internal, "very bad things happened"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			svr := httptest.NewServer(NewHaberdasherTwirpServer(tt.impl, enricher, WithTwirpServerRequestID("X-Request-Id")))
+			defer svr.Close()
+
+			c, err := NewHaberdasherTwirpClient(svr.URL, http.DefaultTransport)
+			require.NoError(t, err)
+
+			header := http.Header{}
+			header.Set("X-Request-Id", "trace-1")
+			ctx, err := twirp.WithHTTPRequestHeaders(context.Background(), header)
+			require.NoError(t, err)
+
+			_, err = c.MakeHat(ctx, &Size{Inches: -1})
+			twerr, ok := err.(twirp.Error)
+			require.True(t, ok)
+			require.Equal(t, tt.code, twerr.Code())
+			require.Equal(t, "Haberdasher", twerr.Meta("service"))
+			require.Equal(t, "MakeHat", twerr.Meta("method"))
+			require.Equal(t, "trace-1", twerr.Meta("trace_id"))
+			require.Equal(t, tt.cause, twerr.Meta("cause"))
+		})
+	}
+}
+
+func TestServerContext(t *testing.T) {
+	ts := NewHaberdasherTwirpServer(&contextHaberdasher{})
+	svr := httptest.NewServer(ts)
+	defer svr.Close()
+
+	c := NewHaberdasherProtobufClient(svr.URL, http.DefaultClient)
+
+	_, err := c.MakeHat(context.Background(), &Size{Inches: -1})
+	require.Error(t, err)
+	twerr, ok := err.(twirp.Error)
+	require.True(t, ok)
+	require.Equal(t, twirp.DeadlineExceeded, twerr.Code())
+	require.Equal(t, "context deadline exceeded", twerr.Msg())
+	require.Equal(t, "wrapped error: context deadline exceeded", twerr.Meta("cause"))
+}
+
+func TestServerEnforceDeadline(t *testing.T) {
+	h := &slowHaberdasher{
+		release: make(chan struct{}),
+	}
+	defer close(h.release)
+
+	ts := NewHaberdasherTwirpServer(h, WithTwirpServerEnforceDeadline())
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 50*time.Millisecond)
+		defer cancel()
+		ts.ServeHTTP(w, r.WithContext(ctx))
+	}))
+	defer svr.Close()
+
+	c := NewHaberdasherProtobufClient(svr.URL, http.DefaultClient)
+
+	_, err := c.MakeHat(context.Background(), &Size{Inches: 14})
+	require.Error(t, err)
+	twerr, ok := err.(twirp.Error)
+	require.True(t, ok)
+	require.Equal(t, twirp.DeadlineExceeded, twerr.Code())
+	require.Equal(t, "context deadline exceeded", twerr.Msg())
+}
+
+func TestClientContextCanceled(t *testing.T) {
+	h := &slowHaberdasher{
+		release: make(chan struct{}),
+	}
+
+	svr := httptest.NewServer(NewHaberdasherTwirpServer(h))
+	defer svr.Close()
+	defer close(h.release)
+
+	c, err := NewHaberdasherTwirpClient(svr.URL, http.DefaultTransport)
+	require.NoError(t, err)
 
-	_, err := c.MakeHat(context.Background(), &Size{Inches: -1})
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err = c.MakeHat(ctx, &Size{Inches: 14})
+	require.Less(t, time.Since(start), time.Second)
+
+	var twerr twirp.Error
+	require.True(t, errors.As(err, &twerr))
+	require.Equal(t, twirp.Canceled, twerr.Code())
+	require.ErrorIs(t, err, context.Canceled)
+
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err = c.MakeHat(ctx, &Size{Inches: 14})
+	require.True(t, errors.As(err, &twerr))
+	require.Equal(t, twirp.DeadlineExceeded, twerr.Code())
+
+	// already done before the request is sent
+	_, err = c.MakeHat(ctx, &Size{Inches: 14})
+	require.True(t, errors.As(err, &twerr))
+	require.Equal(t, twirp.DeadlineExceeded, twerr.Code())
+}
+
+func TestClientTimeout(t *testing.T) {
+	h := &slowHaberdasher{
+		release: make(chan struct{}),
+	}
+
+	svr := httptest.NewServer(NewHaberdasherTwirpServer(h))
+	defer svr.Close()
+	defer close(h.release)
+
+	c, err := NewHaberdasherTwirpClient(svr.URL, http.DefaultTransport, WithTwirpClientTimeout(50*time.Millisecond))
+	require.NoError(t, err)
+
+	start := time.Now()
+	_, err = c.MakeHat(context.Background(), &Size{Inches: 14})
+	require.Less(t, time.Since(start), time.Second)
+
+	var twerr twirp.Error
+	require.True(t, errors.As(err, &twerr))
+	require.Equal(t, twirp.DeadlineExceeded, twerr.Code())
+
+	// a deadline set by the caller is used instead
+	c, err = NewHaberdasherTwirpClient(svr.URL, http.DefaultTransport, WithTwirpClientTimeout(time.Minute))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start = time.Now()
+	_, err = c.MakeHat(ctx, &Size{Inches: 14})
+	require.Less(t, time.Since(start), time.Second)
+	require.True(t, errors.As(err, &twerr))
+	require.Equal(t, twirp.DeadlineExceeded, twerr.Code())
+}
+
+func TestTimeoutHeader(t *testing.T) {
+	var header string
+	var deadline time.Time
+
+	h := &deadlineHaberdasher{}
+	ts := NewHaberdasherTwirpServer(h, WithTwirpServerTimeoutHeader(""))
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Get(TwirpTimeoutHeader)
+		ts.ServeHTTP(w, r)
+	}))
+	defer svr.Close()
+
+	c, err := NewHaberdasherTwirpClient(svr.URL, http.DefaultTransport, WithTwirpClientTimeoutHeader(""))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	deadline, _ = ctx.Deadline()
+
+	_, err = c.MakeHat(ctx, &Size{Inches: 14})
+	require.NoError(t, err)
+
+	ms, err := strconv.Atoi(header)
+	require.NoError(t, err)
+	require.Greater(t, ms, 50000)
+	require.True(t, h.ok)
+	require.InDelta(t, 0, deadline.Sub(h.deadline).Seconds(), 5)
+
+	// no deadline, no header
+	_, err = c.MakeHat(context.Background(), &Size{Inches: 14})
+	require.NoError(t, err)
+	require.Empty(t, header)
+	require.False(t, h.ok)
+
+	// malformed values are ignored
+	req, err := http.NewRequest(http.MethodPost, svr.URL+ts.PathPrefix()+"MakeHat", bytes.NewBufferString(`{"inches":14}`))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(TwirpTimeoutHeader, "soon")
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.False(t, h.ok)
+}
+
+func TestMethodTimeouts(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		opts    []interface{}
+		header  string
+		timeout time.Duration
+	}{
+		{"none", nil, "", 0},
+		{"default", []interface{}{WithTwirpServerDefaultTimeout(5 * time.Second)}, "", 5 * time.Second},
+		{"method", []interface{}{WithTwirpServerDefaultTimeout(5 * time.Second), WithTwirpServerMethodTimeouts(map[string]time.Duration{"MakeHat": time.Minute})}, "", time.Minute},
+		{"exempt", []interface{}{WithTwirpServerDefaultTimeout(5 * time.Second), WithTwirpServerMethodTimeouts(map[string]time.Duration{"MakeHat": 0})}, "", 0},
+		{"other method", []interface{}{WithTwirpServerMethodTimeouts(map[string]time.Duration{"MakeScarf": time.Minute})}, "", 0},
+		{"shorter header", []interface{}{WithTwirpServerTimeoutHeader(""), WithTwirpServerDefaultTimeout(time.Minute)}, "5000", 5 * time.Second},
+		{"longer header", []interface{}{WithTwirpServerTimeoutHeader(""), WithTwirpServerDefaultTimeout(5 * time.Second)}, "60000", 5 * time.Second},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &deadlineHaberdasher{}
+			ts := NewHaberdasherTwirpServer(h, tt.opts...)
+
+			req := httptest.NewRequest(http.MethodPost, ts.PathPrefix()+"MakeHat", bytes.NewBufferString(`{"inches":14}`))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.header != "" {
+				req.Header.Set(TwirpTimeoutHeader, tt.header)
+			}
+
+			start := time.Now()
+			rec := httptest.NewRecorder()
+			ts.ServeHTTP(rec, req)
+			require.Equal(t, http.StatusOK, rec.Code)
+
+			if tt.timeout == 0 {
+				require.False(t, h.ok)
+				return
+			}
+
+			require.True(t, h.ok)
+			require.InDelta(t, tt.timeout.Seconds(), h.deadline.Sub(start).Seconds(), 1)
+		})
+	}
+}
+
+func TestWellKnownTypesJSON(t *testing.T) {
+	ts := NewHaberdasherTwirpServer(&deliveryHaberdasher{})
+
+	post := func(t *testing.T, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, ts.PathPrefix()+"MakeHat", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		ts.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("rfc 3339", func(t *testing.T) {
+		rec := post(t, `{"inches": 14, "deliver_by": "2024-05-01T12:30:00.5+02:00"}`)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var hat map[string]interface{}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &hat))
+		require.Equal(t, "2024-05-01T10:30:00.500Z", hat["deliver_by"])
+		require.Equal(t, "1209600s", hat["lead_time"])
+	})
+
+	t.Run("unset", func(t *testing.T) {
+		rec := post(t, `{"inches": 14}`)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var hat map[string]interface{}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &hat))
+		require.Contains(t, hat, "deliver_by")
+		require.Nil(t, hat["deliver_by"])
+		require.Nil(t, hat["lead_time"])
+	})
+
+	// timestamps are parsed strictly, as protojson does: an offset or Z is required
+	for _, value := range []string{"2024-05-01T12:30:00", "2024-05-01", "1714566600", "2024-05-01 12:30:00Z"} {
+		t.Run("invalid "+value, func(t *testing.T) {
+			rec := post(t, `{"inches": 14, "deliver_by": "`+value+`"}`)
+			require.Equal(t, http.StatusBadRequest, rec.Code)
+			require.Contains(t, rec.Body.String(), string(twirp.Malformed))
+		})
+	}
+
+	t.Run("client round trip", func(t *testing.T) {
+		svr := httptest.NewServer(ts)
+		defer svr.Close()
+
+		c, err := NewHaberdasherTwirpClient(svr.URL, http.DefaultTransport, WithTwirpClientCodec(DefaultTwirpCodecJson))
+		require.NoError(t, err)
+
+		deliverBy := time.Date(2024, 5, 1, 10, 30, 0, 123456789, time.UTC)
+		hat, err := c.MakeHat(context.Background(), &Size{Inches: 14, DeliverBy: timestamppb.New(deliverBy)})
+		require.NoError(t, err)
+		require.True(t, hat.DeliverBy.AsTime().Equal(deliverBy))
+		require.Equal(t, 14*24*time.Hour, hat.LeadTime.AsDuration())
+	})
+}
+
+// deliveryHaberdasher delivers hats by the requested time and takes a day per inch to make them.
+type deliveryHaberdasher struct{}
+
+func (h *deliveryHaberdasher) MakeHat(ctx context.Context, size *Size) (*Hat, error) {
+	hat := &Hat{Size: size.Inches, DeliverBy: size.DeliverBy}
+	if size.DeliverBy != nil {
+		hat.LeadTime = durationpb.New(time.Duration(size.Inches) * 24 * time.Hour)
+	}
+	return hat, nil
+}
+
+func TestClientSingleflight(t *testing.T) {
+	h := &gatedHaberdasher{
+		started: make(chan struct{}, 10),
+		release: make(chan struct{}),
+	}
+	svr := httptest.NewServer(NewHaberdasherTwirpServer(h))
+	defer svr.Close()
+
+	c, err := NewHaberdasherTwirpClient(svr.URL, http.DefaultTransport, WithTwirpClientSingleflight())
+	require.NoError(t, err)
+
+	// callers wait for the first request, so it is released once they all had time to join it
+	call := func(sizes ...int32) ([]*Hat, []error) {
+		hats := make([]*Hat, len(sizes))
+		errs := make([]error, len(sizes))
+
+		var wg sync.WaitGroup
+		for i, size := range sizes {
+			wg.Add(1)
+			go func(i int, size int32) {
+				defer wg.Done()
+				hats[i], errs[i] = c.MakeHat(context.Background(), &Size{Inches: size})
+			}(i, size)
+		}
+
+		<-h.started
+		time.Sleep(100 * time.Millisecond)
+		close(h.release)
+		wg.Wait()
+
+		h.release = make(chan struct{})
+		return hats, errs
+	}
+
+	hats, errs := call(14, 14, 14, 14, 14)
+	require.Equal(t, int32(1), atomic.LoadInt32(&h.calls))
+	for i := range hats {
+		require.NoError(t, errs[i])
+		require.Equal(t, int32(14), hats[i].Size)
+	}
+
+	// callers get their own copy of the response
+	hats[0].Size = 1
+	require.Equal(t, int32(14), hats[1].Size)
+
+	// errors are shared while in flight, but not cached
+	atomic.StoreInt32(&h.calls, 0)
+	_, errs = call(-1, -1, -1)
+	require.Equal(t, int32(1), atomic.LoadInt32(&h.calls))
+	for _, err := range errs {
+		twerr, ok := err.(twirp.Error)
+		require.True(t, ok)
+		require.Equal(t, twirp.InvalidArgument, twerr.Code())
+	}
+
+	close(h.release)
+	_, err = c.MakeHat(context.Background(), &Size{Inches: -1})
 	require.Error(t, err)
-	twerr, ok := err.(twirp.Error)
-	require.True(t, ok)
-	require.Equal(t, twirp.Internal, twerr.Code())
-	require.Equal(t, "internal service panic", twerr.Msg())
-	require.Equal(t, "very bad things happened", twerr.Meta("cause"))
+	require.Equal(t, int32(2), atomic.LoadInt32(&h.calls))
 }
 
-func TestServerContext(t *testing.T) {
-	ts := NewHaberdasherTwirpServer(&contextHaberdasher{})
-	svr := httptest.NewServer(ts)
+func TestServerSingleflight(t *testing.T) {
+	h := &gatedHaberdasher{
+		started: make(chan struct{}, 10),
+		release: make(chan struct{}),
+	}
+	svr := httptest.NewServer(NewHaberdasherTwirpServer(h, WithTwirpServerSingleflight()))
 	defer svr.Close()
 
-	c := NewHaberdasherProtobufClient(svr.URL, http.DefaultClient)
+	// separate clients, so that only the server coalesces requests
+	call := func(sizes ...int32) ([]*Hat, []error) {
+		hats := make([]*Hat, len(sizes))
+		errs := make([]error, len(sizes))
 
-	_, err := c.MakeHat(context.Background(), &Size{Inches: -1})
+		var wg sync.WaitGroup
+		for i, size := range sizes {
+			c, err := NewHaberdasherTwirpClient(svr.URL, http.DefaultTransport)
+			require.NoError(t, err)
+
+			wg.Add(1)
+			go func(i int, size int32) {
+				defer wg.Done()
+				hats[i], errs[i] = c.MakeHat(context.Background(), &Size{Inches: size})
+			}(i, size)
+		}
+
+		<-h.started
+		time.Sleep(100 * time.Millisecond)
+		close(h.release)
+		wg.Wait()
+
+		h.release = make(chan struct{})
+		return hats, errs
+	}
+
+	hats, errs := call(14, 14, 14, 14)
+	require.Equal(t, int32(1), atomic.LoadInt32(&h.calls))
+	for i := range hats {
+		require.NoError(t, errs[i])
+		require.Equal(t, int32(14), hats[i].Size)
+	}
+
+	// errors are shared while in flight, but not cached
+	atomic.StoreInt32(&h.calls, 0)
+	_, errs = call(-1, -1, -1)
+	require.Equal(t, int32(1), atomic.LoadInt32(&h.calls))
+	for _, err := range errs {
+		twerr, ok := err.(twirp.Error)
+		require.True(t, ok)
+		require.Equal(t, twirp.InvalidArgument, twerr.Code())
+	}
+
+	close(h.release)
+	c, err := NewHaberdasherTwirpClient(svr.URL, http.DefaultTransport)
+	require.NoError(t, err)
+	_, err = c.MakeHat(context.Background(), &Size{Inches: -1})
 	require.Error(t, err)
+	require.Equal(t, int32(2), atomic.LoadInt32(&h.calls))
+}
+
+func TestServerSingleflightCanceled(t *testing.T) {
+	h := &cancelableHaberdasher{
+		started: make(chan struct{}, 10),
+		release: make(chan struct{}),
+	}
+	svr := httptest.NewServer(NewHaberdasherTwirpServer(h, WithTwirpServerSingleflight()))
+	defer svr.Close()
+
+	first, err := NewHaberdasherTwirpClient(svr.URL, http.DefaultTransport)
+	require.NoError(t, err)
+	second, err := NewHaberdasherTwirpClient(svr.URL, http.DefaultTransport)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	firstErr := make(chan error, 1)
+	go func() {
+		_, err := first.MakeHat(ctx, &Size{Inches: 14})
+		firstErr <- err
+	}()
+	<-h.started
+
+	var hat *Hat
+	secondErr := make(chan error, 1)
+	go func() {
+		var err error
+		hat, err = second.MakeHat(context.Background(), &Size{Inches: 14})
+		secondErr <- err
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	// the first request gives up, and the second calls the implementation itself
+	cancel()
+	require.Error(t, <-firstErr)
+	select {
+	case <-h.started:
+	case err := <-secondErr:
+		t.Fatalf("second request failed with the first: %v", err)
+	}
+	close(h.release)
+
+	require.NoError(t, <-secondErr)
+	require.Equal(t, int32(14), hat.Size)
+	require.Equal(t, int32(2), atomic.LoadInt32(&h.calls))
+}
+
+// cancelableHaberdasher counts calls, and does not respond until release is closed or the context
+// of the call is done.
+type cancelableHaberdasher struct {
+	calls   int32
+	started chan struct{}
+	release chan struct{}
+}
+
+func (h *cancelableHaberdasher) MakeHat(ctx context.Context, size *Size) (*Hat, error) {
+	atomic.AddInt32(&h.calls, 1)
+	h.started <- struct{}{}
+	select {
+	case <-h.release:
+		return &Hat{Size: size.Inches}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// gatedHaberdasher counts calls, and does not respond until release is closed.
+type gatedHaberdasher struct {
+	calls   int32
+	started chan struct{}
+	release chan struct{}
+}
+
+func (h *gatedHaberdasher) MakeHat(ctx context.Context, size *Size) (*Hat, error) {
+	atomic.AddInt32(&h.calls, 1)
+	h.started <- struct{}{}
+	<-h.release
+	if size.Inches <= 0 {
+		return nil, twirp.InvalidArgumentError("Inches", "I can't make a hat that small!")
+	}
+	return &Hat{Size: size.Inches}, nil
+}
+
+func TestPagination(t *testing.T) {
+	// the second page is empty, and servers may return those
+	rack := &pagedHatRack{pages: [][]int32{{1, 2}, {}, {3}, {4, 5}}}
+	svr := httptest.NewServer(NewHatRackTwirpServer(rack))
+	defer svr.Close()
+
+	c, err := NewHatRackTwirpClient(svr.URL, http.DefaultTransport)
+	require.NoError(t, err)
+
+	in := &ListHatsRequest{PageSize: 2}
+
+	var sizes []int32
+	err = c.ListHatsPages(context.Background(), in).All(func(hat *Hat) error {
+		sizes = append(sizes, hat.Size)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, []int32{1, 2, 3, 4, 5}, sizes)
+	require.Equal(t, 4, rack.calls)
+	require.Empty(t, in.PageToken)
+
+	// starting from a page token
+	sizes = nil
+	err = c.ListHatsPages(context.Background(), &ListHatsRequest{PageToken: "3"}).All(func(hat *Hat) error {
+		sizes = append(sizes, hat.Size)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, []int32{4, 5}, sizes)
+
+	// errors from fn stop the iteration
+	rack.calls = 0
+	stop := errors.New("stop")
+	err = c.ListHatsPages(context.Background(), in).All(func(hat *Hat) error {
+		if hat.Size == 2 {
+			return stop
+		}
+		return nil
+	})
+	require.Equal(t, stop, err)
+	require.Equal(t, 1, rack.calls)
+
+	// errors from the server stop the iteration
+	err = c.ListHatsPages(context.Background(), &ListHatsRequest{PageToken: "invalid"}).All(func(hat *Hat) error {
+		return nil
+	})
 	twerr, ok := err.(twirp.Error)
 	require.True(t, ok)
-	require.Equal(t, twirp.DeadlineExceeded, twerr.Code())
-	require.Equal(t, "context deadline exceeded", twerr.Msg())
-	require.Equal(t, "wrapped error: context deadline exceeded", twerr.Meta("cause"))
+	require.Equal(t, twirp.InvalidArgument, twerr.Code())
+
+	// a server returning the same token would never end
+	rack.repeat = true
+	err = c.ListHatsPages(context.Background(), in).All(func(hat *Hat) error {
+		return nil
+	})
+	twerr, ok = err.(twirp.Error)
+	require.True(t, ok)
+	require.Equal(t, twirp.Internal, twerr.Code())
+}
+
+// pagedHatRack returns a page of hats of the given sizes for each call. Page tokens are the index of
+// the next page.
+func TestValidate(t *testing.T) {
+	require.NoError(t, ValidateListHatsRequest(&ListHatsRequest{PageToken: "1", Order: HatOrder_HAT_ORDER_NEWEST}))
+	require.NoError(t, ValidateSize(&Size{Inches: 14}))
+
+	err := ValidateListHatsRequest(&ListHatsRequest{PageToken: "\xff"})
+	var twerr twirp.Error
+	require.True(t, errors.As(err, &twerr))
+	require.Equal(t, twirp.InvalidArgument, twerr.Code())
+	require.Equal(t, "page_token", twerr.Meta("argument"))
+	require.Equal(t, "page_token is not valid UTF-8", twerr.Msg())
+
+	rack := &pagedHatRack{pages: [][]int32{{1}}}
+	svr := httptest.NewServer(NewHatRackTwirpServer(rack))
+	defer svr.Close()
+
+	c, err := NewHatRackTwirpClient(svr.URL, http.DefaultTransport)
+	require.NoError(t, err)
+
+	// values that are not in the enum are sent, since proto3 enums are open, and rejected by the server
+	_, err = c.ListHats(context.Background(), &ListHatsRequest{Order: HatOrder(7)})
+	require.True(t, errors.As(err, &twerr))
+	require.Equal(t, twirp.InvalidArgument, twerr.Code())
+	require.Equal(t, "order", twerr.Meta("argument"))
+	require.Equal(t, "order has undefined enum value 7", twerr.Msg())
+	require.Equal(t, 0, rack.calls)
+
+	_, err = c.ListHats(context.Background(), &ListHatsRequest{Order: HatOrder_HAT_ORDER_LARGEST})
+	require.NoError(t, err)
+	require.Equal(t, 1, rack.calls)
+}
+
+type pagedHatRack struct {
+	pages  [][]int32
+	calls  int
+	repeat bool
+}
+
+func (r *pagedHatRack) ListHats(ctx context.Context, req *ListHatsRequest) (*ListHatsResponse, error) {
+	r.calls++
+
+	page := 0
+	if req.PageToken != "" {
+		var err error
+		page, err = strconv.Atoi(req.PageToken)
+		if err != nil || page < 0 || page >= len(r.pages) {
+			return nil, twirp.InvalidArgumentError("page_token", "is invalid")
+		}
+	}
+
+	resp := &ListHatsResponse{}
+	for _, size := range r.pages[page] {
+		resp.Hats = append(resp.Hats, &Hat{Size: size})
+	}
+
+	switch {
+	case r.repeat:
+		resp.NextPageToken = req.PageToken
+		if resp.NextPageToken == "" {
+			resp.NextPageToken = "1"
+		}
+	case page+1 < len(r.pages):
+		resp.NextPageToken = strconv.Itoa(page + 1)
+	}
+	return resp, nil
+}
+
+type deadlineHaberdasher struct {
+	deadline time.Time
+	ok       bool
+}
+
+func (h *deadlineHaberdasher) MakeHat(ctx context.Context, size *Size) (*Hat, error) {
+	h.deadline, h.ok = ctx.Deadline()
+	return &Hat{Size: size.Inches}, nil
+}
+
+type slowHaberdasher struct {
+	release chan struct{}
+}
+
+func (h *slowHaberdasher) MakeHat(ctx context.Context, size *Size) (*Hat, error) {
+	<-h.release
+	return &Hat{Size: size.Inches}, nil
+}
+
+// exhaustedHaberdasher fails calls with positive sizes as if it were overloaded.
+type exhaustedHaberdasher struct{}
+
+func (h *exhaustedHaberdasher) MakeHat(ctx context.Context, size *Size) (*Hat, error) {
+	if size.Inches <= 0 {
+		return nil, twirp.InvalidArgumentError("Inches", "I can't make a hat that small!")
+	}
+	return nil, twirp.NewError(twirp.ResourceExhausted, "too many hats")
 }
 
 type contextHaberdasher struct{}
@@ -94,6 +3347,13 @@ func (h *panicHaberdasher) MakeHat(ctx context.Context, size *Size) (*Hat, error
 	panic(errors.New("very bad things happened"))
 }
 
+// namedHaberdasher makes hats with a name as long as their size, to control the response size.
+type namedHaberdasher struct{}
+
+func (h *namedHaberdasher) MakeHat(ctx context.Context, size *Size) (*Hat, error) {
+	return &Hat{Size: size.Inches, Name: strings.Repeat("x", int(size.Inches))}, nil
+}
+
 type testHaberdasher struct{}
 
 func (h *testHaberdasher) MakeHat(ctx context.Context, size *Size) (*Hat, error) {
@@ -151,6 +3411,12 @@ func BenchmarkNewServer(b *testing.B) {
 	benchmarkServer(b, ts)
 }
 
+func BenchmarkNewServerFastCodec(b *testing.B) {
+	ts := NewHaberdasherTwirpServer(&testHaberdasher{}, WithTwirpServerCodec(DefaultTwirpFastCodec))
+
+	benchmarkServer(b, ts)
+}
+
 func BenchmarkOriginalerver(b *testing.B) {
 	ts := NewHaberdasherServer(&testHaberdasher{})
 
@@ -186,6 +3452,159 @@ func BenchmarkNewClient(b *testing.B) {
 	})
 }
 
+func TestHTTP2Transport(t *testing.T) {
+	for _, h2 := range []bool{true, false} {
+		var proto string
+		ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			proto = r.Proto
+			NewHaberdasherTwirpServer(&testHaberdasher{}).ServeHTTP(w, r)
+		}))
+		ts.EnableHTTP2 = h2
+		ts.StartTLS()
+
+		transport := NewTwirpHTTP2Transport(ts.Client().Transport.(*http.Transport).TLSClientConfig)
+
+		client, err := NewHaberdasherTwirpClient(ts.URL, transport)
+		require.NoError(t, err)
+
+		doTests(t, client)
+
+		if h2 {
+			require.Equal(t, "HTTP/2.0", proto)
+		} else {
+			require.Equal(t, "HTTP/1.1", proto)
+		}
+
+		ts.Close()
+	}
+}
+
+func TestInMemoryTransport(t *testing.T) {
+	ts := NewHaberdasherTwirpServer(&testHaberdasher{}, WithTwirpServerTrailers(), twirp.WithServerHooks(&twirp.ServerHooks{
+		ResponseSent: func(ctx context.Context) {
+			_ = TwirpSetTrailer(ctx, "Twirp-Cost", "14")
+		},
+	}))
+
+	c, err := NewHaberdasherTwirpClient("http://twirp.test", NewTwirpInMemoryTransport(ts))
+	require.NoError(t, err)
+	doTests(t, c)
+
+	var trailer http.Header
+	_, err = c.MakeHatWithOptions(context.Background(), &Size{Inches: 14}, WithTwirpCallTrailer(&trailer))
+	require.NoError(t, err)
+	require.Equal(t, "14", trailer.Get("Twirp-Cost"))
+
+	// a handler that panics before writing the header fails the request
+	transport := NewTwirpInMemoryTransport(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+
+	req, err := http.NewRequest(http.MethodPost, "http://twirp.test/", nil)
+	require.NoError(t, err)
+	_, err = transport.RoundTrip(req)
+	require.Error(t, err)
+	require.Equal(t, "handler panic: boom", err.Error())
+
+	// requests end with their context, even if the handler does not
+	release := make(chan struct{})
+	defer close(release)
+
+	transport = NewTwirpInMemoryTransport(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err = transport.RoundTrip(req.WithContext(ctx))
+	require.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestTrailers(t *testing.T) {
+	hooks := twirp.WithServerHooks(&twirp.ServerHooks{
+		ResponseSent: func(ctx context.Context) {
+			// the body has been written
+			require.NoError(t, TwirpSetTrailer(ctx, "Twirp-Cost", "14"))
+		},
+	})
+
+	for _, h2 := range []bool{true, false} {
+		ts := httptest.NewUnstartedServer(NewHaberdasherTwirpServer(&testHaberdasher{}, WithTwirpServerTrailers(), hooks))
+		ts.EnableHTTP2 = h2
+		ts.StartTLS()
+
+		transport := NewTwirpHTTP2Transport(ts.Client().Transport.(*http.Transport).TLSClientConfig)
+
+		c, err := NewHaberdasherTwirpClient(ts.URL, transport)
+		require.NoError(t, err)
+
+		var trailer http.Header
+		hat, err := c.MakeHatWithOptions(context.Background(), &Size{Inches: 14}, WithTwirpCallTrailer(&trailer))
+		require.NoError(t, err)
+		require.Equal(t, int32(14), hat.Size)
+		require.Equal(t, "14", trailer.Get("Twirp-Cost"), fmt.Sprintf("HTTP/2: %v", h2))
+
+		ts.Close()
+	}
+
+	// handlers cannot set trailers unless the server has the option
+	ts := NewHaberdasherTwirpServer(&testHaberdasher{}, twirp.WithServerHooks(&twirp.ServerHooks{
+		ResponseSent: func(ctx context.Context) {
+			require.Error(t, TwirpSetTrailer(ctx, "Twirp-Cost", "14"))
+		},
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, ts.PathPrefix()+"MakeHat", strings.NewReader(`{"inches":14}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	ts.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NotEmpty(t, rec.Header().Get("Content-Length"))
+}
+
+// BenchmarkHTTP2Client compares concurrent requests multiplexed over HTTP/2 with HTTP/1.1,
+// which needs a connection for each request in flight.
+func BenchmarkHTTP2Client(b *testing.B) {
+	ts := httptest.NewUnstartedServer(NewHaberdasherTwirpServer(&testHaberdasher{}))
+	ts.EnableHTTP2 = true
+	ts.StartTLS()
+	defer ts.Close()
+
+	tlsConfig := ts.Client().Transport.(*http.Transport).TLSClientConfig
+
+	for _, h2 := range []bool{false, true} {
+		name := "HTTP/1.1"
+		if h2 {
+			name = "HTTP/2"
+		}
+
+		b.Run(name, func(b *testing.B) {
+			transport := NewTwirpHTTP2Transport(tlsConfig)
+			transport.ForceAttemptHTTP2 = h2
+			transport.MaxIdleConnsPerHost = 100
+			defer transport.CloseIdleConnections()
+
+			c, err := NewHaberdasherTwirpClient(ts.URL, transport)
+			require.NoError(b, err)
+
+			b.SetParallelism(8)
+			b.ResetTimer()
+
+			b.RunParallel(func(pb *testing.PB) {
+				ctx := context.Background()
+				size := Size{Inches: 14}
+
+				for pb.Next() {
+					if _, err := c.MakeHat(ctx, &size); err != nil {
+						b.Error(err)
+					}
+				}
+			})
+		})
+	}
+}
+
 func BenchmarkOriginalClient(b *testing.B) {
 	b.ResetTimer()
 
@@ -0,0 +1,71 @@
+// Code generated by protoc-gen-twirp-go DO NOT EDIT.
+package example
+
+import (
+	proto "google.golang.org/protobuf/proto"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// SizeBuilder builds a Size one field at a time, such as for requests built by admin
+// tools that call methods with Call. It is a convenience over setting the fields of the struct
+// directly, which works as well.
+type SizeBuilder struct {
+	msg *Size
+}
+
+// NewSizeBuilder returns a builder for an empty Size.
+func NewSizeBuilder() *SizeBuilder {
+	return &SizeBuilder{msg: &Size{}}
+}
+
+// WithInches sets the inches field to v.
+func (b *SizeBuilder) WithInches(v int32) *SizeBuilder {
+	b.msg.Inches = v
+	return b
+}
+
+// WithDeliverBy sets the deliver_by field to v.
+func (b *SizeBuilder) WithDeliverBy(v *timestamppb.Timestamp) *SizeBuilder {
+	b.msg.DeliverBy = v
+	return b
+}
+
+// Build returns a copy of the message built so far, so the builder can be used again.
+func (b *SizeBuilder) Build() *Size {
+	return proto.Clone(b.msg).(*Size)
+}
+
+// ListHatsRequestBuilder builds a ListHatsRequest one field at a time, such as for requests built by admin
+// tools that call methods with Call. It is a convenience over setting the fields of the struct
+// directly, which works as well.
+type ListHatsRequestBuilder struct {
+	msg *ListHatsRequest
+}
+
+// NewListHatsRequestBuilder returns a builder for an empty ListHatsRequest.
+func NewListHatsRequestBuilder() *ListHatsRequestBuilder {
+	return &ListHatsRequestBuilder{msg: &ListHatsRequest{}}
+}
+
+// WithPageSize sets the page_size field to v.
+func (b *ListHatsRequestBuilder) WithPageSize(v int32) *ListHatsRequestBuilder {
+	b.msg.PageSize = v
+	return b
+}
+
+// WithPageToken sets the page_token field to v.
+func (b *ListHatsRequestBuilder) WithPageToken(v string) *ListHatsRequestBuilder {
+	b.msg.PageToken = v
+	return b
+}
+
+// WithOrder sets the order field to v.
+func (b *ListHatsRequestBuilder) WithOrder(v HatOrder) *ListHatsRequestBuilder {
+	b.msg.Order = v
+	return b
+}
+
+// Build returns a copy of the message built so far, so the builder can be used again.
+func (b *ListHatsRequestBuilder) Build() *ListHatsRequest {
+	return proto.Clone(b.msg).(*ListHatsRequest)
+}
@@ -0,0 +1,610 @@
+// Code generated by protoc-gen-twirp-go DO NOT EDIT.
+package example
+
+import (
+	durationpb "google.golang.org/protobuf/types/known/durationpb"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	utf8 "unicode/utf8"
+)
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sync"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+// TwirpFastCodec is an experimental protobuf codec that encodes and decodes method inputs and outputs
+// made only of singular fields with generated functions, rather than with reflection. Other messages
+// are encoded by DefaultTwirpCodecProtobuf. The encoding is the same as proto.Marshal.
+// Use it with WithTwirpServerCodec or WithTwirpClientCodec.
+type TwirpFastCodec struct{}
+
+var DefaultTwirpFastCodec = &TwirpFastCodec{}
+
+// twirpFastBufferPool holds the buffers messages are encoded into before they are written.
+var twirpFastBufferPool = sync.Pool{
+	New: func() interface{} {
+		return new([]byte)
+	},
+}
+
+var twirpFastErrFieldNumber = errors.New("invalid field number")
+
+var twirpFastErrInvalidUTF8 = errors.New("string field contains invalid UTF-8")
+
+func (t *TwirpFastCodec) ContentType() string {
+	return DefaultTwirpCodecProtobuf.ContentType()
+}
+
+func (t *TwirpFastCodec) MarshalTo(ctx context.Context, m proto.Message, w io.Writer) error {
+	p := twirpFastBufferPool.Get().(*[]byte)
+	defer twirpFastBufferPool.Put(p)
+
+	b, ok := twirpFastMarshal((*p)[:0], m)
+	if !ok {
+		return DefaultTwirpCodecProtobuf.MarshalTo(ctx, m, w)
+	}
+
+	*p = b
+
+	_, err := w.Write(b)
+	return err
+}
+
+func (t *TwirpFastCodec) UnmarshalFrom(ctx context.Context, m proto.Message, r io.Reader) error {
+	buff := twirpBufferPool.Get().(*bytes.Buffer)
+	defer twirpBufferPool.Put(buff)
+
+	buff.Reset()
+
+	if err := twirpReadBody(buff, r); err != nil {
+		return err
+	}
+
+	if ok, err := twirpFastUnmarshal(buff.Bytes(), m); ok {
+		return err
+	}
+
+	return DefaultTwirpCodecProtobuf.UnmarshalOptions.Unmarshal(buff.Bytes(), m)
+}
+
+// twirpFastMarshal appends the encoding of m to b. It returns false if m has no generated functions,
+// or if they cannot encode it, such as for strings that are not valid UTF-8, which proto.Marshal rejects.
+func twirpFastMarshal(b []byte, m proto.Message) ([]byte, bool) {
+	switch m := m.(type) {
+	case *Size:
+		if m != nil {
+			return twirpFastMarshal_twitch_twirp_example_Size(b, m)
+		}
+	case *Hat:
+		if m != nil {
+			return twirpFastMarshal_twitch_twirp_example_Hat(b, m)
+		}
+	case *ListHatsRequest:
+		if m != nil {
+			return twirpFastMarshal_twitch_twirp_example_ListHatsRequest(b, m)
+		}
+	}
+
+	return b, false
+}
+
+// twirpFastUnmarshal replaces the fields of m with those decoded from b. It returns false if m has
+// no generated functions.
+func twirpFastUnmarshal(b []byte, m proto.Message) (bool, error) {
+	switch m := m.(type) {
+	case *Size:
+		if m != nil {
+			m.Reset()
+			return true, twirpFastUnmarshal_twitch_twirp_example_Size(b, m)
+		}
+	case *Hat:
+		if m != nil {
+			m.Reset()
+			return true, twirpFastUnmarshal_twitch_twirp_example_Hat(b, m)
+		}
+	case *ListHatsRequest:
+		if m != nil {
+			m.Reset()
+			return true, twirpFastUnmarshal_twitch_twirp_example_ListHatsRequest(b, m)
+		}
+	}
+
+	return false, nil
+}
+
+func twirpFastSize_google_protobuf_Timestamp(m *timestamppb.Timestamp) int {
+	n := len(m.ProtoReflect().GetUnknown())
+	if m.Seconds != 0 {
+		n += 1 + protowire.SizeVarint(uint64(m.Seconds))
+	}
+	if m.Nanos != 0 {
+		n += 1 + protowire.SizeVarint(uint64(m.Nanos))
+	}
+
+	return n
+}
+
+func twirpFastMarshal_google_protobuf_Timestamp(b []byte, m *timestamppb.Timestamp) ([]byte, bool) {
+	if m.Seconds != 0 {
+		b = protowire.AppendVarint(b, 8)
+		b = protowire.AppendVarint(b, uint64(m.Seconds))
+	}
+	if m.Nanos != 0 {
+		b = protowire.AppendVarint(b, 16)
+		b = protowire.AppendVarint(b, uint64(m.Nanos))
+	}
+
+	return append(b, m.ProtoReflect().GetUnknown()...), true
+}
+
+func twirpFastUnmarshal_google_protobuf_Timestamp(b []byte, m *timestamppb.Timestamp) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+
+		if num > protowire.MaxValidNumber {
+			return twirpFastErrFieldNumber
+		}
+
+		switch {
+		case num == 1 && typ == protowire.VarintType:
+			v, l := protowire.ConsumeVarint(b[n:])
+			if l < 0 {
+				return protowire.ParseError(l)
+			}
+			n += l
+
+			m.Seconds = int64(v)
+		case num == 2 && typ == protowire.VarintType:
+			v, l := protowire.ConsumeVarint(b[n:])
+			if l < 0 {
+				return protowire.ParseError(l)
+			}
+			n += l
+
+			m.Nanos = int32(v)
+		default:
+			l := protowire.ConsumeFieldValue(num, typ, b[n:])
+			if l < 0 {
+				return protowire.ParseError(l)
+			}
+
+			// like proto.Unmarshal, keep unknown fields with their tags in the shortest encoding
+			unknown := protowire.AppendTag(m.ProtoReflect().GetUnknown(), num, typ)
+			m.ProtoReflect().SetUnknown(append(unknown, b[n:n+l]...))
+			n += l
+		}
+
+		b = b[n:]
+	}
+
+	return nil
+}
+
+func twirpFastSize_twitch_twirp_example_Size(m *Size) int {
+	n := len(m.ProtoReflect().GetUnknown())
+	if m.Inches != 0 {
+		n += 1 + protowire.SizeVarint(uint64(m.Inches))
+	}
+	if m.DeliverBy != nil {
+		n += 1 + protowire.SizeBytes(twirpFastSize_google_protobuf_Timestamp(m.DeliverBy))
+	}
+
+	return n
+}
+
+func twirpFastMarshal_twitch_twirp_example_Size(b []byte, m *Size) ([]byte, bool) {
+	if m.Inches != 0 {
+		b = protowire.AppendVarint(b, 8)
+		b = protowire.AppendVarint(b, uint64(m.Inches))
+	}
+	if m.DeliverBy != nil {
+		b = protowire.AppendVarint(b, 18)
+		b = protowire.AppendVarint(b, uint64(twirpFastSize_google_protobuf_Timestamp(m.DeliverBy)))
+
+		var ok bool
+		if b, ok = twirpFastMarshal_google_protobuf_Timestamp(b, m.DeliverBy); !ok {
+			return b, false
+		}
+	}
+
+	return append(b, m.ProtoReflect().GetUnknown()...), true
+}
+
+func twirpFastUnmarshal_twitch_twirp_example_Size(b []byte, m *Size) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+
+		if num > protowire.MaxValidNumber {
+			return twirpFastErrFieldNumber
+		}
+
+		switch {
+		case num == 1 && typ == protowire.VarintType:
+			v, l := protowire.ConsumeVarint(b[n:])
+			if l < 0 {
+				return protowire.ParseError(l)
+			}
+			n += l
+
+			m.Inches = int32(v)
+		case num == 2 && typ == protowire.BytesType:
+			v, l := protowire.ConsumeBytes(b[n:])
+			if l < 0 {
+				return protowire.ParseError(l)
+			}
+			n += l
+
+			if m.DeliverBy == nil {
+				m.DeliverBy = new(timestamppb.Timestamp)
+			}
+
+			if err := twirpFastUnmarshal_google_protobuf_Timestamp(v, m.DeliverBy); err != nil {
+				return err
+			}
+		default:
+			l := protowire.ConsumeFieldValue(num, typ, b[n:])
+			if l < 0 {
+				return protowire.ParseError(l)
+			}
+
+			// like proto.Unmarshal, keep unknown fields with their tags in the shortest encoding
+			unknown := protowire.AppendTag(m.ProtoReflect().GetUnknown(), num, typ)
+			m.ProtoReflect().SetUnknown(append(unknown, b[n:n+l]...))
+			n += l
+		}
+
+		b = b[n:]
+	}
+
+	return nil
+}
+
+func twirpFastSize_google_protobuf_Duration(m *durationpb.Duration) int {
+	n := len(m.ProtoReflect().GetUnknown())
+	if m.Seconds != 0 {
+		n += 1 + protowire.SizeVarint(uint64(m.Seconds))
+	}
+	if m.Nanos != 0 {
+		n += 1 + protowire.SizeVarint(uint64(m.Nanos))
+	}
+
+	return n
+}
+
+func twirpFastMarshal_google_protobuf_Duration(b []byte, m *durationpb.Duration) ([]byte, bool) {
+	if m.Seconds != 0 {
+		b = protowire.AppendVarint(b, 8)
+		b = protowire.AppendVarint(b, uint64(m.Seconds))
+	}
+	if m.Nanos != 0 {
+		b = protowire.AppendVarint(b, 16)
+		b = protowire.AppendVarint(b, uint64(m.Nanos))
+	}
+
+	return append(b, m.ProtoReflect().GetUnknown()...), true
+}
+
+func twirpFastUnmarshal_google_protobuf_Duration(b []byte, m *durationpb.Duration) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+
+		if num > protowire.MaxValidNumber {
+			return twirpFastErrFieldNumber
+		}
+
+		switch {
+		case num == 1 && typ == protowire.VarintType:
+			v, l := protowire.ConsumeVarint(b[n:])
+			if l < 0 {
+				return protowire.ParseError(l)
+			}
+			n += l
+
+			m.Seconds = int64(v)
+		case num == 2 && typ == protowire.VarintType:
+			v, l := protowire.ConsumeVarint(b[n:])
+			if l < 0 {
+				return protowire.ParseError(l)
+			}
+			n += l
+
+			m.Nanos = int32(v)
+		default:
+			l := protowire.ConsumeFieldValue(num, typ, b[n:])
+			if l < 0 {
+				return protowire.ParseError(l)
+			}
+
+			// like proto.Unmarshal, keep unknown fields with their tags in the shortest encoding
+			unknown := protowire.AppendTag(m.ProtoReflect().GetUnknown(), num, typ)
+			m.ProtoReflect().SetUnknown(append(unknown, b[n:n+l]...))
+			n += l
+		}
+
+		b = b[n:]
+	}
+
+	return nil
+}
+
+func twirpFastSize_twitch_twirp_example_Hat(m *Hat) int {
+	n := len(m.ProtoReflect().GetUnknown())
+	if m.Size != 0 {
+		n += 1 + protowire.SizeVarint(uint64(m.Size))
+	}
+	if len(m.Color) > 0 {
+		n += 1 + protowire.SizeBytes(len(m.Color))
+	}
+	if len(m.Name) > 0 {
+		n += 1 + protowire.SizeBytes(len(m.Name))
+	}
+	if m.DeliverBy != nil {
+		n += 1 + protowire.SizeBytes(twirpFastSize_google_protobuf_Timestamp(m.DeliverBy))
+	}
+	if m.LeadTime != nil {
+		n += 1 + protowire.SizeBytes(twirpFastSize_google_protobuf_Duration(m.LeadTime))
+	}
+	if len(m.Buyer) > 0 {
+		n += 1 + protowire.SizeBytes(len(m.Buyer))
+	}
+
+	return n
+}
+
+func twirpFastMarshal_twitch_twirp_example_Hat(b []byte, m *Hat) ([]byte, bool) {
+	if m.Size != 0 {
+		b = protowire.AppendVarint(b, 8)
+		b = protowire.AppendVarint(b, uint64(m.Size))
+	}
+	if len(m.Color) > 0 {
+		if !utf8.ValidString(m.Color) {
+			return b, false
+		}
+		b = protowire.AppendVarint(b, 18)
+		b = protowire.AppendString(b, m.Color)
+	}
+	if len(m.Name) > 0 {
+		if !utf8.ValidString(m.Name) {
+			return b, false
+		}
+		b = protowire.AppendVarint(b, 26)
+		b = protowire.AppendString(b, m.Name)
+	}
+	if m.DeliverBy != nil {
+		b = protowire.AppendVarint(b, 34)
+		b = protowire.AppendVarint(b, uint64(twirpFastSize_google_protobuf_Timestamp(m.DeliverBy)))
+
+		var ok bool
+		if b, ok = twirpFastMarshal_google_protobuf_Timestamp(b, m.DeliverBy); !ok {
+			return b, false
+		}
+	}
+	if m.LeadTime != nil {
+		b = protowire.AppendVarint(b, 42)
+		b = protowire.AppendVarint(b, uint64(twirpFastSize_google_protobuf_Duration(m.LeadTime)))
+
+		var ok bool
+		if b, ok = twirpFastMarshal_google_protobuf_Duration(b, m.LeadTime); !ok {
+			return b, false
+		}
+	}
+	if len(m.Buyer) > 0 {
+		if !utf8.ValidString(m.Buyer) {
+			return b, false
+		}
+		b = protowire.AppendVarint(b, 50)
+		b = protowire.AppendString(b, m.Buyer)
+	}
+
+	return append(b, m.ProtoReflect().GetUnknown()...), true
+}
+
+func twirpFastUnmarshal_twitch_twirp_example_Hat(b []byte, m *Hat) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+
+		if num > protowire.MaxValidNumber {
+			return twirpFastErrFieldNumber
+		}
+
+		switch {
+		case num == 1 && typ == protowire.VarintType:
+			v, l := protowire.ConsumeVarint(b[n:])
+			if l < 0 {
+				return protowire.ParseError(l)
+			}
+			n += l
+
+			m.Size = int32(v)
+		case num == 2 && typ == protowire.BytesType:
+			v, l := protowire.ConsumeBytes(b[n:])
+			if l < 0 {
+				return protowire.ParseError(l)
+			}
+			n += l
+
+			if !utf8.Valid(v) {
+				return twirpFastErrInvalidUTF8
+			}
+
+			m.Color = string(v)
+		case num == 3 && typ == protowire.BytesType:
+			v, l := protowire.ConsumeBytes(b[n:])
+			if l < 0 {
+				return protowire.ParseError(l)
+			}
+			n += l
+
+			if !utf8.Valid(v) {
+				return twirpFastErrInvalidUTF8
+			}
+
+			m.Name = string(v)
+		case num == 4 && typ == protowire.BytesType:
+			v, l := protowire.ConsumeBytes(b[n:])
+			if l < 0 {
+				return protowire.ParseError(l)
+			}
+			n += l
+
+			if m.DeliverBy == nil {
+				m.DeliverBy = new(timestamppb.Timestamp)
+			}
+
+			if err := twirpFastUnmarshal_google_protobuf_Timestamp(v, m.DeliverBy); err != nil {
+				return err
+			}
+		case num == 5 && typ == protowire.BytesType:
+			v, l := protowire.ConsumeBytes(b[n:])
+			if l < 0 {
+				return protowire.ParseError(l)
+			}
+			n += l
+
+			if m.LeadTime == nil {
+				m.LeadTime = new(durationpb.Duration)
+			}
+
+			if err := twirpFastUnmarshal_google_protobuf_Duration(v, m.LeadTime); err != nil {
+				return err
+			}
+		case num == 6 && typ == protowire.BytesType:
+			v, l := protowire.ConsumeBytes(b[n:])
+			if l < 0 {
+				return protowire.ParseError(l)
+			}
+			n += l
+
+			if !utf8.Valid(v) {
+				return twirpFastErrInvalidUTF8
+			}
+
+			m.Buyer = string(v)
+		default:
+			l := protowire.ConsumeFieldValue(num, typ, b[n:])
+			if l < 0 {
+				return protowire.ParseError(l)
+			}
+
+			// like proto.Unmarshal, keep unknown fields with their tags in the shortest encoding
+			unknown := protowire.AppendTag(m.ProtoReflect().GetUnknown(), num, typ)
+			m.ProtoReflect().SetUnknown(append(unknown, b[n:n+l]...))
+			n += l
+		}
+
+		b = b[n:]
+	}
+
+	return nil
+}
+
+func twirpFastSize_twitch_twirp_example_ListHatsRequest(m *ListHatsRequest) int {
+	n := len(m.ProtoReflect().GetUnknown())
+	if m.PageSize != 0 {
+		n += 1 + protowire.SizeVarint(uint64(m.PageSize))
+	}
+	if len(m.PageToken) > 0 {
+		n += 1 + protowire.SizeBytes(len(m.PageToken))
+	}
+	if m.Order != 0 {
+		n += 1 + protowire.SizeVarint(uint64(m.Order))
+	}
+
+	return n
+}
+
+func twirpFastMarshal_twitch_twirp_example_ListHatsRequest(b []byte, m *ListHatsRequest) ([]byte, bool) {
+	if m.PageSize != 0 {
+		b = protowire.AppendVarint(b, 8)
+		b = protowire.AppendVarint(b, uint64(m.PageSize))
+	}
+	if len(m.PageToken) > 0 {
+		if !utf8.ValidString(m.PageToken) {
+			return b, false
+		}
+		b = protowire.AppendVarint(b, 18)
+		b = protowire.AppendString(b, m.PageToken)
+	}
+	if m.Order != 0 {
+		b = protowire.AppendVarint(b, 24)
+		b = protowire.AppendVarint(b, uint64(m.Order))
+	}
+
+	return append(b, m.ProtoReflect().GetUnknown()...), true
+}
+
+func twirpFastUnmarshal_twitch_twirp_example_ListHatsRequest(b []byte, m *ListHatsRequest) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+
+		if num > protowire.MaxValidNumber {
+			return twirpFastErrFieldNumber
+		}
+
+		switch {
+		case num == 1 && typ == protowire.VarintType:
+			v, l := protowire.ConsumeVarint(b[n:])
+			if l < 0 {
+				return protowire.ParseError(l)
+			}
+			n += l
+
+			m.PageSize = int32(v)
+		case num == 2 && typ == protowire.BytesType:
+			v, l := protowire.ConsumeBytes(b[n:])
+			if l < 0 {
+				return protowire.ParseError(l)
+			}
+			n += l
+
+			if !utf8.Valid(v) {
+				return twirpFastErrInvalidUTF8
+			}
+
+			m.PageToken = string(v)
+		case num == 3 && typ == protowire.VarintType:
+			v, l := protowire.ConsumeVarint(b[n:])
+			if l < 0 {
+				return protowire.ParseError(l)
+			}
+			n += l
+
+			m.Order = HatOrder(v)
+		default:
+			l := protowire.ConsumeFieldValue(num, typ, b[n:])
+			if l < 0 {
+				return protowire.ParseError(l)
+			}
+
+			// like proto.Unmarshal, keep unknown fields with their tags in the shortest encoding
+			unknown := protowire.AppendTag(m.ProtoReflect().GetUnknown(), num, typ)
+			m.ProtoReflect().SetUnknown(append(unknown, b[n:n+l]...))
+			n += l
+		}
+
+		b = b[n:]
+	}
+
+	return nil
+}
@@ -0,0 +1,120 @@
+// Code generated by protoc-gen-twirp-go DO NOT EDIT.
+package example
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// TwirpStringInterner deduplicates strings, so that equal strings share memory.
+// It is safe for concurrent use.
+type TwirpStringInterner struct {
+	mu      sync.RWMutex
+	strings map[string]string
+	max     int
+}
+
+// NewTwirpStringInterner creates a TwirpStringInterner that holds at most max distinct strings.
+// Once it is full, new strings are returned as is. Interned strings are kept for the lifetime
+// of the interner.
+func NewTwirpStringInterner(max int) *TwirpStringInterner {
+	return &TwirpStringInterner{
+		strings: make(map[string]string),
+		max:     max,
+	}
+}
+
+// Intern returns a string equal to s, sharing memory with earlier strings equal to s.
+func (i *TwirpStringInterner) Intern(s string) string {
+	i.mu.RLock()
+	interned, ok := i.strings[s]
+	i.mu.RUnlock()
+
+	if ok {
+		return interned
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if interned, ok := i.strings[s]; ok {
+		return interned
+	}
+
+	if len(i.strings) >= i.max {
+		return s
+	}
+
+	i.strings[s] = s
+
+	return s
+}
+
+// InternMessage replaces the string fields of m, including repeated fields, map values,
+// and the fields of nested messages, with interned strings.
+func (i *TwirpStringInterner) InternMessage(m proto.Message) {
+	i.internMessage(m.ProtoReflect())
+}
+
+func (i *TwirpStringInterner) internMessage(m protoreflect.Message) {
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch {
+		case fd.IsList():
+			list := v.List()
+			for n := 0; n < list.Len(); n++ {
+				switch fd.Kind() {
+				case protoreflect.StringKind:
+					list.Set(n, protoreflect.ValueOfString(i.Intern(list.Get(n).String())))
+				case protoreflect.MessageKind, protoreflect.GroupKind:
+					i.internMessage(list.Get(n).Message())
+				}
+			}
+		case fd.IsMap():
+			mapValue := fd.MapValue()
+			m := v.Map()
+			m.Range(func(k protoreflect.MapKey, v protoreflect.Value) bool {
+				switch mapValue.Kind() {
+				case protoreflect.StringKind:
+					m.Set(k, protoreflect.ValueOfString(i.Intern(v.String())))
+				case protoreflect.MessageKind:
+					i.internMessage(v.Message())
+				}
+				return true
+			})
+		case fd.Kind() == protoreflect.StringKind:
+			m.Set(fd, protoreflect.ValueOfString(i.Intern(v.String())))
+		case fd.Kind() == protoreflect.MessageKind || fd.Kind() == protoreflect.GroupKind:
+			i.internMessage(v.Message())
+		}
+		return true
+	})
+}
+
+// TwirpInterningCodec wraps a TwirpCodec and interns the strings of every decoded message.
+type TwirpInterningCodec struct {
+	TwirpCodec
+	Interner *TwirpStringInterner
+}
+
+// NewTwirpInterningCodec returns a codec that decodes with codec and then interns strings with interner.
+// Use it with WithTwirpServerCodec or WithTwirpClientCodec.
+func NewTwirpInterningCodec(codec TwirpCodec, interner *TwirpStringInterner) *TwirpInterningCodec {
+	return &TwirpInterningCodec{
+		TwirpCodec: codec,
+		Interner:   interner,
+	}
+}
+
+func (t *TwirpInterningCodec) UnmarshalFrom(ctx context.Context, m proto.Message, r io.Reader) error {
+	if err := t.TwirpCodec.UnmarshalFrom(ctx, m, r); err != nil {
+		return err
+	}
+
+	t.Interner.InternMessage(m)
+
+	return nil
+}
@@ -0,0 +1,50 @@
+// Code generated by protoc-gen-twirp-go DO NOT EDIT.
+package example
+
+import (
+	"google.golang.org/protobuf/proto"
+)
+
+// TwirpRedact returns a copy of m with the fields marked with (twirpgo.pii), including those
+// of its messages, cleared. m is not modified.
+func (m *Hat) TwirpRedact() proto.Message {
+	if m == nil {
+		return m
+	}
+
+	redacted := proto.Clone(m).(*Hat)
+	redacted.twirpRedact()
+	return redacted
+}
+
+func (m *Hat) twirpRedact() {
+	if m == nil {
+		return
+	}
+
+	msg := m.ProtoReflect()
+	fields := msg.Descriptor().Fields()
+	msg.Clear(fields.ByNumber(6)) // buyer
+}
+
+// TwirpRedact returns a copy of m with the fields marked with (twirpgo.pii), including those
+// of its messages, cleared. m is not modified.
+func (m *ListHatsResponse) TwirpRedact() proto.Message {
+	if m == nil {
+		return m
+	}
+
+	redacted := proto.Clone(m).(*ListHatsResponse)
+	redacted.twirpRedact()
+	return redacted
+}
+
+func (m *ListHatsResponse) twirpRedact() {
+	if m == nil {
+		return
+	}
+
+	for _, v := range m.GetHats() {
+		v.twirpRedact()
+	}
+}
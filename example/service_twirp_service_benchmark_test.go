@@ -0,0 +1,214 @@
+// Code generated by protoc-gen-twirp-go DO NOT EDIT.
+package example
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"testing"
+)
+
+type twirpBenchmarkResponseWriter struct {
+	header http.Header
+	status int
+}
+
+func (w *twirpBenchmarkResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *twirpBenchmarkResponseWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (w *twirpBenchmarkResponseWriter) WriteHeader(statusCode int) {
+	w.status = statusCode
+}
+
+type twirpBenchmarkTransport struct {
+	data []byte
+}
+
+func (t *twirpBenchmarkTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if r.Body != nil {
+		_ = r.Body.Close()
+	}
+
+	resp := http.Response{
+		Status:     "OK",
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{DefaultTwirpCodecProtobuf.ContentType()}},
+		Body:       ioutil.NopCloser(bytes.NewReader(t.data)),
+	}
+
+	return &resp, nil
+}
+
+type noopHaberdasherTwirpService struct {
+}
+
+func (noopHaberdasherTwirpService) MakeHat(context.Context, *Size) (*Hat, error) {
+	return new(Hat), nil
+}
+
+func BenchmarkHaberdasherTwirpServerMakeHat(b *testing.B) {
+	benchmarkHaberdasherTwirpServerMakeHat(b, DefaultTwirpCodecProtobuf)
+}
+
+func BenchmarkHaberdasherTwirpServerMakeHatJSON(b *testing.B) {
+	benchmarkHaberdasherTwirpServerMakeHat(b, DefaultTwirpCodecJson)
+}
+
+func BenchmarkHaberdasherTwirpServerMakeHatFast(b *testing.B) {
+	benchmarkHaberdasherTwirpServerMakeHat(b, DefaultTwirpFastCodec, WithTwirpServerCodec(DefaultTwirpFastCodec))
+}
+
+func benchmarkHaberdasherTwirpServerMakeHat(b *testing.B, codec TwirpCodec, opts ...interface{}) {
+	s := NewHaberdasherTwirpServer(noopHaberdasherTwirpService{}, opts...)
+
+	var buff bytes.Buffer
+	if err := codec.MarshalTo(context.Background(), new(Size), &buff); err != nil {
+		b.Fatal(err)
+	}
+
+	data := buff.Bytes()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		rdr := bytes.NewReader(data)
+
+		req, err := http.NewRequest(http.MethodPost, "http://localhost"+s.PathPrefix()+"MakeHat", rdr)
+		if err != nil {
+			b.Error(err)
+			return
+		}
+		req.Header.Set("Content-Type", codec.ContentType())
+
+		w := twirpBenchmarkResponseWriter{
+			header: make(http.Header),
+		}
+
+		for pb.Next() {
+			rdr.Reset(data)
+
+			s.ServeHTTP(&w, req)
+
+			if w.status != http.StatusOK {
+				b.Errorf("unexpected status %d", w.status)
+			}
+		}
+	})
+}
+
+func BenchmarkHaberdasherTwirpClientMakeHat(b *testing.B) {
+	var buff bytes.Buffer
+	if err := DefaultTwirpCodecProtobuf.MarshalTo(context.Background(), new(Hat), &buff); err != nil {
+		b.Fatal(err)
+	}
+
+	c, err := NewHaberdasherTwirpClient("http://localhost", &twirpBenchmarkTransport{data: buff.Bytes()})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		ctx := context.Background()
+		in := new(Size)
+
+		for pb.Next() {
+			if _, err := c.MakeHat(ctx, in); err != nil {
+				b.Error(err)
+			}
+		}
+	})
+}
+
+type noopHatRackTwirpService struct {
+}
+
+func (noopHatRackTwirpService) ListHats(context.Context, *ListHatsRequest) (*ListHatsResponse, error) {
+	return new(ListHatsResponse), nil
+}
+
+func BenchmarkHatRackTwirpServerListHats(b *testing.B) {
+	benchmarkHatRackTwirpServerListHats(b, DefaultTwirpCodecProtobuf)
+}
+
+func BenchmarkHatRackTwirpServerListHatsJSON(b *testing.B) {
+	benchmarkHatRackTwirpServerListHats(b, DefaultTwirpCodecJson)
+}
+
+func BenchmarkHatRackTwirpServerListHatsFast(b *testing.B) {
+	benchmarkHatRackTwirpServerListHats(b, DefaultTwirpFastCodec, WithTwirpServerCodec(DefaultTwirpFastCodec))
+}
+
+func benchmarkHatRackTwirpServerListHats(b *testing.B, codec TwirpCodec, opts ...interface{}) {
+	s := NewHatRackTwirpServer(noopHatRackTwirpService{}, opts...)
+
+	var buff bytes.Buffer
+	if err := codec.MarshalTo(context.Background(), new(ListHatsRequest), &buff); err != nil {
+		b.Fatal(err)
+	}
+
+	data := buff.Bytes()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		rdr := bytes.NewReader(data)
+
+		req, err := http.NewRequest(http.MethodPost, "http://localhost"+s.PathPrefix()+"ListHats", rdr)
+		if err != nil {
+			b.Error(err)
+			return
+		}
+		req.Header.Set("Content-Type", codec.ContentType())
+
+		w := twirpBenchmarkResponseWriter{
+			header: make(http.Header),
+		}
+
+		for pb.Next() {
+			rdr.Reset(data)
+
+			s.ServeHTTP(&w, req)
+
+			if w.status != http.StatusOK {
+				b.Errorf("unexpected status %d", w.status)
+			}
+		}
+	})
+}
+
+func BenchmarkHatRackTwirpClientListHats(b *testing.B) {
+	var buff bytes.Buffer
+	if err := DefaultTwirpCodecProtobuf.MarshalTo(context.Background(), new(ListHatsResponse), &buff); err != nil {
+		b.Fatal(err)
+	}
+
+	c, err := NewHatRackTwirpClient("http://localhost", &twirpBenchmarkTransport{data: buff.Bytes()})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		ctx := context.Background()
+		in := new(ListHatsRequest)
+
+		for pb.Next() {
+			if _, err := c.ListHats(ctx, in); err != nil {
+				b.Error(err)
+			}
+		}
+	})
+}
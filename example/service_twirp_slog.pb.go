@@ -0,0 +1,89 @@
+// Code generated by protoc-gen-twirp-go DO NOT EDIT.
+
+//go:build go1.21
+// +build go1.21
+
+package example
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/twitchtv/twirp"
+)
+
+type twirpSlogKey struct{}
+
+type twirpSlogState struct {
+	start time.Time
+	code  twirp.ErrorCode
+}
+
+// WithTwirpServerSlogLogger logs the start and end of each request to logger. Requests that
+// succeed are logged at successLevel and requests that fail at errorLevel. A nil successLevel
+// defaults to slog.LevelInfo and a nil errorLevel to slog.LevelError. With
+// WithTwirpServerRequestSampler, requests the sampler did not pick are only logged when they fail.
+func WithTwirpServerSlogLogger(logger *slog.Logger, successLevel slog.Leveler, errorLevel slog.Leveler) TwirpServerOption {
+	if successLevel == nil {
+		successLevel = slog.LevelInfo
+	}
+
+	if errorLevel == nil {
+		errorLevel = slog.LevelError
+	}
+
+	hooks := &twirp.ServerHooks{
+		RequestReceived: func(ctx context.Context) (context.Context, error) {
+			return context.WithValue(ctx, twirpSlogKey{}, &twirpSlogState{start: time.Now()}), nil
+		},
+		RequestRouted: func(ctx context.Context) (context.Context, error) {
+			if !twirpLogDetail(ctx) {
+				return ctx, nil
+			}
+
+			service, _ := twirp.ServiceName(ctx)
+			method, _ := twirp.MethodName(ctx)
+			logger.LogAttrs(ctx, successLevel.Level(), "twirp request started",
+				slog.String("service", service),
+				slog.String("method", method),
+			)
+			return ctx, nil
+		},
+		Error: func(ctx context.Context, err twirp.Error) context.Context {
+			if state, ok := ctx.Value(twirpSlogKey{}).(*twirpSlogState); ok {
+				state.code = err.Code()
+			}
+			return ctx
+		},
+		ResponseSent: func(ctx context.Context) {
+			state, ok := ctx.Value(twirpSlogKey{}).(*twirpSlogState)
+			if !ok {
+				return
+			}
+
+			service, _ := twirp.ServiceName(ctx)
+			method, _ := twirp.MethodName(ctx)
+
+			level := successLevel.Level()
+			code := "ok"
+			if state.code != twirp.NoError {
+				level = errorLevel.Level()
+				code = string(state.code)
+			} else if !twirpLogDetail(ctx) {
+				return
+			}
+
+			logger.LogAttrs(ctx, level, "twirp request finished",
+				slog.String("service", service),
+				slog.String("method", method),
+				slog.Duration("duration", time.Since(state.start)),
+				slog.String("code", code),
+			)
+		},
+	}
+
+	return func(o *TwirpServerOptions) {
+		o.hooks = append(o.hooks, hooks)
+	}
+}
@@ -0,0 +1,134 @@
+// Code generated by protoc-gen-twirp-go DO NOT EDIT.
+package example
+
+import (
+	durationpb "google.golang.org/protobuf/types/known/durationpb"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// SizeTagged is a shim over Size that adds struct tags to its fields, for tooling
+// that reflects over struct tags. It is not a protobuf message. Message and repeated fields
+// are copied shallowly by NewSizeTagged and Proto.
+type SizeTagged struct {
+	Inches    int32                  `json:"inches" yaml:"inches" validate:"gt=0"`
+	DeliverBy *timestamppb.Timestamp `json:"deliverBy" yaml:"deliverBy"`
+}
+
+// NewSizeTagged copies the fields of m into a new SizeTagged. It returns nil if m is nil.
+func NewSizeTagged(m *Size) *SizeTagged {
+	if m == nil {
+		return nil
+	}
+
+	return &SizeTagged{
+		Inches:    m.Inches,
+		DeliverBy: m.DeliverBy,
+	}
+}
+
+// Proto copies the fields of t into a new Size.
+func (t *SizeTagged) Proto() *Size {
+	return &Size{
+		Inches:    t.Inches,
+		DeliverBy: t.DeliverBy,
+	}
+}
+
+// HatTagged is a shim over Hat that adds struct tags to its fields, for tooling
+// that reflects over struct tags. It is not a protobuf message. Message and repeated fields
+// are copied shallowly by NewHatTagged and Proto.
+type HatTagged struct {
+	Size      int32                  `json:"size" yaml:"size"`
+	Color     string                 `json:"color" yaml:"color"`
+	Name      string                 `json:"name" yaml:"name"`
+	DeliverBy *timestamppb.Timestamp `json:"deliverBy" yaml:"deliverBy"`
+	LeadTime  *durationpb.Duration   `json:"leadTime" yaml:"leadTime"`
+	Buyer     string                 `json:"buyer" yaml:"buyer"`
+}
+
+// NewHatTagged copies the fields of m into a new HatTagged. It returns nil if m is nil.
+func NewHatTagged(m *Hat) *HatTagged {
+	if m == nil {
+		return nil
+	}
+
+	return &HatTagged{
+		Size:      m.Size,
+		Color:     m.Color,
+		Name:      m.Name,
+		DeliverBy: m.DeliverBy,
+		LeadTime:  m.LeadTime,
+		Buyer:     m.Buyer,
+	}
+}
+
+// Proto copies the fields of t into a new Hat.
+func (t *HatTagged) Proto() *Hat {
+	return &Hat{
+		Size:      t.Size,
+		Color:     t.Color,
+		Name:      t.Name,
+		DeliverBy: t.DeliverBy,
+		LeadTime:  t.LeadTime,
+		Buyer:     t.Buyer,
+	}
+}
+
+// ListHatsRequestTagged is a shim over ListHatsRequest that adds struct tags to its fields, for tooling
+// that reflects over struct tags. It is not a protobuf message. Message and repeated fields
+// are copied shallowly by NewListHatsRequestTagged and Proto.
+type ListHatsRequestTagged struct {
+	PageSize  int32    `json:"pageSize" yaml:"pageSize"`
+	PageToken string   `json:"pageToken" yaml:"pageToken"`
+	Order     HatOrder `json:"order" yaml:"order"`
+}
+
+// NewListHatsRequestTagged copies the fields of m into a new ListHatsRequestTagged. It returns nil if m is nil.
+func NewListHatsRequestTagged(m *ListHatsRequest) *ListHatsRequestTagged {
+	if m == nil {
+		return nil
+	}
+
+	return &ListHatsRequestTagged{
+		PageSize:  m.PageSize,
+		PageToken: m.PageToken,
+		Order:     m.Order,
+	}
+}
+
+// Proto copies the fields of t into a new ListHatsRequest.
+func (t *ListHatsRequestTagged) Proto() *ListHatsRequest {
+	return &ListHatsRequest{
+		PageSize:  t.PageSize,
+		PageToken: t.PageToken,
+		Order:     t.Order,
+	}
+}
+
+// ListHatsResponseTagged is a shim over ListHatsResponse that adds struct tags to its fields, for tooling
+// that reflects over struct tags. It is not a protobuf message. Message and repeated fields
+// are copied shallowly by NewListHatsResponseTagged and Proto.
+type ListHatsResponseTagged struct {
+	Hats          []*Hat `json:"hats" yaml:"hats"`
+	NextPageToken string `json:"nextPageToken" yaml:"nextPageToken"`
+}
+
+// NewListHatsResponseTagged copies the fields of m into a new ListHatsResponseTagged. It returns nil if m is nil.
+func NewListHatsResponseTagged(m *ListHatsResponse) *ListHatsResponseTagged {
+	if m == nil {
+		return nil
+	}
+
+	return &ListHatsResponseTagged{
+		Hats:          m.Hats,
+		NextPageToken: m.NextPageToken,
+	}
+}
+
+// Proto copies the fields of t into a new ListHatsResponse.
+func (t *ListHatsResponseTagged) Proto() *ListHatsResponse {
+	return &ListHatsResponse{
+		Hats:          t.Hats,
+		NextPageToken: t.NextPageToken,
+	}
+}
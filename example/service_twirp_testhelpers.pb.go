@@ -0,0 +1,460 @@
+// Code generated by protoc-gen-twirp-go DO NOT EDIT.
+package example
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/twitchtv/twirp"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// TwirpRecordedCall is a call recorded by a Recording client.
+type TwirpRecordedCall struct {
+	// Method is the name of the RPC method, as used in the URL.
+	Method string
+	// Request is a deep copy of the request message.
+	Request proto.Message
+}
+
+// WithTwirpClientCassette records the responses to the client's calls in the file at path, and
+// replays them instead of sending requests once the file exists, for tests that run without the
+// server. If the file does not exist when the client is created, calls are sent and the cassette
+// is written after each call. Otherwise calls are answered from the cassette, and calls that were
+// not recorded fail with twirp.Internal. Delete the file to record again.
+//
+// Calls match a recording with the same method and an equal request message, so cassettes work
+// with both protobuf and JSON clients. Messages are stored as JSON, and twirp.Error responses are
+// stored and replayed too. Only the first recording of the same request is kept.
+func WithTwirpClientCassette(path string) TwirpClientOption {
+	return func(o *TwirpClientOptions) {
+		o.recorder = func() (twirpCallRecorder, error) {
+			c, err := newTwirpCassette(path)
+			if err != nil {
+				return nil, err
+			}
+			return c, nil
+		}
+	}
+}
+
+// twirpCassetteEntry is a call recorded in a cassette.
+type twirpCassetteEntry struct {
+	Method   string              `json:"method"`
+	Request  jsoniter.RawMessage `json:"request"`
+	Response jsoniter.RawMessage `json:"response,omitempty"`
+	Error    *twirpErrorJSON     `json:"error,omitempty"`
+
+	// request is Request decoded, once a call of Method has been compared with it.
+	request proto.Message
+}
+
+// twirpCassette records calls to a file, or replays them from the file.
+type twirpCassette struct {
+	path   string
+	replay bool
+
+	mu      sync.Mutex
+	entries []*twirpCassetteEntry
+}
+
+// newTwirpCassette returns a cassette that replays the calls in the file at path, or records calls
+// to it if it does not exist.
+func newTwirpCassette(path string) (*twirpCassette, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &twirpCassette{path: path}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c := &twirpCassette{path: path, replay: true}
+	if err := jsonCodec.Unmarshal(data, &c.entries); err != nil {
+		return nil, fmt.Errorf("invalid cassette %s: %w", path, err)
+	}
+
+	return c, nil
+}
+
+// find returns the entry of the call of method with in, if there is one. c.mu must be held.
+func (c *twirpCassette) find(method string, in proto.Message) (*twirpCassetteEntry, error) {
+	for _, entry := range c.entries {
+		if entry.Method != method {
+			continue
+		}
+
+		if entry.request == nil {
+			request := in.ProtoReflect().New().Interface()
+			if err := protojson.Unmarshal(entry.Request, request); err != nil {
+				return nil, fmt.Errorf("invalid request of %s in cassette %s: %w", method, c.path, err)
+			}
+			entry.request = request
+		}
+
+		if proto.Equal(entry.request, in) {
+			return entry, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// do replays the call of method with in into out, or, when recording, calls fn and records its
+// results.
+func (c *twirpCassette) do(ctx context.Context, method string, in proto.Message, out proto.Message, fn func() (context.Context, error)) (context.Context, error) {
+	c.mu.Lock()
+	entry, err := c.find(method, in)
+	c.mu.Unlock()
+	if err != nil {
+		return ctx, twirp.InternalErrorWith(err)
+	}
+
+	if c.replay {
+		switch {
+		case entry == nil:
+			return ctx, twirp.InternalError("no recorded call of " + method + " with the request in cassette " + c.path)
+		case entry.Error != nil:
+			twerr := twirp.NewError(twirp.ErrorCode(entry.Error.Code), entry.Error.Msg)
+			for k, v := range entry.Error.Meta {
+				twerr = twerr.WithMeta(k, v)
+			}
+			return ctx, twerr
+		}
+
+		if err := protojson.Unmarshal(entry.Response, out); err != nil {
+			return ctx, twirp.InternalErrorWith(fmt.Errorf("invalid response of %s in cassette %s: %w", method, c.path, err))
+		}
+		return ctx, nil
+	}
+
+	respCtx, err := fn()
+
+	// calls that failed on the client, or were canceled by the caller, are not the server's answer
+	var twerr twirp.Error
+	if err != nil && (!errors.As(err, &twerr) || ctx.Err() != nil) {
+		return respCtx, err
+	}
+
+	if entry == nil {
+		if recordErr := c.record(method, in, out, twerr); recordErr != nil {
+			return respCtx, twirp.InternalErrorWith(recordErr)
+		}
+	}
+
+	return respCtx, err
+}
+
+// record adds a call to the cassette and writes it to its file.
+func (c *twirpCassette) record(method string, in proto.Message, out proto.Message, twerr twirp.Error) error {
+	entry := &twirpCassetteEntry{Method: method, request: proto.Clone(in)}
+
+	var err error
+	entry.Request, err = protojson.Marshal(in)
+	if err != nil {
+		return err
+	}
+
+	if twerr != nil {
+		entry.Error = &twirpErrorJSON{Code: string(twerr.Code()), Msg: twerr.Msg(), Meta: twerr.MetaMap()}
+	} else {
+		entry.Response, err = protojson.Marshal(out)
+		if err != nil {
+			return err
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// a concurrent call with the same request may have been recorded first
+	if existing, err := c.find(method, in); err != nil || existing != nil {
+		return err
+	}
+	c.entries = append(c.entries, entry)
+
+	data, err := jsonCodec.MarshalIndent(c.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(c.path, data, 0o644)
+}
+
+// NewTwirpInMemoryTransport returns a transport that serves requests with handler, such as a
+// <Service>TwirpServer, in the calling process, for fast tests of clients and servers together. Requests
+// and responses go through the same encoding, decoding and dispatch as over HTTP, but nothing is sent
+// over a network: there are no connections, TLS or proxies, and request URLs only need a scheme and a
+// host, such as http://twirp.test. The response is streamed to the client as handler writes it, and
+// trailers are supported. handler runs on its own goroutine, and its request context is the context of
+// the client's request.
+func NewTwirpInMemoryTransport(handler http.Handler) http.RoundTripper {
+	return &twirpInMemoryTransport{handler: handler}
+}
+
+type twirpInMemoryTransport struct {
+	handler http.Handler
+}
+
+func (t *twirpInMemoryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+
+	r := req.Clone(ctx)
+	if r.Body == nil {
+		r.Body = http.NoBody
+	}
+	if r.Host == "" {
+		r.Host = req.URL.Host
+	}
+	r.Proto, r.ProtoMajor, r.ProtoMinor = "HTTP/1.1", 1, 1
+	r.RequestURI = req.URL.RequestURI()
+	r.RemoteAddr = "127.0.0.1:0"
+
+	pr, pw := io.Pipe()
+	w := &twirpInMemoryResponseWriter{
+		header:      http.Header{},
+		wroteHeader: make(chan struct{}),
+		body:        pw,
+		resp: &http.Response{
+			Proto:      "HTTP/1.1",
+			ProtoMajor: 1,
+			ProtoMinor: 1,
+			Body:       pr,
+			Request:    req,
+		},
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		err := w.serve(t.handler, r)
+		pw.CloseWithError(err)
+	}()
+
+	// like a network transport, the response body fails once the request context is done
+	go func() {
+		select {
+		case <-ctx.Done():
+			pr.CloseWithError(ctx.Err())
+		case <-done:
+		}
+	}()
+
+	select {
+	case <-w.wroteHeader:
+		if w.err != nil {
+			return nil, w.err
+		}
+		return w.resp, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// twirpInMemoryResponseWriter writes the response of an in-memory request to the pipe its body is
+// read from.
+type twirpInMemoryResponseWriter struct {
+	header      http.Header
+	wroteHeader chan struct{}
+	written     bool
+	body        *io.PipeWriter
+	resp        *http.Response
+	// err is the error of a handler that panicked before writing the header
+	err error
+}
+
+// serve calls handler and sets the trailers of the response. If handler panics, it returns the error
+// the response body fails with, or RoundTrip fails if the header was not written.
+func (w *twirpInMemoryResponseWriter) serve(handler http.Handler, r *http.Request) (err error) {
+	defer func() {
+		_ = r.Body.Close()
+
+		if p := recover(); p != nil {
+			err = fmt.Errorf("handler panic: %v", p)
+			if !w.written {
+				w.written = true
+				w.err = err
+				close(w.wroteHeader)
+				return
+			}
+		}
+
+		w.WriteHeader(http.StatusOK)
+
+		for key, values := range w.header {
+			if strings.HasPrefix(key, http.TrailerPrefix) {
+				if w.resp.Trailer == nil {
+					w.resp.Trailer = http.Header{}
+				}
+				w.resp.Trailer[http.CanonicalHeaderKey(strings.TrimPrefix(key, http.TrailerPrefix))] = values
+			}
+		}
+		for key := range w.resp.Trailer {
+			if values, ok := w.header[key]; ok {
+				w.resp.Trailer[key] = values
+			}
+		}
+	}()
+
+	handler.ServeHTTP(w, r)
+	return nil
+}
+
+func (w *twirpInMemoryResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *twirpInMemoryResponseWriter) WriteHeader(statusCode int) {
+	if w.written || statusCode < 200 {
+		return
+	}
+	w.written = true
+
+	w.resp.StatusCode = statusCode
+	w.resp.Status = strconv.Itoa(statusCode) + " " + http.StatusText(statusCode)
+	w.resp.Header = w.header.Clone()
+	w.resp.ContentLength = -1
+	if length, err := strconv.ParseInt(w.header.Get("Content-Length"), 10, 64); err == nil {
+		w.resp.ContentLength = length
+	}
+
+	// declared trailers are set when handler returns
+	for _, declared := range w.resp.Header.Values("Trailer") {
+		for _, key := range strings.Split(declared, ",") {
+			if key = strings.TrimSpace(key); key != "" {
+				if w.resp.Trailer == nil {
+					w.resp.Trailer = http.Header{}
+				}
+				w.resp.Trailer[http.CanonicalHeaderKey(key)] = nil
+			}
+		}
+	}
+
+	close(w.wroteHeader)
+}
+
+func (w *twirpInMemoryResponseWriter) Write(b []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.body.Write(b)
+}
+
+// Flush sends the header if it has not been sent, as the body is not buffered.
+func (w *twirpInMemoryResponseWriter) Flush() {
+	w.WriteHeader(http.StatusOK)
+}
+
+// RecordingHaberdasherClient wraps a HaberdasherTwirpClient and records every call made
+// through it. It is intended for tests that assert which RPCs were made.
+type RecordingHaberdasherClient struct {
+	client *HaberdasherTwirpClient
+
+	mu    sync.Mutex
+	calls []TwirpRecordedCall
+}
+
+// NewRecordingHaberdasherClient creates a RecordingHaberdasherClient that forwards calls to client.
+func NewRecordingHaberdasherClient(client *HaberdasherTwirpClient) *RecordingHaberdasherClient {
+	return &RecordingHaberdasherClient{
+		client: client,
+	}
+}
+
+// Calls returns the calls made so far, in order. Calls are recorded before they are sent,
+// so failed calls are included.
+func (c *RecordingHaberdasherClient) Calls() []TwirpRecordedCall {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	calls := make([]TwirpRecordedCall, len(c.calls))
+	copy(calls, c.calls)
+
+	return calls
+}
+
+// Reset discards all recorded calls.
+func (c *RecordingHaberdasherClient) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.calls = nil
+}
+
+func (c *RecordingHaberdasherClient) record(method string, in proto.Message) {
+	call := TwirpRecordedCall{
+		Method:  method,
+		Request: proto.Clone(in),
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.calls = append(c.calls, call)
+}
+
+func (c *RecordingHaberdasherClient) MakeHat(ctx context.Context, in *Size) (*Hat, error) {
+	c.record("MakeHat", in)
+	return c.client.MakeHat(ctx, in)
+}
+
+// RecordingHatRackClient wraps a HatRackTwirpClient and records every call made
+// through it. It is intended for tests that assert which RPCs were made.
+type RecordingHatRackClient struct {
+	client *HatRackTwirpClient
+
+	mu    sync.Mutex
+	calls []TwirpRecordedCall
+}
+
+// NewRecordingHatRackClient creates a RecordingHatRackClient that forwards calls to client.
+func NewRecordingHatRackClient(client *HatRackTwirpClient) *RecordingHatRackClient {
+	return &RecordingHatRackClient{
+		client: client,
+	}
+}
+
+// Calls returns the calls made so far, in order. Calls are recorded before they are sent,
+// so failed calls are included.
+func (c *RecordingHatRackClient) Calls() []TwirpRecordedCall {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	calls := make([]TwirpRecordedCall, len(c.calls))
+	copy(calls, c.calls)
+
+	return calls
+}
+
+// Reset discards all recorded calls.
+func (c *RecordingHatRackClient) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.calls = nil
+}
+
+func (c *RecordingHatRackClient) record(method string, in proto.Message) {
+	call := TwirpRecordedCall{
+		Method:  method,
+		Request: proto.Clone(in),
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.calls = append(c.calls, call)
+}
+
+func (c *RecordingHatRackClient) ListHats(ctx context.Context, in *ListHatsRequest) (*ListHatsResponse, error) {
+	c.record("ListHats", in)
+	return c.client.ListHats(ctx, in)
+}
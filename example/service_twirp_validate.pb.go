@@ -0,0 +1,72 @@
+// Code generated by protoc-gen-twirp-go DO NOT EDIT.
+package example
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/twitchtv/twirp"
+)
+
+// ValidateSize checks that m has what the types of its fields require, in m and the messages it
+// holds: required fields are set, enum fields have defined values, and strings are valid UTF-8.
+// It returns a twirp.InvalidArgument error for the first invalid field.
+func ValidateSize(m *Size) error {
+	return nil
+}
+
+// ValidateListHatsRequest checks that m has what the types of its fields require, in m and the messages it
+// holds: required fields are set, enum fields have defined values, and strings are valid UTF-8.
+// It returns a twirp.InvalidArgument error for the first invalid field.
+func ValidateListHatsRequest(m *ListHatsRequest) error {
+	if err := twirpValidate_twitch_twirp_example_ListHatsRequest(m); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// twirpValidateError returns the error for the invalid field at path.
+func twirpValidateError(path string, reason string) twirp.Error {
+	return twirp.InvalidArgumentError(path, reason)
+}
+
+// twirpValidateNested prefixes the path of err, an error for a field of a message, with the path
+// of the field holding the message.
+func twirpValidateNested(path string, err twirp.Error) twirp.Error {
+	argument := err.Meta("argument")
+	return twirp.InvalidArgumentError(path+"."+argument, strings.TrimPrefix(err.Msg(), argument+" "))
+}
+
+func twirpValidateIndex(field string, i int) string {
+	return field + "[" + strconv.Itoa(i) + "]"
+}
+
+func twirpValidateKey(field string, key interface{}) string {
+	return fmt.Sprintf("%s[%v]", field, key)
+}
+
+func twirpValidateUTF8(path string, s string) twirp.Error {
+	if !utf8.ValidString(s) {
+		return twirpValidateError(path, "is not valid UTF-8")
+	}
+	return nil
+}
+
+func twirpValidate_twitch_twirp_example_ListHatsRequest(m *ListHatsRequest) twirp.Error {
+	if m == nil {
+		return nil
+	}
+
+	if err := twirpValidateUTF8("page_token", m.GetPageToken()); err != nil {
+		return err
+	}
+
+	if m.GetOrder().Descriptor().Values().ByNumber(m.GetOrder().Number()) == nil {
+		return twirpValidateError("order", "has undefined enum value "+strconv.Itoa(int(m.GetOrder())))
+	}
+
+	return nil
+}
@@ -0,0 +1,261 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/twitchtv/twirp"
+)
+
+type testCounter struct {
+	// done receives the error returned by each call of Count.
+	done chan error
+}
+
+func (c *testCounter) Square(ctx context.Context, n *Number) (*Number, error) {
+	return &Number{Value: n.Value * n.Value}, nil
+}
+
+func (c *testCounter) Count(ctx context.Context, req *CountRequest, send func(*Number) error) (err error) {
+	defer func() {
+		if c.done != nil {
+			c.done <- err
+		}
+	}()
+
+	if req.To < 0 {
+		return twirp.InvalidArgumentError("to", "must not be negative")
+	}
+
+	for i := int32(1); i <= req.To; i++ {
+		if i > 1 {
+			select {
+			case <-time.After(time.Duration(req.IntervalMs) * time.Millisecond):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		if err := send(&Number{Value: i}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func collect(t *testing.T, c *CounterTwirpClient, ctx context.Context, req *CountRequest) ([]int32, error) {
+	var values []int32
+	err := c.Count(ctx, req, func(n *Number) error {
+		values = append(values, n.Value)
+		return nil
+	})
+	return values, err
+}
+
+func TestStream(t *testing.T) {
+	svr := httptest.NewServer(NewCounterTwirpServer(&testCounter{}))
+	defer svr.Close()
+
+	for _, codec := range []TwirpCodec{DefaultTwirpCodecProtobuf, DefaultTwirpCodecJson} {
+		t.Run(codec.ContentType(), func(t *testing.T) {
+			c, err := NewCounterTwirpClient(svr.URL, http.DefaultTransport, WithTwirpClientCodec(codec))
+			require.NoError(t, err)
+
+			values, err := collect(t, c, context.Background(), &CountRequest{To: 3})
+			require.NoError(t, err)
+			require.Equal(t, []int32{1, 2, 3}, values)
+
+			values, err = collect(t, c, context.Background(), &CountRequest{})
+			require.NoError(t, err)
+			require.Empty(t, values)
+
+			// unary methods are unchanged
+			n, err := c.Square(context.Background(), &Number{Value: 3})
+			require.NoError(t, err)
+			require.Equal(t, int32(9), n.Value)
+		})
+	}
+}
+
+// testTicker serves Ticker, whose only method is a server streaming one, with a testCounter.
+type testTicker struct {
+	testCounter
+}
+
+func (c *testTicker) Tick(ctx context.Context, req *CountRequest, send func(*Number) error) error {
+	return c.Count(ctx, req, send)
+}
+
+func TestStreamOnlyService(t *testing.T) {
+	svr := httptest.NewServer(NewTickerTwirpServer(&testTicker{}))
+	defer svr.Close()
+
+	c, err := NewTickerTwirpClient(svr.URL, http.DefaultTransport)
+	require.NoError(t, err)
+
+	var values []int32
+	err = c.Tick(context.Background(), &CountRequest{To: 3}, func(n *Number) error {
+		values = append(values, n.Value)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, []int32{1, 2, 3}, values)
+}
+
+func TestStreamEvents(t *testing.T) {
+	ts := NewCounterTwirpServer(&testCounter{})
+
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, ts.PathPrefix()+"Count", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		ts.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := post(`{"to": 2}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "text/event-stream", rec.Header().Get("Content-Type"))
+	require.Equal(t, "event: message\ndata: {\"value\":1}\n\nevent: message\ndata: {\"value\":2}\n\nevent: end\ndata: \n\n", rec.Body.String())
+
+	// errors after the stream has started are sent as an event
+	rec = post(`{"to": -1}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "event: error\ndata: {\"meta\":{\"argument\":\"to\"},\"code\":\"invalid_argument\",\"msg\":\"to must not be negative\"}\n\n", rec.Body.String())
+
+	// errors before are sent as an error response
+	rec = post(`{"to": `)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.Contains(t, rec.Body.String(), string(twirp.Malformed))
+}
+
+func TestStreamErrors(t *testing.T) {
+	counter := &testCounter{done: make(chan error, 10)}
+	svr := httptest.NewServer(NewCounterTwirpServer(counter))
+	defer svr.Close()
+
+	c, err := NewCounterTwirpClient(svr.URL, http.DefaultTransport)
+	require.NoError(t, err)
+
+	_, err = collect(t, c, context.Background(), &CountRequest{To: -1})
+	twerr, ok := err.(twirp.Error)
+	require.True(t, ok)
+	require.Equal(t, twirp.InvalidArgument, twerr.Code())
+	require.Equal(t, "to must not be negative", twerr.Msg())
+	require.Equal(t, "to", twerr.Meta("argument"))
+	<-counter.done
+
+	// errors from fn stop the stream, and the server sees the client go away
+	stop := errors.New("stop")
+	err = c.Count(context.Background(), &CountRequest{To: 1000, IntervalMs: 10}, func(n *Number) error {
+		if n.Value == 2 {
+			return stop
+		}
+		return nil
+	})
+	require.Equal(t, stop, err)
+	select {
+	case err := <-counter.done:
+		require.Error(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the server did not stop")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	values, err := collect(t, c, ctx, &CountRequest{To: 1000, IntervalMs: 10})
+	twerr, ok = err.(twirp.Error)
+	require.True(t, ok)
+	require.Equal(t, twirp.DeadlineExceeded, twerr.Code())
+	require.NotEmpty(t, values)
+	<-counter.done
+}
+
+func TestStreamKeepAlive(t *testing.T) {
+	ts := NewCounterTwirpServer(&testCounter{}, WithTwirpServerSSEKeepAlive(10*time.Millisecond))
+
+	req := httptest.NewRequest(http.MethodPost, ts.PathPrefix()+"Count", strings.NewReader(`{"to": 2, "interval_ms": 100}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	ts.ServeHTTP(rec, req)
+
+	require.Contains(t, rec.Body.String(), "data: {\"value\":1}\n\n: keep-alive\n\n")
+	require.True(t, strings.HasSuffix(rec.Body.String(), "event: end\ndata: \n\n"))
+
+	// comments are ignored by the client
+	svr := httptest.NewServer(ts)
+	defer svr.Close()
+
+	c, err := NewCounterTwirpClient(svr.URL, http.DefaultTransport)
+	require.NoError(t, err)
+
+	values, err := collect(t, c, context.Background(), &CountRequest{To: 2, IntervalMs: 100})
+	require.NoError(t, err)
+	require.Equal(t, []int32{1, 2}, values)
+}
+
+func TestStreamInterrupted(t *testing.T) {
+	// a stream that ends without an end event, as when a proxy closes the connection
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = ioutil.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte("event: message\ndata: {\"value\":1}\n\n"))
+	}))
+	defer svr.Close()
+
+	c, err := NewCounterTwirpClient(svr.URL, http.DefaultTransport)
+	require.NoError(t, err)
+
+	values, err := collect(t, c, context.Background(), &CountRequest{To: 2})
+	require.Equal(t, []int32{1}, values)
+	twerr, ok := err.(twirp.Error)
+	require.True(t, ok)
+	require.Equal(t, twirp.Unavailable, twerr.Code())
+}
+
+func TestMethodConcurrency(t *testing.T) {
+	counter := &testCounter{done: make(chan error, 10)}
+	svr := httptest.NewServer(NewCounterTwirpServer(counter, WithTwirpServerMethodConcurrency(map[string]int{"Count": 1})))
+	defer svr.Close()
+
+	c, err := NewCounterTwirpClient(svr.URL, http.DefaultTransport)
+	require.NoError(t, err)
+
+	// keep the only slot of Count busy until ctx is canceled
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan struct{})
+	go func() {
+		_ = c.Count(ctx, &CountRequest{To: 2, IntervalMs: 60000}, func(*Number) error {
+			close(started)
+			return nil
+		})
+	}()
+	<-started
+
+	_, err = collect(t, c, context.Background(), &CountRequest{To: 1})
+	twerr, ok := err.(twirp.Error)
+	require.True(t, ok)
+	require.Equal(t, twirp.ResourceExhausted, twerr.Code())
+
+	// the limit is per method
+	n, err := c.Square(context.Background(), &Number{Value: 3})
+	require.NoError(t, err)
+	require.Equal(t, int32(9), n.Value)
+
+	cancel()
+	<-counter.done
+
+	// the slot is released once the call has ended
+	require.Eventually(t, func() bool {
+		_, err := collect(t, c, context.Background(), &CountRequest{To: 1})
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+}
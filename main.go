@@ -2,43 +2,205 @@ package main
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"embed"
+	"encoding/hex"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
 	"text/template"
 
+	"github.com/twitchtv/twirp"
 	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+
+	"github.com/bakins/protoc-gen-twirp-go/twirpgo"
 )
 
 //go:embed *.tmpl
 var templates embed.FS
 
+type generatorOptions struct {
+	GenerateBenchmarks bool
+	ErrorConstructors  bool
+	GenerateSlog       bool
+	GenerateStub       bool
+	// RequireUnimplemented requires implementations to embed Unimplemented<Service>TwirpService.
+	RequireUnimplemented bool
+	// PrometheusMetrics generates a server option that imports the Prometheus client library.
+	PrometheusMetrics   bool
+	GenerateTestHelpers bool
+	// FileSuffix is appended to the proto file name, without its extension, to name the service file.
+	FileSuffix string
+	// TaggedStructs generates wrapper structs with struct tags for method inputs and outputs.
+	TaggedStructs bool
+	// GenerateBuilders generates <Message>Builder types for method inputs.
+	GenerateBuilders bool
+	// Validate generates Validate<Message> functions for method inputs, which servers call for every request.
+	Validate bool
+	// FastCodec generates TwirpFastCodec, which encodes messages with only singular fields without reflection.
+	FastCodec bool
+	// StructTags lists the tag keys, separated by "+", set to the JSON name of each field.
+	StructTags string
+	// InternStrings generates a codec that interns the strings of decoded messages.
+	InternStrings bool
+	// GenerateExtendedClient generates <Method>WithStatus client methods that also return the HTTP status.
+	GenerateExtendedClient bool
+	// ConnectCompat makes servers also accept unary requests using the Connect protocol.
+	ConnectCompat bool
+	// GRPCCompat generates a function that registers implementations as gRPC services.
+	GRPCCompat bool
+	// GeneratePagination generates <Method>Pages client methods for paginated list methods.
+	GeneratePagination bool
+	// SSE generates server streaming methods that send their messages as Server-Sent Events.
+	SSE bool
+	// GenerateRedact generates TwirpRedact methods for messages with (twirpgo.pii) fields.
+	GenerateRedact bool
+	// Methods lists the names of the methods to generate, separated by "+". All methods are
+	// generated if it is empty.
+	Methods string
+	// GeneratePlayground generates a server option that serves an HTML page for sending JSON requests.
+	GeneratePlayground bool
+	// ClientBudgets generates a server option that limits the requests of each client with token buckets.
+	ClientBudgets bool
+	// ClientErrorRates generates a client option that tracks the error rate of each method.
+	ClientErrorRates bool
+}
+
+// includeMethod reports whether method is generated, as selected by the methods option.
+func (o generatorOptions) includeMethod(method *protogen.Method) bool {
+	if o.Methods == "" {
+		return true
+	}
+
+	for _, name := range strings.Split(o.Methods, "+") {
+		if name == string(method.Desc.Name()) {
+			return true
+		}
+	}
+
+	return false
+}
+
 func main() {
-	var flags flag.FlagSet
+	var (
+		flags flag.FlagSet
+		opts  generatorOptions
+	)
+
+	flags.BoolVar(&opts.GenerateBenchmarks, "generate_benchmarks", false, "generate benchmarks for each service")
+	flags.BoolVar(&opts.GenerateSlog, "generate_slog", false, "generate a log/slog server option, built only with Go 1.21 and later")
+	flags.BoolVar(&opts.GenerateStub, "generate_stub", false, "generate an Unimplemented<Service>TwirpService type for each service")
+	flags.BoolVar(&opts.RequireUnimplemented, "require_unimplemented", false, "require implementations to embed Unimplemented<Service>TwirpService")
+	flags.BoolVar(&opts.PrometheusMetrics, "prometheus_metrics", false, "generate a Prometheus metrics server option")
+	flags.BoolVar(&opts.GenerateTestHelpers, "generate_testhelpers", false, "generate Recording<Service>Client types, a cassette client option and an in-memory transport for tests")
+	flags.StringVar(&opts.FileSuffix, "file_suffix", "_twirp_service.pb.go", "suffix of the generated service file names")
+	flags.BoolVar(&opts.TaggedStructs, "tagged_structs", false, "generate wrapper structs with struct tags for method inputs and outputs")
+	flags.StringVar(&opts.StructTags, "struct_tags", "json", "tag keys, separated by +, used for tagged_structs")
+	flags.BoolVar(&opts.GenerateBuilders, "generate_builders", false, "generate <Message>Builder types for method inputs")
+	flags.BoolVar(&opts.Validate, "validate", false, "generate Validate<Message> functions for method inputs and call them in servers")
+	flags.BoolVar(&opts.FastCodec, "fast_codec", false, "generate an experimental codec that encodes messages with only singular fields without reflection")
+	flags.BoolVar(&opts.InternStrings, "intern_strings", false, "generate a codec that interns the strings of decoded messages")
+	flags.BoolVar(&opts.GenerateExtendedClient, "generate_extended_client", false, "generate <Method>WithStatus client methods that also return the HTTP status")
+	flags.BoolVar(&opts.ConnectCompat, "connect_compat", false, "make servers also accept unary requests using the Connect protocol")
+	flags.BoolVar(&opts.GeneratePagination, "generate_pagination", false, "generate <Method>Pages client methods that follow next_page_token")
+	flags.BoolVar(&opts.SSE, "sse", false, "generate server streaming methods that send their messages as Server-Sent Events")
+	flags.BoolVar(&opts.GenerateRedact, "generate_redact", false, "generate TwirpRedact methods that clear fields marked with (twirpgo.pii)")
+	flags.StringVar(&opts.Methods, "methods", "", "names of the only methods to generate, separated by +")
+	flags.BoolVar(&opts.GeneratePlayground, "generate_playground", false, "generate a server option that serves an HTML playground for sending JSON requests")
+	flags.BoolVar(&opts.ClientBudgets, "client_budgets", false, "generate a server option that limits the requests of each client")
+	flags.BoolVar(&opts.ClientErrorRates, "client_error_rates", false, "generate a client option that tracks the error rate of each method")
+	flags.BoolVar(&opts.GRPCCompat, "grpc_compat", false, "generate Register<Service>GRPCServer functions that import grpc-go")
+	flags.BoolVar(&opts.ErrorConstructors, "error_constructors", false, "generate constructors for enum values annotated with (twirpgo.error_kind)")
 
 	protogen.Options{
 		ParamFunc: flags.Set,
 	}.Run(func(gen *protogen.Plugin) error {
+		if !strings.HasSuffix(opts.FileSuffix, ".go") || strings.HasSuffix(opts.FileSuffix, "_test.go") {
+			return fmt.Errorf("invalid file_suffix %q: must end in .go and not _test.go", opts.FileSuffix)
+		}
+
+		if opts.RequireUnimplemented {
+			opts.GenerateStub = true
+		}
+
+		if opts.Methods != "" {
+			if err := checkMethods(gen, opts.Methods); err != nil {
+				return err
+			}
+		}
+
 		for _, f := range gen.Files {
 			if f.Generate {
-				generateFile(gen, f)
+				generateFile(gen, f, opts)
 			}
 		}
 		return nil
 	})
 }
 
+// checkMethods returns an error if a name in methods, separated by "+", is not the name of a
+// method of a service in the files to generate.
+func checkMethods(gen *protogen.Plugin, methods string) error {
+	known := map[string]bool{}
+	for _, f := range gen.Files {
+		if !f.Generate {
+			continue
+		}
+
+		for _, service := range f.Services {
+			for _, method := range service.Methods {
+				known[string(method.Desc.Name())] = true
+			}
+		}
+	}
+
+	for _, name := range strings.Split(methods, "+") {
+		if !known[name] {
+			return fmt.Errorf("invalid methods %q: no service has a method named %q", methods, name)
+		}
+	}
+
+	return nil
+}
+
 type templatePackage struct {
 	Name     string
 	Package  string
 	Services []templateService
+	Options  generatorOptions
+	// FileDescriptor is the variable holding the file descriptor generated by protoc-gen-go.
+	FileDescriptor string
 }
 
 type templateService struct {
 	Name    string
 	GoName  string
 	Methods []templateMethod
+	// StreamMethods lists the server streaming methods when the SSE option is set.
+	StreamMethods []templateMethod
+	// Versions lists the values of the (twirpgo.version) service option.
+	Versions []string
+	// Fingerprint is the schemaFingerprint of the service.
+	Fingerprint string
+}
+
+type templateErrors struct {
+	Package string
+	Errors  []templateError
+}
+
+type templateError struct {
+	Kind   string
+	GoName string
+	Code   string
 }
 
 type templateMethod struct {
@@ -46,6 +208,32 @@ type templateMethod struct {
 	GoName string
 	Input  string
 	Output string
+	// Idempotent is set for methods with an idempotency_level of IDEMPOTENT or NO_SIDE_EFFECTS.
+	Idempotent bool
+	// Cacheable is set for methods with the (twirpgo.cacheable) option.
+	Cacheable bool
+	// Auditable is set for methods with the (twirpgo.auditable) option.
+	Auditable bool
+	// Pagination is set for paginated list methods when GeneratePagination is set.
+	Pagination *templatePagination
+	// Doc is the leading comment of the method, set when GeneratePlayground is set.
+	Doc string
+	// Example is an example JSON request, set when GeneratePlayground is set.
+	Example string
+	// Validate is the name of the Validate<Message> function of the input, set when Validate is set.
+	Validate string
+}
+
+// templatePagination describes the fields of a paginated list method.
+type templatePagination struct {
+	// PageToken is the GoName of the page_token field of the input.
+	PageToken string
+	// NextPageToken is the GoName of the next_page_token field of the output.
+	NextPageToken string
+	// Items is the GoName of the repeated field of the output holding the items of a page.
+	Items string
+	// Item is the type of the items.
+	Item string
 }
 
 func exitError(err error) {
@@ -53,30 +241,986 @@ func exitError(err error) {
 	os.Exit(1)
 }
 
-func generateFile(gen *protogen.Plugin, file *protogen.File) {
+func generateFile(gen *protogen.Plugin, file *protogen.File, opts generatorOptions) {
+	if opts.ErrorConstructors {
+		generateErrors(gen, file)
+	}
+
+	generateItemErrors(gen, file)
+
+	if opts.GenerateRedact {
+		generateRedact(gen, file)
+	}
+
 	if len(file.Services) == 0 {
 		return
 	}
 
-	data, err := templates.ReadFile("twirp.go.tmpl")
-	if err != nil {
-		exitError(err)
+	filename := file.GeneratedFilenamePrefix + opts.FileSuffix
+	if !executeTemplate("twirp.go.tmpl", gen.NewGeneratedFile(filename, file.GoImportPath), file, opts) {
+		return
 	}
 
-	tmpl, err := template.New("twirp.go.tmpl").Parse(string(data))
-	if err != nil {
-		exitError(err)
+	if opts.GenerateBenchmarks {
+		filename := file.GeneratedFilenamePrefix + "_twirp_service_benchmark_test.go"
+		executeTemplate("twirp_benchmark_test.go.tmpl", gen.NewGeneratedFile(filename, file.GoImportPath), file, opts)
+	}
+
+	if opts.PrometheusMetrics {
+		filename := file.GeneratedFilenamePrefix + "_twirp_prometheus.pb.go"
+		executeTemplate("twirp_prometheus.go.tmpl", gen.NewGeneratedFile(filename, file.GoImportPath), file, opts)
+	}
+
+	if opts.GRPCCompat {
+		filename := file.GeneratedFilenamePrefix + "_twirp_grpc.pb.go"
+		executeTemplate("twirp_grpc.go.tmpl", gen.NewGeneratedFile(filename, file.GoImportPath), file, opts)
+	}
+
+	if opts.GenerateTestHelpers {
+		filename := file.GeneratedFilenamePrefix + "_twirp_testhelpers.pb.go"
+		executeTemplate("twirp_testhelpers.go.tmpl", gen.NewGeneratedFile(filename, file.GoImportPath), file, opts)
+	}
+
+	if opts.TaggedStructs {
+		generateTaggedStructs(gen, file, opts)
+	}
+
+	if opts.GenerateBuilders {
+		generateBuilders(gen, file, opts)
+	}
+
+	if opts.Validate {
+		generateValidate(gen, file, opts)
+	}
+
+	if opts.FastCodec {
+		generateFastCodec(gen, file, opts)
+	}
+
+	if opts.InternStrings {
+		filename := file.GeneratedFilenamePrefix + "_twirp_intern.pb.go"
+		executeTemplate("twirp_intern.go.tmpl", gen.NewGeneratedFile(filename, file.GoImportPath), file, opts)
+	}
+
+	if opts.GenerateSlog {
+		filename := file.GeneratedFilenamePrefix + "_twirp_slog.pb.go"
+		executeTemplate("twirp_slog.go.tmpl", gen.NewGeneratedFile(filename, file.GoImportPath), file, opts)
+	}
+}
+
+// twirpErrorCodes maps Twirp error codes to the name of the matching constant in the twirp package.
+var twirpErrorCodes = map[twirp.ErrorCode]string{
+	twirp.Canceled:           "Canceled",
+	twirp.Unknown:            "Unknown",
+	twirp.InvalidArgument:    "InvalidArgument",
+	twirp.Malformed:          "Malformed",
+	twirp.DeadlineExceeded:   "DeadlineExceeded",
+	twirp.NotFound:           "NotFound",
+	twirp.BadRoute:           "BadRoute",
+	twirp.AlreadyExists:      "AlreadyExists",
+	twirp.PermissionDenied:   "PermissionDenied",
+	twirp.Unauthenticated:    "Unauthenticated",
+	twirp.ResourceExhausted:  "ResourceExhausted",
+	twirp.FailedPrecondition: "FailedPrecondition",
+	twirp.Aborted:            "Aborted",
+	twirp.OutOfRange:         "OutOfRange",
+	twirp.Unimplemented:      "Unimplemented",
+	twirp.Internal:           "Internal",
+	twirp.Unavailable:        "Unavailable",
+	twirp.DataLoss:           "DataLoss",
+}
+
+func generateErrors(gen *protogen.Plugin, file *protogen.File) {
+	te := templateErrors{
+		Package: string(file.GoPackageName),
+	}
+
+	enums := append([]*protogen.Enum(nil), file.Enums...)
+	var collect func([]*protogen.Message)
+	collect = func(messages []*protogen.Message) {
+		for _, message := range messages {
+			enums = append(enums, message.Enums...)
+			collect(message.Messages)
+		}
+	}
+	collect(file.Messages)
+
+	for _, enum := range enums {
+		for _, value := range enum.Values {
+			kind, ok := proto.GetExtension(value.Desc.Options(), twirpgo.E_ErrorKind).(string)
+			if !ok || kind == "" {
+				continue
+			}
+
+			code, ok := twirpErrorCodes[twirp.ErrorCode(kind)]
+			if !ok {
+				exitError(fmt.Errorf("%s: invalid twirp error code %q", value.Desc.FullName(), kind))
+			}
+
+			te.Errors = append(te.Errors, templateError{
+				Kind:   string(value.Desc.Name()),
+				GoName: camelCase(string(value.Desc.Name())),
+				Code:   code,
+			})
+		}
+	}
+
+	if len(te.Errors) == 0 {
+		return
+	}
+
+	filename := file.GeneratedFilenamePrefix + "_twirp_errors.pb.go"
+	renderTemplate("twirp_errors.go.tmpl", gen.NewGeneratedFile(filename, file.GoImportPath), &te)
+}
+
+type templateItemErrors struct {
+	Package  string
+	Messages []templateItemErrorMessage
+}
+
+type templateItemErrorMessage struct {
+	GoName    string
+	Field     string
+	ItemError string
+}
+
+// generateItemErrors generates AddTwirpError and TwirpErrors methods for the messages of file
+// with a repeated twirpgo.ItemError field.
+func generateItemErrors(gen *protogen.Plugin, file *protogen.File) {
+	filename := file.GeneratedFilenamePrefix + "_twirp_item_errors.pb.go"
+	g := gen.NewGeneratedFile(filename, file.GoImportPath)
+
+	ti := templateItemErrors{
+		Package: string(file.GoPackageName),
+	}
+
+	var collect func([]*protogen.Message)
+	collect = func(messages []*protogen.Message) {
+		for _, message := range messages {
+			var fields []*protogen.Field
+			for _, field := range message.Fields {
+				if field.Desc.IsList() && field.Message != nil && field.Message.Desc.FullName() == "twirpgo.ItemError" {
+					fields = append(fields, field)
+				}
+			}
+
+			switch len(fields) {
+			case 0:
+			case 1:
+				ti.Messages = append(ti.Messages, templateItemErrorMessage{
+					GoName:    message.GoIdent.GoName,
+					Field:     fields[0].GoName,
+					ItemError: g.QualifiedGoIdent(fields[0].Message.GoIdent),
+				})
+			default:
+				exitError(fmt.Errorf("%s: only one repeated twirpgo.ItemError field is allowed", message.Desc.FullName()))
+			}
+
+			collect(message.Messages)
+		}
+	}
+	collect(file.Messages)
+
+	if len(ti.Messages) == 0 {
+		g.Skip()
+		return
 	}
 
-	_ = tmpl
-	filename := file.GeneratedFilenamePrefix + "_twirp_service.pb.go"
+	renderTemplate("twirp_item_errors.go.tmpl", g, &ti)
+}
+
+type templateRedact struct {
+	Package  string
+	Messages []templateRedactMessage
+}
+
+type templateRedactMessage struct {
+	GoName string
+	// Fields lists the (twirpgo.pii) fields, which are cleared.
+	Fields []templateRedactField
+	// Nested lists the message fields whose messages have fields to redact.
+	Nested []templateRedactField
+}
+
+type templateRedactField struct {
+	Name   string
+	Number int32
+	GoName string
+	// Repeated is set for lists and maps.
+	Repeated bool
+}
+
+// generateRedact generates TwirpRedact methods for the messages of file with (twirpgo.pii)
+// fields, or with message fields, of a message in file, that have them.
+func generateRedact(gen *protogen.Plugin, file *protogen.File) {
+	filename := file.GeneratedFilenamePrefix + "_twirp_redact.pb.go"
 	g := gen.NewGeneratedFile(filename, file.GoImportPath)
 
-	_ = g
+	var messages []*protogen.Message
+	var collect func([]*protogen.Message)
+	collect = func(ms []*protogen.Message) {
+		for _, message := range ms {
+			// map entries are redacted through the field of their map
+			if !message.Desc.IsMapEntry() {
+				messages = append(messages, message)
+			}
+			collect(message.Messages)
+		}
+	}
+	collect(file.Messages)
+
+	// the value of a map field is its entry's second field
+	fieldMessage := func(field *protogen.Field) *protogen.Message {
+		if field.Desc.IsMap() {
+			return field.Message.Fields[1].Message
+		}
+		return field.Message
+	}
 
+	// messages need redacting if they have pii fields or message fields, of messages in file,
+	// that need redacting, which is repeated until nothing changes to cover recursive messages
+	redact := map[*protogen.Message]bool{}
+	for changed := true; changed; {
+		changed = false
+		for _, message := range messages {
+			if redact[message] {
+				continue
+			}
+
+			for _, field := range message.Fields {
+				pii, _ := proto.GetExtension(field.Desc.Options(), twirpgo.E_Pii).(bool)
+				if pii || redact[fieldMessage(field)] {
+					redact[message] = true
+					changed = true
+					break
+				}
+			}
+		}
+	}
+
+	tr := templateRedact{
+		Package: string(file.GoPackageName),
+	}
+
+	for _, message := range messages {
+		if !redact[message] {
+			continue
+		}
+
+		m := templateRedactMessage{GoName: message.GoIdent.GoName}
+		for _, field := range message.Fields {
+			f := templateRedactField{
+				Name:     string(field.Desc.Name()),
+				Number:   int32(field.Desc.Number()),
+				GoName:   field.GoName,
+				Repeated: field.Desc.IsList() || field.Desc.IsMap(),
+			}
+
+			if pii, _ := proto.GetExtension(field.Desc.Options(), twirpgo.E_Pii).(bool); pii {
+				m.Fields = append(m.Fields, f)
+				continue
+			}
+
+			if redact[fieldMessage(field)] {
+				m.Nested = append(m.Nested, f)
+			}
+		}
+
+		tr.Messages = append(tr.Messages, m)
+	}
+
+	if len(tr.Messages) == 0 {
+		g.Skip()
+		return
+	}
+
+	renderTemplate("twirp_redact.go.tmpl", g, &tr)
+}
+
+type templateTagged struct {
+	Package string
+	Structs []templateTaggedStruct
+}
+
+type templateTaggedStruct struct {
+	Name    string
+	Message string
+	Fields  []templateTaggedField
+}
+
+type templateTaggedField struct {
+	GoName string
+	Type   string
+	Tag    string
+}
+
+func generateTaggedStructs(gen *protogen.Plugin, file *protogen.File, opts generatorOptions) {
+	filename := file.GeneratedFilenamePrefix + "_twirp_tagged.pb.go"
+	g := gen.NewGeneratedFile(filename, file.GoImportPath)
+
+	tt := templateTagged{
+		Package: string(file.GoPackageName),
+	}
+
+	seen := map[protoreflect.FullName]bool{}
+	for _, service := range file.Services {
+		for _, method := range service.Methods {
+			if !opts.includeMethod(method) {
+				continue
+			}
+
+			for _, message := range []*protogen.Message{method.Input, method.Output} {
+				if seen[message.Desc.FullName()] {
+					continue
+				}
+				seen[message.Desc.FullName()] = true
+
+				tt.Structs = append(tt.Structs, newTaggedStruct(g, message, strings.Split(opts.StructTags, "+")))
+			}
+		}
+	}
+
+	if len(tt.Structs) == 0 {
+		g.Skip()
+		return
+	}
+
+	renderTemplate("twirp_tagged.go.tmpl", g, &tt)
+}
+
+func newTaggedStruct(g *protogen.GeneratedFile, message *protogen.Message, keys []string) templateTaggedStruct {
+	ts := templateTaggedStruct{
+		Name:    message.GoIdent.GoName + "Tagged",
+		Message: g.QualifiedGoIdent(message.GoIdent),
+	}
+
+	for _, field := range message.Fields {
+		// oneof fields are stored in wrapper types, so they have no plain struct field to copy
+		if field.Oneof != nil && !field.Oneof.Desc.IsSynthetic() {
+			continue
+		}
+
+		var tags []string
+		for _, key := range keys {
+			if key != "" {
+				tags = append(tags, fmt.Sprintf("%s:%q", key, field.Desc.JSONName()))
+			}
+		}
+
+		if extra, ok := proto.GetExtension(field.Desc.Options(), twirpgo.E_Tags).(string); ok && extra != "" {
+			tags = append(tags, extra)
+		}
+
+		ts.Fields = append(ts.Fields, templateTaggedField{
+			GoName: field.GoName,
+			Type:   fieldGoType(g, field),
+			Tag:    strings.Join(tags, " "),
+		})
+	}
+
+	return ts
+}
+
+type templateBuilders struct {
+	Package string
+	// Clone is the qualified name of proto.Clone.
+	Clone    string
+	Builders []templateBuilder
+}
+
+type templateBuilder struct {
+	Name    string
+	Message string
+	Fields  []templateBuilderField
+}
+
+type templateBuilderField struct {
+	Name   string
+	GoName string
+	// Type is the type of the value passed to the setter: the element type of lists, and the
+	// type without the pointer of fields with presence.
+	Type string
+	List bool
+	Map  bool
+	// Pointer is set for scalar fields with presence, which are stored as pointers.
+	Pointer bool
+	// Oneof and Wrapper are the struct field and the wrapper type of fields in a oneof.
+	Oneof   string
+	Wrapper string
+	Key     string
+	Value   string
+}
+
+func generateBuilders(gen *protogen.Plugin, file *protogen.File, opts generatorOptions) {
+	filename := file.GeneratedFilenamePrefix + "_twirp_builders.pb.go"
+	g := gen.NewGeneratedFile(filename, file.GoImportPath)
+
+	tb := templateBuilders{
+		Package: string(file.GoPackageName),
+		Clone:   g.QualifiedGoIdent(protogen.GoImportPath("google.golang.org/protobuf/proto").Ident("Clone")),
+	}
+
+	seen := map[protoreflect.FullName]bool{}
+	for _, service := range file.Services {
+		for _, method := range service.Methods {
+			if !opts.includeMethod(method) || seen[method.Input.Desc.FullName()] {
+				continue
+			}
+			seen[method.Input.Desc.FullName()] = true
+
+			tb.Builders = append(tb.Builders, newBuilder(g, method.Input))
+		}
+	}
+
+	if len(tb.Builders) == 0 {
+		g.Skip()
+		return
+	}
+
+	renderTemplate("twirp_builders.go.tmpl", g, &tb)
+}
+
+func newBuilder(g *protogen.GeneratedFile, message *protogen.Message) templateBuilder {
+	b := templateBuilder{
+		Name:    message.GoIdent.GoName + "Builder",
+		Message: g.QualifiedGoIdent(message.GoIdent),
+	}
+
+	for _, field := range message.Fields {
+		f := templateBuilderField{
+			Name:   string(field.Desc.Name()),
+			GoName: field.GoName,
+			Type:   fieldGoType(g, field),
+		}
+
+		switch {
+		case field.Desc.IsMap():
+			f.Map = true
+			f.Key = fieldGoType(g, field.Message.Fields[0])
+			f.Value = fieldGoType(g, field.Message.Fields[1])
+		case field.Desc.IsList():
+			f.List = true
+			f.Type = strings.TrimPrefix(f.Type, "[]")
+		case field.Oneof != nil && !field.Oneof.Desc.IsSynthetic():
+			f.Oneof = field.Oneof.GoName
+			f.Wrapper = g.QualifiedGoIdent(field.GoIdent)
+			if kind := field.Desc.Kind(); kind != protoreflect.MessageKind && kind != protoreflect.GroupKind {
+				f.Type = strings.TrimPrefix(f.Type, "*")
+			}
+		case strings.HasPrefix(f.Type, "*") && field.Message == nil:
+			f.Pointer = true
+			f.Type = strings.TrimPrefix(f.Type, "*")
+		}
+
+		b.Fields = append(b.Fields, f)
+	}
+
+	return b
+}
+
+type templateFastCodec struct {
+	Package string
+	// Roots are the method inputs and outputs that TwirpFastCodec encodes with generated functions.
+	Roots    []templateFastMessage
+	Messages []templateFastMessage
+	// ValidateUTF8 is set if a message has proto3 string fields, which must be valid UTF-8.
+	ValidateUTF8 bool
+}
+
+type templateFastMessage struct {
+	// Name is the full name of the message with dots replaced by underscores, which suffixes
+	// the names of its functions.
+	Name   string
+	Type   string
+	Fields []templateFastField
+}
+
+type templateFastField struct {
+	Number protoreflect.FieldNumber
+	// Tag is the encoded field number and wire type, and TagSize its size in bytes.
+	Tag     uint64
+	TagSize int
+	// Wire is the wire type: Varint, Fixed32, Fixed64, Bytes, or Message.
+	Wire string
+	// Field is the struct field, such as m.Size.
+	Field string
+	// Present is the condition under which the field is encoded.
+	Present string
+	// Encode converts the value of the field to the argument of the protowire append function.
+	Encode string
+	// Decode converts v, the value consumed by the protowire function, to the type of the field.
+	Decode  string
+	Pointer bool
+	String  bool
+	// ValidString and Valid are the qualified utf8 functions for proto3 string fields.
+	ValidString string
+	Valid       string
+	// Message and MessageType are the name and type of the message of message fields.
+	Message     string
+	MessageType string
+}
+
+func generateFastCodec(gen *protogen.Plugin, file *protogen.File, opts generatorOptions) {
+	filename := file.GeneratedFilenamePrefix + "_twirp_fastcodec.pb.go"
+	g := gen.NewGeneratedFile(filename, file.GoImportPath)
+
+	tf := templateFastCodec{
+		Package: string(file.GoPackageName),
+	}
+
+	eligible := map[protoreflect.FullName]bool{}
+	var messages []*protogen.Message
+	seen := map[protoreflect.FullName]bool{}
+
+	for _, service := range file.Services {
+		for _, method := range service.Methods {
+			if !opts.includeMethod(method) {
+				continue
+			}
+
+			for _, message := range []*protogen.Message{method.Input, method.Output} {
+				if seen[message.Desc.FullName()] || !fastCodecEligible(message, eligible, &messages) {
+					continue
+				}
+				seen[message.Desc.FullName()] = true
+
+				tf.Roots = append(tf.Roots, templateFastMessage{
+					Name: fastCodecName(message),
+					Type: g.QualifiedGoIdent(message.GoIdent),
+				})
+			}
+		}
+	}
+
+	if len(tf.Roots) == 0 {
+		g.Skip()
+		return
+	}
+
+	for _, message := range messages {
+		tm := newFastMessage(g, message)
+		for _, field := range tm.Fields {
+			if field.Valid != "" {
+				tf.ValidateUTF8 = true
+			}
+		}
+
+		tf.Messages = append(tf.Messages, tm)
+	}
+
+	renderTemplate("twirp_fastcodec.go.tmpl", g, &tf)
+}
+
+// fastCodecEligible reports whether the fast codec can encode message: all of its fields are
+// singular fields outside of oneofs, and the fields holding messages hold eligible messages.
+// Messages that contain themselves, have required fields, or have extensions are not eligible.
+// Eligible messages are appended to messages after the messages they contain.
+func fastCodecEligible(message *protogen.Message, eligible map[protoreflect.FullName]bool, messages *[]*protogen.Message) bool {
+	name := message.Desc.FullName()
+	if ok, found := eligible[name]; found {
+		return ok
+	}
+
+	// a message seen again before it is decided contains itself
+	eligible[name] = false
+
+	if message.Desc.IsMapEntry() || message.Desc.ExtensionRanges().Len() > 0 {
+		return false
+	}
+
+	for _, field := range message.Fields {
+		switch {
+		case field.Desc.IsList(), field.Desc.IsMap(), field.Desc.IsWeak():
+			return false
+		case field.Desc.Cardinality() == protoreflect.Required, field.Desc.Kind() == protoreflect.GroupKind:
+			return false
+		case field.Oneof != nil && !field.Oneof.Desc.IsSynthetic():
+			return false
+		case field.Message != nil && !fastCodecEligible(field.Message, eligible, messages):
+			return false
+		}
+	}
+
+	eligible[name] = true
+	*messages = append(*messages, message)
+
+	return true
+}
+
+func fastCodecName(message *protogen.Message) string {
+	return strings.ReplaceAll(string(message.Desc.FullName()), ".", "_")
+}
+
+func newFastMessage(g *protogen.GeneratedFile, message *protogen.Message) templateFastMessage {
+	tm := templateFastMessage{
+		Name: fastCodecName(message),
+		Type: g.QualifiedGoIdent(message.GoIdent),
+	}
+
+	fields := append([]*protogen.Field(nil), message.Fields...)
+	// protobuf encodes fields in the order of their numbers
+	sort.Slice(fields, func(i, j int) bool {
+		return fields[i].Desc.Number() < fields[j].Desc.Number()
+	})
+
+	for _, field := range fields {
+		tm.Fields = append(tm.Fields, newFastField(g, field))
+	}
+
+	return tm
+}
+
+func newFastField(g *protogen.GeneratedFile, field *protogen.Field) templateFastField {
+	math := func(name string) string {
+		return g.QualifiedGoIdent(protogen.GoImportPath("math").Ident(name))
+	}
+
+	goType := fieldGoType(g, field)
+	f := templateFastField{
+		Number:  field.Desc.Number(),
+		Field:   "m." + field.GoName,
+		Pointer: strings.HasPrefix(goType, "*") && field.Message == nil,
+	}
+
+	value := f.Field
+	if f.Pointer {
+		value = "*" + f.Field
+		goType = strings.TrimPrefix(goType, "*")
+	}
+
+	var wireType protowire.Type
+
+	switch field.Desc.Kind() {
+	case protoreflect.BoolKind:
+		wireType, f.Encode, f.Decode = protowire.VarintType, "protowire.EncodeBool("+value+")", "protowire.DecodeBool(v)"
+	case protoreflect.EnumKind, protoreflect.Int32Kind, protoreflect.Int64Kind, protoreflect.Uint32Kind, protoreflect.Uint64Kind:
+		wireType, f.Encode, f.Decode = protowire.VarintType, "uint64("+value+")", goType+"(v)"
+	case protoreflect.Sint32Kind:
+		wireType, f.Encode, f.Decode = protowire.VarintType, "protowire.EncodeZigZag(int64("+value+"))", "int32(protowire.DecodeZigZag(v & "+math("MaxUint32")+"))"
+	case protoreflect.Sint64Kind:
+		wireType, f.Encode, f.Decode = protowire.VarintType, "protowire.EncodeZigZag("+value+")", "protowire.DecodeZigZag(v)"
+	case protoreflect.Fixed32Kind, protoreflect.Sfixed32Kind:
+		wireType, f.Encode, f.Decode = protowire.Fixed32Type, "uint32("+value+")", goType+"(v)"
+	case protoreflect.FloatKind:
+		wireType, f.Encode, f.Decode = protowire.Fixed32Type, math("Float32bits")+"("+value+")", math("Float32frombits")+"(v)"
+	case protoreflect.Fixed64Kind, protoreflect.Sfixed64Kind:
+		wireType, f.Encode, f.Decode = protowire.Fixed64Type, "uint64("+value+")", goType+"(v)"
+	case protoreflect.DoubleKind:
+		wireType, f.Encode, f.Decode = protowire.Fixed64Type, math("Float64bits")+"("+value+")", math("Float64frombits")+"(v)"
+	case protoreflect.StringKind:
+		wireType, f.Encode, f.Decode = protowire.BytesType, value, "string(v)"
+		f.String = true
+		if field.Desc.Syntax() == protoreflect.Proto3 {
+			f.ValidString = g.QualifiedGoIdent(protogen.GoImportPath("unicode/utf8").Ident("ValidString"))
+			f.Valid = g.QualifiedGoIdent(protogen.GoImportPath("unicode/utf8").Ident("Valid"))
+		}
+	case protoreflect.BytesKind:
+		wireType, f.Encode, f.Decode = protowire.BytesType, value, "append([]byte{}, v...)"
+	case protoreflect.MessageKind:
+		wireType, f.Encode = protowire.BytesType, value
+		f.Message = fastCodecName(field.Message)
+		f.MessageType = g.QualifiedGoIdent(field.Message.GoIdent)
+	}
+
+	switch wireType {
+	case protowire.VarintType:
+		f.Wire = "Varint"
+	case protowire.Fixed32Type:
+		f.Wire = "Fixed32"
+	case protowire.Fixed64Type:
+		f.Wire = "Fixed64"
+	case protowire.BytesType:
+		f.Wire = "Bytes"
+		if f.Message != "" {
+			f.Wire = "Message"
+		}
+	}
+
+	f.Tag = protowire.EncodeTag(field.Desc.Number(), wireType)
+	f.TagSize = protowire.SizeVarint(f.Tag)
+
+	// fields with presence are encoded when set, and other fields when they are not the zero value,
+	// comparing the bits of floats so that -0 is encoded
+	switch {
+	case f.Pointer || field.Message != nil || field.Desc.Kind() == protoreflect.BytesKind && field.Desc.HasPresence():
+		f.Present = f.Field + " != nil"
+	case field.Desc.Kind() == protoreflect.BoolKind:
+		f.Present = f.Field
+	case field.Desc.Kind() == protoreflect.StringKind || field.Desc.Kind() == protoreflect.BytesKind:
+		f.Present = "len(" + f.Field + ") > 0"
+	case field.Desc.Kind() == protoreflect.FloatKind || field.Desc.Kind() == protoreflect.DoubleKind:
+		f.Present = f.Encode + " != 0"
+	default:
+		f.Present = f.Field + " != 0"
+	}
+
+	return f
+}
+
+type templateValidate struct {
+	Package  string
+	Roots    []templateValidateRoot
+	Messages []templateValidateMessage
+}
+
+type templateValidateRoot struct {
+	// Func is the name of the exported function, Validate<Message>.
+	Func string
+	Type string
+	// Message names the function that checks the message, or is empty if it has nothing to check.
+	Message string
+}
+
+type templateValidateMessage struct {
+	// Name is the full name of the message with dots replaced by underscores.
+	Name   string
+	Type   string
+	Fields []templateValidateField
+}
+
+type templateValidateField struct {
+	// Name is the name of the field in the paths of errors.
+	Name string
+	// Field is the struct field, such as m.Size, checked to be set for required fields.
+	Field    string
+	Getter   string
+	Required bool
+	List     bool
+	Map      bool
+	// KeyUTF8 is set for maps with string keys that must be valid UTF-8.
+	KeyUTF8 bool
+	// Value is the expression of the value checked: the getter of singular fields, and v, the
+	// element or map value, of the others. Path is the expression of its path in errors.
+	Value string
+	Path  string
+	// Enum, UTF8 and Message are the checks of the value: a defined enum value, valid UTF-8, and a
+	// message checked by the function named by Message.
+	Enum    bool
+	UTF8    bool
+	Message string
+}
+
+func generateValidate(gen *protogen.Plugin, file *protogen.File, opts generatorOptions) {
+	filename := file.GeneratedFilenamePrefix + "_twirp_validate.pb.go"
+	g := gen.NewGeneratedFile(filename, file.GoImportPath)
+
+	tv := templateValidate{
+		Package: string(file.GoPackageName),
+	}
+
+	var roots, messages []*protogen.Message
+	seen := map[protoreflect.FullName]bool{}
+	for _, service := range file.Services {
+		for _, method := range service.Methods {
+			if !opts.includeMethod(method) || seen[method.Input.Desc.FullName()] {
+				continue
+			}
+			seen[method.Input.Desc.FullName()] = true
+
+			roots = append(roots, method.Input)
+		}
+	}
+
+	if len(roots) == 0 {
+		g.Skip()
+		return
+	}
+
+	reached := map[protoreflect.FullName]bool{}
+	for _, message := range roots {
+		messages = reachMessages(message, reached, messages)
+	}
+
+	// a message is checked if it has fields to check, or holds messages that are checked, which
+	// is repeated until no more are found since messages may contain each other
+	checked := map[protoreflect.FullName]bool{}
+	for changed := true; changed; {
+		changed = false
+		for _, message := range messages {
+			if checked[message.Desc.FullName()] {
+				continue
+			}
+
+			for _, field := range message.Fields {
+				if f := newValidateField(g, field, checked); f != nil {
+					checked[message.Desc.FullName()] = true
+					changed = true
+					break
+				}
+			}
+		}
+	}
+
+	for _, message := range messages {
+		if !checked[message.Desc.FullName()] {
+			continue
+		}
+
+		tm := templateValidateMessage{
+			Name: fastCodecName(message),
+			Type: g.QualifiedGoIdent(message.GoIdent),
+		}
+
+		for _, field := range message.Fields {
+			if f := newValidateField(g, field, checked); f != nil {
+				tm.Fields = append(tm.Fields, *f)
+			}
+		}
+
+		tv.Messages = append(tv.Messages, tm)
+	}
+
+	for _, message := range roots {
+		root := templateValidateRoot{
+			Func: validateFunc(message),
+			Type: g.QualifiedGoIdent(message.GoIdent),
+		}
+
+		if checked[message.Desc.FullName()] {
+			root.Message = fastCodecName(message)
+		}
+
+		tv.Roots = append(tv.Roots, root)
+	}
+
+	renderTemplate("twirp_validate.go.tmpl", g, &tv)
+}
+
+// validateFunc returns the name of the exported function that validates message.
+func validateFunc(message *protogen.Message) string {
+	return "Validate" + message.GoIdent.GoName
+}
+
+// reachMessages appends message, and the messages its fields hold, to messages if they are not reached yet.
+func reachMessages(message *protogen.Message, reached map[protoreflect.FullName]bool, messages []*protogen.Message) []*protogen.Message {
+	if reached[message.Desc.FullName()] {
+		return messages
+	}
+	reached[message.Desc.FullName()] = true
+
+	if !message.Desc.IsMapEntry() {
+		messages = append(messages, message)
+	}
+
+	for _, field := range message.Fields {
+		if field.Message != nil {
+			messages = reachMessages(field.Message, reached, messages)
+		}
+	}
+
+	return messages
+}
+
+// newValidateField returns the checks of field, or nil if it has none. Messages are only
+// checked if they are in checked.
+func newValidateField(g *protogen.GeneratedFile, field *protogen.Field, checked map[protoreflect.FullName]bool) *templateValidateField {
+	name := string(field.Desc.Name())
+	f := templateValidateField{
+		Name:     name,
+		Field:    "m." + field.GoName,
+		Getter:   "m.Get" + field.GoName + "()",
+		Required: field.Desc.Cardinality() == protoreflect.Required,
+		Value:    "m.Get" + field.GoName + "()",
+		Path:     strconv.Quote(name),
+	}
+
+	value := field
+	switch {
+	case field.Desc.IsMap():
+		f.Map = true
+		f.KeyUTF8 = validateUTF8(field.Message.Fields[0])
+		f.Value = "v"
+		f.Path = "twirpValidateKey(" + strconv.Quote(name) + ", k)"
+		value = field.Message.Fields[1]
+	case field.Desc.IsList():
+		f.List = true
+		f.Value = "v"
+		f.Path = "twirpValidateIndex(" + strconv.Quote(name) + ", i)"
+	}
+
+	f.Enum = value.Desc.Kind() == protoreflect.EnumKind
+	f.UTF8 = validateUTF8(value)
+	if value.Message != nil && checked[value.Message.Desc.FullName()] {
+		f.Message = fastCodecName(value.Message)
+	}
+
+	if !f.Required && !f.KeyUTF8 && !f.Enum && !f.UTF8 && f.Message == "" {
+		return nil
+	}
+
+	return &f
+}
+
+// validateUTF8 reports whether field is a proto3 string field, which must be valid UTF-8.
+func validateUTF8(field *protogen.Field) bool {
+	return field.Desc.Kind() == protoreflect.StringKind && field.Desc.Syntax() == protoreflect.Proto3
+}
+
+// fieldGoType returns the type protoc-gen-go uses for the struct field of field.
+func fieldGoType(g *protogen.GeneratedFile, field *protogen.Field) string {
+	if field.Desc.IsMap() {
+		return "map[" + fieldGoType(g, field.Message.Fields[0]) + "]" + fieldGoType(g, field.Message.Fields[1])
+	}
+
+	var goType string
+	pointer := field.Desc.HasPresence()
+
+	switch field.Desc.Kind() {
+	case protoreflect.BoolKind:
+		goType = "bool"
+	case protoreflect.EnumKind:
+		goType = g.QualifiedGoIdent(field.Enum.GoIdent)
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		goType = "int32"
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		goType = "uint32"
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		goType = "int64"
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		goType = "uint64"
+	case protoreflect.FloatKind:
+		goType = "float32"
+	case protoreflect.DoubleKind:
+		goType = "float64"
+	case protoreflect.StringKind:
+		goType = "string"
+	case protoreflect.BytesKind:
+		goType = "[]byte"
+		pointer = false
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		goType = "*" + g.QualifiedGoIdent(field.Message.GoIdent)
+		pointer = false
+	}
+
+	switch {
+	case field.Desc.IsList():
+		return "[]" + goType
+	case pointer:
+		return "*" + goType
+	}
+
+	return goType
+}
+
+// camelCase converts an enum value name such as HAT_TOO_SMALL to HatTooSmall.
+func camelCase(s string) string {
+	parts := strings.Split(strings.ToLower(s), "_")
+	for i, part := range parts {
+		if part != "" {
+			parts[i] = strings.ToUpper(part[:1]) + part[1:]
+		}
+	}
+
+	return strings.Join(parts, "")
+}
+
+func newTemplatePackage(g *protogen.GeneratedFile, file *protogen.File, opts generatorOptions) templatePackage {
 	tp := templatePackage{
 		Name:    string(file.Desc.FullName()),
 		Package: string(file.GoPackageName),
+		Options: opts,
+
+		FileDescriptor: g.QualifiedGoIdent(file.GoDescriptorIdent),
 	}
 
 	for _, service := range file.Services {
@@ -85,11 +1229,20 @@ func generateFile(gen *protogen.Plugin, file *protogen.File) {
 		}
 
 		s := templateService{
-			Name:   string(service.Desc.Name()),
-			GoName: service.GoName,
+			Name:        string(service.Desc.Name()),
+			GoName:      service.GoName,
+			Fingerprint: schemaFingerprint(service.Desc),
+		}
+
+		if versions, ok := proto.GetExtension(service.Desc.Options(), twirpgo.E_Version).([]string); ok {
+			s.Versions = versions
 		}
 
 		for _, method := range service.Methods {
+			if !opts.includeMethod(method) {
+				continue
+			}
+
 			m := templateMethod{
 				Name:   string(method.Desc.Name()),
 				GoName: method.GoName,
@@ -97,18 +1250,290 @@ func generateFile(gen *protogen.Plugin, file *protogen.File) {
 				Output: g.QualifiedGoIdent(method.Output.GoIdent),
 			}
 
+			if opts.Validate {
+				m.Validate = validateFunc(method.Input)
+			}
+
+			if options, ok := method.Desc.Options().(*descriptorpb.MethodOptions); ok {
+				m.Idempotent = options.GetIdempotencyLevel() != descriptorpb.MethodOptions_IDEMPOTENCY_UNKNOWN
+			}
+
+			if cacheable, ok := proto.GetExtension(method.Desc.Options(), twirpgo.E_Cacheable).(bool); ok {
+				m.Cacheable = cacheable
+			}
+
+			if auditable, ok := proto.GetExtension(method.Desc.Options(), twirpgo.E_Auditable).(bool); ok {
+				m.Auditable = auditable
+			}
+
+			if opts.GeneratePagination {
+				m.Pagination = newTemplatePagination(g, method)
+			}
+
+			if opts.GeneratePlayground {
+				m.Doc = strings.TrimSpace(string(method.Comments.Leading))
+				m.Example = playgroundExample(method.Input)
+			}
+
+			if opts.GRPCCompat && !opts.SSE && (method.Desc.IsStreamingClient() || method.Desc.IsStreamingServer()) {
+				exitError(fmt.Errorf("%s: streaming methods are only supported with grpc_compat when sse is set", method.Desc.FullName()))
+			}
+
+			if opts.SSE && method.Desc.IsStreamingClient() {
+				exitError(fmt.Errorf("%s: only server streaming methods are supported with sse", method.Desc.FullName()))
+			}
+
+			if opts.SSE && method.Desc.IsStreamingServer() {
+				s.StreamMethods = append(s.StreamMethods, m)
+				continue
+			}
+
 			s.Methods = append(s.Methods, m)
 		}
 
+		if len(s.Methods) == 0 && len(s.StreamMethods) == 0 {
+			continue
+		}
+
 		tp.Services = append(tp.Services, s)
 	}
 
-	if len(tp.Services) == 0 {
+	return tp
+}
+
+// schemaFingerprint returns a hash of the wire schema of service: its methods, and the fields of
+// the messages and the values of the enums they use, directly or through other messages. It
+// only depends on the descriptors, so it is the same in every build of the same schema, and
+// comments and options, which do not change how messages are encoded, do not change it.
+func schemaFingerprint(service protoreflect.ServiceDescriptor) string {
+	var lines []string
+	seen := map[protoreflect.FullName]bool{}
+
+	var addEnum func(protoreflect.EnumDescriptor)
+	addEnum = func(enum protoreflect.EnumDescriptor) {
+		if seen[enum.FullName()] {
+			return
+		}
+		seen[enum.FullName()] = true
+
+		values := enum.Values()
+		for i := 0; i < values.Len(); i++ {
+			value := values.Get(i)
+			lines = append(lines, fmt.Sprintf("enum %s %s=%d", enum.FullName(), value.Name(), value.Number()))
+		}
+	}
+
+	var addMessage func(protoreflect.MessageDescriptor)
+	addMessage = func(message protoreflect.MessageDescriptor) {
+		if seen[message.FullName()] {
+			return
+		}
+		seen[message.FullName()] = true
+
+		lines = append(lines, fmt.Sprintf("message %s", message.FullName()))
+
+		fields := message.Fields()
+		for i := 0; i < fields.Len(); i++ {
+			field := fields.Get(i)
+
+			var typeName protoreflect.FullName
+			switch {
+			case field.Message() != nil:
+				typeName = field.Message().FullName()
+				addMessage(field.Message())
+			case field.Enum() != nil:
+				typeName = field.Enum().FullName()
+				addEnum(field.Enum())
+			}
+
+			var oneof protoreflect.Name
+			if field.ContainingOneof() != nil {
+				oneof = field.ContainingOneof().Name()
+			}
+
+			lines = append(lines, fmt.Sprintf("field %s %d %s %s %s %s %s %s", message.FullName(), field.Number(), field.Name(),
+				field.JSONName(), field.Cardinality(), field.Kind(), typeName, oneof))
+		}
+	}
+
+	methods := service.Methods()
+	for i := 0; i < methods.Len(); i++ {
+		method := methods.Get(i)
+		lines = append(lines, fmt.Sprintf("method %s %s %s %t %t", method.Name(), method.Input().FullName(),
+			method.Output().FullName(), method.IsStreamingClient(), method.IsStreamingServer()))
+		addMessage(method.Input())
+		addMessage(method.Output())
+	}
+
+	sort.Strings(lines)
+
+	hash := sha256.New()
+	for _, line := range lines {
+		hash.Write([]byte(line + "\n"))
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)[:16])
+}
+
+// newTemplatePagination returns the pagination fields of method, or nil if it is not a paginated
+// list method. By convention, these have a string page_token field in the input, and a string
+// next_page_token field and exactly one repeated message field, holding the items, in the output.
+func newTemplatePagination(g *protogen.GeneratedFile, method *protogen.Method) *templatePagination {
+	pageToken := method.Input.Desc.Fields().ByName("page_token")
+	nextPageToken := method.Output.Desc.Fields().ByName("next_page_token")
+	if pageToken == nil || nextPageToken == nil ||
+		pageToken.Kind() != protoreflect.StringKind || pageToken.Cardinality() == protoreflect.Repeated ||
+		nextPageToken.Kind() != protoreflect.StringKind || nextPageToken.Cardinality() == protoreflect.Repeated {
+		return nil
+	}
+
+	tp := templatePagination{}
+	for _, field := range method.Input.Fields {
+		if field.Desc == pageToken {
+			tp.PageToken = field.GoName
+		}
+	}
+
+	for _, field := range method.Output.Fields {
+		switch {
+		case field.Desc == nextPageToken:
+			tp.NextPageToken = field.GoName
+		case field.Desc.IsList() && field.Message != nil:
+			if tp.Items != "" {
+				return nil
+			}
+			tp.Items = field.GoName
+			tp.Item = g.QualifiedGoIdent(field.Message.GoIdent)
+		}
+	}
+
+	if tp.Items == "" {
+		return nil
+	}
+
+	return &tp
+}
+
+// playgroundExample returns an example JSON request for message, with a zero value for each
+// field, indented for editing.
+func playgroundExample(message *protogen.Message) string {
+	var buff bytes.Buffer
+	writeExampleMessage(&buff, message, map[*protogen.Message]bool{})
+
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, buff.Bytes(), "", "  "); err != nil {
+		exitError(fmt.Errorf("%s: invalid example request: %w", message.Desc.FullName(), err))
+	}
+
+	return indented.String()
+}
+
+// wellKnownExamples are the JSON values of the well-known types that do not have the JSON form of
+// a message.
+var wellKnownExamples = map[protoreflect.FullName]string{
+	"google.protobuf.Timestamp":   `"1970-01-01T00:00:00Z"`,
+	"google.protobuf.Duration":    `"0s"`,
+	"google.protobuf.FieldMask":   `""`,
+	"google.protobuf.Value":       `null`,
+	"google.protobuf.ListValue":   `[]`,
+	"google.protobuf.BoolValue":   `null`,
+	"google.protobuf.BytesValue":  `null`,
+	"google.protobuf.DoubleValue": `null`,
+	"google.protobuf.FloatValue":  `null`,
+	"google.protobuf.Int32Value":  `null`,
+	"google.protobuf.Int64Value":  `null`,
+	"google.protobuf.StringValue": `null`,
+	"google.protobuf.UInt32Value": `null`,
+	"google.protobuf.UInt64Value": `null`,
+}
+
+// writeExampleMessage writes the example of message. Messages that contain themselves are written
+// as {} where they recur. Only the first field of each oneof is written, since setting more than one
+// is invalid.
+func writeExampleMessage(buff *bytes.Buffer, message *protogen.Message, seen map[*protogen.Message]bool) {
+	if example, ok := wellKnownExamples[message.Desc.FullName()]; ok {
+		buff.WriteString(example)
+		return
+	}
+
+	if seen[message] {
+		buff.WriteString("{}")
 		return
 	}
+	seen[message] = true
+	defer delete(seen, message)
+
+	buff.WriteString("{")
+	oneofs := map[*protogen.Oneof]bool{}
+	for i, field := range message.Fields {
+		if oneof := field.Oneof; oneof != nil && !oneof.Desc.IsSynthetic() {
+			if oneofs[oneof] {
+				continue
+			}
+			oneofs[oneof] = true
+		}
+
+		if i > 0 {
+			buff.WriteString(",")
+		}
+		fmt.Fprintf(buff, "%q:", field.Desc.Name())
+
+		switch {
+		case field.Desc.IsMap():
+			buff.WriteString("{}")
+		case field.Desc.IsList():
+			buff.WriteString("[")
+			writeExampleValue(buff, field, seen)
+			buff.WriteString("]")
+		default:
+			writeExampleValue(buff, field, seen)
+		}
+	}
+	buff.WriteString("}")
+}
+
+func writeExampleValue(buff *bytes.Buffer, field *protogen.Field, seen map[*protogen.Message]bool) {
+	switch field.Desc.Kind() {
+	case protoreflect.BoolKind:
+		buff.WriteString("false")
+	case protoreflect.StringKind, protoreflect.BytesKind:
+		buff.WriteString(`""`)
+	case protoreflect.EnumKind:
+		fmt.Fprintf(buff, "%q", field.Enum.Values[0].Desc.Name())
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		writeExampleMessage(buff, field.Message, seen)
+	default:
+		buff.WriteString("0")
+	}
+}
+
+// executeTemplate renders the named template for file into g.
+// It returns false, and skips g, if file has no services with methods.
+func executeTemplate(name string, g *protogen.GeneratedFile, file *protogen.File, opts generatorOptions) bool {
+	tp := newTemplatePackage(g, file, opts)
+	if len(tp.Services) == 0 {
+		g.Skip()
+		return false
+	}
+
+	renderTemplate(name, g, &tp)
+
+	return true
+}
+
+func renderTemplate(name string, g *protogen.GeneratedFile, data interface{}) {
+	content, err := templates.ReadFile(name)
+	if err != nil {
+		exitError(err)
+	}
+
+	tmpl, err := template.New(name).Parse(string(content))
+	if err != nil {
+		exitError(err)
+	}
 
 	var buff bytes.Buffer
-	if err := tmpl.Execute(&buff, &tp); err != nil {
+	if err := tmpl.Execute(&buff, data); err != nil {
 		exitError(err)
 	}
 
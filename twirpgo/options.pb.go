@@ -0,0 +1,322 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.26.0
+// 	protoc        v3.15.6
+// source: twirpgo/options.proto
+
+package twirpgo
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	descriptorpb "google.golang.org/protobuf/types/descriptorpb"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// ItemError is the error of one item of a batch method. Responses that have a
+// repeated ItemError field get AddTwirpError and TwirpErrors methods, so
+// handlers can return the items that succeeded along with the errors of the
+// others instead of failing the whole call.
+type ItemError struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// index is the position of the failed item in the request.
+	Index int32 `protobuf:"varint,1,opt,name=index,proto3" json:"index,omitempty"`
+	// code is the Twirp error code, such as "invalid_argument".
+	Code string            `protobuf:"bytes,2,opt,name=code,proto3" json:"code,omitempty"`
+	Msg  string            `protobuf:"bytes,3,opt,name=msg,proto3" json:"msg,omitempty"`
+	Meta map[string]string `protobuf:"bytes,4,rep,name=meta,proto3" json:"meta,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+}
+
+func (x *ItemError) Reset() {
+	*x = ItemError{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_twirpgo_options_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ItemError) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ItemError) ProtoMessage() {}
+
+func (x *ItemError) ProtoReflect() protoreflect.Message {
+	mi := &file_twirpgo_options_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ItemError.ProtoReflect.Descriptor instead.
+func (*ItemError) Descriptor() ([]byte, []int) {
+	return file_twirpgo_options_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *ItemError) GetIndex() int32 {
+	if x != nil {
+		return x.Index
+	}
+	return 0
+}
+
+func (x *ItemError) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *ItemError) GetMsg() string {
+	if x != nil {
+		return x.Msg
+	}
+	return ""
+}
+
+func (x *ItemError) GetMeta() map[string]string {
+	if x != nil {
+		return x.Meta
+	}
+	return nil
+}
+
+var file_twirpgo_options_proto_extTypes = []protoimpl.ExtensionInfo{
+	{
+		ExtendedType:  (*descriptorpb.EnumValueOptions)(nil),
+		ExtensionType: (*string)(nil),
+		Field:         50700,
+		Name:          "twirpgo.error_kind",
+		Tag:           "bytes,50700,opt,name=error_kind",
+		Filename:      "twirpgo/options.proto",
+	},
+	{
+		ExtendedType:  (*descriptorpb.FieldOptions)(nil),
+		ExtensionType: (*string)(nil),
+		Field:         50701,
+		Name:          "twirpgo.tags",
+		Tag:           "bytes,50701,opt,name=tags",
+		Filename:      "twirpgo/options.proto",
+	},
+	{
+		ExtendedType:  (*descriptorpb.FieldOptions)(nil),
+		ExtensionType: (*bool)(nil),
+		Field:         50705,
+		Name:          "twirpgo.pii",
+		Tag:           "varint,50705,opt,name=pii",
+		Filename:      "twirpgo/options.proto",
+	},
+	{
+		ExtendedType:  (*descriptorpb.ServiceOptions)(nil),
+		ExtensionType: ([]string)(nil),
+		Field:         50702,
+		Name:          "twirpgo.version",
+		Tag:           "bytes,50702,rep,name=version",
+		Filename:      "twirpgo/options.proto",
+	},
+	{
+		ExtendedType:  (*descriptorpb.MethodOptions)(nil),
+		ExtensionType: (*bool)(nil),
+		Field:         50703,
+		Name:          "twirpgo.cacheable",
+		Tag:           "varint,50703,opt,name=cacheable",
+		Filename:      "twirpgo/options.proto",
+	},
+	{
+		ExtendedType:  (*descriptorpb.MethodOptions)(nil),
+		ExtensionType: (*bool)(nil),
+		Field:         50704,
+		Name:          "twirpgo.auditable",
+		Tag:           "varint,50704,opt,name=auditable",
+		Filename:      "twirpgo/options.proto",
+	},
+}
+
+// Extension fields to descriptorpb.EnumValueOptions.
+var (
+	// error_kind marks an enum value as an application error. The value is the
+	// Twirp error code, such as "invalid_argument", used for errors of this kind.
+	//
+	// optional string error_kind = 50700;
+	E_ErrorKind = &file_twirpgo_options_proto_extTypes[0]
+)
+
+// Extension fields to descriptorpb.FieldOptions.
+var (
+	// tags is appended to the struct tag of the field in the wrapper structs
+	// generated with the tagged_structs option, such as 'validate:"gt=0"'.
+	//
+	// optional string tags = 50701;
+	E_Tags = &file_twirpgo_options_proto_extTypes[1]
+	// pii marks a field as personal data that TwirpRedact methods, generated
+	// with the generate_redact option, clear.
+	//
+	// optional bool pii = 50705;
+	E_Pii = &file_twirpgo_options_proto_extTypes[2]
+)
+
+// Extension fields to descriptorpb.ServiceOptions.
+var (
+	// version mounts the service under a versioned path, such as
+	// /twirp/v1/<package>.<Service>/<Method>. It may be set more than once to
+	// serve the same service under several versions.
+	//
+	// repeated string version = 50702;
+	E_Version = &file_twirpgo_options_proto_extTypes[3]
+)
+
+// Extension fields to descriptorpb.MethodOptions.
+var (
+	// cacheable lets handlers of the method set an ETag for the response with
+	// SetTwirpETag. Requests with a matching If-None-Match header then get a 304
+	// Not Modified response without a body, and clients resend the ETag of the
+	// last response to the same request.
+	//
+	// optional bool cacheable = 50703;
+	E_Cacheable = &file_twirpgo_options_proto_extTypes[4]
+	// auditable passes the request and response of every call of the method to
+	// the sink set with WithTwirpServerAuditSink.
+	//
+	// optional bool auditable = 50704;
+	E_Auditable = &file_twirpgo_options_proto_extTypes[5]
+)
+
+var File_twirpgo_options_proto protoreflect.FileDescriptor
+
+var file_twirpgo_options_proto_rawDesc = []byte{
+	0x0a, 0x15, 0x74, 0x77, 0x69, 0x72, 0x70, 0x67, 0x6f, 0x2f, 0x6f, 0x70, 0x74, 0x69, 0x6f, 0x6e,
+	0x73, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x07, 0x74, 0x77, 0x69, 0x72, 0x70, 0x67, 0x6f,
+	0x1a, 0x20, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75,
+	0x66, 0x2f, 0x64, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x6f, 0x72, 0x2e, 0x70, 0x72, 0x6f,
+	0x74, 0x6f, 0x22, 0xb2, 0x01, 0x0a, 0x09, 0x49, 0x74, 0x65, 0x6d, 0x45, 0x72, 0x72, 0x6f, 0x72,
+	0x12, 0x14, 0x0a, 0x05, 0x69, 0x6e, 0x64, 0x65, 0x78, 0x18, 0x01, 0x20, 0x01, 0x28, 0x05, 0x52,
+	0x05, 0x69, 0x6e, 0x64, 0x65, 0x78, 0x12, 0x12, 0x0a, 0x04, 0x63, 0x6f, 0x64, 0x65, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x63, 0x6f, 0x64, 0x65, 0x12, 0x10, 0x0a, 0x03, 0x6d, 0x73,
+	0x67, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6d, 0x73, 0x67, 0x12, 0x30, 0x0a, 0x04,
+	0x6d, 0x65, 0x74, 0x61, 0x18, 0x04, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1c, 0x2e, 0x74, 0x77, 0x69,
+	0x72, 0x70, 0x67, 0x6f, 0x2e, 0x49, 0x74, 0x65, 0x6d, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x2e, 0x4d,
+	0x65, 0x74, 0x61, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x04, 0x6d, 0x65, 0x74, 0x61, 0x1a, 0x37,
+	0x0a, 0x09, 0x4d, 0x65, 0x74, 0x61, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b,
+	0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a,
+	0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61,
+	0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x3a, 0x42, 0x0a, 0x0a, 0x65, 0x72, 0x72, 0x6f, 0x72,
+	0x5f, 0x6b, 0x69, 0x6e, 0x64, 0x12, 0x21, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70,
+	0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x45, 0x6e, 0x75, 0x6d, 0x56, 0x61, 0x6c, 0x75,
+	0x65, 0x4f, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0x8c, 0x8c, 0x03, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x09, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x4b, 0x69, 0x6e, 0x64, 0x3a, 0x33, 0x0a, 0x04, 0x74,
+	0x61, 0x67, 0x73, 0x12, 0x1d, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f,
+	0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x46, 0x69, 0x65, 0x6c, 0x64, 0x4f, 0x70, 0x74, 0x69, 0x6f,
+	0x6e, 0x73, 0x18, 0x8d, 0x8c, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x74, 0x61, 0x67, 0x73,
+	0x3a, 0x31, 0x0a, 0x03, 0x70, 0x69, 0x69, 0x12, 0x1d, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65,
+	0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x46, 0x69, 0x65, 0x6c, 0x64, 0x4f,
+	0x70, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0x91, 0x8c, 0x03, 0x20, 0x01, 0x28, 0x08, 0x52, 0x03,
+	0x70, 0x69, 0x69, 0x3a, 0x3b, 0x0a, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x1f,
+	0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66,
+	0x2e, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x4f, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18,
+	0x8e, 0x8c, 0x03, 0x20, 0x03, 0x28, 0x09, 0x52, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e,
+	0x3a, 0x3e, 0x0a, 0x09, 0x63, 0x61, 0x63, 0x68, 0x65, 0x61, 0x62, 0x6c, 0x65, 0x12, 0x1e, 0x2e,
+	0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e,
+	0x4d, 0x65, 0x74, 0x68, 0x6f, 0x64, 0x4f, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0x8f, 0x8c,
+	0x03, 0x20, 0x01, 0x28, 0x08, 0x52, 0x09, 0x63, 0x61, 0x63, 0x68, 0x65, 0x61, 0x62, 0x6c, 0x65,
+	0x3a, 0x3e, 0x0a, 0x09, 0x61, 0x75, 0x64, 0x69, 0x74, 0x61, 0x62, 0x6c, 0x65, 0x12, 0x1e, 0x2e,
+	0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e,
+	0x4d, 0x65, 0x74, 0x68, 0x6f, 0x64, 0x4f, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0x90, 0x8c,
+	0x03, 0x20, 0x01, 0x28, 0x08, 0x52, 0x09, 0x61, 0x75, 0x64, 0x69, 0x74, 0x61, 0x62, 0x6c, 0x65,
+	0x42, 0x2f, 0x5a, 0x2d, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x62,
+	0x61, 0x6b, 0x69, 0x6e, 0x73, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x2d, 0x67, 0x65, 0x6e,
+	0x2d, 0x74, 0x77, 0x69, 0x72, 0x70, 0x2d, 0x67, 0x6f, 0x2f, 0x74, 0x77, 0x69, 0x72, 0x70, 0x67,
+	0x6f, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_twirpgo_options_proto_rawDescOnce sync.Once
+	file_twirpgo_options_proto_rawDescData = file_twirpgo_options_proto_rawDesc
+)
+
+func file_twirpgo_options_proto_rawDescGZIP() []byte {
+	file_twirpgo_options_proto_rawDescOnce.Do(func() {
+		file_twirpgo_options_proto_rawDescData = protoimpl.X.CompressGZIP(file_twirpgo_options_proto_rawDescData)
+	})
+	return file_twirpgo_options_proto_rawDescData
+}
+
+var file_twirpgo_options_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_twirpgo_options_proto_goTypes = []interface{}{
+	(*ItemError)(nil),                     // 0: twirpgo.ItemError
+	nil,                                   // 1: twirpgo.ItemError.MetaEntry
+	(*descriptorpb.EnumValueOptions)(nil), // 2: google.protobuf.EnumValueOptions
+	(*descriptorpb.FieldOptions)(nil),     // 3: google.protobuf.FieldOptions
+	(*descriptorpb.ServiceOptions)(nil),   // 4: google.protobuf.ServiceOptions
+	(*descriptorpb.MethodOptions)(nil),    // 5: google.protobuf.MethodOptions
+}
+var file_twirpgo_options_proto_depIdxs = []int32{
+	1, // 0: twirpgo.ItemError.meta:type_name -> twirpgo.ItemError.MetaEntry
+	2, // 1: twirpgo.error_kind:extendee -> google.protobuf.EnumValueOptions
+	3, // 2: twirpgo.tags:extendee -> google.protobuf.FieldOptions
+	3, // 3: twirpgo.pii:extendee -> google.protobuf.FieldOptions
+	4, // 4: twirpgo.version:extendee -> google.protobuf.ServiceOptions
+	5, // 5: twirpgo.cacheable:extendee -> google.protobuf.MethodOptions
+	5, // 6: twirpgo.auditable:extendee -> google.protobuf.MethodOptions
+	7, // [7:7] is the sub-list for method output_type
+	7, // [7:7] is the sub-list for method input_type
+	7, // [7:7] is the sub-list for extension type_name
+	1, // [1:7] is the sub-list for extension extendee
+	0, // [0:1] is the sub-list for field type_name
+}
+
+func init() { file_twirpgo_options_proto_init() }
+func file_twirpgo_options_proto_init() {
+	if File_twirpgo_options_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_twirpgo_options_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ItemError); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_twirpgo_options_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   2,
+			NumExtensions: 6,
+			NumServices:   0,
+		},
+		GoTypes:           file_twirpgo_options_proto_goTypes,
+		DependencyIndexes: file_twirpgo_options_proto_depIdxs,
+		MessageInfos:      file_twirpgo_options_proto_msgTypes,
+		ExtensionInfos:    file_twirpgo_options_proto_extTypes,
+	}.Build()
+	File_twirpgo_options_proto = out.File
+	file_twirpgo_options_proto_rawDesc = nil
+	file_twirpgo_options_proto_goTypes = nil
+	file_twirpgo_options_proto_depIdxs = nil
+}
@@ -0,0 +1,643 @@
+// Package twirpmsgpack encodes protobuf messages as MessagePack, for clients that prefer msgpack
+// to JSON or protobuf. Marshaler implements the TwirpMarshaler interface of generated code, so a
+// codec is created with NewTwirpCodec:
+//
+//	msgpack := NewTwirpCodec(twirpmsgpack.ContentType, twirpmsgpack.Marshaler{})
+//	server := NewHaberdasherTwirpServer(impl, WithTwirpServerCodec(msgpack))
+//
+// A message is encoded as a map from field name, as written in the .proto file, to value. Only
+// populated fields are encoded, in the order they are declared. Values are mapped as follows:
+//
+//   - bool, string and bytes fields are msgpack booleans, strings and binary
+//   - integer fields are msgpack integers, including 64-bit integers, which JSON sends as strings
+//   - float and double fields are msgpack float 32 and float 64
+//   - enum fields are the integer value of the enum
+//   - message fields are maps, and well-known types, such as google.protobuf.Timestamp, are encoded
+//     as any other message, for example {"seconds": 1700000000, "nanos": 0}, not as in JSON
+//   - repeated fields are arrays, and map fields are maps with keys of the key type
+//
+// When decoding, fields may also be named with their JSON name, such as "pageSize", enums may be
+// named, nil is the same as a missing field, and integers are accepted for float fields. Keys that
+// are not fields of the message are rejected, as are msgpack extension types. Unknown fields and
+// extensions of messages are not encoded, so they are lost when a message passes through msgpack.
+package twirpmsgpack
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// ContentType is the content type of msgpack requests and responses.
+const ContentType = "application/msgpack"
+
+// maxDepth limits how deeply values may be nested, so that a hostile body cannot exhaust the stack.
+const maxDepth = 100
+
+// Marshaler encodes messages as msgpack.
+type Marshaler struct{}
+
+// Marshal returns the msgpack encoding of m.
+func (Marshaler) Marshal(m proto.Message) ([]byte, error) {
+	var e encoder
+	if err := e.message(m.ProtoReflect()); err != nil {
+		return nil, err
+	}
+	return e.buf, nil
+}
+
+// Unmarshal decodes the msgpack encoding of a message in b into m, which is reset first.
+func (Marshaler) Unmarshal(b []byte, m proto.Message) error {
+	proto.Reset(m)
+
+	d := decoder{buf: b}
+	v, err := d.value(0)
+	if err != nil {
+		return err
+	}
+	if d.pos != len(d.buf) {
+		return fmt.Errorf("msgpack: %d bytes after the message", len(d.buf)-d.pos)
+	}
+
+	return setMessage(m.ProtoReflect(), v)
+}
+
+// encoder appends the msgpack encoding of values to buf.
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) message(m protoreflect.Message) error {
+	fields := m.Descriptor().Fields()
+
+	n := 0
+	for i := 0; i < fields.Len(); i++ {
+		if m.Has(fields.Get(i)) {
+			n++
+		}
+	}
+	e.header(0x80, 0xde, 0xdf, n)
+
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		if !m.Has(fd) {
+			continue
+		}
+
+		e.string(string(fd.Name()))
+
+		var err error
+		switch {
+		case fd.IsList():
+			err = e.list(fd, m.Get(fd).List())
+		case fd.IsMap():
+			err = e.mapField(fd, m.Get(fd).Map())
+		default:
+			err = e.value(fd, m.Get(fd))
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (e *encoder) list(fd protoreflect.FieldDescriptor, list protoreflect.List) error {
+	e.header(0x90, 0xdc, 0xdd, list.Len())
+	for i := 0; i < list.Len(); i++ {
+		if err := e.value(fd, list.Get(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *encoder) mapField(fd protoreflect.FieldDescriptor, m protoreflect.Map) error {
+	keys := make([]protoreflect.MapKey, 0, m.Len())
+	m.Range(func(k protoreflect.MapKey, _ protoreflect.Value) bool {
+		keys = append(keys, k)
+		return true
+	})
+
+	// entries are sorted by key, so that a message always has the same encoding
+	sort.Slice(keys, func(i, j int) bool {
+		switch a := keys[i].Interface().(type) {
+		case string:
+			return a < keys[j].String()
+		case bool:
+			return !a && keys[j].Bool()
+		case int32, int64:
+			return keys[i].Int() < keys[j].Int()
+		default:
+			return keys[i].Uint() < keys[j].Uint()
+		}
+	})
+
+	e.header(0x80, 0xde, 0xdf, len(keys))
+	for _, k := range keys {
+		if err := e.value(fd.MapKey(), k.Value()); err != nil {
+			return err
+		}
+		if err := e.value(fd.MapValue(), m.Get(k)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// value encodes a single value of fd, which is an element for repeated fields.
+func (e *encoder) value(fd protoreflect.FieldDescriptor, v protoreflect.Value) error {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		if v.Bool() {
+			e.buf = append(e.buf, 0xc3)
+		} else {
+			e.buf = append(e.buf, 0xc2)
+		}
+	case protoreflect.EnumKind:
+		e.int(int64(v.Enum()))
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+		protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		e.int(v.Int())
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind, protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		e.uint(v.Uint())
+	case protoreflect.FloatKind:
+		e.buf = append(e.buf, 0xca)
+		e.buf = appendUint32(e.buf, math.Float32bits(float32(v.Float())))
+	case protoreflect.DoubleKind:
+		e.buf = append(e.buf, 0xcb)
+		e.buf = appendUint64(e.buf, math.Float64bits(v.Float()))
+	case protoreflect.StringKind:
+		e.string(v.String())
+	case protoreflect.BytesKind:
+		e.bytes(v.Bytes())
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return e.message(v.Message())
+	default:
+		return fmt.Errorf("msgpack: unsupported kind %s of field %s", fd.Kind(), fd.FullName())
+	}
+	return nil
+}
+
+func (e *encoder) int(v int64) {
+	switch {
+	case v >= 0:
+		e.uint(uint64(v))
+	case v >= -32:
+		e.buf = append(e.buf, byte(v))
+	case v >= math.MinInt8:
+		e.buf = append(e.buf, 0xd0, byte(v))
+	case v >= math.MinInt16:
+		e.buf = append(e.buf, 0xd1)
+		e.buf = appendUint16(e.buf, uint16(v))
+	case v >= math.MinInt32:
+		e.buf = append(e.buf, 0xd2)
+		e.buf = appendUint32(e.buf, uint32(v))
+	default:
+		e.buf = append(e.buf, 0xd3)
+		e.buf = appendUint64(e.buf, uint64(v))
+	}
+}
+
+func (e *encoder) uint(v uint64) {
+	switch {
+	case v <= 0x7f:
+		e.buf = append(e.buf, byte(v))
+	case v <= math.MaxUint8:
+		e.buf = append(e.buf, 0xcc, byte(v))
+	case v <= math.MaxUint16:
+		e.buf = append(e.buf, 0xcd)
+		e.buf = appendUint16(e.buf, uint16(v))
+	case v <= math.MaxUint32:
+		e.buf = append(e.buf, 0xce)
+		e.buf = appendUint32(e.buf, uint32(v))
+	default:
+		e.buf = append(e.buf, 0xcf)
+		e.buf = appendUint64(e.buf, v)
+	}
+}
+
+func (e *encoder) string(s string) {
+	if len(s) < 32 {
+		e.buf = append(e.buf, 0xa0|byte(len(s)))
+	} else {
+		e.length(0xd9, 0xda, 0xdb, len(s))
+	}
+	e.buf = append(e.buf, s...)
+}
+
+func (e *encoder) bytes(b []byte) {
+	e.length(0xc4, 0xc5, 0xc6, len(b))
+	e.buf = append(e.buf, b...)
+}
+
+// header appends the header of an array or map of n elements, with fix as its fixed form.
+func (e *encoder) header(fix byte, code16 byte, code32 byte, n int) {
+	if n < 16 {
+		e.buf = append(e.buf, fix|byte(n))
+		return
+	}
+	if n <= math.MaxUint16 {
+		e.buf = append(e.buf, code16)
+		e.buf = appendUint16(e.buf, uint16(n))
+		return
+	}
+	e.buf = append(e.buf, code32)
+	e.buf = appendUint32(e.buf, uint32(n))
+}
+
+// length appends the header of a string or binary of n bytes.
+func (e *encoder) length(code8 byte, code16 byte, code32 byte, n int) {
+	switch {
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, code8, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, code16)
+		e.buf = appendUint16(e.buf, uint16(n))
+	default:
+		e.buf = append(e.buf, code32)
+		e.buf = appendUint32(e.buf, uint32(n))
+	}
+}
+
+func appendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v>>8), byte(v))
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	return append(b, byte(v>>56), byte(v>>48), byte(v>>40), byte(v>>32), byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+// mapValue is a decoded msgpack map, with its entries in order.
+type mapValue []mapEntry
+
+type mapEntry struct {
+	key   interface{}
+	value interface{}
+}
+
+// binValue is a decoded msgpack binary, to tell it from a string.
+type binValue []byte
+
+var errShort = errors.New("msgpack: unexpected end of data")
+
+// decoder decodes msgpack values into nil, bool, int64, uint64, float64, string, binValue,
+// []interface{} and mapValue.
+type decoder struct {
+	buf []byte
+	pos int
+}
+
+func (d *decoder) value(depth int) (interface{}, error) {
+	if depth > maxDepth {
+		return nil, errors.New("msgpack: values are nested too deeply")
+	}
+
+	c, err := d.byte()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case c <= 0x7f:
+		return int64(c), nil
+	case c >= 0xe0:
+		return int64(int8(c)), nil
+	case c&0xf0 == 0x80:
+		return d.mapValue(uint64(c&0x0f), depth)
+	case c&0xf0 == 0x90:
+		return d.array(uint64(c&0x0f), depth)
+	case c&0xe0 == 0xa0:
+		return d.string(uint64(c & 0x1f))
+	}
+
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		n, err := d.uint(1 << (c - 0xc4))
+		if err != nil {
+			return nil, err
+		}
+		b, err := d.next(n)
+		return binValue(b), err
+	case 0xca:
+		v, err := d.uint(4)
+		return float64(math.Float32frombits(uint32(v))), err
+	case 0xcb:
+		v, err := d.uint(8)
+		return math.Float64frombits(v), err
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		v, err := d.uint(1 << (c - 0xcc))
+		if v <= math.MaxInt64 {
+			return int64(v), err
+		}
+		return v, err
+	case 0xd0:
+		v, err := d.uint(1)
+		return int64(int8(v)), err
+	case 0xd1:
+		v, err := d.uint(2)
+		return int64(int16(v)), err
+	case 0xd2:
+		v, err := d.uint(4)
+		return int64(int32(v)), err
+	case 0xd3:
+		v, err := d.uint(8)
+		return int64(v), err
+	case 0xd9, 0xda, 0xdb:
+		n, err := d.uint(1 << (c - 0xd9))
+		if err != nil {
+			return nil, err
+		}
+		return d.string(n)
+	case 0xdc, 0xdd:
+		n, err := d.uint(2 << (c - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return d.array(n, depth)
+	case 0xde, 0xdf:
+		n, err := d.uint(2 << (c - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return d.mapValue(n, depth)
+	}
+
+	return nil, fmt.Errorf("msgpack: unsupported type 0x%02x", c)
+}
+
+func (d *decoder) array(n uint64, depth int) (interface{}, error) {
+	// every element is at least one byte, so a length past the end of the data is invalid
+	if n > uint64(len(d.buf)-d.pos) {
+		return nil, errShort
+	}
+
+	values := make([]interface{}, n)
+	for i := range values {
+		v, err := d.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+func (d *decoder) mapValue(n uint64, depth int) (interface{}, error) {
+	if 2*n > uint64(len(d.buf)-d.pos) {
+		return nil, errShort
+	}
+
+	entries := make(mapValue, n)
+	for i := range entries {
+		k, err := d.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		v, err := d.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		entries[i] = mapEntry{key: k, value: v}
+	}
+	return entries, nil
+}
+
+func (d *decoder) string(n uint64) (interface{}, error) {
+	b, err := d.next(n)
+	return string(b), err
+}
+
+func (d *decoder) byte() (byte, error) {
+	if d.pos >= len(d.buf) {
+		return 0, errShort
+	}
+	d.pos++
+	return d.buf[d.pos-1], nil
+}
+
+// uint decodes an unsigned big-endian integer of size bytes.
+func (d *decoder) uint(size uint64) (uint64, error) {
+	b, err := d.next(size)
+	if err != nil {
+		return 0, err
+	}
+
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v, nil
+}
+
+// next returns the next n bytes.
+func (d *decoder) next(n uint64) ([]byte, error) {
+	if n > uint64(len(d.buf)-d.pos) {
+		return nil, errShort
+	}
+	b := d.buf[d.pos : d.pos+int(n)]
+	d.pos += int(n)
+	return b, nil
+}
+
+// setMessage sets the fields of m from v, which must be a map.
+func setMessage(m protoreflect.Message, v interface{}) error {
+	entries, ok := v.(mapValue)
+	if !ok {
+		return fmt.Errorf("msgpack: %s must be a map, not %T", m.Descriptor().FullName(), v)
+	}
+
+	fields := m.Descriptor().Fields()
+	for _, entry := range entries {
+		name, ok := entry.key.(string)
+		if !ok {
+			return fmt.Errorf("msgpack: field names of %s must be strings, not %T", m.Descriptor().FullName(), entry.key)
+		}
+
+		fd := fields.ByName(protoreflect.Name(name))
+		if fd == nil {
+			fd = fields.ByJSONName(name)
+		}
+		if fd == nil {
+			return fmt.Errorf("msgpack: unknown field %q of %s", name, m.Descriptor().FullName())
+		}
+
+		if entry.value == nil {
+			continue
+		}
+
+		if oneof := fd.ContainingOneof(); oneof != nil && !oneof.IsSynthetic() {
+			if set := m.WhichOneof(oneof); set != nil {
+				return fmt.Errorf("msgpack: fields %s and %s of oneof %s are both set", set.Name(), fd.Name(), oneof.FullName())
+			}
+		}
+
+		var err error
+		switch {
+		case fd.IsList():
+			err = setList(m.Mutable(fd).List(), fd, entry.value)
+		case fd.IsMap():
+			err = setMap(m.Mutable(fd).Map(), fd, entry.value)
+		case fd.Message() != nil:
+			err = setMessage(m.Mutable(fd).Message(), entry.value)
+		default:
+			var value protoreflect.Value
+			value, err = scalar(fd, entry.value)
+			if err == nil {
+				m.Set(fd, value)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func setList(list protoreflect.List, fd protoreflect.FieldDescriptor, v interface{}) error {
+	values, ok := v.([]interface{})
+	if !ok {
+		return fmt.Errorf("msgpack: field %s must be an array, not %T", fd.FullName(), v)
+	}
+
+	for _, value := range values {
+		if fd.Message() != nil {
+			element := list.NewElement()
+			if err := setMessage(element.Message(), value); err != nil {
+				return err
+			}
+			list.Append(element)
+			continue
+		}
+
+		element, err := scalar(fd, value)
+		if err != nil {
+			return err
+		}
+		list.Append(element)
+	}
+	return nil
+}
+
+func setMap(m protoreflect.Map, fd protoreflect.FieldDescriptor, v interface{}) error {
+	entries, ok := v.(mapValue)
+	if !ok {
+		return fmt.Errorf("msgpack: field %s must be a map, not %T", fd.FullName(), v)
+	}
+
+	for _, entry := range entries {
+		key, err := scalar(fd.MapKey(), entry.key)
+		if err != nil {
+			return err
+		}
+
+		if fd.MapValue().Message() != nil {
+			value := m.NewValue()
+			if entry.value != nil {
+				if err := setMessage(value.Message(), entry.value); err != nil {
+					return err
+				}
+			}
+			m.Set(key.MapKey(), value)
+			continue
+		}
+
+		value, err := scalar(fd.MapValue(), entry.value)
+		if err != nil {
+			return err
+		}
+		m.Set(key.MapKey(), value)
+	}
+	return nil
+}
+
+// scalar converts v to a value of fd, which is not a message.
+func scalar(fd protoreflect.FieldDescriptor, v interface{}) (protoreflect.Value, error) {
+	invalid := func() (protoreflect.Value, error) {
+		return protoreflect.Value{}, fmt.Errorf("msgpack: invalid value %v (%T) for %s field %s", v, v, fd.Kind(), fd.FullName())
+	}
+
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		if b, ok := v.(bool); ok {
+			return protoreflect.ValueOfBool(b), nil
+		}
+	case protoreflect.StringKind:
+		if s, ok := v.(string); ok {
+			return protoreflect.ValueOfString(s), nil
+		}
+	case protoreflect.BytesKind:
+		switch b := v.(type) {
+		case binValue:
+			return protoreflect.ValueOfBytes(append([]byte(nil), b...)), nil
+		case string:
+			// older msgpack encoders have no binary type, and send bytes as strings
+			return protoreflect.ValueOfBytes([]byte(b)), nil
+		}
+	case protoreflect.EnumKind:
+		if name, ok := v.(string); ok {
+			if value := fd.Enum().Values().ByName(protoreflect.Name(name)); value != nil {
+				return protoreflect.ValueOfEnum(value.Number()), nil
+			}
+			return invalid()
+		}
+		if i, ok := v.(int64); ok && i >= math.MinInt32 && i <= math.MaxInt32 {
+			return protoreflect.ValueOfEnum(protoreflect.EnumNumber(i)), nil
+		}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		if i, ok := v.(int64); ok && i >= math.MinInt32 && i <= math.MaxInt32 {
+			return protoreflect.ValueOfInt32(int32(i)), nil
+		}
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		if i, ok := v.(int64); ok {
+			return protoreflect.ValueOfInt64(i), nil
+		}
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		if i, ok := v.(int64); ok && i >= 0 && i <= math.MaxUint32 {
+			return protoreflect.ValueOfUint32(uint32(i)), nil
+		}
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		switch i := v.(type) {
+		case int64:
+			if i >= 0 {
+				return protoreflect.ValueOfUint64(uint64(i)), nil
+			}
+		case uint64:
+			return protoreflect.ValueOfUint64(i), nil
+		}
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		var f float64
+		switch n := v.(type) {
+		case float64:
+			f = n
+		case int64:
+			f = float64(n)
+		case uint64:
+			f = float64(n)
+		default:
+			return invalid()
+		}
+		if fd.Kind() == protoreflect.FloatKind {
+			return protoreflect.ValueOfFloat32(float32(f)), nil
+		}
+		return protoreflect.ValueOfFloat64(f), nil
+	}
+
+	return invalid()
+}
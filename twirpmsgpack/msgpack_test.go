@@ -0,0 +1,118 @@
+package twirpmsgpack_test
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/bakins/protoc-gen-twirp-go/example"
+	"github.com/bakins/protoc-gen-twirp-go/twirpmsgpack"
+)
+
+func TestMarshal(t *testing.T) {
+	b, err := twirpmsgpack.Marshaler{}.Marshal(&example.Size{Inches: 14})
+	require.NoError(t, err)
+	require.Equal(t, append([]byte{0x81, 0xa6}, append([]byte("inches"), 0x0e)...), b)
+
+	// unpopulated fields are not encoded
+	b, err = twirpmsgpack.Marshaler{}.Marshal(&example.Size{})
+	require.NoError(t, err)
+	require.Equal(t, []byte{0x80}, b)
+}
+
+func TestRoundTrip(t *testing.T) {
+	st, err := structpb.NewStruct(map[string]interface{}{
+		"name":  "bowler",
+		"sizes": []interface{}{7.5, -1, nil},
+		"made":  true,
+		"inner": map[string]interface{}{"color": "red"},
+	})
+	require.NoError(t, err)
+
+	for _, msg := range []proto.Message{
+		&example.ListHatsResponse{
+			Hats: []*example.Hat{
+				{Size: 14, Color: "red", Name: "derby", DeliverBy: timestamppb.New(time.Unix(1700000000, 5))},
+				{Size: 200, Color: string(make([]byte, 300))},
+			},
+			NextPageToken: "2",
+		},
+		st,
+		&descriptorpb.FieldDescriptorProto{
+			Name:   proto.String("hat"),
+			Number: proto.Int32(-70000),
+			Label:  descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum(),
+		},
+		&descriptorpb.FileOptions{OptimizeFor: descriptorpb.FileOptions_SPEED.Enum()},
+		&descriptorpb.UninterpretedOption{
+			PositiveIntValue: proto.Uint64(math.MaxUint64),
+			NegativeIntValue: proto.Int64(math.MinInt64),
+			DoubleValue:      proto.Float64(math.Pi),
+			StringValue:      []byte{0, 1, 2, 255},
+		},
+	} {
+		b, err := twirpmsgpack.Marshaler{}.Marshal(msg)
+		require.NoError(t, err)
+
+		got := msg.ProtoReflect().New().Interface()
+		require.NoError(t, twirpmsgpack.Marshaler{}.Unmarshal(b, got))
+		require.True(t, proto.Equal(msg, got))
+	}
+}
+
+func TestUnmarshal(t *testing.T) {
+	// {"pageSize": 5, "page_token": nil}, with the JSON name and a nil field
+	b := []byte{0x82, 0xa8}
+	b = append(b, "pageSize"...)
+	b = append(b, 0x05, 0xaa)
+	b = append(b, "page_token"...)
+	b = append(b, 0xc0)
+
+	var req example.ListHatsRequest
+	require.NoError(t, twirpmsgpack.Marshaler{}.Unmarshal(b, &req))
+	require.True(t, proto.Equal(&example.ListHatsRequest{PageSize: 5}, &req))
+
+	for name, b := range map[string][]byte{
+		"truncated":      b[:len(b)-1],
+		"trailing bytes": append(append([]byte(nil), b...), 0xc0),
+		"unknown field":  append([]byte{0x81, 0xa3}, "hat"...),
+		"wrong type":     append(append([]byte{0x81, 0xa8}, "pageSize"...), 0xc3),
+		"out of range":   append(append([]byte{0x81, 0xa8}, "pageSize"...), 0xcf, 0xff, 0, 0, 0, 0, 0, 0, 0),
+		"not a map":      {0x90},
+		"extension type": {0xd4, 0x01, 0x00},
+		"huge array":     {0xdd, 0xff, 0xff, 0xff, 0xff},
+	} {
+		t.Run(name, func(t *testing.T) {
+			require.Error(t, twirpmsgpack.Marshaler{}.Unmarshal(b, &req))
+		})
+	}
+}
+
+func TestCodec(t *testing.T) {
+	msgpack := example.NewTwirpCodec(twirpmsgpack.ContentType, twirpmsgpack.Marshaler{})
+
+	svr := httptest.NewServer(example.NewHaberdasherTwirpServer(&haberdasher{}, example.WithTwirpServerCodec(msgpack)))
+	defer svr.Close()
+
+	c, err := example.NewHaberdasherTwirpClient(svr.URL, http.DefaultTransport, example.WithTwirpClientCodec(msgpack))
+	require.NoError(t, err)
+
+	hat, err := c.MakeHat(context.Background(), &example.Size{Inches: 14})
+	require.NoError(t, err)
+	require.True(t, proto.Equal(&example.Hat{Size: 14, Color: "red"}, hat))
+}
+
+type haberdasher struct{}
+
+func (h *haberdasher) MakeHat(ctx context.Context, size *example.Size) (*example.Hat, error) {
+	return &example.Hat{Size: size.Inches, Color: "red"}, nil
+}
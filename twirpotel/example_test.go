@@ -0,0 +1,30 @@
+package twirpotel_test
+
+import (
+	"log"
+
+	"go.opentelemetry.io/otel"
+
+	"github.com/bakins/protoc-gen-twirp-go/twirpotel"
+)
+
+func Example() {
+	// the global meter provider, set up by the program with an exporter
+	meter := otel.Meter("haberdasher")
+
+	serverObserver, err := twirpotel.NewServerObserver(meter)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	clientObserver, err := twirpotel.NewClientObserver(meter)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// with the generated code of the example package:
+	//
+	//	server := example.NewHaberdasherTwirpServer(impl, example.WithTwirpServerObserver(serverObserver))
+	//	client, err := example.NewHaberdasherTwirpClient(address, http.DefaultTransport, example.WithTwirpClientObserver(clientObserver))
+	_, _ = serverObserver, clientObserver
+}
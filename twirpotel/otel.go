@@ -0,0 +1,97 @@
+// Package twirpotel records OpenTelemetry metrics for clients and servers generated by
+// protoc-gen-twirp-go. Observer implements the TwirpObserver interface of generated code, so the
+// generated packages do not depend on OpenTelemetry themselves:
+//
+//	observer, err := twirpotel.NewServerObserver(otel.Meter("haberdasher"))
+//	server := NewHaberdasherTwirpServer(impl, WithTwirpServerObserver(observer))
+//
+// It is a separate module, so only programs that import it depend on go.opentelemetry.io/otel.
+package twirpotel
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/twitchtv/twirp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+type startKey struct{}
+
+// Observer records a call counter and a call duration histogram with OpenTelemetry metrics. Use
+// NewClientObserver with WithTwirpClientObserver, and NewServerObserver with WithTwirpServerObserver.
+type Observer struct {
+	calls    metric.Int64Counter
+	duration metric.Float64Histogram
+}
+
+// NewClientObserver creates the "twirp.client.calls" counter and the "twirp.client.duration"
+// histogram, in seconds, with meter, for calls made by clients.
+func NewClientObserver(meter metric.Meter) (*Observer, error) {
+	return newObserver(meter, "client")
+}
+
+// NewServerObserver creates the "twirp.server.calls" counter and the "twirp.server.duration"
+// histogram, in seconds, with meter, for requests handled by servers.
+func NewServerObserver(meter metric.Meter) (*Observer, error) {
+	return newObserver(meter, "server")
+}
+
+func newObserver(meter metric.Meter, kind string) (*Observer, error) {
+	calls, err := meter.Int64Counter(
+		"twirp."+kind+".calls",
+		metric.WithDescription("Number of Twirp calls."),
+		metric.WithUnit("{call}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	duration, err := meter.Float64Histogram(
+		"twirp."+kind+".duration",
+		metric.WithDescription("Duration of Twirp calls."),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Observer{calls: calls, duration: duration}, nil
+}
+
+// StartRPC records when the call started.
+func (o *Observer) StartRPC(ctx context.Context, method string) context.Context {
+	return context.WithValue(ctx, startKey{}, time.Now())
+}
+
+// EndRPC records the call, with the attributes "rpc.system" of "twirp", "rpc.service", such as
+// "twitch.twirp.example.Haberdasher", "rpc.method", such as "MakeHat", and "twirp.code", the
+// Twirp error code, or "ok" if the call succeeded.
+func (o *Observer) EndRPC(ctx context.Context, method string, err twirp.Error) {
+	start, ok := ctx.Value(startKey{}).(time.Time)
+	if !ok {
+		return
+	}
+
+	code := "ok"
+	if err != nil {
+		code = string(err.Code())
+	}
+
+	service := method
+	if i := strings.LastIndexByte(method, '/'); i >= 0 {
+		service, method = method[:i], method[i+1:]
+	}
+
+	attributes := metric.WithAttributes(
+		attribute.String("rpc.system", "twirp"),
+		attribute.String("rpc.service", service),
+		attribute.String("rpc.method", method),
+		attribute.String("twirp.code", code),
+	)
+
+	o.calls.Add(ctx, 1, attributes)
+	o.duration.Record(ctx, time.Since(start).Seconds(), attributes)
+}
@@ -0,0 +1,116 @@
+package twirpotel_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/twitchtv/twirp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+
+	"github.com/bakins/protoc-gen-twirp-go/twirpotel"
+)
+
+// measurement is a value recorded by an instrument of a recordingMeter.
+type measurement struct {
+	instrument string
+	value      float64
+	attributes attribute.Set
+}
+
+// recordingMeter records the measurements of its counters and histograms.
+type recordingMeter struct {
+	noop.Meter
+
+	mu           sync.Mutex
+	measurements []measurement
+}
+
+func (m *recordingMeter) record(instrument string, value float64, attributes attribute.Set) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.measurements = append(m.measurements, measurement{instrument: instrument, value: value, attributes: attributes})
+}
+
+func (m *recordingMeter) Int64Counter(name string, _ ...metric.Int64CounterOption) (metric.Int64Counter, error) {
+	return &recordingCounter{meter: m, name: name}, nil
+}
+
+func (m *recordingMeter) Float64Histogram(name string, _ ...metric.Float64HistogramOption) (metric.Float64Histogram, error) {
+	return &recordingHistogram{meter: m, name: name}, nil
+}
+
+type recordingCounter struct {
+	noop.Int64Counter
+	meter *recordingMeter
+	name  string
+}
+
+func (c *recordingCounter) Add(_ context.Context, incr int64, options ...metric.AddOption) {
+	c.meter.record(c.name, float64(incr), metric.NewAddConfig(options).Attributes())
+}
+
+type recordingHistogram struct {
+	noop.Float64Histogram
+	meter *recordingMeter
+	name  string
+}
+
+func (h *recordingHistogram) Record(_ context.Context, incr float64, options ...metric.RecordOption) {
+	h.meter.record(h.name, incr, metric.NewRecordConfig(options).Attributes())
+}
+
+// observer is the TwirpObserver interface of generated code.
+type observer interface {
+	StartRPC(ctx context.Context, method string) context.Context
+	EndRPC(ctx context.Context, method string, err twirp.Error)
+}
+
+var _ observer = (*twirpotel.Observer)(nil)
+
+// call notifies o of a call the way generated clients and servers do.
+func call(o observer, method string, err twirp.Error) {
+	ctx := o.StartRPC(context.Background(), method)
+	o.EndRPC(ctx, method, err)
+}
+
+func TestObserver(t *testing.T) {
+	serverMeter := &recordingMeter{}
+	serverObserver, err := twirpotel.NewServerObserver(serverMeter)
+	require.NoError(t, err)
+
+	clientMeter := &recordingMeter{}
+	clientObserver, err := twirpotel.NewClientObserver(clientMeter)
+	require.NoError(t, err)
+
+	for _, o := range []observer{serverObserver, clientObserver} {
+		call(o, "twitch.twirp.example.Haberdasher/MakeHat", nil)
+		call(o, "twitch.twirp.example.Haberdasher/MakeHat", twirp.InvalidArgumentError("inches", "must be positive"))
+	}
+	for kind, meter := range map[string]*recordingMeter{"server": serverMeter, "client": clientMeter} {
+		require.Len(t, meter.measurements, 4, kind)
+
+		for i, code := range []string{"ok", "invalid_argument"} {
+			calls, duration := meter.measurements[2*i], meter.measurements[2*i+1]
+
+			require.Equal(t, "twirp."+kind+".calls", calls.instrument)
+			require.Equal(t, float64(1), calls.value)
+			require.Equal(t, "twirp."+kind+".duration", duration.instrument)
+			require.GreaterOrEqual(t, duration.value, float64(0))
+			require.True(t, calls.attributes.Equals(&duration.attributes))
+
+			want := attribute.NewSet(
+				attribute.String("rpc.system", "twirp"),
+				attribute.String("rpc.service", "twitch.twirp.example.Haberdasher"),
+				attribute.String("rpc.method", "MakeHat"),
+				attribute.String("twirp.code", code),
+			)
+			require.True(t, want.Equals(&calls.attributes), fmt.Sprintf("%s: %v", kind, calls.attributes.Encoded(attribute.DefaultEncoder())))
+		}
+	}
+}